@@ -1,7 +1,6 @@
 package app
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -18,11 +17,15 @@ import (
 	"github.com/maximfedotov74/diploma-backend/internal/config"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/handler"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/repository"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/scheduler"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/service"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/cache"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/feed"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/file"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/imageproc"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/jwt"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/mail"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/payment"
@@ -32,6 +35,12 @@ import (
 func Start() {
 	configuration := config.MustLoadConfig()
 
+	// a build without the vips tag silently writes JPEG, so the format has to be asked for explicitly
+	if configuration.ImageFormat != imageproc.Ext {
+		log.Fatalf("Images are rendered to %s but IMAGE_FORMAT is %s, build with -tags vips or set IMAGE_FORMAT=%s",
+			imageproc.Ext, configuration.ImageFormat, imageproc.Ext)
+	}
+
 	fiberApp := fiber.New(fiber.Config{BodyLimit: 10 * 10 * 1024 * 1024})
 
 	fiberApp.Use((logger.New(logger.Config{
@@ -48,9 +57,38 @@ func Start() {
 
 	postgresClient := db.NewPostgresConnection(configuration.DatabaseUrl)
 
-	fileContext := context.Background()
+	storageConfig := file.Config{
+		Backend:       configuration.StorageBackend,
+		Bucket:        "images",
+		MinioUrl:      configuration.MinioApiUrl,
+		MinioUser:     configuration.MinioUser,
+		MinioPassword: configuration.MinioPassword,
+		LocalPath:     configuration.StorageLocalPath,
+		LocalSecret:   configuration.StorageSecret,
+	}
+
+	store, err := file.NewStore(storageConfig)
+	if err != nil {
+		log.Fatalf("Failed to create file storage, cause: %s", err.Error())
+	}
+
+	// direct uploads wait for their checks in a bucket that is never served publicly
+	storageConfig.Bucket = "uploads"
+	storageConfig.Private = true
+
+	pendingStore, err := file.NewStore(storageConfig)
+	if err != nil {
+		log.Fatalf("Failed to create file storage, cause: %s", err.Error())
+	}
+
+	for _, s := range []file.BlobStore{store, pendingStore} {
+		if local, ok := s.(*file.LocalStore); ok {
+			local.Routes(fiberApp)
+		}
+	}
 
-	fileClient := file.New(configuration.MinioApiUrl, configuration.MinioUser, configuration.MinioPassword, "images", fileContext)
+	fileClient := file.New(store, "images")
+	pendingClient := file.New(pendingStore, "uploads")
 
 	router := fiberApp.Group("/api")
 
@@ -58,7 +96,7 @@ func Start() {
 
 	cron.StartAsync()
 
-	initDeps(router, postgresClient, configuration, fileClient, cron)
+	initDeps(router, postgresClient, configuration, fileClient, pendingClient, cron)
 
 	log.Infof("Swagger Api docs working on : %s", "/swagger")
 	log.Infof("Server started on PORT: %s", configuration.Port)
@@ -80,7 +118,7 @@ func Start() {
 }
 
 func initDeps(router fiber.Router, postgresClient db.PostgresClient,
-	config *config.Config, fileClient *file.FileClient, cron *gocron.Scheduler) {
+	config *config.Config, fileClient *file.FileClient, pendingClient *file.FileClient, cron *gocron.Scheduler) {
 
 	jwtService := jwt.NewJwtService(jwt.JwtConfig{
 		RefreshTokenExp:    config.RefreshTokenExp,
@@ -89,7 +127,25 @@ func initDeps(router fiber.Router, postgresClient db.PostgresClient,
 		AccessTokenSecret:  config.AccessTokenSecret,
 	})
 
-	paymentService := payment.NewPaymentService(config.YouKassaShopId, config.YouKassaSecret, config.AppLink)
+	paymentService, err := payment.NewPaymentProvider(payment.ProviderConfig{
+		Provider:    config.PaymentProvider,
+		ShopId:      config.YouKassaShopId,
+		SecretKey:   config.YouKassaSecret,
+		AppLink:     config.AppLink,
+		FakeOutcome: config.FakePaymentOutcome,
+	})
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	responseCache, err := cache.New(cache.Config{
+		Backend: config.CacheBackend,
+		Size:    config.CacheSize,
+		TTL:     time.Duration(config.CacheTTL) * time.Second,
+	})
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 
 	mailService := mail.NewMailService(mail.MailConfig{SmtpKey: config.SmtpKey, SenderEmail: config.SmtpMail, SmtpHost: config.SmtpHost, SmtpPort: config.SmtpPort, AppLink: config.AppLink})
 
@@ -98,6 +154,9 @@ func initDeps(router fiber.Router, postgresClient db.PostgresClient,
 
 	roleRepo := repository.NewRoleRepository(postgresClient)
 	userRepo := repository.NewUserRepository(postgresClient, roleRepo)
+	slugRepo := repository.NewSlugRepository(postgresClient)
+	imageRepo := repository.NewImageRepository(postgresClient)
+	storageRepo := repository.NewStorageRepository(postgresClient)
 	brandRepo := repository.NewBrandRepository(postgresClient)
 	categoryRepo := repository.NewCategoryRepository(postgresClient)
 	deliveryRepo := repository.NewDeliveryRepository(postgresClient)
@@ -105,19 +164,50 @@ func initDeps(router fiber.Router, postgresClient db.PostgresClient,
 	productRepo := repository.NewProductRepository(postgresClient)
 	feedbackRepo := repository.NewFeedbackRepository(postgresClient)
 	wishRepo := repository.NewWishRepository(postgresClient)
-	orderRepo := repository.NewOrderRepository(postgresClient, wishRepo, productRepo, paymentService)
+	promoRepo := repository.NewPromoRepository(postgresClient)
+	outboxRepo := repository.NewOutboxRepository(postgresClient)
+	returnRepo := repository.NewReturnRepository(postgresClient, productRepo, outboxRepo)
+	reservationRepo := repository.NewReservationRepository(postgresClient, productRepo)
+	orderRepo := repository.NewOrderRepository(postgresClient, wishRepo, productRepo, promoRepo, returnRepo, outboxRepo, reservationRepo)
 	actionRepo := repository.NewActionRepository(postgresClient)
+	searchRepo := repository.NewSearchRepository(postgresClient)
+	catalogImportRepo := repository.NewCatalogImportRepository(postgresClient)
+	exchangeRepo := repository.NewExchangeRepository(postgresClient, catalogImportRepo)
+	feedRepo := repository.NewFeedRepository(postgresClient)
+	seoRepo := repository.NewSeoRepository(postgresClient)
+	uploadSessionRepo := repository.NewUploadSessionRepository(postgresClient)
+	stockRepo := repository.NewStockRepository(postgresClient)
 
 	roleService := service.NewRoleService(roleRepo)
 	userService := service.NewUserService(userRepo, sessionService, mailService)
-	brandService := service.NewBrandService(brandRepo)
-	categoryService := service.NewCategoryService(categoryRepo)
-	optionService := service.NewOptionService(optionRepo)
-	productService := service.NewProductService(productRepo, brandService, categoryService)
+	brandService := service.NewBrandService(brandRepo, slugRepo, responseCache)
+	categoryService := service.NewCategoryService(categoryRepo, slugRepo, responseCache)
+	optionService := service.NewOptionService(optionRepo, responseCache)
+	searchService := service.NewSearchService(searchRepo)
+	imageService := service.NewImageService(imageRepo, fileClient, responseCache)
+	storageService := service.NewStorageService(storageRepo, fileClient, time.Duration(config.StorageSweepGrace)*time.Hour)
+	productService := service.NewProductService(productRepo, brandService, categoryService, searchService, slugRepo, imageService, responseCache)
 	feedbackService := service.NewFeedbackService(feedbackRepo)
 	wishService := service.NewWishService(wishRepo)
-	orderService := service.NewOrderService(orderRepo, wishService, userService, deliveryRepo, mailService, paymentService)
-	actionService := service.NewActionService(actionRepo, productService)
+	promoService := service.NewPromoService(promoRepo)
+	returnService := service.NewReturnService(returnRepo, paymentService)
+	outboxService := service.NewOutboxService(outboxRepo)
+	orderService := service.NewOrderService(orderRepo, wishService, userService, deliveryRepo, mailService, paymentService, promoService, outboxService, reservationRepo)
+	actionService := service.NewActionService(actionRepo, productService, responseCache)
+	catalogImportService := service.NewCatalogImportService(catalogImportRepo, responseCache)
+	exchangeService := service.NewExchangeService(exchangeRepo, fileClient, responseCache, config.ExchangeLogin,
+		config.ExchangePassword, config.ExchangeDir)
+	feedService := service.NewFeedService(feedRepo, fileClient, feed.Shop{Name: config.FeedShopName, Company: config.FeedCompany,
+		Url: config.AppLink})
+	seoService := service.NewSeoService(seoRepo, fileClient, config.SeoSiteName, config.AppLink)
+	uploadSessionService := service.NewUploadSessionService(uploadSessionRepo, fileClient, pendingClient, productService,
+		brandService, actionService, userService, time.Duration(config.UploadSessionTTL)*time.Minute)
+	stockService := service.NewStockService(stockRepo, responseCache)
+
+	outboxService.Register(model.TopicOrderEmail, orderService.ProcessOrderEmail)
+	outboxService.Register(model.TopicPaymentCreate, orderService.ProcessCreatePayment)
+	outboxService.Register(model.TopicPaymentCancel, orderService.ProcessCancelPayment)
+	outboxService.Register(model.TopicPaymentRefund, returnService.ProcessRefund)
 
 	authMiddleware := middleware.CreateAuthMiddleware(sessionService, userService)
 	roleMiddleware := middleware.CreateRoleMiddleware()
@@ -133,14 +223,41 @@ func initDeps(router fiber.Router, postgresClient db.PostgresClient,
 	productHandler := handler.NewProductHandler(productService, router, authMiddleware)
 	feedbackHandler := handler.NewFeedbackHandler(feedbackService, router, authMiddleware)
 	wishHandler := handler.NewWishHandler(wishService, router, authMiddleware)
-	orderHandler := handler.NewOrderHandler(orderService, router, authMiddleware, config.ClientUrl)
-	fileHandler := handler.NewFileHandler(fileClient, router, authMiddleware)
+	orderHandler := handler.NewOrderHandler(orderService, router, authMiddleware, roleMiddleware, config.ClientUrl)
+	fileHandler := handler.NewFileHandler(fileClient, storageService, router, authMiddleware, roleMiddleware)
 	actionHandler := handler.NewActionHandler(actionService, router, authMiddleware)
+	promoHandler := handler.NewPromoHandler(promoService, router, authMiddleware, roleMiddleware)
+	returnHandler := handler.NewReturnHandler(returnService, router, authMiddleware, roleMiddleware)
+	searchHandler := handler.NewSearchHandler(searchService, router)
+	catalogImportHandler := handler.NewCatalogImportHandler(catalogImportService, router, authMiddleware, roleMiddleware)
+	exchangeHandler := handler.NewExchangeHandler(exchangeService, router)
+	feedHandler := handler.NewFeedHandler(feedService, router, authMiddleware, roleMiddleware)
+	seoHandler := handler.NewSeoHandler(seoService, router, authMiddleware, roleMiddleware)
+	uploadSessionHandler := handler.NewUploadSessionHandler(uploadSessionService, router, authMiddleware, roleMiddleware)
+	stockHandler := handler.NewStockHandler(stockService, router, authMiddleware, roleMiddleware)
+
+	if fake, ok := paymentService.(*payment.FakeProvider); ok {
+		handler.NewFakePaymentHandler(fake, router, authMiddleware, roleMiddleware).InitRoutes()
+	}
 
-	actionScheduler := scheduler.NewActionScheduler(cron, postgresClient)
+	actionScheduler := scheduler.NewActionScheduler(cron, postgresClient, responseCache)
 	actionScheduler.Start()
-	orderScheduler := scheduler.NewOrderScheduler(cron, postgresClient, paymentService)
+	orderScheduler := scheduler.NewOrderScheduler(cron, orderService)
 	orderScheduler.Start()
+	outboxScheduler := scheduler.NewOutboxScheduler(cron, outboxService)
+	outboxScheduler.Start()
+	reservationScheduler := scheduler.NewReservationScheduler(cron, orderService)
+	reservationScheduler.Start()
+	feedScheduler := scheduler.NewFeedScheduler(cron, feedService, config.FeedInterval)
+	feedScheduler.Start()
+	sitemapScheduler := scheduler.NewSitemapScheduler(cron, seoService, config.SitemapInterval)
+	sitemapScheduler.Start()
+	imageScheduler := scheduler.NewImageScheduler(cron, imageService)
+	imageScheduler.Start()
+	storageScheduler := scheduler.NewStorageScheduler(cron, storageService, config.StorageSweepEvery)
+	storageScheduler.Start()
+	uploadSessionScheduler := scheduler.NewUploadSessionScheduler(cron, uploadSessionService)
+	uploadSessionScheduler.Start()
 
 	roleHandler.InitRoutes()
 	userHandler.InitRoutes()
@@ -155,4 +272,13 @@ func initDeps(router fiber.Router, postgresClient db.PostgresClient,
 	orderHandler.InitRoutes()
 	fileHandler.InitRoutes()
 	actionHandler.InitRoutes()
+	promoHandler.InitRoutes()
+	returnHandler.InitRoutes()
+	searchHandler.InitRoutes()
+	catalogImportHandler.InitRoutes()
+	exchangeHandler.InitRoutes()
+	feedHandler.InitRoutes()
+	seoHandler.InitRoutes()
+	uploadSessionHandler.InitRoutes()
+	stockHandler.InitRoutes()
 }
@@ -27,9 +27,29 @@ type Config struct {
 	ClientUrl          string
 	YouKassaShopId     string
 	YouKassaSecret     string
+	PaymentProvider    string
+	FakePaymentOutcome string
+	StorageBackend     string
+	StorageLocalPath   string
+	StorageSecret      string
 	MinioApiUrl        string
 	MinioUser          string
 	MinioPassword      string
+	CacheBackend       string
+	CacheSize          int
+	CacheTTL           int
+	ExchangeLogin      string
+	ExchangePassword   string
+	ExchangeDir        string
+	FeedShopName       string
+	FeedCompany        string
+	FeedInterval       int
+	SeoSiteName        string
+	SitemapInterval    int
+	StorageSweepEvery  int
+	StorageSweepGrace  int
+	UploadSessionTTL   int
+	ImageFormat        string
 }
 
 func createPanicMessage(key string) string {
@@ -64,9 +84,29 @@ func MustLoadConfig() *Config {
 			ClientUrl:          getEnv("CLIENT_URL"),
 			YouKassaShopId:     getEnv("YOUKASSA_SHOP_ID"),
 			YouKassaSecret:     getEnv("YOUKASSA_SECRET"),
-			MinioApiUrl:        getEnv("MINIO_API_URL"),
-			MinioUser:          getEnv("MINIO_USER"),
-			MinioPassword:      getEnv("MINIO_PASSWORD"),
+			PaymentProvider:    getEnvOrDefault("PAYMENT_PROVIDER", "yookassa"),
+			FakePaymentOutcome: getEnvOrDefault("FAKE_PAYMENT_OUTCOME", "succeeded"),
+			StorageBackend:     getEnvOrDefault("STORAGE_BACKEND", "minio"),
+			StorageLocalPath:   getEnvOrDefault("STORAGE_LOCAL_PATH", "./storage"),
+			StorageSecret:      getEnvOrDefault("STORAGE_LOCAL_SECRET", ""),
+			MinioApiUrl:        getEnvOrDefault("MINIO_API_URL", ""),
+			MinioUser:          getEnvOrDefault("MINIO_USER", ""),
+			MinioPassword:      getEnvOrDefault("MINIO_PASSWORD", ""),
+			CacheBackend:       getEnvOrDefault("CACHE_BACKEND", "memory"),
+			CacheSize:          getEnvAsIntOrDefault("CACHE_SIZE", 1024),
+			CacheTTL:           getEnvAsIntOrDefault("CACHE_TTL", 60),
+			ExchangeLogin:      getEnvOrDefault("EXCHANGE_LOGIN", ""),
+			ExchangePassword:   getEnvOrDefault("EXCHANGE_PASSWORD", ""),
+			ExchangeDir:        getEnvOrDefault("EXCHANGE_DIR", ""),
+			FeedShopName:       getEnvOrDefault("FEED_SHOP_NAME", "Shop"),
+			FeedCompany:        getEnvOrDefault("FEED_COMPANY", ""),
+			FeedInterval:       getEnvAsIntOrDefault("FEED_INTERVAL", 60),
+			SeoSiteName:        getEnvOrDefault("SEO_SITE_NAME", "FamilyModa"),
+			SitemapInterval:    getEnvAsIntOrDefault("SITEMAP_INTERVAL", 10),
+			StorageSweepEvery:  getEnvAsIntOrDefault("STORAGE_SWEEP_INTERVAL", 1440),
+			StorageSweepGrace:  getEnvAsIntOrDefault("STORAGE_SWEEP_GRACE_HOURS", 72),
+			UploadSessionTTL:   getEnvAsIntOrDefault("UPLOAD_SESSION_TTL", 15),
+			ImageFormat:        getEnvOrDefault("IMAGE_FORMAT", "webp"),
 		}
 	})
 	return config
@@ -83,6 +123,16 @@ func getEnv(key string) string {
 	return value
 }
 
+func getEnvOrDefault(key string, defaultValue string) string {
+	value, exists := os.LookupEnv(key)
+
+	if !exists {
+		return defaultValue
+	}
+
+	return value
+}
+
 func getEnvAsInt(name string) int {
 	valueStr := getEnv(name)
 	value, err := strconv.Atoi(valueStr)
@@ -94,6 +144,22 @@ func getEnvAsInt(name string) int {
 	return value
 }
 
+func getEnvAsIntOrDefault(name string, defaultValue int) int {
+	valueStr, exists := os.LookupEnv(name)
+
+	if !exists {
+		return defaultValue
+	}
+
+	value, err := strconv.Atoi(valueStr)
+
+	if err != nil {
+		panic("Error when coverting env variable to integer")
+	}
+
+	return value
+}
+
 func getEnvAsBool(name string) bool {
 	valueStr := getEnv(name)
 	value, err := strconv.ParseBool(valueStr)
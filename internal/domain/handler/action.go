@@ -37,9 +37,9 @@ func (h *ActionHandler) InitRoutes() {
 	{
 		actionRouter.Post("/", h.create)
 		actionRouter.Post("/model", h.addModelToAction)
-		actionRouter.Get("/", h.getAll)
+		actionRouter.Get("/", etagMiddleware, h.getAll)
 		actionRouter.Get("/by-id/:id", h.getById)
-		actionRouter.Get("/by-gender/:gender", h.getByGender)
+		actionRouter.Get("/by-gender/:gender", etagMiddleware, h.getByGender)
 		actionRouter.Get("/model/:id", h.getActionModels)
 		actionRouter.Patch("/:id", h.update)
 		actionRouter.Delete("/model/:actionModelId", h.deleteActionModel)
@@ -12,6 +12,7 @@ import (
 
 type brandService interface {
 	FindBySlug(ctx context.Context, slug string) (*model.Brand, fall.Error)
+	FindRedirect(ctx context.Context, slug string) (*model.SlugRedirect, fall.Error)
 	GetAll(ctx context.Context) ([]model.Brand, fall.Error)
 	Update(ctx context.Context, dto model.UpdateBrandDto, id int) fall.Error
 	Create(ctx context.Context, dto model.CreateBrandDto) fall.Error
@@ -39,8 +40,8 @@ func (h *BrandHandler) InitRoutes() {
 		brandRouter.Post("/", h.create)
 		brandRouter.Patch("/:id", h.update)
 		brandRouter.Delete("/:slug", h.delete)
-		brandRouter.Get("/", h.getAll)
-		brandRouter.Get("/by-gender/:categorySlug", h.getByGender)
+		brandRouter.Get("/", etagMiddleware, h.getAll)
+		brandRouter.Get("/by-gender/:categorySlug", etagMiddleware, h.getByGender)
 		brandRouter.Get("/:slug", h.findBySlug)
 	}
 }
@@ -103,6 +104,7 @@ func (h *BrandHandler) delete(ctx *fiber.Ctx) error {
 // @Param slug path string true "Brand Slug"
 // @Router /api/brand/{slug} [get]
 // @Success 200 {object} model.Brand
+// @Success 301 {object} model.SlugRedirect
 // @Failure 400 {object} fall.ValidationError
 // @Failure 404 {object} fall.AppErr
 // @Failure 500 {object} fall.AppErr
@@ -113,7 +115,9 @@ func (h *BrandHandler) findBySlug(ctx *fiber.Ctx) error {
 	brand, err := h.service.FindBySlug(ctx.Context(), slug)
 
 	if err != nil {
-		return ctx.Status(err.Status()).JSON(err)
+		return lookupError(ctx, err, func() (*model.SlugRedirect, fall.Error) {
+			return h.service.FindRedirect(ctx.Context(), slug)
+		})
 	}
 
 	return ctx.Status(fall.STATUS_OK).JSON(brand)
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/keys"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/table"
+)
+
+type catalogImportService interface {
+	Import(ctx context.Context, data []byte, format string, dryRun bool) (*model.CatalogImportReport, fall.Error)
+	Export(ctx context.Context, w io.Writer, format string) fall.Error
+}
+
+type CatalogImportHandler struct {
+	service        catalogImportService
+	router         fiber.Router
+	authMiddleware middleware.AuthMiddleware
+	roleMiddleware middleware.RoleMiddleware
+}
+
+func NewCatalogImportHandler(service catalogImportService, router fiber.Router, authMiddleware middleware.AuthMiddleware,
+	roleMiddleware middleware.RoleMiddleware) *CatalogImportHandler {
+	return &CatalogImportHandler{service: service, router: router, authMiddleware: authMiddleware, roleMiddleware: roleMiddleware}
+}
+
+func (h *CatalogImportHandler) InitRoutes() {
+	catalogRouter := h.router.Group("catalog")
+	{
+		catalogRouter.Post("/import", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.importFile)
+		catalogRouter.Get("/export", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.export)
+	}
+}
+
+// @Summary Import catalog
+// @Security BearerToken
+// @Description Create or update products, models, sizes and options from a CSV or XLSX file.
+// @Description Rows are matched by article, unknown brands, options and values are created.
+// @Description With dry_run the file is validated and written in a transaction that is rolled back.
+// @Tags catalog
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or XLSX file"
+// @Param dry_run query bool false "Only validate"
+// @Router /api/catalog/import [post]
+// @Success 200 {object} model.CatalogImportReport
+// @Failure 400 {object} model.CatalogImportReport
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *CatalogImportHandler) importFile(ctx *fiber.Ctx) error {
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		appErr := fall.NewErr(msg.CatalogImportBadFile, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		ex := fall.ServerError(err.Error())
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		ex := fall.ServerError(err.Error())
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	dryRun := ctx.QueryBool("dry_run")
+
+	report, ex := h.service.Import(ctx.Context(), data, table.FormatFromName(file.Filename), dryRun)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	if len(report.Errors) > 0 && !dryRun {
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(report)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(report)
+}
+
+// @Summary Export catalog
+// @Security BearerToken
+// @Description Export every model in the import format
+// @Tags catalog
+// @Produce octet-stream
+// @Param format query string false "csv or xlsx, csv by default"
+// @Router /api/catalog/export [get]
+// @Success 200 {file} file
+// @Failure 400 {object} fall.AppErr
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *CatalogImportHandler) export(ctx *fiber.Ctx) error {
+	format := ctx.Query("format", table.CSV)
+
+	var buf bytes.Buffer
+
+	ex := h.service.Export(ctx.Context(), &buf, format)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	ctx.Attachment("catalog." + format)
+	ctx.Set(fiber.HeaderContentType, table.ContentType(format))
+
+	return ctx.Status(fall.STATUS_OK).Send(buf.Bytes())
+}
@@ -14,6 +14,7 @@ type categoryService interface {
 	Create(ctx context.Context, dto model.CreateCategoryDto) fall.Error
 	Update(ctx context.Context, dto model.UpdateCategoryDto, id int) fall.Error
 	FindBySlug(ctx context.Context, slug string) (*model.CategoryModel, fall.Error)
+	FindRedirect(ctx context.Context, slug string) (*model.SlugRedirect, fall.Error)
 	FindBySlugRelation(ctx context.Context, slug string) (*model.Category, fall.Error)
 	Delete(ctx context.Context, slug string) fall.Error
 	GetAll(ctx context.Context) ([]*model.Category, fall.Error)
@@ -41,7 +42,7 @@ func (h *CategoryHandler) InitRoutes() {
 	categoryRouter := h.router.Group("category")
 	{
 		{
-			categoryRouter.Get("/", h.getAll)
+			categoryRouter.Get("/", etagMiddleware, h.getAll)
 			categoryRouter.Get("/top", h.getTopLevels)
 			categoryRouter.Get("/without-children", h.getWithoutChildren)
 			categoryRouter.Get("/last-levels/:slug", h.getLastLevels)
@@ -111,6 +112,7 @@ func (h *CategoryHandler) findBySlugRelation(ctx *fiber.Ctx) error {
 // @Param slug path string true "Category Slug"
 // @Router /api/category/{slug} [get]
 // @Success 200 {object} model.CategoryModel
+// @Success 301 {object} model.SlugRedirect
 // @Failure 400 {object} fall.ValidationError
 // @Failure 404 {object} fall.AppErr
 // @Failure 500 {object} fall.AppErr
@@ -121,7 +123,9 @@ func (h *CategoryHandler) findBySlug(ctx *fiber.Ctx) error {
 	category, err := h.service.FindBySlug(ctx.Context(), slug)
 
 	if err != nil {
-		return ctx.Status(err.Status()).JSON(err)
+		return lookupError(ctx, err, func() (*model.SlugRedirect, fall.Error) {
+			return h.service.FindRedirect(ctx.Context(), slug)
+		})
 	}
 
 	return ctx.Status(fall.STATUS_OK).JSON(category)
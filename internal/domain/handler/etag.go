@@ -0,0 +1,7 @@
+package handler
+
+import "github.com/gofiber/fiber/v2/middleware/etag"
+
+// etagMiddleware is put in front of cached endpoints: it sends an ETag of the response body
+// and answers 304 Not Modified when the request's If-None-Match already holds it.
+var etagMiddleware = etag.New()
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+const (
+	exchangeCookie = "exchange_session"
+	// exchangeFileLimit is the largest request 1C sends, bigger files come in parts, it stays under the app body limit
+	exchangeFileLimit = 50 * 1024 * 1024
+)
+
+type exchangeService interface {
+	CheckAuth(login string, password string) (string, fall.Error)
+	Init(token string) fall.Error
+	SaveFile(token string, filename string, data []byte) fall.Error
+	Import(ctx context.Context, token string, filename string) fall.Error
+	QueryOrders(ctx context.Context, token string) ([]byte, fall.Error)
+	ConfirmOrders(ctx context.Context, token string) fall.Error
+}
+
+// ExchangeHandler implements the CommerceML 2 exchange protocol of 1C, it answers with plain text
+// "success" or "failure" lines instead of JSON and authorizes by its own cookie.
+type ExchangeHandler struct {
+	service exchangeService
+	router  fiber.Router
+}
+
+func NewExchangeHandler(service exchangeService, router fiber.Router) *ExchangeHandler {
+	return &ExchangeHandler{service: service, router: router}
+}
+
+func (h *ExchangeHandler) InitRoutes() {
+	exchangeRouter := h.router.Group("exchange")
+	{
+		exchangeRouter.Get("/1c", h.exchange)
+		exchangeRouter.Post("/1c", h.exchange)
+	}
+}
+
+// @Summary 1C exchange
+// @Description CommerceML 2 exchange with 1C. type=catalog supports checkauth, init, file and import,
+// @Description type=sale supports checkauth, init, query and success. checkauth takes basic auth,
+// @Description the other modes take the cookie it returns.
+// @Tags exchange
+// @Accept octet-stream
+// @Produce plain
+// @Param type query string true "catalog or sale"
+// @Param mode query string true "checkauth, init, file, import, query or success"
+// @Param filename query string false "file name for file and import"
+// @Router /api/exchange/1c [get]
+// @Router /api/exchange/1c [post]
+// @Success 200 {string} string
+// @Failure 400 {string} string
+// @Failure 401 {string} string
+// @Failure 500 {string} string
+func (h *ExchangeHandler) exchange(ctx *fiber.Ctx) error {
+	exchangeType := ctx.Query("type")
+	mode := ctx.Query("mode")
+
+	if mode == "checkauth" {
+		login, password, _ := parseBasicAuth(ctx.Get(fiber.HeaderAuthorization))
+		token, ex := h.service.CheckAuth(login, password)
+		if ex != nil {
+			return exchangeFailure(ctx, ex)
+		}
+		return exchangeSuccess(ctx, exchangeCookie, token)
+	}
+
+	token := ctx.Cookies(exchangeCookie)
+
+	switch {
+	case mode == "init":
+		if ex := h.service.Init(token); ex != nil {
+			return exchangeFailure(ctx, ex)
+		}
+		return ctx.Status(fall.STATUS_OK).SendString(fmt.Sprintf("zip=no\nfile_limit=%d", exchangeFileLimit))
+
+	case mode == "file" && exchangeType == "catalog":
+		if ex := h.service.SaveFile(token, ctx.Query("filename"), ctx.Body()); ex != nil {
+			return exchangeFailure(ctx, ex)
+		}
+		return exchangeSuccess(ctx)
+
+	case mode == "import" && exchangeType == "catalog":
+		if ex := h.service.Import(ctx.Context(), token, ctx.Query("filename")); ex != nil {
+			return exchangeFailure(ctx, ex)
+		}
+		return exchangeSuccess(ctx)
+
+	case mode == "query" && exchangeType == "sale":
+		document, ex := h.service.QueryOrders(ctx.Context(), token)
+		if ex != nil {
+			return exchangeFailure(ctx, ex)
+		}
+		ctx.Set(fiber.HeaderContentType, "application/xml; charset=utf-8")
+		return ctx.Status(fall.STATUS_OK).Send(document)
+
+	case mode == "success" && exchangeType == "sale":
+		if ex := h.service.ConfirmOrders(ctx.Context(), token); ex != nil {
+			return exchangeFailure(ctx, ex)
+		}
+		return exchangeSuccess(ctx)
+	}
+
+	return exchangeFailure(ctx, fall.NewErr(msg.ExchangeUnknownMode, fall.STATUS_BAD_REQUEST))
+}
+
+func exchangeSuccess(ctx *fiber.Ctx, lines ...string) error {
+	return ctx.Status(fall.STATUS_OK).SendString(strings.Join(append([]string{"success"}, lines...), "\n"))
+}
+
+func exchangeFailure(ctx *fiber.Ctx, ex fall.Error) error {
+	return ctx.Status(ex.Status()).SendString("failure\n" + ex.Message())
+}
+
+func parseBasicAuth(header string) (string, string, bool) {
+	encoded, ok := strings.CutPrefix(header, "Basic ")
+	if !ok {
+		return "", "", false
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(raw), ":")
+}
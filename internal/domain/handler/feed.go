@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/keys"
+)
+
+type feedService interface {
+	Generate(ctx context.Context) ([]model.FeedGeneration, fall.Error)
+	GetGenerations(ctx context.Context) ([]model.FeedGeneration, fall.Error)
+	GetPath(ctx context.Context, name string) (string, fall.Error)
+}
+
+type FeedHandler struct {
+	service        feedService
+	router         fiber.Router
+	authMiddleware middleware.AuthMiddleware
+	roleMiddleware middleware.RoleMiddleware
+}
+
+func NewFeedHandler(service feedService, router fiber.Router, authMiddleware middleware.AuthMiddleware,
+	roleMiddleware middleware.RoleMiddleware) *FeedHandler {
+	return &FeedHandler{service: service, router: router, authMiddleware: authMiddleware, roleMiddleware: roleMiddleware}
+}
+
+func (h *FeedHandler) InitRoutes() {
+	feedRouter := h.router.Group("feed")
+	{
+		feedRouter.Get("/admin", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.getGenerations)
+		feedRouter.Post("/admin/generate", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.generate)
+		feedRouter.Get("/:name", h.getFeed)
+	}
+}
+
+// @Summary Get feed
+// @Description Redirects to the latest generated feed: yandex.yml, google.xml or google.tsv
+// @Tags feed
+// @Param name path string true "Feed name"
+// @Router /api/feed/{name} [get]
+// @Success 302
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *FeedHandler) getFeed(ctx *fiber.Ctx) error {
+	name := ctx.Params("name")
+
+	if !slices.Contains(model.Feeds, name) {
+		appErr := fall.NewErr(msg.FeedNotFound, fall.STATUS_NOT_FOUND)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	path, ex := h.service.GetPath(ctx.Context(), name)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Redirect(path, fall.STATUS_REDIRECT_TEMP)
+}
+
+// @Summary Get feed generation status
+// @Security BearerToken
+// @Description Latest run of every feed with its offer warnings
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Router /api/feed/admin [get]
+// @Success 200 {array} model.FeedGeneration
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *FeedHandler) getGenerations(ctx *fiber.Ctx) error {
+	generations, ex := h.service.GetGenerations(ctx.Context())
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(generations)
+}
+
+// @Summary Generate feeds
+// @Security BearerToken
+// @Description Generate all feeds now instead of waiting for the schedule
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Router /api/feed/admin/generate [post]
+// @Success 200 {array} model.FeedGeneration
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 409 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *FeedHandler) generate(ctx *fiber.Ctx) error {
+	generations, ex := h.service.Generate(ctx.Context())
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(generations)
+}
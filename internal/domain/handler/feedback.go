@@ -9,6 +9,7 @@ import (
 	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
 )
 
@@ -16,7 +17,7 @@ type feedbackService interface {
 	AddFeedback(ctx context.Context, dto model.AddFeedbackDto, userId int) fall.Error
 	ToggleHidden(ctx context.Context, feedbackId int) fall.Error
 	GetModelFeedback(ctx context.Context, modelId int, order string) (*model.ModelFeedbackResponse, fall.Error)
-	GetAll(ctx context.Context, order string, page int, filter string) (*model.AdminAllFeedbackResponse, fall.Error)
+	GetAll(ctx context.Context, order string, page pagination.Page, filter string) (*model.AdminAllFeedbackResponse, fall.Error)
 	DeleteFeedback(ctx context.Context, feedbackId int) fall.Error
 	GetMyFeedback(ctx context.Context, userId int) ([]model.UserFeedback, fall.Error)
 }
@@ -137,7 +138,9 @@ func (fh *FeedbackHandler) toggleHidden(ctx *fiber.Ctx) error {
 // @Produce json
 // @Router /api/feedback/admin/all [get]
 // @Param order query string false "Order [ASC | DESC]"
-// @Param page query int false "Page"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "page size, 1-100"
+// @Param page query int false "deprecated page number, used only without a cursor"
 // @Param filter query string false "Filter"
 // @Success 200 {object} model.AdminAllFeedbackResponse
 // @Failure 400 {object} fall.ValidationError
@@ -150,7 +153,14 @@ func (fh *FeedbackHandler) getAll(ctx *fiber.Ctx) error {
 		order = "ASC"
 	}
 
-	page := ctx.QueryInt("page", 1)
+	page, pageErr := pagination.Parse(ctx.Query("cursor"), ctx.Query("limit"), model.FeedbackSort(order))
+	if pageErr == nil {
+		pageErr = page.Number(ctx.Query("page"))
+	}
+	if pageErr != nil {
+		validError := fall.NewFieldValidErr(pageErr.Key, pageErr.Message)
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
 
 	filter := ctx.Query("filter")
 
@@ -165,7 +175,7 @@ func (fh *FeedbackHandler) getAll(ctx *fiber.Ctx) error {
 		return ctx.Status(ex.Status()).JSON(ex)
 	}
 
-	feedback, ex := fh.service.GetAll(ctx.Context(), order, page, filter)
+	feedback, ex := fh.service.GetAll(ctx.Context(), order, *page, filter)
 	if ex != nil {
 		return ctx.Status(ex.Status()).JSON(ex)
 	}
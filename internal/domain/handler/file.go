@@ -2,56 +2,149 @@ package handler
 
 import (
 	"context"
-	"mime/multipart"
+	"errors"
+	"io"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/imageproc"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/keys"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/upload"
 )
 
 type fileClient interface {
-	Upload(ctx context.Context, h *multipart.FileHeader) (*model.UploadResponse, error)
+	Upload(ctx context.Context, data []byte, contentType string, ext string) (*model.UploadResponse, error)
+}
+
+type storageService interface {
+	Sweep(ctx context.Context, dryRun bool) (*model.SweepReport, fall.Error)
 }
 
 type FileHandler struct {
 	client         fileClient
+	storage        storageService
 	router         fiber.Router
 	authMiddleware middleware.AuthMiddleware
+	roleMiddleware middleware.RoleMiddleware
 }
 
-func NewFileHandler(client fileClient, router fiber.Router, authMiddleware middleware.AuthMiddleware) *FileHandler {
-	return &FileHandler{client: client, router: router, authMiddleware: authMiddleware}
+func NewFileHandler(client fileClient, storage storageService, router fiber.Router, authMiddleware middleware.AuthMiddleware,
+	roleMiddleware middleware.RoleMiddleware) *FileHandler {
+	return &FileHandler{client: client, storage: storage, router: router, authMiddleware: authMiddleware,
+		roleMiddleware: roleMiddleware}
 }
 
 func (h *FileHandler) InitRoutes() {
 	fileRouter := h.router.Group("file")
 	{
-		fileRouter.Post("/", h.upload)
+		fileRouter.Post("/", h.authMiddleware, h.upload(false))
+		fileRouter.Post("/admin", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.upload(true))
+		fileRouter.Get("/admin/sweep", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.sweep(true))
+		fileRouter.Post("/admin/sweep", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.sweep(false))
 	}
 }
 
 // @Summary Upload file
-// @Description Upload file
+// @Security BearerToken
+// @Description Upload a picture, its type is detected from the content. Purpose sets the size and type limits:
+// @Description image (default) and avatar for everyone, brand, category, action and product at /api/file/admin only
 // @Tags file
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "File"
+// @Param purpose formData string false "image, avatar, brand, category, action or product"
 // @Router /api/file/ [post]
+// @Router /api/file/admin [post]
 // @Success 201 {object} model.UploadResponse
 // @Failure 400 {object} fall.AppErr
-// @Failure 404 {object} fall.AppErr
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
 // @Failure 500 {object} fall.AppErr
-func (h *FileHandler) upload(ctx *fiber.Ctx) error {
-	file, err := ctx.FormFile("file")
-	if err != nil {
-		ex := fall.ServerError(err.Error())
-		return ctx.Status(ex.Status()).JSON(ex)
+func (h *FileHandler) upload(admin bool) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		purpose := ctx.FormValue("purpose", upload.PurposeImage)
+
+		policy, ok := upload.Policies[purpose]
+		if !ok {
+			appErr := fall.NewErr(msg.FileUnknownPurpose, fall.STATUS_BAD_REQUEST)
+			return ctx.Status(appErr.Status()).JSON(appErr)
+		}
+
+		if policy.AdminOnly && !admin {
+			appErr := fall.NewErr(msg.FileAdminPurpose, fall.STATUS_FORBIDDEN)
+			return ctx.Status(appErr.Status()).JSON(appErr)
+		}
+
+		header, err := ctx.FormFile("file")
+		if err != nil {
+			appErr := fall.NewErr(msg.FileRequired, fall.STATUS_BAD_REQUEST)
+			return ctx.Status(appErr.Status()).JSON(appErr)
+		}
+
+		if header.Size > policy.MaxSize {
+			appErr := fall.NewErr(msg.FileTooLarge, fall.STATUS_BAD_REQUEST)
+			return ctx.Status(appErr.Status()).JSON(appErr)
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			ex := fall.ServerError(err.Error())
+			return ctx.Status(ex.Status()).JSON(ex)
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, policy.MaxSize+1))
+		if err != nil {
+			ex := fall.ServerError(err.Error())
+			return ctx.Status(ex.Status()).JSON(ex)
+		}
+
+		contentType, err := upload.Check(policy, data, imageproc.Supported)
+		if err != nil {
+			message := msg.FileUnsafe
+			switch {
+			case errors.Is(err, upload.ErrTooLarge):
+				message = msg.FileTooLarge
+			case errors.Is(err, upload.ErrType):
+				message = msg.FileTypeNotAllowed
+			case errors.Is(err, upload.ErrPixels):
+				message = msg.FileTooManyPixels
+			}
+			appErr := fall.NewErr(message, fall.STATUS_BAD_REQUEST)
+			return ctx.Status(appErr.Status()).JSON(appErr)
+		}
+
+		res, err := h.client.Upload(ctx.Context(), data, contentType, upload.Extensions[contentType])
+		if err != nil {
+			ex := fall.ServerError(err.Error())
+			return ctx.Status(ex.Status()).JSON(ex)
+		}
+		return ctx.Status(fall.STATUS_CREATED).JSON(res)
 	}
-	res, err := h.client.Upload(ctx.Context(), file)
-	if err != nil {
-		ex := fall.ServerError(err.Error())
-		return ctx.Status(ex.Status()).JSON(ex)
+}
+
+// @Summary Sweep orphaned files
+// @Security BearerToken
+// @Description Files no record refers to and older than the grace period. GET only reports them, POST deletes them
+// @Tags file
+// @Accept json
+// @Produce json
+// @Router /api/file/admin/sweep [get]
+// @Router /api/file/admin/sweep [post]
+// @Success 200 {object} model.SweepReport
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 409 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *FileHandler) sweep(dryRun bool) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		report, ex := h.storage.Sweep(ctx.Context(), dryRun)
+		if ex != nil {
+			return ctx.Status(ex.Status()).JSON(ex)
+		}
+		return ctx.Status(fall.STATUS_OK).JSON(report)
 	}
-	return ctx.Status(fall.STATUS_CREATED).JSON(res)
 }
@@ -8,24 +8,26 @@ import (
 	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/generator"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
 )
 
 type optionService interface {
-	GetCatalogFilters(ctx context.Context, categorySlug string) (*model.CatalogFilters, fall.Error)
+	GetCatalogFilters(ctx context.Context, params generator.CatalogParams) (*model.CatalogFilters, fall.Error)
 	GetAll(ctx context.Context) ([]*model.Option, fall.Error)
-	FindOptionById(ctx context.Context, id int) (*model.Option, fall.Error)                       // +
-	CreateOption(ctx context.Context, dto model.CreateOptionDto) fall.Error                       // +
-	CreateSize(ctx context.Context, dto model.CreateSizeDto) fall.Error                           // +
-	CreateValue(ctx context.Context, dto model.CreateOptionValueDto) fall.Error                   // +
-	DeleteOption(ctx context.Context, id int) fall.Error                                          // +
-	DeleteValue(ctx context.Context, id int) fall.Error                                           // +
-	DeleteSize(ctx context.Context, id int) fall.Error                                            // +
-	DeleteSizeFromProductModel(ctx context.Context, modelSizeId int) fall.Error                   // +
-	DeleteOptionFromProductModel(ctx context.Context, productModelOptionId int) fall.Error        // +
-	AddOptionToProductModel(ctx context.Context, dto model.AddOptionToProductModelDto) fall.Error // +
-	AddSizeToProductModel(ctx context.Context, dto model.AddSizeToProductModelDto) fall.Error     // +
-	UpdateOption(ctx context.Context, dto model.UpdateOptionDto, id int) fall.Error               // +
-	UpdateOptionValue(ctx context.Context, dto model.UpdateOptionValueDto, id int) fall.Error     // +
+	FindOptionById(ctx context.Context, id int) (*model.Option, fall.Error)                               // +
+	CreateOption(ctx context.Context, dto model.CreateOptionDto) fall.Error                               // +
+	CreateSize(ctx context.Context, dto model.CreateSizeDto) fall.Error                                   // +
+	CreateValue(ctx context.Context, dto model.CreateOptionValueDto) fall.Error                           // +
+	DeleteOption(ctx context.Context, id int) fall.Error                                                  // +
+	DeleteValue(ctx context.Context, id int) fall.Error                                                   // +
+	DeleteSize(ctx context.Context, id int) fall.Error                                                    // +
+	DeleteSizeFromProductModel(ctx context.Context, modelSizeId int) fall.Error                           // +
+	DeleteOptionFromProductModel(ctx context.Context, productModelOptionId int) fall.Error                // +
+	AddOptionToProductModel(ctx context.Context, dto model.AddOptionToProductModelDto) fall.Error         // +
+	AddSizeToProductModel(ctx context.Context, userId int, dto model.AddSizeToProductModelDto) fall.Error // +
+	UpdateOption(ctx context.Context, dto model.UpdateOptionDto, id int) fall.Error                       // +
+	UpdateOptionValue(ctx context.Context, dto model.UpdateOptionValueDto, id int) fall.Error             // +
 	GetAllSizes(ctx context.Context) ([]model.Size, fall.Error)
 }
 
@@ -52,13 +54,13 @@ func (h *OptionHandler) InitRoutes() {
 		optionRouter.Delete("/option/:id", h.deleteOption)
 		optionRouter.Delete("/value/:id", h.deleteValue)
 		optionRouter.Post("/option/model", h.addOptionToProductModel)
-		optionRouter.Post("/size/model", h.addSizeToProductModel)
+		optionRouter.Post("/size/model", h.authMiddleware, h.addSizeToProductModel)
 		optionRouter.Post("/option", h.createOption)
 		optionRouter.Post("/value", h.createOptionValue)
 		optionRouter.Post("/size", h.createSize)
 		optionRouter.Patch("/option/:id", h.updateOption)
 		optionRouter.Patch("/value/:id", h.updateOptionValue)
-		optionRouter.Get("/catalog/:slug", h.getCatalogFilters)
+		optionRouter.Get("/catalog/:slug", etagMiddleware, h.getCatalogFilters)
 		optionRouter.Get("/option", h.getAll)
 		optionRouter.Get("/size", h.getAllSizes)
 
@@ -71,6 +73,10 @@ func (h *OptionHandler) InitRoutes() {
 // @Accept json
 // @Produce json
 // @Param categorySlug path string true "Category slug"
+// @Param size query string false "sizes"
+// @Param brands query string false "brands"
+// @Param is_sale query string false "get items with sale"
+// @Param price query string false "from - to"
 // @Router /api/characteristics/catalog/{categorySlug} [get]
 // @Success 200 {object} model.CatalogFilters
 // @Failure 400 {object} fall.ValidationError
@@ -80,7 +86,13 @@ func (h *OptionHandler) getCatalogFilters(ctx *fiber.Ctx) error {
 
 	slug := ctx.Params("slug")
 
-	filters, err := h.service.GetCatalogFilters(ctx.Context(), slug)
+	params, filterErr := generator.ParseCatalogFilters(generator.CatalogFiltersFromQuery(slug, ctx.Queries()))
+	if filterErr != nil {
+		validError := fall.NewFieldValidErr(filterErr.Key, filterErr.Message)
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	filters, err := h.service.GetCatalogFilters(ctx.Context(), *params)
 
 	if err != nil {
 		return ctx.Status(err.Status()).JSON(err)
@@ -194,7 +206,8 @@ func (h *OptionHandler) deleteOptionFromProductModel(ctx *fiber.Ctx) error {
 }
 
 // @Summary Add size to product model
-// @Description size option to product model
+// @Security BearerToken
+// @Description size option to product model, the opening stock is recorded as an adjustment by the current user
 // @Tags characteristics
 // @Accept json
 // @Produce json
@@ -202,9 +215,14 @@ func (h *OptionHandler) deleteOptionFromProductModel(ctx *fiber.Ctx) error {
 // @Router /api/characteristics/size/model [post]
 // @Success 201 {object} fall.AppErr
 // @Failure 400 {object} fall.ValidationError
+// @Failure 401 {object} fall.AppErr
 // @Failure 404 {object} fall.AppErr
 // @Failure 500 {object} fall.AppErr
 func (h *OptionHandler) addSizeToProductModel(ctx *fiber.Ctx) error {
+	session, ex := utils.GetLocalSession(ctx)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
 
 	dto := model.AddSizeToProductModelDto{}
 
@@ -227,7 +245,7 @@ func (h *OptionHandler) addSizeToProductModel(ctx *fiber.Ctx) error {
 		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
 	}
 
-	ex := h.service.AddSizeToProductModel(ctx.Context(), dto)
+	ex = h.service.AddSizeToProductModel(ctx.Context(), session.UserId, dto)
 	if ex != nil {
 		return ctx.Status(ex.Status()).JSON(ex)
 	}
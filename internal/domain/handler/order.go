@@ -6,33 +6,43 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/keys"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/payment"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
 )
 
 type orderService interface {
 	Create(ctx context.Context, dto model.CreateOrderDto, user *model.LocalSession) (*string, fall.Error)
-	GetAdminOrders(ctx context.Context, page int, fromDate *string, toDate *string) (*model.AllOrdersResponse, fall.Error)
+	GetAdminOrders(ctx context.Context, page pagination.Page, fromDate *string, toDate *string) (*model.AllOrdersResponse, fall.Error)
 	GetUserOrders(ctx context.Context, userId int) ([]*model.Order, fall.Error)
 	GetOrder(ctx context.Context, id string) (*model.Order, fall.Error)
 	CancelOrder(ctx context.Context, orderId string, userId int) fall.Error
-	ChangeStatus(ctx context.Context, orderId string, status model.OrderStatusEnum) fall.Error
+	ChangeStatus(ctx context.Context, orderId string, change model.OrderStatusChange) fall.Error
 	ChangeDeliveryDate(ctx context.Context, orderId string, date time.Time) fall.Error
 	ConfirmPayment(ctx context.Context, id string) fall.Error
+	HandlePaymentNotification(ctx context.Context, n payment.Notification) fall.Error
+	SendNewActivationLink(ctx context.Context, orderId string, user *model.LocalSession) fall.Error
+	ActivateOrder(ctx context.Context, link string) fall.Error
 }
 
 type OrderHandler struct {
 	service        orderService
 	router         fiber.Router
 	authMiddleware middleware.AuthMiddleware
+	roleMiddleware middleware.RoleMiddleware
 	clientUrl      string
 }
 
-func NewOrderHandler(service orderService, router fiber.Router, authMiddleware middleware.AuthMiddleware, clientUrl string,
+func NewOrderHandler(service orderService, router fiber.Router, authMiddleware middleware.AuthMiddleware,
+	roleMiddleware middleware.RoleMiddleware, clientUrl string,
 ) *OrderHandler {
-	return &OrderHandler{service: service, router: router, authMiddleware: authMiddleware, clientUrl: clientUrl}
+	return &OrderHandler{service: service, router: router, authMiddleware: authMiddleware, roleMiddleware: roleMiddleware, clientUrl: clientUrl}
 }
 
 func (h *OrderHandler) InitRoutes() {
@@ -40,13 +50,16 @@ func (h *OrderHandler) InitRoutes() {
 	{
 		orderRouter.Post("/", h.authMiddleware, h.create)
 		orderRouter.Get("/confirm-online-payment/:orderId", h.confirmPayment)
+		orderRouter.Post("/payment/notification", h.paymentNotification)
 		orderRouter.Get("/admin/all", h.getAllOrders)
 		orderRouter.Get("/admin/user/:userId", h.getAdminUserOrders)
 
+		orderRouter.Get("/activate/:link", h.activate)
+		orderRouter.Post("/activation-link/:orderId", h.authMiddleware, h.sendActivationLink)
 		orderRouter.Patch("/cancel/:orderId", h.authMiddleware, h.cancel)
 		orderRouter.Get("/my", h.authMiddleware, h.getUserOrders)
 		orderRouter.Get("/:orderId", h.getOrder)
-		orderRouter.Patch("/change-status/:orderId", h.changeStatus)
+		orderRouter.Patch("/change-status/:orderId", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.changeStatus)
 		orderRouter.Patch("/change-delivery-date/:orderId", h.changeDeliveryDate)
 	}
 }
@@ -126,6 +139,7 @@ func (h *OrderHandler) changeDeliveryDate(ctx *fiber.Ctx) error {
 }
 
 // @Summary Change order status
+// @Security BearerToken
 // @Description Change order status
 // @Tags order
 // @Accept json
@@ -153,6 +167,8 @@ func (h *OrderHandler) changeStatus(ctx *fiber.Ctx) error {
 
 	validate := validator.New()
 
+	validate.RegisterValidation("orderStatusEnumValidation", model.OrderStatusEnumValidation)
+
 	err = validate.Struct(&dto)
 
 	if err != nil {
@@ -163,7 +179,17 @@ func (h *OrderHandler) changeStatus(ctx *fiber.Ctx) error {
 		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
 	}
 
-	ex := h.service.ChangeStatus(ctx.Context(), orderId, dto.Status)
+	user, ex := utils.GetLocalSession(ctx)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	ex = h.service.ChangeStatus(ctx.Context(), orderId, model.OrderStatusChange{
+		Status:    dto.Status,
+		ActorType: model.ActorAdmin,
+		ActorId:   &user.UserId,
+		Reason:    dto.Reason,
+	})
 	if ex != nil {
 		return ctx.Status(ex.Status()).JSON(ex)
 	}
@@ -180,14 +206,23 @@ func (h *OrderHandler) changeStatus(ctx *fiber.Ctx) error {
 // @Router /api/order/admin/all [get]
 // @Param fromDate query string false "from date"
 // @Param toDate query string false "to date"
-// @Param page query int false "pagination page"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "page size, 1-100"
+// @Param page query int false "deprecated page number, used only without a cursor"
 // @Success 200 {object} model.AllOrdersResponse
 // @Failure 400 {object} fall.ValidationError
 // @Failure 404 {object} fall.AppErr
 // @Failure 500 {object} fall.AppErr
 func (h *OrderHandler) getAllOrders(ctx *fiber.Ctx) error {
 
-	page := ctx.QueryInt("page", 1)
+	page, pageErr := pagination.Parse(ctx.Query("cursor"), ctx.Query("limit"), model.AdminOrdersSort)
+	if pageErr == nil {
+		pageErr = page.Number(ctx.Query("page"))
+	}
+	if pageErr != nil {
+		validError := fall.NewFieldValidErr(pageErr.Key, pageErr.Message)
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
 
 	var fromDate *string
 	var toDate *string
@@ -215,7 +250,7 @@ func (h *OrderHandler) getAllOrders(ctx *fiber.Ctx) error {
 		}
 	}
 
-	orders, ex := h.service.GetAdminOrders(ctx.Context(), page, fromDate, toDate)
+	orders, ex := h.service.GetAdminOrders(ctx.Context(), *page, fromDate, toDate)
 	if ex != nil {
 		return ctx.Status(ex.Status()).JSON(ex)
 	}
@@ -250,6 +285,62 @@ func (h *OrderHandler) cancel(ctx *fiber.Ctx) error {
 	return ctx.Status(ok.Status()).JSON(ok)
 }
 
+// @Summary Activate order
+// @Description Activates an order paid upon receipt by the link from the email
+// @Tags order
+// @Accept json
+// @Produce json
+// @Param link path string true "Activation link"
+// @Router /api/order/activate/{link} [get]
+// @Success 200 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *OrderHandler) activate(ctx *fiber.Ctx) error {
+	link := ctx.Params("link")
+
+	if _, err := uuid.Parse(link); err != nil {
+		appErr := fall.NewErr(msg.OrderActivationLinkNotFound, fall.STATUS_NOT_FOUND)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	ex := h.service.ActivateOrder(ctx.Context(), link)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+	ok := fall.GetOk()
+	return ctx.Status(ok.Status()).JSON(ok)
+}
+
+// @Summary Send new activation link
+// @Security BearerToken
+// @Description Replaces the activation link of an order that waits for activation and emails it,
+// @Description the stock stays reserved for as long as the new link is valid
+// @Tags order
+// @Accept json
+// @Produce json
+// @Param orderId path string true "Order id"
+// @Router /api/order/activation-link/{orderId} [post]
+// @Success 200 {object} fall.AppErr
+// @Failure 400 {object} fall.AppErr
+// @Failure 401 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *OrderHandler) sendActivationLink(ctx *fiber.Ctx) error {
+	orderId := ctx.Params("orderId")
+
+	user, ex := utils.GetLocalSession(ctx)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	ex = h.service.SendNewActivationLink(ctx.Context(), orderId, user)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+	ok := fall.GetOk()
+	return ctx.Status(ok.Status()).JSON(ok)
+}
+
 // @Summary Confirm online payment
 // @Description Confirm online payment
 // @Tags order
@@ -326,6 +417,7 @@ func (h *OrderHandler) getUserOrders(ctx *fiber.Ctx) error {
 // @Success 201 {object} model.OrderConfirmation
 // @Failure 401 {object} fall.AppErr
 // @Failure 400 {object} fall.ValidationError
+// @Failure 409 {object} fall.StockError
 // @Failure 404 {object} fall.AppErr
 // @Failure 500 {object} fall.AppErr
 func (h *OrderHandler) create(ctx *fiber.Ctx) error {
@@ -374,3 +466,33 @@ func (h *OrderHandler) create(ctx *fiber.Ctx) error {
 
 	return ctx.Status(fall.STATUS_CREATED).JSON(r)
 }
+
+// @Summary YooKassa payment notification
+// @Description Webhook for payment.succeeded, payment.canceled and refund.succeeded events
+// @Tags order
+// @Accept json
+// @Produce json
+// @Param dto body payment.Notification true "Notification body"
+// @Router /api/order/payment/notification [post]
+// @Success 200 {object} fall.AppErr
+// @Failure 400 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *OrderHandler) paymentNotification(ctx *fiber.Ctx) error {
+	n := payment.Notification{}
+
+	err := ctx.BodyParser(&n)
+
+	if err != nil || n.Object.ID == "" {
+		appErr := fall.NewErr(fall.INVALID_BODY, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	ex := h.service.HandlePaymentNotification(ctx.Context(), n)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	resp := fall.GetOk()
+	return ctx.Status(resp.Status()).JSON(resp)
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/keys"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/payment"
+)
+
+type fakePaymentProvider interface {
+	SetOutcome(outcome string) error
+	SetStatus(paymentId string, status string) error
+}
+
+// FakePaymentHandler lets an admin steer the fake gateway, it is only mounted when that gateway is in use.
+type FakePaymentHandler struct {
+	provider       fakePaymentProvider
+	router         fiber.Router
+	authMiddleware middleware.AuthMiddleware
+	roleMiddleware middleware.RoleMiddleware
+}
+
+func NewFakePaymentHandler(provider fakePaymentProvider, router fiber.Router, authMiddleware middleware.AuthMiddleware,
+	roleMiddleware middleware.RoleMiddleware) *FakePaymentHandler {
+	return &FakePaymentHandler{provider: provider, router: router, authMiddleware: authMiddleware, roleMiddleware: roleMiddleware}
+}
+
+func (h *FakePaymentHandler) InitRoutes() {
+	paymentRouter := h.router.Group("payment/fake")
+	{
+		paymentRouter.Post("/outcome", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.setOutcome)
+		paymentRouter.Post("/:paymentId/status", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.setStatus)
+	}
+}
+
+// @Summary Set fake payment outcome
+// @Security BearerToken
+// @Description Status that pending payments of the fake gateway resolve to when they are checked
+// @Tags payment
+// @Accept json
+// @Produce json
+// @Param dto body model.FakePaymentStatusDto true "Outcome"
+// @Router /api/payment/fake/outcome [post]
+// @Success 200 {object} fall.AppErr
+// @Failure 400 {object} fall.ValidationError
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+func (h *FakePaymentHandler) setOutcome(ctx *fiber.Ctx) error {
+	dto := model.FakePaymentStatusDto{}
+
+	err := ctx.BodyParser(&dto)
+	if err != nil {
+		appErr := fall.NewErr(fall.INVALID_BODY, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(&dto)
+	if err != nil {
+		error_messages := err.(validator.ValidationErrors)
+		items := fall.ValidationMessages(error_messages)
+		validError := fall.NewValidErr(items)
+
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	if err = h.provider.SetOutcome(dto.Status); err != nil {
+		ex := fakePaymentError(err)
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	resp := fall.GetOk()
+	return ctx.Status(resp.Status()).JSON(resp)
+}
+
+// @Summary Set fake payment status
+// @Security BearerToken
+// @Description Forces the status of a single payment of the fake gateway
+// @Tags payment
+// @Accept json
+// @Produce json
+// @Param paymentId path string true "Payment id"
+// @Param dto body model.FakePaymentStatusDto true "Status"
+// @Router /api/payment/fake/{paymentId}/status [post]
+// @Success 200 {object} fall.AppErr
+// @Failure 400 {object} fall.ValidationError
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+func (h *FakePaymentHandler) setStatus(ctx *fiber.Ctx) error {
+	dto := model.FakePaymentStatusDto{}
+
+	err := ctx.BodyParser(&dto)
+	if err != nil {
+		appErr := fall.NewErr(fall.INVALID_BODY, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(&dto)
+	if err != nil {
+		error_messages := err.(validator.ValidationErrors)
+		items := fall.ValidationMessages(error_messages)
+		validError := fall.NewValidErr(items)
+
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	if err = h.provider.SetStatus(ctx.Params("paymentId"), dto.Status); err != nil {
+		ex := fakePaymentError(err)
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	resp := fall.GetOk()
+	return ctx.Status(resp.Status()).JSON(resp)
+}
+
+func fakePaymentError(err error) fall.Error {
+	switch {
+	case errors.Is(err, payment.ErrUnknownOutcome):
+		return fall.NewErr(msg.PaymentUnknownOutcome, fall.STATUS_BAD_REQUEST)
+	case errors.Is(err, payment.ErrPaymentNotFound):
+		return fall.NewErr(msg.PaymentNotFound, fall.STATUS_NOT_FOUND)
+	}
+	return fall.ServerError(err.Error())
+}
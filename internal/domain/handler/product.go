@@ -2,19 +2,24 @@ package handler
 
 import (
 	"context"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/generator"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
 )
 
 type productService interface {
 	CreateProduct(ctx context.Context, dto model.CreateProductDto) fall.Error             // +
 	GetProductPage(ctx context.Context, slug string) (*model.ProductRelation, fall.Error) // +
+	FindRedirect(ctx context.Context, slug string) (*model.SlugRedirect, fall.Error)
 	FindProductById(ctx context.Context, id int) (*model.Product, fall.Error)
 	FindProductModelById(ctx context.Context, id int) (*model.ProductModel, fall.Error)
 	CreateModel(ctx context.Context, dto model.CreateProductModelDto) fall.Error                //+
@@ -27,7 +32,7 @@ type productService interface {
 	FindModelsColored(ctx context.Context, id int) ([]model.ProductModelColors, fall.Error)     // +
 	AdminGetProducts(ctx context.Context, page int, brandId *int, categoryId *int) (*model.AdminProductResponse, fall.Error)
 	AdminGetProductModels(ctx context.Context, id int) ([]model.ProductModel, fall.Error)
-	GetCatalogModels(ctx context.Context, query generator.CatalogFilters) (*model.CatalogResponse, fall.Error)
+	GetCatalogModels(ctx context.Context, params generator.CatalogParams) (*model.CatalogResponse, fall.Error)
 	GetModelImages(ctx context.Context, modelId int) ([]model.ProductModelImg, fall.Error)
 	GetModelSizes(ctx context.Context, modelId int) ([]model.ProductModelSize, fall.Error)
 	GetModelOptions(ctx context.Context, modelId int) ([]*model.ProductModelOption, fall.Error)
@@ -39,9 +44,11 @@ type productService interface {
 	GetViewHistory(ctx context.Context, userId int, modelId int) ([]*model.CatalogProductModel, fall.Error)
 	AddToViewHistory(ctx context.Context, userId int, modelId int) fall.Error
 	GetPopularProducts(ctx context.Context, slug string) ([]*model.CatalogProductModel, fall.Error)
-	Search(ctx context.Context, term string) ([]model.SearchProductModel, fall.Error)
+	Search(ctx context.Context, term string, page pagination.Page) (*model.SearchResponse, fall.Error)
 }
 
+const maxSearchTermLength = 100
+
 type ProductHandler struct {
 	service        productService
 	router         fiber.Router
@@ -77,7 +84,7 @@ func (h *ProductHandler) InitRoutes() {
 		productRouter.Get("/catalog/:categorySlug", h.getCatalogModels)
 
 		productRouter.Get("/model/colors/:id", h.findModelsColored)
-		productRouter.Get("/model/page/:slug", h.getProductPage)
+		productRouter.Get("/model/page/:slug", etagMiddleware, h.getProductPage)
 		productRouter.Get("/model/img/:id", h.getProductModelImg)
 		productRouter.Get("/model/sizes/:id", h.getProductModelSizes)
 		productRouter.Get("/model/views/:id", h.getModelViews)
@@ -100,21 +107,34 @@ func (h *ProductHandler) InitRoutes() {
 // @Accept json
 // @Produce json
 // @Param searchTerm query string true "search term"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "page size, 1-100"
 // @Router /api/product/model/search [get]
-// @Success 200 {array} model.SearchProductModel
+// @Success 200 {object} model.SearchResponse
 // @Failure 400 {object} fall.ValidationError
 // @Failure 404 {object} fall.AppErr
 // @Failure 500 {object} fall.AppErr
 func (h *ProductHandler) search(ctx *fiber.Ctx) error {
 
-	searchTerm := ctx.Query("searchTerm")
+	searchTerm := strings.TrimSpace(ctx.Query("searchTerm"))
 
 	if len(searchTerm) == 0 {
-		appErr := fall.NewErr("Пустое значение поиска!", fall.STATUS_BAD_REQUEST)
+		appErr := fall.NewErr(msg.SearchEmptyTerm, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	if utf8.RuneCountInString(searchTerm) > maxSearchTermLength {
+		appErr := fall.NewErr(msg.SearchTermTooLong, fall.STATUS_BAD_REQUEST)
 		return ctx.Status(appErr.Status()).JSON(appErr)
 	}
 
-	models, ex := h.service.Search(ctx.Context(), searchTerm)
+	page, pageErr := pagination.Parse(ctx.Query("cursor"), ctx.Query("limit"), model.SearchSortRelevance, model.SearchSortSimilarity)
+	if pageErr != nil {
+		validError := fall.NewFieldValidErr(pageErr.Key, pageErr.Message)
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	models, ex := h.service.Search(ctx.Context(), searchTerm, *page)
 	if ex != nil {
 		return ctx.Status(ex.Status()).JSON(ex)
 	}
@@ -400,7 +420,9 @@ func (h *ProductHandler) getProductById(ctx *fiber.Ctx) error {
 // @Param sort query string false "sort by"
 // @Param is_sale query string false "get items with sale"
 // @Param price query string false "from - to"
-// @Param page query string false "pagination page"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "page size, 1-100"
+// @Param page query int false "deprecated page number, used only without a cursor"
 // @Router /api/product/catalog/{categorySlug} [get]
 // @Success 200 {object} model.CatalogResponse
 // @Failure 400 {object} fall.ValidationError
@@ -410,58 +432,15 @@ func (h *ProductHandler) getCatalogModels(ctx *fiber.Ctx) error {
 
 	slug := ctx.Params("categorySlug")
 
-	query := ctx.Queries()
-
-	delete(query, "categorySlug")
-
-	sizes, ok := query["size"]
-
-	if ok {
-		delete(query, "size")
-	}
-
-	brands, ok := query["brands"]
-
-	if ok {
-		delete(query, "brands")
-	}
+	filters := generator.CatalogFiltersFromQuery(slug, ctx.Queries())
 
-	sortBy, ok := query["sort"]
-
-	if ok {
-		delete(query, "sort")
-	}
-
-	onlyWithDiscount, ok := query["is_sale"]
-
-	if ok {
-		delete(query, "is_sale")
-	}
-
-	price, ok := query["price"]
-
-	if ok {
-		delete(query, "price")
-	}
-
-	page, ok := query["page"]
-
-	if ok {
-		delete(query, "page")
-	}
-
-	filters := generator.CatalogFilters{
-		Options:          query,
-		Slug:             slug,
-		Sizes:            sizes,
-		Brands:           brands,
-		SortBy:           sortBy,
-		OnlyWithDiscount: onlyWithDiscount,
-		Price:            price,
-		Page:             page,
+	params, filterErr := generator.ParseCatalogFilters(filters)
+	if filterErr != nil {
+		validError := fall.NewFieldValidErr(filterErr.Key, filterErr.Message)
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
 	}
 
-	res, ex := h.service.GetCatalogModels(ctx.Context(), filters)
+	res, ex := h.service.GetCatalogModels(ctx.Context(), *params)
 	if ex != nil {
 		return ctx.Status(ex.Status()).JSON(ex)
 	}
@@ -880,6 +859,7 @@ func (h *ProductHandler) removePhoto(ctx *fiber.Ctx) error {
 // @Param slug path string true "Product Model Slug"
 // @Router /api/product/model/page/{slug} [get]
 // @Success 200 {object} model.ProductRelation
+// @Success 301 {object} model.SlugRedirect
 // @Failure 400 {object} fall.ValidationError
 // @Failure 404 {object} fall.AppErr
 // @Failure 500 {object} fall.AppErr
@@ -890,7 +870,9 @@ func (h *ProductHandler) getProductPage(ctx *fiber.Ctx) error {
 	page, ex := h.service.GetProductPage(ctx.Context(), slug)
 
 	if ex != nil {
-		return ctx.Status(ex.Status()).JSON(ex)
+		return lookupError(ctx, ex, func() (*model.SlugRedirect, fall.Error) {
+			return h.service.FindRedirect(ctx.Context(), slug)
+		})
 	}
 
 	return ctx.Status(fall.STATUS_OK).JSON(page)
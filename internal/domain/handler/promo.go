@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/keys"
+)
+
+type promoService interface {
+	Create(ctx context.Context, dto model.CreatePromoCodeDto) fall.Error
+	Update(ctx context.Context, dto model.UpdatePromoCodeDto, id int) fall.Error
+	FindById(ctx context.Context, id int) (*model.PromoCode, fall.Error)
+	GetAll(ctx context.Context) ([]model.PromoCode, fall.Error)
+	Delete(ctx context.Context, id int) fall.Error
+}
+
+type PromoHandler struct {
+	service        promoService
+	router         fiber.Router
+	authMiddleware middleware.AuthMiddleware
+	roleMiddleware middleware.RoleMiddleware
+}
+
+func NewPromoHandler(service promoService, router fiber.Router, authMiddleware middleware.AuthMiddleware,
+	roleMiddleware middleware.RoleMiddleware) *PromoHandler {
+	return &PromoHandler{service: service, router: router, authMiddleware: authMiddleware, roleMiddleware: roleMiddleware}
+}
+
+func (h *PromoHandler) InitRoutes() {
+	promoRouter := h.router.Group("promo")
+	{
+		promoRouter.Post("/", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.create)
+		promoRouter.Get("/", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.getAll)
+		promoRouter.Get("/:id", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.getById)
+		promoRouter.Patch("/:id", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.update)
+		promoRouter.Delete("/:id", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.delete)
+	}
+}
+
+// @Summary Create promo code
+// @Security BearerToken
+// @Description Create promo code
+// @Tags promo
+// @Accept json
+// @Produce json
+// @Param dto body model.CreatePromoCodeDto true "Create promo code with body dto"
+// @Router /api/promo/ [post]
+// @Success 201 {object} fall.AppErr
+// @Failure 400 {object} fall.ValidationError
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *PromoHandler) create(ctx *fiber.Ctx) error {
+	dto := model.CreatePromoCodeDto{}
+
+	err := ctx.BodyParser(&dto)
+
+	if err != nil {
+		appErr := fall.NewErr(fall.INVALID_BODY, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	validate := validator.New()
+
+	validate.RegisterValidation("promoDiscountTypeEnumValidation", model.PromoDiscountTypeEnumValidation)
+
+	err = validate.Struct(&dto)
+
+	if err != nil {
+		error_messages := err.(validator.ValidationErrors)
+		items := fall.ValidationMessages(error_messages)
+		validError := fall.NewValidErr(items)
+
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	ex := h.service.Create(ctx.Context(), dto)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	resp := fall.GetCreated()
+	return ctx.Status(resp.Status()).JSON(resp)
+}
+
+// @Summary Get all promo codes
+// @Security BearerToken
+// @Description Get all promo codes
+// @Tags promo
+// @Accept json
+// @Produce json
+// @Router /api/promo/ [get]
+// @Success 200 {array} model.PromoCode
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *PromoHandler) getAll(ctx *fiber.Ctx) error {
+	codes, ex := h.service.GetAll(ctx.Context())
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(codes)
+}
+
+// @Summary Get promo code by id
+// @Security BearerToken
+// @Description Get promo code by id
+// @Tags promo
+// @Accept json
+// @Produce json
+// @Param id path int true "Promo code id"
+// @Router /api/promo/{id} [get]
+// @Success 200 {object} model.PromoCode
+// @Failure 400 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *PromoHandler) getById(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+
+	if err != nil {
+		appErr := fall.NewErr(fall.VALIDATION_ID, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	code, ex := h.service.FindById(ctx.Context(), id)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(code)
+}
+
+// @Summary Update promo code
+// @Security BearerToken
+// @Description Update promo code
+// @Tags promo
+// @Accept json
+// @Produce json
+// @Param id path int true "Promo code id"
+// @Param dto body model.UpdatePromoCodeDto true "Update promo code with body dto"
+// @Router /api/promo/{id} [patch]
+// @Success 200 {object} fall.AppErr
+// @Failure 400 {object} fall.ValidationError
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *PromoHandler) update(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+
+	if err != nil {
+		appErr := fall.NewErr(fall.VALIDATION_ID, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	dto := model.UpdatePromoCodeDto{}
+
+	err = ctx.BodyParser(&dto)
+
+	if err != nil {
+		appErr := fall.NewErr(fall.INVALID_BODY, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	validate := validator.New()
+
+	validate.RegisterValidation("promoDiscountTypeEnumValidation", model.PromoDiscountTypeEnumValidation)
+
+	err = validate.Struct(&dto)
+
+	if err != nil {
+		error_messages := err.(validator.ValidationErrors)
+		items := fall.ValidationMessages(error_messages)
+		validError := fall.NewValidErr(items)
+
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	ex := h.service.Update(ctx.Context(), dto, id)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	resp := fall.GetOk()
+	return ctx.Status(resp.Status()).JSON(resp)
+}
+
+// @Summary Delete promo code
+// @Security BearerToken
+// @Description Delete promo code
+// @Tags promo
+// @Accept json
+// @Produce json
+// @Param id path int true "Promo code id"
+// @Router /api/promo/{id} [delete]
+// @Success 200 {object} fall.AppErr
+// @Failure 400 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *PromoHandler) delete(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+
+	if err != nil {
+		appErr := fall.NewErr(fall.VALIDATION_ID, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	ex := h.service.Delete(ctx.Context(), id)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	resp := fall.GetOk()
+	return ctx.Status(resp.Status()).JSON(resp)
+}
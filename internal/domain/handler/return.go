@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/keys"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
+)
+
+type returnService interface {
+	Create(ctx context.Context, dto model.CreateOrderReturnDto, orderId string, userId int) (*int, fall.Error)
+	Approve(ctx context.Context, id int, comment *string) fall.Error
+	Reject(ctx context.Context, id int, comment *string) fall.Error
+	FindById(ctx context.Context, id int) (*model.OrderReturn, fall.Error)
+	GetAll(ctx context.Context, status *model.OrderReturnStatus) ([]model.OrderReturn, fall.Error)
+}
+
+type ReturnHandler struct {
+	service        returnService
+	router         fiber.Router
+	authMiddleware middleware.AuthMiddleware
+	roleMiddleware middleware.RoleMiddleware
+}
+
+func NewReturnHandler(service returnService, router fiber.Router, authMiddleware middleware.AuthMiddleware,
+	roleMiddleware middleware.RoleMiddleware) *ReturnHandler {
+	return &ReturnHandler{service: service, router: router, authMiddleware: authMiddleware, roleMiddleware: roleMiddleware}
+}
+
+func (h *ReturnHandler) InitRoutes() {
+	returnRouter := h.router.Group("return")
+	{
+		returnRouter.Post("/order/:orderId", h.authMiddleware, h.create)
+		returnRouter.Get("/admin", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.getAll)
+		returnRouter.Get("/admin/:id", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.getById)
+		returnRouter.Patch("/admin/:id/approve", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.approve)
+		returnRouter.Patch("/admin/:id/reject", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.reject)
+	}
+}
+
+// @Summary Create return request
+// @Security BearerToken
+// @Description Create return request for order items
+// @Tags return
+// @Accept json
+// @Produce json
+// @Param orderId path string true "Order id"
+// @Param dto body model.CreateOrderReturnDto true "Create return with body dto"
+// @Router /api/return/order/{orderId} [post]
+// @Success 201 {object} model.OrderReturn
+// @Failure 400 {object} fall.ValidationError
+// @Failure 401 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *ReturnHandler) create(ctx *fiber.Ctx) error {
+	orderId := ctx.Params("orderId")
+
+	user, ex := utils.GetLocalSession(ctx)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	dto := model.CreateOrderReturnDto{}
+
+	err := ctx.BodyParser(&dto)
+
+	if err != nil {
+		appErr := fall.NewErr(fall.INVALID_BODY, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(&dto)
+
+	if err != nil {
+		error_messages := err.(validator.ValidationErrors)
+		items := fall.ValidationMessages(error_messages)
+		validError := fall.NewValidErr(items)
+
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	id, ex := h.service.Create(ctx.Context(), dto, orderId, user.UserId)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	orderReturn, ex := h.service.FindById(ctx.Context(), *id)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_CREATED).JSON(orderReturn)
+}
+
+// @Summary Get all return requests
+// @Security BearerToken
+// @Description Get all return requests
+// @Tags return
+// @Accept json
+// @Produce json
+// @Param status query string false "Return status"
+// @Router /api/return/admin [get]
+// @Success 200 {array} model.OrderReturn
+// @Failure 400 {object} fall.AppErr
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *ReturnHandler) getAll(ctx *fiber.Ctx) error {
+	var status *model.OrderReturnStatus
+
+	if s := ctx.Query("status"); s != "" {
+		validate := validator.New()
+
+		validate.RegisterValidation("orderReturnStatusEnumValidation", model.OrderReturnStatusEnumValidation)
+
+		err := validate.Var(s, "orderReturnStatusEnumValidation")
+
+		if err != nil {
+			ex := fall.NewErr(err.Error(), fall.STATUS_BAD_REQUEST)
+			return ctx.Status(ex.Status()).JSON(ex)
+		}
+
+		v := model.OrderReturnStatus(s)
+		status = &v
+	}
+
+	returns, ex := h.service.GetAll(ctx.Context(), status)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(returns)
+}
+
+// @Summary Get return request by id
+// @Security BearerToken
+// @Description Get return request by id
+// @Tags return
+// @Accept json
+// @Produce json
+// @Param id path int true "Return id"
+// @Router /api/return/admin/{id} [get]
+// @Success 200 {object} model.OrderReturn
+// @Failure 400 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *ReturnHandler) getById(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+
+	if err != nil {
+		appErr := fall.NewErr(fall.VALIDATION_ID, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	orderReturn, ex := h.service.FindById(ctx.Context(), id)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(orderReturn)
+}
+
+// @Summary Approve return request
+// @Security BearerToken
+// @Description Approve return request, restock returned items and refund the amount
+// @Tags return
+// @Accept json
+// @Produce json
+// @Param id path int true "Return id"
+// @Param dto body model.ProcessOrderReturnDto true "Admin comment"
+// @Router /api/return/admin/{id}/approve [patch]
+// @Success 200 {object} fall.AppErr
+// @Failure 400 {object} fall.ValidationError
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *ReturnHandler) approve(ctx *fiber.Ctx) error {
+	return h.process(ctx, h.service.Approve)
+}
+
+// @Summary Reject return request
+// @Security BearerToken
+// @Description Reject return request
+// @Tags return
+// @Accept json
+// @Produce json
+// @Param id path int true "Return id"
+// @Param dto body model.ProcessOrderReturnDto true "Admin comment"
+// @Router /api/return/admin/{id}/reject [patch]
+// @Success 200 {object} fall.AppErr
+// @Failure 400 {object} fall.ValidationError
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *ReturnHandler) reject(ctx *fiber.Ctx) error {
+	return h.process(ctx, h.service.Reject)
+}
+
+func (h *ReturnHandler) process(ctx *fiber.Ctx, action func(ctx context.Context, id int, comment *string) fall.Error) error {
+	id, err := ctx.ParamsInt("id")
+
+	if err != nil {
+		appErr := fall.NewErr(fall.VALIDATION_ID, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	dto := model.ProcessOrderReturnDto{}
+
+	if len(ctx.Body()) > 0 {
+		err = ctx.BodyParser(&dto)
+
+		if err != nil {
+			appErr := fall.NewErr(fall.INVALID_BODY, fall.STATUS_BAD_REQUEST)
+			return ctx.Status(appErr.Status()).JSON(appErr)
+		}
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(&dto)
+
+	if err != nil {
+		error_messages := err.(validator.ValidationErrors)
+		items := fall.ValidationMessages(error_messages)
+		validError := fall.NewValidErr(items)
+
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	ex := action(ctx.Context(), id, dto.Comment)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	resp := fall.GetOk()
+	return ctx.Status(resp.Status()).JSON(resp)
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type searchService interface {
+	Suggest(ctx context.Context, term string) (*model.Suggestions, fall.Error)
+}
+
+type SearchHandler struct {
+	service searchService
+	router  fiber.Router
+}
+
+func NewSearchHandler(service searchService, router fiber.Router) *SearchHandler {
+	return &SearchHandler{service: service, router: router}
+}
+
+func (h *SearchHandler) InitRoutes() {
+	searchRouter := h.router.Group("search")
+	{
+		searchRouter.Get("/suggest", h.suggest)
+	}
+}
+
+// @Summary Search suggestions
+// @Description Brands, categories, products and popular queries for the search box
+// @Tags search
+// @Accept json
+// @Produce json
+// @Param term query string true "typed prefix"
+// @Router /api/search/suggest [get]
+// @Success 200 {object} model.Suggestions
+// @Failure 400 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *SearchHandler) suggest(ctx *fiber.Ctx) error {
+	term := strings.TrimSpace(ctx.Query("term"))
+
+	if len(term) == 0 {
+		appErr := fall.NewErr(msg.SearchEmptyTerm, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	if utf8.RuneCountInString(term) > maxSearchTermLength {
+		appErr := fall.NewErr(msg.SearchTermTooLong, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	suggestions, ex := h.service.Suggest(ctx.Context(), term)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(suggestions)
+}
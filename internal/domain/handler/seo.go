@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/keys"
+)
+
+type seoService interface {
+	GetMeta(ctx context.Context, entity string, slug string) (*model.SeoMeta, fall.Error)
+	Update(ctx context.Context, entity string, id int, dto model.UpdateSeoDto) fall.Error
+	Generate(ctx context.Context) (*model.SitemapGeneration, fall.Error)
+	GetSitemap(ctx context.Context) (*model.SitemapGeneration, fall.Error)
+	GetPath(ctx context.Context, name string) (string, fall.Error)
+}
+
+type SeoHandler struct {
+	service        seoService
+	router         fiber.Router
+	authMiddleware middleware.AuthMiddleware
+	roleMiddleware middleware.RoleMiddleware
+}
+
+func NewSeoHandler(service seoService, router fiber.Router, authMiddleware middleware.AuthMiddleware,
+	roleMiddleware middleware.RoleMiddleware) *SeoHandler {
+	return &SeoHandler{service: service, router: router, authMiddleware: authMiddleware, roleMiddleware: roleMiddleware}
+}
+
+func (h *SeoHandler) InitRoutes() {
+	seoRouter := h.router.Group("seo")
+	{
+		seoRouter.Get("/sitemap", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.getSitemap)
+		seoRouter.Post("/sitemap/generate", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.generateSitemap)
+		seoRouter.Patch("/:entity/:id", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.update)
+		seoRouter.Get("/:entity/:slug", h.getMeta)
+	}
+	h.router.Get("/sitemap/:name", h.getSitemapFile)
+}
+
+// @Summary Get page meta tags
+// @Description Meta title, description and canonical url of a category, brand or model page
+// @Tags seo
+// @Accept json
+// @Produce json
+// @Param entity path string true "category, brand or model"
+// @Param slug path string true "Page slug"
+// @Router /api/seo/{entity}/{slug} [get]
+// @Success 200 {object} model.SeoMeta
+// @Failure 400 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *SeoHandler) getMeta(ctx *fiber.Ctx) error {
+	meta, ex := h.service.GetMeta(ctx.Context(), ctx.Params("entity"), ctx.Params("slug"))
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(meta)
+}
+
+// @Summary Update page meta tags
+// @Security BearerToken
+// @Description Set meta title, description and canonical of a category, brand, product or model, an empty string restores the default
+// @Tags seo
+// @Accept json
+// @Produce json
+// @Param dto body model.UpdateSeoDto true "Update seo with body dto"
+// @Param entity path string true "category, brand, product or model"
+// @Param id path int true "Entity id"
+// @Router /api/seo/{entity}/{id} [patch]
+// @Success 200 {object} fall.AppErr
+// @Failure 400 {object} fall.ValidationError
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *SeoHandler) update(ctx *fiber.Ctx) error {
+
+	id, err := ctx.ParamsInt("id")
+
+	if err != nil {
+		appErr := fall.NewErr(fall.VALIDATION_ID, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	dto := model.UpdateSeoDto{}
+
+	err = ctx.BodyParser(&dto)
+
+	if err != nil {
+		appErr := fall.NewErr(fall.INVALID_BODY, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(&dto)
+
+	if err != nil {
+		error_messages := err.(validator.ValidationErrors)
+		items := fall.ValidationMessages(error_messages)
+		validError := fall.NewValidErr(items)
+
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	ex := h.service.Update(ctx.Context(), ctx.Params("entity"), id, dto)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	resp := fall.GetOk()
+	return ctx.Status(resp.Status()).JSON(resp)
+}
+
+// @Summary Get sitemap
+// @Description Redirects to the sitemap index sitemap.xml or to one of the sitemaps it lists
+// @Tags seo
+// @Param name path string true "sitemap.xml or sitemap-N.xml"
+// @Router /api/sitemap/{name} [get]
+// @Success 302
+// @Failure 404 {object} fall.AppErr
+func (h *SeoHandler) getSitemapFile(ctx *fiber.Ctx) error {
+	path, ex := h.service.GetPath(ctx.Context(), ctx.Params("name"))
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Redirect(path, fall.STATUS_REDIRECT_TEMP)
+}
+
+// @Summary Get sitemap status
+// @Security BearerToken
+// @Description Last generated sitemap
+// @Tags seo
+// @Accept json
+// @Produce json
+// @Router /api/seo/sitemap [get]
+// @Success 200 {object} model.SitemapGeneration
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+func (h *SeoHandler) getSitemap(ctx *fiber.Ctx) error {
+	generation, ex := h.service.GetSitemap(ctx.Context())
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(generation)
+}
+
+// @Summary Generate sitemap
+// @Security BearerToken
+// @Description Generate the sitemap now instead of waiting for the scheduler
+// @Tags seo
+// @Accept json
+// @Produce json
+// @Router /api/seo/sitemap/generate [post]
+// @Success 200 {object} model.SitemapGeneration
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 409 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *SeoHandler) generateSitemap(ctx *fiber.Ctx) error {
+	generation, ex := h.service.Generate(ctx.Context())
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(generation)
+}
@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+// lookupError answers a lookup by slug that failed: a retired slug gets 301 with the current one,
+// anything else is sent as it is.
+func lookupError(ctx *fiber.Ctx, ex fall.Error, findRedirect func() (*model.SlugRedirect, fall.Error)) error {
+	if ex.Status() == fall.STATUS_NOT_FOUND {
+		if redirect, redirectEx := findRedirect(); redirectEx == nil {
+			return ctx.Status(fall.STATUS_REDIRECT_PERM).JSON(redirect)
+		}
+	}
+	return ctx.Status(ex.Status()).JSON(ex)
+}
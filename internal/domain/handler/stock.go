@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/keys"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
+)
+
+type stockService interface {
+	GetHistory(ctx context.Context, modelSizeId int, page pagination.Page) (*model.StockHistory, fall.Error)
+	Adjust(ctx context.Context, userId int, modelSizeId int, dto model.StockAdjustmentDto) fall.Error
+	StockTake(ctx context.Context, userId int, modelSizeId int, dto model.StockTakeDto) fall.Error
+	Reconcile(ctx context.Context, dryRun bool, userId int) (*model.StockReconcileReport, fall.Error)
+}
+
+type StockHandler struct {
+	service        stockService
+	router         fiber.Router
+	authMiddleware middleware.AuthMiddleware
+	roleMiddleware middleware.RoleMiddleware
+}
+
+func NewStockHandler(service stockService, router fiber.Router, authMiddleware middleware.AuthMiddleware,
+	roleMiddleware middleware.RoleMiddleware) *StockHandler {
+	return &StockHandler{service: service, router: router, authMiddleware: authMiddleware, roleMiddleware: roleMiddleware}
+}
+
+func (h *StockHandler) InitRoutes() {
+	stockRouter := h.router.Group("stock")
+	{
+		stockRouter.Get("/admin/reconcile", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.reconcile(true))
+		stockRouter.Post("/admin/reconcile", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.reconcile(false))
+		stockRouter.Get("/admin/:modelSizeId", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.getHistory)
+		stockRouter.Post("/admin/:modelSizeId/adjust", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.adjust)
+		stockRouter.Post("/admin/:modelSizeId/stocktake", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.stockTake)
+	}
+}
+
+// @Summary Get stock movements
+// @Security BearerToken
+// @Description Movement history of a model size, newest first: sales, cancellations, returns, adjustments, imports and stocktakes
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param modelSizeId path int true "Model size id"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "page size, 1-100"
+// @Router /api/stock/admin/{modelSizeId} [get]
+// @Success 200 {object} model.StockHistory
+// @Failure 400 {object} fall.ValidationError
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *StockHandler) getHistory(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("modelSizeId")
+	if err != nil {
+		appErr := fall.NewErr(fall.VALIDATION_ID, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	page, pageErr := pagination.Parse(ctx.Query("cursor"), ctx.Query("limit"), model.StockMovementsSort)
+	if pageErr != nil {
+		validError := fall.NewFieldValidErr(pageErr.Key, pageErr.Message)
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	history, ex := h.service.GetHistory(ctx.Context(), id, *page)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	return ctx.Status(fall.STATUS_OK).JSON(history)
+}
+
+// @Summary Adjust stock
+// @Security BearerToken
+// @Description Adds a signed quantity to the stock of a model size, the stock can't become negative
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param modelSizeId path int true "Model size id"
+// @Param dto body model.StockAdjustmentDto true "Adjustment"
+// @Router /api/stock/admin/{modelSizeId}/adjust [post]
+// @Success 200 {object} fall.AppErr
+// @Failure 400 {object} fall.ValidationError
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *StockHandler) adjust(ctx *fiber.Ctx) error {
+	session, ex := utils.GetLocalSession(ctx)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	id, err := ctx.ParamsInt("modelSizeId")
+	if err != nil {
+		appErr := fall.NewErr(fall.VALIDATION_ID, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	dto := model.StockAdjustmentDto{}
+
+	err = ctx.BodyParser(&dto)
+	if err != nil {
+		appErr := fall.NewErr(fall.INVALID_BODY, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(&dto)
+	if err != nil {
+		error_messages := err.(validator.ValidationErrors)
+		items := fall.ValidationMessages(error_messages)
+		validError := fall.NewValidErr(items)
+
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	ex = h.service.Adjust(ctx.Context(), session.UserId, id, dto)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	resp := fall.GetOk()
+	return ctx.Status(resp.Status()).JSON(resp)
+}
+
+// @Summary Stocktake
+// @Security BearerToken
+// @Description Sets the stock of a model size from a count, units of orders that aren't on the way yet are taken out of it
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param modelSizeId path int true "Model size id"
+// @Param dto body model.StockTakeDto true "Counted quantity"
+// @Router /api/stock/admin/{modelSizeId}/stocktake [post]
+// @Success 200 {object} fall.AppErr
+// @Failure 400 {object} fall.ValidationError
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *StockHandler) stockTake(ctx *fiber.Ctx) error {
+	session, ex := utils.GetLocalSession(ctx)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	id, err := ctx.ParamsInt("modelSizeId")
+	if err != nil {
+		appErr := fall.NewErr(fall.VALIDATION_ID, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	dto := model.StockTakeDto{}
+
+	err = ctx.BodyParser(&dto)
+	if err != nil {
+		appErr := fall.NewErr(fall.INVALID_BODY, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	validate := validator.New()
+
+	err = validate.Struct(&dto)
+	if err != nil {
+		error_messages := err.(validator.ValidationErrors)
+		items := fall.ValidationMessages(error_messages)
+		validError := fall.NewValidErr(items)
+
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
+
+	ex = h.service.StockTake(ctx.Context(), session.UserId, id, dto)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	resp := fall.GetOk()
+	return ctx.Status(resp.Status()).JSON(resp)
+}
+
+// @Summary Reconcile stock with the ledger
+// @Security BearerToken
+// @Description Model sizes whose stock differs from the sum of their movements. GET only reports them,
+// @Description POST adds a stocktake movement for each difference, the stock itself is not changed
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Router /api/stock/admin/reconcile [get]
+// @Router /api/stock/admin/reconcile [post]
+// @Success 200 {object} model.StockReconcileReport
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *StockHandler) reconcile(dryRun bool) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		session, ex := utils.GetLocalSession(ctx)
+		if ex != nil {
+			return ctx.Status(ex.Status()).JSON(ex)
+		}
+
+		report, ex := h.service.Reconcile(ctx.Context(), dryRun, session.UserId)
+		if ex != nil {
+			return ctx.Status(ex.Status()).JSON(ex)
+		}
+		return ctx.Status(fall.STATUS_OK).JSON(report)
+	}
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/middleware"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/keys"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/upload"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
+)
+
+type uploadSessionService interface {
+	Create(ctx context.Context, userId int, dto model.CreateUploadSessionDto) (*model.UploadTicket, fall.Error)
+	Confirm(ctx context.Context, userId int, id string) (*model.UploadResponse, fall.Error)
+}
+
+type UploadSessionHandler struct {
+	service        uploadSessionService
+	router         fiber.Router
+	authMiddleware middleware.AuthMiddleware
+	roleMiddleware middleware.RoleMiddleware
+}
+
+func NewUploadSessionHandler(service uploadSessionService, router fiber.Router, authMiddleware middleware.AuthMiddleware,
+	roleMiddleware middleware.RoleMiddleware) *UploadSessionHandler {
+	return &UploadSessionHandler{service: service, router: router, authMiddleware: authMiddleware, roleMiddleware: roleMiddleware}
+}
+
+func (h *UploadSessionHandler) InitRoutes() {
+	uploadRouter := h.router.Group("upload-session")
+	{
+		uploadRouter.Post("/", h.authMiddleware, h.create(false))
+		uploadRouter.Post("/admin", h.authMiddleware, h.roleMiddleware(keys.ADMIN_ROLE), h.create(true))
+		uploadRouter.Post("/:id/confirm", h.authMiddleware, h.confirm)
+	}
+}
+
+// @Summary Start direct upload
+// @Security BearerToken
+// @Description Returns a short-lived url to PUT the file to the storage directly, then the session has to be confirmed.
+// @Description The PUT has to send the returned content_type as Content-Type, the file is kept private until confirmed.
+// @Description Avatar is available to everyone, product, brand and action at /api/upload-session/admin only
+// @Tags upload-session
+// @Accept json
+// @Produce json
+// @Param dto body model.CreateUploadSessionDto true "Upload session data"
+// @Router /api/upload-session/ [post]
+// @Router /api/upload-session/admin [post]
+// @Success 201 {object} model.UploadTicket
+// @Failure 400 {object} fall.ValidationError
+// @Failure 401 {object} fall.AppErr
+// @Failure 403 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *UploadSessionHandler) create(admin bool) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		session, ex := utils.GetLocalSession(ctx)
+		if ex != nil {
+			return ctx.Status(ex.Status()).JSON(ex)
+		}
+
+		dto := model.CreateUploadSessionDto{}
+
+		err := ctx.BodyParser(&dto)
+		if err != nil {
+			appErr := fall.NewErr(fall.INVALID_BODY, fall.STATUS_BAD_REQUEST)
+			return ctx.Status(appErr.Status()).JSON(appErr)
+		}
+
+		validate := validator.New()
+
+		err = validate.Struct(&dto)
+		if err != nil {
+			error_messages := err.(validator.ValidationErrors)
+			items := fall.ValidationMessages(error_messages)
+			validError := fall.NewValidErr(items)
+
+			return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+		}
+
+		if upload.Policies[dto.Purpose].AdminOnly && !admin {
+			appErr := fall.NewErr(msg.FileAdminPurpose, fall.STATUS_FORBIDDEN)
+			return ctx.Status(appErr.Status()).JSON(appErr)
+		}
+
+		ticket, ex := h.service.Create(ctx.Context(), session.UserId, dto)
+		if ex != nil {
+			return ctx.Status(ex.Status()).JSON(ex)
+		}
+		return ctx.Status(fall.STATUS_CREATED).JSON(ticket)
+	}
+}
+
+// @Summary Confirm direct upload
+// @Security BearerToken
+// @Description Checks the file put by the url of the session and attaches it to the product model, brand, action or user
+// @Tags upload-session
+// @Accept json
+// @Produce json
+// @Param id path string true "Upload session id"
+// @Router /api/upload-session/{id}/confirm [post]
+// @Success 200 {object} model.UploadResponse
+// @Failure 400 {object} fall.AppErr
+// @Failure 401 {object} fall.AppErr
+// @Failure 404 {object} fall.AppErr
+// @Failure 409 {object} fall.AppErr
+// @Failure 500 {object} fall.AppErr
+func (h *UploadSessionHandler) confirm(ctx *fiber.Ctx) error {
+	session, ex := utils.GetLocalSession(ctx)
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+
+	id, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		appErr := fall.NewErr(fall.VALIDATION_ID, fall.STATUS_BAD_REQUEST)
+		return ctx.Status(appErr.Status()).JSON(appErr)
+	}
+
+	res, ex := h.service.Confirm(ctx.Context(), session.UserId, id.String())
+	if ex != nil {
+		return ctx.Status(ex.Status()).JSON(ex)
+	}
+	return ctx.Status(fall.STATUS_OK).JSON(res)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/jwt"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
 )
 
@@ -20,7 +21,7 @@ type userService interface {
 	ConfirmChangePassword(ctx context.Context, code string, userId int) fall.Error
 	ChangePassword(ctx context.Context, dto model.ChangePasswordDto, localSession model.LocalSession) (*jwt.Tokens, fall.Error)
 	GetUserSessions(ctx context.Context, userId int, agent string) (*model.UserSessionsResponse, fall.Error)
-	GetAll(ctx context.Context, page int) (*model.GetAllUsersResponse, fall.Error)
+	GetAll(ctx context.Context, page pagination.Page) (*model.GetAllUsersResponse, fall.Error)
 
 	RemoveAllSessions(ctx context.Context, userId int) fall.Error
 	RemoveSession(ctx context.Context, userId int, sessionId int) fall.Error
@@ -65,16 +66,25 @@ func (h *UserHandler) InitRoutes() {
 // @Accept json
 // @Produce json
 // @Router /api/user/all [get]
-// @Param page query int false "Page"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "page size, 1-100"
+// @Param page query int false "deprecated page number, used only without a cursor"
 // @Success 200 {object} model.GetAllUsersResponse
 // @Failure 400 {object} fall.ValidationError
 // @Failure 404 {object} fall.AppErr
 // @Failure 500 {object} fall.AppErr
 func (h *UserHandler) getAll(ctx *fiber.Ctx) error {
 
-	page := ctx.QueryInt("page", 1)
+	page, pageErr := pagination.Parse(ctx.Query("cursor"), ctx.Query("limit"), model.UsersSort)
+	if pageErr == nil {
+		pageErr = page.Number(ctx.Query("page"))
+	}
+	if pageErr != nil {
+		validError := fall.NewFieldValidErr(pageErr.Key, pageErr.Message)
+		return ctx.Status(fall.STATUS_BAD_REQUEST).JSON(validError)
+	}
 
-	users, err := h.service.GetAll(ctx.Context(), page)
+	users, err := h.service.GetAll(ctx.Context(), *page)
 	if err != nil {
 		return ctx.Status(err.Status()).JSON(err)
 	}
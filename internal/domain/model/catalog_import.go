@@ -0,0 +1,74 @@
+package model
+
+// CatalogColumns is the header of an import file and of an export, in export order.
+var CatalogColumns = []string{"product", "description", "brand", "category", "article", "price", "discount",
+	"main_image", "images", "sizes", "options"}
+
+const (
+	CatalogListSeparator   = "|"
+	CatalogSizeSeparator   = ":"
+	CatalogOptionSeparator = "="
+)
+
+const CatalogImportBatchSize = 100
+
+type CatalogRowSize struct {
+	Value   string `json:"value"`
+	Literal string `json:"literal"`
+	InStock int    `json:"in_stock"`
+}
+
+type CatalogRowOption struct {
+	Option     string `json:"option"`
+	OptionSlug string `json:"-"`
+	Value      string `json:"value"`
+}
+
+// CatalogRow is one model of an import file. Line is its line in the file, the header being line 1.
+// BrandSlug and TitleSlug are filled by the service, they are used for new brands and model slugs.
+// KeepPrice leaves price and discount of an existing model as they are, an empty MainImage keeps its image.
+type CatalogRow struct {
+	Line        int
+	Product     string
+	Description *string
+	Brand       string
+	BrandSlug   string
+	TitleSlug   string
+	Category    string
+	Article     string
+	Price       int
+	Discount    *byte
+	MainImage   string
+	Images      []string
+	Sizes       []CatalogRowSize
+	Options     []CatalogRowOption
+	KeepPrice   bool
+}
+
+type CatalogImportError struct {
+	Row     int    `json:"row" example:"2" validate:"required"`
+	Column  string `json:"column" example:"price" validate:"required"`
+	Message string `json:"message" validate:"required"`
+}
+
+type CatalogImportCounts struct {
+	ProductsCreated int `json:"products_created" validate:"required"`
+	ModelsCreated   int `json:"models_created" validate:"required"`
+	ModelsUpdated   int `json:"models_updated" validate:"required"`
+	BrandsCreated   int `json:"brands_created" validate:"required"`
+	OptionsCreated  int `json:"options_created" validate:"required"`
+	ValuesCreated   int `json:"values_created" validate:"required"`
+	SizesCreated    int `json:"sizes_created" validate:"required"`
+}
+
+// CatalogImportReport describes an import. For a dry run the counts are what a real run would write,
+// Imported is the number of rows that were committed.
+type CatalogImportReport struct {
+	CatalogImportCounts
+	// ImportId is the reference of the stock movements the import made.
+	ImportId string               `json:"import_id" validate:"required"`
+	DryRun   bool                 `json:"dry_run" validate:"required"`
+	Rows     int                  `json:"rows" example:"120" validate:"required"`
+	Imported int                  `json:"imported" example:"120" validate:"required"`
+	Errors   []CatalogImportError `json:"errors" validate:"required"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// ExchangeOffer is a price and stock update from 1C for one size of a model, nil fields are left as they are.
+// A model without sizes in 1C gets its price only.
+type ExchangeOffer struct {
+	Article string
+	Price   *int
+	Size    *string
+	Stock   *int
+}
+
+// ExchangeReport describes one imported 1C file, skipped entries are listed in Errors.
+type ExchangeReport struct {
+	ImportId  string
+	Processed int
+	Skipped   int
+	Errors    []string
+}
+
+type ExchangeOrderItem struct {
+	Article  string
+	Title    string
+	Size     string
+	Quantity int
+	Price    int
+	Discount *byte
+}
+
+type ExchangeOrder struct {
+	Id                 string
+	CreatedAt          time.Time
+	Status             OrderStatusEnum
+	PaymentMethod      PaymentMethodEnum
+	Conditions         OrderConditions
+	TotalPrice         float64
+	PromoDiscount      int
+	DeliveryPrice      int
+	UserId             int
+	UserEmail          string
+	RecipientFirstname string
+	RecipientLastname  string
+	RecipientPhone     string
+	Items              []ExchangeOrderItem
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	FeedYandex    = "yandex.yml"
+	FeedGoogleXML = "google.xml"
+	FeedGoogleTSV = "google.tsv"
+)
+
+var Feeds = []string{FeedYandex, FeedGoogleXML, FeedGoogleTSV}
+
+type FeedStatus string
+
+const (
+	FeedRunning   FeedStatus = "running"
+	FeedSucceeded FeedStatus = "succeeded"
+	FeedFailed    FeedStatus = "failed"
+)
+
+type FeedCategory struct {
+	Id       int
+	ParentId *int
+	Title    string
+}
+
+type FeedSize struct {
+	ModelSizeId int    `json:"size_model_id"`
+	Value       string `json:"size_value"`
+	Literal     string `json:"literal"`
+	InStock     int    `json:"in_stock"`
+}
+
+type FeedParam struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// FeedModel is a listed model, every size of it becomes an offer of the feeds.
+type FeedModel struct {
+	Id          int
+	Title       string
+	Description *string
+	Slug        string
+	Article     string
+	Price       int
+	Discount    *byte
+	Brand       string
+	CategoryId  int
+	MainImage   string
+	Images      []string
+	Sizes       []FeedSize
+	Params      []FeedParam
+}
+
+// SalePrice is the price with the active discount, rounded the way orders round discounts.
+func (m FeedModel) SalePrice() int {
+	if m.Discount == nil || *m.Discount == 0 {
+		return m.Price
+	}
+	return m.Price - int(math.Ceil(float64(m.Price)/100*float64(*m.Discount)))
+}
+
+type FeedCatalog struct {
+	Categories []FeedCategory
+	Models     []FeedModel
+}
+
+type FeedWarning struct {
+	OfferId string `json:"offer_id" example:"125" validate:"required"`
+	Message string `json:"message" validate:"required"`
+}
+
+type FeedGeneration struct {
+	Feed       string        `json:"feed" example:"yandex.yml" validate:"required"`
+	Status     FeedStatus    `json:"status" example:"succeeded" validate:"required"`
+	StartedAt  time.Time     `json:"started_at" validate:"required"`
+	FinishedAt *time.Time    `json:"finished_at"`
+	Offers     int           `json:"offers" validate:"required"`
+	Path       *string       `json:"path" example:"/storage/images/feeds/yandex.yml"`
+	Error      *string       `json:"error"`
+	Warnings   []FeedWarning `json:"warnings" validate:"required"`
+}
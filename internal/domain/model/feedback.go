@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -67,6 +68,12 @@ type AddFeedbackDto struct {
 }
 
 type AdminAllFeedbackResponse struct {
-	Feedback []Feedback `json:"feedback" validate:"required"`
-	Total    int        `json:"total" validate:"required"`
+	Feedback   []Feedback `json:"feedback" validate:"required"`
+	Total      int        `json:"total" validate:"required"`
+	NextCursor *string    `json:"next_cursor"`
+}
+
+// FeedbackSort is the ordering the admin feedback cursors are issued for, order is ASC or DESC.
+func FeedbackSort(order string) string {
+	return "created_at_" + strings.ToLower(order)
 }
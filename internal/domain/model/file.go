@@ -1,5 +1,27 @@
 package model
 
+import "time"
+
 type UploadResponse struct {
 	Path string `json:"path"`
+	// Variants holds every rendition of an uploaded picture by name, Path is the main one.
+	Variants map[string]string `json:"variants,omitempty"`
+}
+
+type StoredObject struct {
+	Key          string    `json:"key" example:"images/1f0c.../zoom.webp" validate:"required"`
+	Size         int64     `json:"size" example:"104857" validate:"required"`
+	LastModified time.Time `json:"last_modified" validate:"required"`
+	ContentType  string    `json:"content_type,omitempty" example:"image/webp"`
+}
+
+// SweepReport lists objects no record refers to. In a dry run nothing is deleted and Deleted is 0.
+type SweepReport struct {
+	DryRun     bool           `json:"dry_run" validate:"required"`
+	Scanned    int            `json:"scanned" example:"5120" validate:"required"`
+	Referenced int            `json:"referenced" example:"4800" validate:"required"`
+	Recent     int            `json:"recent" example:"12" validate:"required"`
+	Orphaned   []StoredObject `json:"orphaned" validate:"required"`
+	Bytes      int64          `json:"bytes" example:"73400320" validate:"required"`
+	Deleted    int            `json:"deleted" example:"308" validate:"required"`
 }
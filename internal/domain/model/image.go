@@ -0,0 +1,19 @@
+package model
+
+const (
+	ImageModelPhoto = "photo"
+	ImageModelMain  = "main"
+)
+
+// ImageRecord is a stored image path that still points at an original upload.
+type ImageRecord struct {
+	Kind string
+	Id   int
+	Path string
+}
+
+type ImageBackfillResult struct {
+	Processed int `json:"processed" example:"50" validate:"required"`
+	Failed    int `json:"failed" example:"1" validate:"required"`
+	Remaining int `json:"remaining" example:"1200" validate:"required"`
+}
@@ -70,16 +70,22 @@ type CatalogValue struct {
 	Id       int    `json:"value_id" validate:"required"`
 	Value    string `json:"value" validate:"required"`
 	OptionId int    `json:"option_id" validate:"required"`
+	Count    int    `json:"count" example:"12" validate:"required"`
+	Disabled bool   `json:"disabled" validate:"required"`
 }
 
 type CatalogSize struct {
-	Id    int    `json:"size_id" validate:"required"`
-	Value string `json:"value" validate:"required"`
+	Id       int    `json:"size_id" validate:"required"`
+	Value    string `json:"value" validate:"required"`
+	Count    int    `json:"count" example:"12" validate:"required"`
+	Disabled bool   `json:"disabled" validate:"required"`
 }
 
 type CatalogBrand struct {
-	Id    int    `json:"brand_id" validate:"required"`
-	Title string `json:"brand_title" validate:"required"`
+	Id       int    `json:"brand_id" validate:"required"`
+	Title    string `json:"brand_title" validate:"required"`
+	Count    int    `json:"count" example:"12" validate:"required"`
+	Disabled bool   `json:"disabled" validate:"required"`
 }
 
 type CatalogPrice struct {
@@ -93,3 +99,22 @@ type CatalogFilters struct {
 	Brands  []CatalogBrand  `json:"brands" validate:"required"`
 	Price   CatalogPrice    `json:"price" validate:"required"`
 }
+
+// CatalogScope names what an option write is keyed on, the models it affects are looked up by it.
+type CatalogScope string
+
+const (
+	ScopeModel       CatalogScope = "model"
+	ScopeModelSize   CatalogScope = "model_size"
+	ScopeModelOption CatalogScope = "model_option"
+	ScopeOption      CatalogScope = "option"
+	ScopeValue       CatalogScope = "value"
+	ScopeSize        CatalogScope = "size"
+)
+
+// CatalogTargets are the products whose pages and the categories, with all their parents,
+// whose filters show the models a write affects.
+type CatalogTargets struct {
+	ProductIds    []int
+	CategorySlugs []string
+}
@@ -8,19 +8,25 @@ import (
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 )
 
+// AdminOrdersSort is the ordering the admin order list cursors are issued for.
+const AdminOrdersSort = "created_at_desc"
+
 type AllOrdersResponse struct {
-	Orders []*Order `json:"orders"`
-	Total  int      `json:"total"`
+	Orders     []*Order `json:"orders"`
+	Total      int      `json:"total"`
+	NextCursor *string  `json:"next_cursor"`
 }
 
 type CreateOrderResponse struct {
-	Link  string  `json:"link" validate:"required"`
-	Id    string  `json:"id" validate:"required"`
-	Total float64 `json:"total" validate:"required"`
+	Link     string  `json:"link" validate:"required"`
+	Id       string  `json:"id" validate:"required"`
+	Total    float64 `json:"total" validate:"required"`
+	OutboxId *int64  `json:"-"`
 }
 
 type ChangeOrderStatusDto struct {
-	Status OrderStatusEnum `json:"status" validate:"required"`
+	Status OrderStatusEnum `json:"status" validate:"required,orderStatusEnumValidation"`
+	Reason *string         `json:"reason" validate:"omitempty,max=1000"`
 }
 
 type ChangeOrderDeliveryDate struct {
@@ -43,6 +49,52 @@ const (
 	WaitingForActivation OrderStatusEnum = "waiting_for_activation"
 )
 
+// OrderStatusTransitions lists the statuses an order may move to from each status.
+var OrderStatusTransitions = map[OrderStatusEnum][]OrderStatusEnum{
+	WaitingForActivation: {InProcessing, Canceled},
+	WaitingForPayment:    {Paid, Canceled},
+	Paid:                 {InProcessing, Canceled},
+	InProcessing:         {OnTheWay, Canceled},
+	OnTheWay:             {Completed, Canceled},
+	Completed:            {},
+	Canceled:             {},
+}
+
+func CanChangeOrderStatus(from OrderStatusEnum, to OrderStatusEnum) bool {
+	for _, s := range OrderStatusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+type OrderStatusActor string
+
+const (
+	ActorUser    OrderStatusActor = "user"
+	ActorAdmin   OrderStatusActor = "admin"
+	ActorPayment OrderStatusActor = "payment"
+	ActorSystem  OrderStatusActor = "system"
+)
+
+type OrderStatusChange struct {
+	Status    OrderStatusEnum
+	ActorType OrderStatusActor
+	ActorId   *int
+	Reason    *string
+}
+
+type OrderStatusHistory struct {
+	Id         int              `json:"id" validate:"required"`
+	CreatedAt  time.Time        `json:"created_at" validate:"required"`
+	FromStatus *OrderStatusEnum `json:"from_status"`
+	ToStatus   OrderStatusEnum  `json:"to_status" validate:"required"`
+	ActorType  OrderStatusActor `json:"actor_type" example:"admin" validate:"required"`
+	ActorId    *int             `json:"actor_id"`
+	Reason     *string          `json:"reason"`
+}
+
 type PaymentMethodEnum string
 
 const (
@@ -66,23 +118,27 @@ type OrderUser struct {
 }
 
 type Order struct {
-	Id            string            `json:"order_id" validate:"required"`
-	PaymentId     *string           `json:"-"`
-	User          OrderUser         `json:"user" validate:"required"`
-	CreatedAt     time.Time         `json:"created_at" validate:"required"`
-	UpdatedAt     time.Time         `json:"updated_at" validate:"required"`
-	DeliveryDate  *time.Time        `json:"delivery_date"`
-	IsActivated   bool              `json:"is_activated" validate:"required"`
-	Status        OrderStatusEnum   `json:"status" validate:"required"`
-	PaymentMethod PaymentMethodEnum `json:"payment_method" validate:"required"`
-	Conditions    OrderConditions   `json:"conditions" validate:"required"`
-	ProductsPrice float64           `json:"products_price" validate:"required"`
-	TotalPrice    float64           `json:"total_price" validate:"required"`
-	TotalDiscount *float64          `json:"total_discount"`
-	PromoDiscount *int              `json:"promo_discount"`
-	DeliveryPrice int               `json:"delivery_price" validate:"required"`
-	DeliveryPoint DeliveryPoint     `json:"delivery_point" validate:"required"`
-	Models        []OrderModel      `json:"models" validate:"required"`
+	Id            string               `json:"order_id" validate:"required"`
+	PaymentId     *string              `json:"-"`
+	PaymentUrl    *string              `json:"payment_url"`
+	User          OrderUser            `json:"user" validate:"required"`
+	CreatedAt     time.Time            `json:"created_at" validate:"required"`
+	UpdatedAt     time.Time            `json:"updated_at" validate:"required"`
+	DeliveryDate  *time.Time           `json:"delivery_date"`
+	IsActivated   bool                 `json:"is_activated" validate:"required"`
+	Status        OrderStatusEnum      `json:"status" validate:"required"`
+	PaymentMethod PaymentMethodEnum    `json:"payment_method" validate:"required"`
+	Conditions    OrderConditions      `json:"conditions" validate:"required"`
+	ProductsPrice float64              `json:"products_price" validate:"required"`
+	TotalPrice    float64              `json:"total_price" validate:"required"`
+	TotalDiscount *float64             `json:"total_discount"`
+	PromoDiscount *int                 `json:"promo_discount"`
+	DeliveryPrice int                  `json:"delivery_price" validate:"required"`
+	DeliveryPoint DeliveryPoint        `json:"delivery_point" validate:"required"`
+	Models        []OrderModel         `json:"models" validate:"required"`
+	Returns       []OrderReturn        `json:"returns"`
+	Refunds       []OrderRefund        `json:"refunds"`
+	History       []OrderStatusHistory `json:"history"`
 }
 
 type OrderModelProduct struct {
@@ -108,7 +164,8 @@ type OrderModel struct {
 func OrderStatusEnumValidation(fl validator.FieldLevel) bool {
 	value := fl.Field().String()
 	switch value {
-	case string(Completed), string(Canceled), string(OnTheWay), string(WaitingForPayment), string(Paid):
+	case string(Completed), string(Canceled), string(OnTheWay), string(WaitingForPayment), string(Paid),
+		string(InProcessing), string(WaitingForActivation):
 		return true
 	}
 	return false
@@ -154,6 +211,7 @@ type CreateOrderDto struct {
 	RecipientLastname  string            `json:"recipient_lastname" validate:"required,min=2"`
 	RecipientPhone     string            `json:"recipient_phone" validate:"required,phoneValidation"`
 	ModelSizeIds       []int             `json:"model_size_ids" validate:"required,dive,min=1"`
+	PromoCode          *string           `json:"promo_code" validate:"omitempty,min=3,max=50"`
 }
 
 type CreateOrderInput struct {
@@ -161,6 +219,8 @@ type CreateOrderInput struct {
 	TotalPrice         float64
 	ProductsPrice      float64
 	TotalDiscount      float64
+	PromoDiscount      int
+	PromoCodeId        *int
 	RecipientFirstname string
 	RecipientLastname  string
 	Conditions         OrderConditions `json:"order_conditions" validate:"required,orderConditionsEnumValidation"`
@@ -168,4 +228,26 @@ type CreateOrderInput struct {
 	PaymentMethod      PaymentMethodEnum `json:"payment_method" validate:"required,paymentMethodEnumValidation"`
 	DeliveryPointId    int               `json:"delivery_point_id" validate:"required,min=1"`
 	CartItems          []*CartItemModel
+	Email              string
+}
+
+// PendingPayment is an online order still waiting for its payment.
+type PendingPayment struct {
+	OrderId   string
+	PaymentId string
+}
+
+type PaymentEvent struct {
+	Event        string
+	ObjectId     string
+	PaymentId    string
+	OrderId      string
+	Status       OrderStatusEnum
+	FromStatuses []OrderStatusEnum
+	ReturnStock  bool
+}
+
+// FakePaymentStatusDto drives the fake payment gateway: pending, succeeded or canceled.
+type FakePaymentStatusDto struct {
+	Status string `json:"status" example:"canceled" validate:"required"`
 }
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxDone    OutboxStatus = "done"
+	OutboxDead    OutboxStatus = "dead"
+)
+
+const (
+	TopicOrderEmail    = "order.email"
+	TopicPaymentCreate = "payment.create"
+	TopicPaymentRefund = "payment.refund"
+	TopicPaymentCancel = "payment.cancel"
+)
+
+type OutboxMessage struct {
+	Id        int64
+	CreatedAt time.Time
+	Topic     string
+	Payload   json.RawMessage
+	Attempts  int
+}
+
+type OrderEmailPayload struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Link    string `json:"link"`
+}
+
+type PaymentCreatePayload struct {
+	OrderId string  `json:"order_id"`
+	Total   float64 `json:"total"`
+}
+
+type PaymentRefundPayload struct {
+	RefundId  int     `json:"refund_id"`
+	PaymentId string  `json:"payment_id"`
+	Amount    float64 `json:"amount"`
+}
+
+type PaymentCancelPayload struct {
+	OrderId   string `json:"order_id"`
+	PaymentId string `json:"payment_id"`
+}
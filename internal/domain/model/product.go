@@ -3,8 +3,8 @@ package model
 type SimilarProductsFilter string
 
 const (
-	SimilarByCategory SimilarProductsFilter = "WHERE ct.category_id = $1 AND b.brand_id != $2"
-	SimilarByBrand    SimilarProductsFilter = "WHERE b.brand_id = $1 AND ct.category_id = $2"
+	SimilarByCategory SimilarProductsFilter = "WHERE cm.category_id = $1 AND cm.brand_id != $2"
+	SimilarByBrand    SimilarProductsFilter = "WHERE cm.brand_id = $1 AND cm.category_id = $2"
 )
 
 type Views struct {
@@ -98,6 +98,11 @@ type OrderProductModelSize struct {
 	InStock     int   `json:"in_stock" validate:"required"`
 }
 
+type StockChange struct {
+	ModelSizeId int
+	Quantity    int
+}
+
 type ProductModelOption struct {
 	Id                   int                       `json:"id" example:"4" validate:"required"`
 	Title                string                    `json:"title" example:"Цвет" validate:"required"`
@@ -177,8 +182,11 @@ type CatalogProductModel struct {
 type CatalogResponse struct {
 	Models     []*CatalogProductModel `json:"models"`
 	TotalCount int                    `json:"total_count" example:"100" validate:"required"`
+	NextCursor *string                `json:"next_cursor"`
 }
 
+// SearchProductModel is a search hit. Highlight is escaped html with only the matches marked up,
+// it is nil for fuzzy matches.
 type SearchProductModel struct {
 	ProductId     int           `json:"product_id" example:"1" validate:"required"`
 	Title         string        `json:"product_title" example:"Ботинки" validate:"required"`
@@ -190,4 +198,18 @@ type SearchProductModel struct {
 	MainImagePath string        `json:"model_main_image_path" example:"/static/category/test.webp" validate:"required"`
 	Brand         Brand         `json:"brand" validate:"required"`
 	Category      CategoryModel `json:"category" validate:"required"`
+	Highlight     *string       `json:"highlight" example:"Тёплая <mark>куртка</mark> на зиму"`
+}
+
+const (
+	SearchSortRelevance  = "relevance"
+	SearchSortSimilarity = "similarity"
+)
+
+// SearchResponse is a page of search results, Fuzzy is set when nothing matched exactly
+// and the results come from the similarity fallback.
+type SearchResponse struct {
+	Models     []SearchProductModel `json:"models" validate:"required"`
+	NextCursor *string              `json:"next_cursor"`
+	Fuzzy      bool                 `json:"fuzzy" validate:"required"`
 }
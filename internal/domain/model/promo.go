@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type PromoDiscountType string
+
+const (
+	PromoPercent PromoDiscountType = "percent"
+	PromoFixed   PromoDiscountType = "fixed"
+)
+
+func PromoDiscountTypeEnumValidation(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	switch value {
+	case string(PromoPercent), string(PromoFixed):
+		return true
+	}
+	return false
+}
+
+type PromoCode struct {
+	Id                int               `json:"id" example:"1" validate:"required"`
+	CreatedAt         time.Time         `json:"created_at" validate:"required"`
+	UpdatedAt         time.Time         `json:"updated_at" validate:"required"`
+	Code              string            `json:"code" example:"SPRING10" validate:"required"`
+	DiscountType      PromoDiscountType `json:"discount_type" example:"percent" validate:"required"`
+	DiscountValue     int               `json:"discount_value" example:"10" validate:"required"`
+	MinBasketPrice    int               `json:"min_basket_price" example:"3000" validate:"required"`
+	StartDate         time.Time         `json:"start_date" validate:"required"`
+	EndDate           time.Time         `json:"end_date" validate:"required"`
+	UsageLimit        *int              `json:"usage_limit" example:"100"`
+	UsageLimitPerUser *int              `json:"usage_limit_per_user" example:"1"`
+	UsedCount         int               `json:"used_count" example:"12" validate:"required"`
+	IsActivated       bool              `json:"is_activated" validate:"required"`
+	BrandIds          []int             `json:"brand_ids" validate:"required"`
+	CategoryIds       []int             `json:"category_ids" validate:"required"`
+}
+
+type CreatePromoCodeDto struct {
+	Code              string            `json:"code" example:"SPRING10" validate:"required,min=3,max=50,alphanum"`
+	DiscountType      PromoDiscountType `json:"discount_type" example:"percent" validate:"required,promoDiscountTypeEnumValidation"`
+	DiscountValue     int               `json:"discount_value" example:"10" validate:"required,min=1"`
+	MinBasketPrice    int               `json:"min_basket_price" example:"3000" validate:"omitempty,min=0"`
+	StartDate         *time.Time        `json:"start_date" validate:"omitempty"`
+	EndDate           time.Time         `json:"end_date" validate:"required,gt"`
+	UsageLimit        *int              `json:"usage_limit" example:"100" validate:"omitempty,min=1"`
+	UsageLimitPerUser *int              `json:"usage_limit_per_user" example:"1" validate:"omitempty,min=1"`
+	BrandIds          []int             `json:"brand_ids" validate:"omitempty,dive,min=1"`
+	CategoryIds       []int             `json:"category_ids" validate:"omitempty,dive,min=1"`
+}
+
+type UpdatePromoCodeDto struct {
+	DiscountType      *PromoDiscountType `json:"discount_type" example:"percent" validate:"omitempty,promoDiscountTypeEnumValidation"`
+	DiscountValue     *int               `json:"discount_value" example:"10" validate:"omitempty,min=1"`
+	MinBasketPrice    *int               `json:"min_basket_price" example:"3000" validate:"omitempty,min=0"`
+	StartDate         *time.Time         `json:"start_date" validate:"omitempty"`
+	EndDate           *time.Time         `json:"end_date" validate:"omitempty"`
+	UsageLimit        *int               `json:"usage_limit" example:"100" validate:"omitempty,min=1"`
+	UsageLimitPerUser *int               `json:"usage_limit_per_user" example:"1" validate:"omitempty,min=1"`
+	IsActivated       *bool              `json:"is_activated" validate:"omitempty"`
+	BrandIds          []int              `json:"brand_ids" validate:"omitempty,dive,min=1"`
+	CategoryIds       []int              `json:"category_ids" validate:"omitempty,dive,min=1"`
+}
+
+type AppliedPromoCode struct {
+	PromoCodeId int
+	Code        string
+	Discount    int
+}
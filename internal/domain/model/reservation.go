@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+type ReservationStatus string
+
+const (
+	ReservationActive   ReservationStatus = "active"
+	ReservationSold     ReservationStatus = "sold"
+	ReservationReleased ReservationStatus = "released"
+)
+
+// ActivationLinkTTL is how long an order activation link stays valid.
+const ActivationLinkTTL = 4 * time.Hour
+
+// ReservationTTL is how long checkout holds the stock: the payment session lifetime
+// for online orders and the activation link lifetime for orders paid upon receipt.
+func ReservationTTL(method PaymentMethodEnum) time.Duration {
+	if method == Online {
+		return time.Hour
+	}
+	return ActivationLinkTTL
+}
+
+// IsSaleStatus reports whether moving an order to status turns its reservations into a sale.
+func IsSaleStatus(status OrderStatusEnum) bool {
+	return status == Paid || status == InProcessing
+}
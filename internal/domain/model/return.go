@@ -0,0 +1,77 @@
+package model
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type OrderReturnStatus string
+
+const (
+	ReturnPending  OrderReturnStatus = "pending"
+	ReturnApproved OrderReturnStatus = "approved"
+	ReturnRejected OrderReturnStatus = "rejected"
+)
+
+func OrderReturnStatusEnumValidation(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	switch value {
+	case string(ReturnPending), string(ReturnApproved), string(ReturnRejected):
+		return true
+	}
+	return false
+}
+
+type OrderReturnModel struct {
+	Id           int     `json:"id" example:"1" validate:"required"`
+	OrderModelId int     `json:"order_model_id" example:"1" validate:"required"`
+	ModelSizeId  int     `json:"model_size_id" example:"1" validate:"required"`
+	Quantity     int     `json:"quantity" example:"1" validate:"required"`
+	Amount       float64 `json:"amount" example:"1990" validate:"required"`
+}
+
+type OrderReturn struct {
+	Id        int                `json:"id" example:"1" validate:"required"`
+	CreatedAt time.Time          `json:"created_at" validate:"required"`
+	UpdatedAt time.Time          `json:"updated_at" validate:"required"`
+	OrderId   string             `json:"order_id" validate:"required"`
+	UserId    int                `json:"user_id" validate:"required"`
+	Status    OrderReturnStatus  `json:"status" example:"pending" validate:"required"`
+	Reason    string             `json:"reason" validate:"required"`
+	Comment   *string            `json:"admin_comment"`
+	Amount    float64            `json:"amount" example:"1990" validate:"required"`
+	Models    []OrderReturnModel `json:"models" validate:"required"`
+}
+
+type OrderRefund struct {
+	Id              int       `json:"id" example:"1" validate:"required"`
+	CreatedAt       time.Time `json:"created_at" validate:"required"`
+	OrderId         string    `json:"order_id" validate:"required"`
+	OrderReturnId   *int      `json:"order_return_id"`
+	PaymentRefundId *string   `json:"payment_refund_id"`
+	Amount          float64   `json:"amount" example:"1990" validate:"required"`
+	Status          string    `json:"status" example:"succeeded" validate:"required"`
+}
+
+type OrderRefundInput struct {
+	OrderId         string
+	OrderReturnId   *int
+	PaymentRefundId *string
+	Amount          float64
+	Status          string
+}
+
+type CreateOrderReturnModelDto struct {
+	OrderModelId int `json:"order_model_id" example:"1" validate:"required,min=1"`
+	Quantity     int `json:"quantity" example:"1" validate:"required,min=1"`
+}
+
+type CreateOrderReturnDto struct {
+	Reason string                      `json:"reason" validate:"required,min=3,max=1000"`
+	Models []CreateOrderReturnModelDto `json:"models" validate:"required,min=1,dive"`
+}
+
+type ProcessOrderReturnDto struct {
+	Comment *string `json:"admin_comment" validate:"omitempty,min=3,max=1000"`
+}
@@ -0,0 +1,26 @@
+package model
+
+type SuggestItem struct {
+	Id    int    `json:"id" example:"1" validate:"required"`
+	Title string `json:"title" example:"Куртка" validate:"required"`
+	Slug  string `json:"slug" example:"kurtka" validate:"required"`
+}
+
+type SuggestQuery struct {
+	Query string `json:"query" example:"куртка зимняя" validate:"required"`
+	Hits  int    `json:"hits" example:"120" validate:"required"`
+}
+
+// Suggestions groups autocomplete hits, Corrected is set when they were found
+// for a retyped or transliterated form of the term.
+type Suggestions struct {
+	Brands     []SuggestItem  `json:"brands" validate:"required"`
+	Categories []SuggestItem  `json:"categories" validate:"required"`
+	Products   []SuggestItem  `json:"products" validate:"required"`
+	Queries    []SuggestQuery `json:"queries" validate:"required"`
+	Corrected  *string        `json:"corrected" example:"куртка"`
+}
+
+func (s *Suggestions) Empty() bool {
+	return len(s.Brands) == 0 && len(s.Categories) == 0 && len(s.Products) == 0 && len(s.Queries) == 0
+}
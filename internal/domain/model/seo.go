@@ -0,0 +1,74 @@
+package model
+
+import "time"
+
+const (
+	SeoCategory = "category"
+	SeoBrand    = "brand"
+	SeoProduct  = "product"
+	SeoModel    = "model"
+)
+
+// Storefront pages, relative to the site url.
+const (
+	CategoryPagePath = "/catalog/%s"
+	BrandPagePath    = "/brand/%s"
+	ProductPagePath  = "/product/%s"
+)
+
+// Default meta templates, placeholders are {title}, {brand}, {category}, {article}, {price} and {site}.
+const (
+	CategoryTitleTemplate       = "{title} купить в {site}"
+	CategoryDescriptionTemplate = "{title} в интернет-магазине {site}: большой выбор моделей, доставка по России."
+	BrandTitleTemplate          = "{title} купить в {site}"
+	BrandDescriptionTemplate    = "Одежда, обувь и аксессуары {title} в интернет-магазине {site}."
+	ModelTitleTemplate          = "{brand} {title} купить в {site}"
+	ModelDescriptionTemplate    = "{brand} {title} ({article}) по цене {price} ₽ в интернет-магазине {site}. {category} с доставкой по России."
+)
+
+// Seo is what an admin set explicitly, nil fields fall back to the templates.
+type Seo struct {
+	MetaTitle       *string `json:"meta_title" example:"Куртки adidas купить"`
+	MetaDescription *string `json:"meta_description" example:"Куртки adidas с доставкой"`
+	Canonical       *string `json:"canonical" example:"/catalog/kurtki"`
+}
+
+// SeoSource is a page with the values its templates need.
+type SeoSource struct {
+	Seo
+	Title    string
+	Slug     string
+	Brand    string
+	Category string
+	Article  string
+	Price    int
+}
+
+type SeoMeta struct {
+	Title       string `json:"title" example:"adidas Куртка купить в FamilyModa" validate:"required"`
+	Description string `json:"description" example:"adidas Куртка (AD123) по цене 9990 ₽..." validate:"required"`
+	Canonical   string `json:"canonical" example:"https://familymoda.ru/product/kurtka-ad123" validate:"required"`
+}
+
+// UpdateSeoDto sets the fields that are present, an empty string clears a field back to its default.
+type UpdateSeoDto struct {
+	MetaTitle       *string `json:"meta_title" validate:"omitempty,max=255" example:"Куртки adidas купить"`
+	MetaDescription *string `json:"meta_description" validate:"omitempty,max=1000" example:"Куртки adidas с доставкой"`
+	Canonical       *string `json:"canonical" validate:"omitempty,max=2048" example:"/catalog/kurtki"`
+}
+
+type SitemapUrl struct {
+	Path      string
+	Canonical *string
+	LastMod   time.Time
+}
+
+// SitemapGeneration is the last sitemap written: Version is the catalog version it was built from,
+// Paths maps its file names to storage paths.
+type SitemapGeneration struct {
+	Urls        int               `json:"urls" example:"120345" validate:"required"`
+	Files       []string          `json:"files" validate:"required"`
+	GeneratedAt time.Time         `json:"generated_at" validate:"required"`
+	Version     string            `json:"-"`
+	Paths       map[string]string `json:"-"`
+}
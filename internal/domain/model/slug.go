@@ -0,0 +1,13 @@
+package model
+
+const (
+	SlugBrand    = "brand"
+	SlugCategory = "category"
+	SlugModel    = "model"
+)
+
+// SlugRedirect is returned with 301 for a retired slug, Path is the storefront page under the current one.
+type SlugRedirect struct {
+	Slug string `json:"slug" example:"adidas-originals" validate:"required"`
+	Path string `json:"path" example:"/brand/adidas-originals" validate:"required"`
+}
@@ -0,0 +1,77 @@
+package model
+
+import "time"
+
+type StockMovementType string
+
+const (
+	StockSale         StockMovementType = "sale"
+	StockCancellation StockMovementType = "cancellation"
+	StockReturn       StockMovementType = "return"
+	StockAdjustment   StockMovementType = "adjustment"
+	StockImport       StockMovementType = "import"
+	StockTake         StockMovementType = "stocktake"
+)
+
+// StockMovementsSort is the ordering the stock movement cursors are issued for.
+const StockMovementsSort = "created_at_desc"
+
+// StockSource names the cause of the stock changes made in a transaction, the ledger trigger records it
+// with every change.
+type StockSource struct {
+	Type     StockMovementType `json:"type"`
+	OrderId  *string           `json:"order_id,omitempty"`
+	UserId   *int              `json:"user_id,omitempty"`
+	ImportId *string           `json:"import_id,omitempty"`
+	Comment  *string           `json:"comment,omitempty"`
+}
+
+type StockMovement struct {
+	Id          int64             `json:"stock_movement_id" validate:"required"`
+	CreatedAt   time.Time         `json:"created_at" validate:"required"`
+	ModelSizeId int               `json:"model_size_id" validate:"required"`
+	Type        StockMovementType `json:"movement_type" example:"sale" validate:"required"`
+	Quantity    int               `json:"quantity" example:"-2" validate:"required"`
+	Balance     int               `json:"balance" example:"18" validate:"required"`
+	OrderId     *string           `json:"order_id"`
+	UserId      *int              `json:"user_id"`
+	ImportId    *string           `json:"import_id"`
+	Comment     *string           `json:"comment"`
+}
+
+// StockHistory is a page of the movements of one model size, newest first. LedgerStock is the sum
+// of all of them and equals InStock unless the stock was changed around the ledger.
+type StockHistory struct {
+	ModelSizeId int             `json:"model_size_id" validate:"required"`
+	InStock     int             `json:"in_stock" validate:"required"`
+	LedgerStock int             `json:"ledger_stock" validate:"required"`
+	Movements   []StockMovement `json:"movements" validate:"required"`
+	Total       int             `json:"total" validate:"required"`
+	NextCursor  *string         `json:"next_cursor"`
+}
+
+type StockAdjustmentDto struct {
+	Quantity int    `json:"quantity" example:"-3" validate:"required"`
+	Comment  string `json:"comment" example:"брак при приемке" validate:"required,min=3"`
+}
+
+// StockTakeDto is a counted quantity. Units of orders that aren't on the way yet are still on the shelf
+// and counted too, they are taken out of it.
+type StockTakeDto struct {
+	Counted *int    `json:"counted" example:"20" validate:"required,min=0"`
+	Comment *string `json:"comment" example:"инвентаризация склада" validate:"omitempty,min=3"`
+}
+
+type StockDiscrepancy struct {
+	ModelSizeId int `json:"model_size_id" validate:"required"`
+	InStock     int `json:"in_stock" validate:"required"`
+	LedgerStock int `json:"ledger_stock" validate:"required"`
+}
+
+// StockReconcileReport lists model sizes whose stock differs from their ledger. Unless it is a dry run
+// each of them gets a stocktake entry for the difference and Fixed counts them.
+type StockReconcileReport struct {
+	DryRun        bool               `json:"dry_run" validate:"required"`
+	Discrepancies []StockDiscrepancy `json:"discrepancies" validate:"required"`
+	Fixed         int                `json:"fixed" validate:"required"`
+}
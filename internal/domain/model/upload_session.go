@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+type UploadSessionStatus string
+
+const (
+	UploadPending   UploadSessionStatus = "pending"
+	UploadConfirmed UploadSessionStatus = "confirmed"
+	UploadRejected  UploadSessionStatus = "rejected"
+)
+
+type UploadSession struct {
+	Id          string              `json:"upload_session_id" validate:"required"`
+	CreatedAt   time.Time           `json:"created_at" validate:"required"`
+	ExpiresAt   time.Time           `json:"expires_at" validate:"required"`
+	ConfirmedAt *time.Time          `json:"confirmed_at"`
+	UserId      int                 `json:"user_id" validate:"required"`
+	Purpose     string              `json:"purpose" example:"product" validate:"required"`
+	TargetId    string              `json:"target_id" example:"12" validate:"required"`
+	Key         string              `json:"object_key" validate:"required"`
+	Status      UploadSessionStatus `json:"upload_status" validate:"required"`
+	Path        *string             `json:"path"`
+}
+
+// CreateUploadSessionDto names what the file is for: the product model id for product, brand id for brand,
+// action id for action. An avatar is always the uploader's own, its target is ignored.
+type CreateUploadSessionDto struct {
+	Purpose     string `json:"purpose" example:"product" validate:"required,oneof=product brand action avatar"`
+	TargetId    string `json:"target_id" example:"12"`
+	ContentType string `json:"content_type" example:"image/jpeg" validate:"required"`
+	Size        int64  `json:"size" example:"2097152" validate:"required,min=1"`
+}
+
+// UploadTicket tells the client where to PUT the file before ExpiresAt. The url is signed for ContentType,
+// the upload has to send it as its Content-Type header.
+type UploadTicket struct {
+	Id          string    `json:"upload_session_id" validate:"required"`
+	Url         string    `json:"url" validate:"required"`
+	Method      string    `json:"method" example:"PUT" validate:"required"`
+	ContentType string    `json:"content_type" example:"image/jpeg" validate:"required"`
+	MaxSize     int64     `json:"max_size" example:"15728640" validate:"required"`
+	ExpiresAt   time.Time `json:"expires_at" validate:"required"`
+}
@@ -18,9 +18,13 @@ func UserGenderEnumValidation(fl validator.FieldLevel) bool {
 	return false
 }
 
+// UsersSort is the ordering the user list cursors are issued for.
+const UsersSort = "created_at_asc"
+
 type GetAllUsersResponse struct {
-	Users []*User `json:"users" validate:"required"`
-	Total int     `json:"total" validate:"required"`
+	Users      []*User `json:"users" validate:"required"`
+	Total      int     `json:"total" validate:"required"`
+	NextCursor *string `json:"next_cursor"`
 }
 
 type User struct {
@@ -0,0 +1,21 @@
+package msg
+
+const (
+	CatalogImportUnknownFormat   = "Поддерживаются только файлы CSV и XLSX!"
+	CatalogImportBadFile         = "Не удалось прочитать файл импорта!"
+	CatalogImportEmptyFile       = "Файл импорта не содержит строк!"
+	CatalogImportTooManyRows     = "Слишком много строк в файле импорта!"
+	CatalogImportMissingColumn   = "В файле отсутствует обязательная колонка!"
+	CatalogImportRequired        = "Обязательное поле не заполнено!"
+	CatalogImportTooLong         = "Значение слишком длинное!"
+	CatalogImportTooShort        = "Значение слишком короткое!"
+	CatalogImportInvalidNumber   = "Ожидается целое неотрицательное число!"
+	CatalogImportInvalidDiscount = "Скидка должна быть числом от 1 до 99!"
+	CatalogImportInvalidArticle  = "Артикул может содержать только латинские буквы и цифры!"
+	CatalogImportDuplicate       = "Артикул уже встречается в файле!"
+	CatalogImportInvalidSizes    = "Размеры указываются в формате значение:обозначение:остаток через |!"
+	CatalogImportInvalidOptions  = "Характеристики указываются в формате название=значение через |!"
+	CatalogImportRowError        = "Ошибка при записи строки!"
+	CatalogImportBatchRolledBack = "Пакет отменён из-за ошибки в другой строке!"
+	CatalogExportError           = "Ошибка при выгрузке каталога!"
+)
@@ -0,0 +1,13 @@
+package msg
+
+const (
+	ExchangeUnauthorized   = "Неверный логин или пароль обмена!"
+	ExchangeSessionExpired = "Сессия обмена не найдена или истекла!"
+	ExchangeUnknownMode    = "Неизвестный режим обмена!"
+	ExchangeInvalidFile    = "Недопустимое имя файла обмена!"
+	ExchangeFileNotFound   = "Файл обмена не найден!"
+	ExchangeBadFile        = "Не удалось разобрать файл обмена!"
+	ExchangeErrorWhenSave  = "Ошибка при сохранении файла обмена!"
+	ExchangeOrdersError    = "Ошибка при выгрузке заказов!"
+	ExchangeSessionFull    = "Превышен объём файлов сессии обмена!"
+)
@@ -0,0 +1,15 @@
+package msg
+
+const (
+	FeedNotFound          = "Фид не найден или ещё не сформирован!"
+	FeedAlreadyRunning    = "Фиды уже формируются!"
+	FeedErrorWhenGenerate = "Ошибка при формировании фида!"
+	FeedNoDescription     = "Нет описания, вместо него выгружено название"
+	FeedTitleTruncated    = "Название длиннее 150 символов и обрезано"
+	FeedDescriptionCut    = "Описание слишком длинное и обрезано"
+	FeedTooManyImages     = "Больше 10 изображений, лишние не выгружены"
+	FeedNoImages          = "Нет изображений"
+	FeedInvalidPrice      = "Цена не указана, предложение не выгружено"
+	FeedOutOfStock        = "Нет в наличии"
+	FeedInvalidCategory   = "Категория не найдена, предложение выгружено без категории"
+)
@@ -0,0 +1,13 @@
+package msg
+
+const (
+	FileRequired         = "Файл не передан!"
+	FileUnknownPurpose   = "Неизвестное назначение файла, допустимые значения: image, avatar, brand, category, action, product!"
+	FileAdminPurpose     = "Загружать файлы с этим назначением может только администратор!"
+	FileTooLarge         = "Файл слишком большой для этого назначения!"
+	FileTypeNotAllowed   = "Тип файла не подходит для этого назначения!"
+	FileUnsafe           = "Файл содержит недопустимое содержимое!"
+	FileTooManyPixels    = "Разрешение изображения слишком большое!"
+	FileSweepRunning     = "Очистка хранилища уже запущена!"
+	FileSweepNoReference = "Не найдено ни одной ссылки на файлы, очистка остановлена!"
+)
@@ -0,0 +1,7 @@
+package msg
+
+const (
+	ImageProcessError    = "Не удалось обработать изображение!"
+	ImageBackfillRunning = "Обработка старых изображений уже запущена!"
+	ImageUpdatePathError = "Ошибка при обновлении пути к изображению!"
+)
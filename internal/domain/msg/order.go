@@ -14,4 +14,10 @@ const (
 	OrderErrorWhenChangeDeliveryDate    = "Ошибка при смене даты доставки!"
 	OrderErrorWhenSetPaymentID          = "Ошибка при обновлении ID платежа"
 	OrderAlreadyPaid                    = "Заказ уже оплачен!"
+	OrderErrorWhenApplyPaymentEvent     = "Ошибка при обработке уведомления о платеже!"
+	OrderPaymentEventMismatch           = "Статус платежа не совпадает с уведомлением!"
+	OrderIllegalStatusTransition        = "Недопустимая смена статуса заказа: %s -> %s!"
+	OrderErrorWhenAddStatusHistory      = "Ошибка при сохранении истории статусов заказа!"
+	OrderPaymentNotSucceeded            = "Платеж по заказу не завершен!"
+	OrderNotWaitingForActivation        = "Заказ не ожидает активации!"
 )
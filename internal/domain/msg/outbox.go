@@ -0,0 +1,5 @@
+package msg
+
+const (
+	OutboxErrorWhenAdd = "Ошибка при сохранении отложенной задачи!"
+)
@@ -0,0 +1,6 @@
+package msg
+
+const (
+	PaymentUnknownOutcome = "Неизвестный исход платежа, допустимые значения: pending, succeeded, canceled!"
+	PaymentNotFound       = "Платеж не найден!"
+)
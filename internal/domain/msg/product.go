@@ -8,8 +8,8 @@ const (
 	ProductAddPhotoError               = "Ошибка при добавлении фотографии!"
 	ProductUpdateError                 = "Ошибка при обновлении товара!"
 	ProductModelUpdateError            = "Ошибка при обновлении модели товара!"
-	ProductModelSlugUnique             = "Slug модели должен быть уникален!"
 	ProductInStockCannotBeLessThanZero = "Количество товара на складе не может быть меньше 0"
+	ProductNotEnoughInStock            = "Недостаточно товара на складе!"
 	ProductDeleteError                 = "Ошибка при удалении товара!"
 	ProductModelDeleteError            = "Ошибка при удалении модели товара!"
 	ProductInvalidCategory             = "Категория товара не должна иметь потомков!"
@@ -0,0 +1,20 @@
+package msg
+
+const (
+	PromoCodeNotFound         = "Промокод не найден!"
+	PromoCodeExists           = "Промокод с таким кодом уже существует!"
+	PromoCodeCreateError      = "Ошибка при создании промокода!"
+	PromoCodeUpdateError      = "Ошибка при обновлении промокода!"
+	PromoCodeDeleteError      = "Ошибка при удалении промокода!"
+	PromoCodeNotActive        = "Промокод не активен!"
+	PromoCodeExpired          = "Срок действия промокода истек!"
+	PromoCodeNotStarted       = "Срок действия промокода еще не начался!"
+	PromoCodeUsageLimit       = "Промокод больше не может быть использован!"
+	PromoCodeUserUsageLimit   = "Вы уже использовали этот промокод максимальное количество раз!"
+	PromoCodeMinBasketPrice   = "Сумма заказа меньше минимальной для этого промокода!"
+	PromoCodeNotApplicable    = "Промокод не распространяется на товары в заказе!"
+	PromoCodeInvalidPercent   = "Скидка в процентах должна быть от 1 до 100!"
+	PromoCodeInvalidDates     = "Дата окончания действия промокода должна быть позже даты начала!"
+	PromoCodeErrorWhenRedeem  = "Ошибка при применении промокода!"
+	PromoCodeErrorWhenRelease = "Ошибка при возврате промокода!"
+)
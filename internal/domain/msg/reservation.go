@@ -0,0 +1,7 @@
+package msg
+
+const (
+	ReservationErrorWhenCreate = "Ошибка при резервировании товара!"
+	ReservationErrorWhenUpdate = "Ошибка при обновлении резерва товара!"
+	ReservationExpired         = "Резерв товара истек"
+)
@@ -0,0 +1,14 @@
+package msg
+
+const (
+	ReturnNotFound             = "Заявка на возврат не найдена!"
+	ReturnOrderNotCompleted    = "Возврат возможен только для полученного заказа!"
+	ReturnOrderModelNotFound   = "Товар не найден в заказе!"
+	ReturnQuantityExceeded     = "Количество к возврату превышает количество в заказе!"
+	ReturnDuplicateOrderModel  = "Товар указан в заявке несколько раз!"
+	ReturnAlreadyProcessed     = "Заявка на возврат уже обработана!"
+	ReturnErrorWhenCreate      = "Ошибка при создании заявки на возврат!"
+	ReturnErrorWhenChangeState = "Ошибка при обработке заявки на возврат!"
+	RefundErrorWhenSave        = "Ошибка при сохранении возврата средств!"
+	RefundError                = "Ошибка при возврате средств. Попробуйте позже."
+)
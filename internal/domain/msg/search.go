@@ -0,0 +1,6 @@
+package msg
+
+const (
+	SearchEmptyTerm   = "Пустое значение поиска!"
+	SearchTermTooLong = "Слишком длинное значение поиска!"
+)
@@ -0,0 +1,10 @@
+package msg
+
+const (
+	SeoPageNotFound        = "Страница не найдена!"
+	SeoUnknownEntity       = "Неизвестный тип страницы, допустимые значения: category, brand, product, model!"
+	SeoEmptyUpdate         = "Не передано ни одного SEO-поля!"
+	SitemapNotFound        = "Карта сайта не найдена или ещё не сформирована!"
+	SitemapAlreadyRunning  = "Карта сайта уже формируется!"
+	SitemapErrorWhenCreate = "Ошибка при формировании карты сайта!"
+)
@@ -0,0 +1,7 @@
+package msg
+
+const (
+	SlugHistoryNotFound = "Адрес не найден в истории!"
+	SlugUnknownEntity   = "Неизвестный тип сущности для адреса!"
+	SlugTaken           = "Адрес уже занят, попробуйте ещё раз!"
+)
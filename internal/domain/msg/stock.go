@@ -0,0 +1,8 @@
+package msg
+
+const (
+	StockModelSizeNotFound  = "Размер модели не найден!"
+	StockNegative           = "Остаток не может стать отрицательным!"
+	StockErrorWhenUpdate    = "Ошибка при изменении остатка!"
+	StockErrorWhenReconcile = "Ошибка при сверке остатков с журналом движений!"
+)
@@ -0,0 +1,11 @@
+package msg
+
+const (
+	UploadSessionNotFound        = "Сессия загрузки не найдена!"
+	UploadSessionClosed          = "Сессия загрузки уже завершена!"
+	UploadSessionExpired         = "Срок действия сессии загрузки истек!"
+	UploadSessionNoFile          = "Файл еще не загружен в хранилище!"
+	UploadSessionTarget          = "Не указан объект, к которому относится файл!"
+	UploadSessionErrorWhenCreate = "Ошибка при создании сессии загрузки!"
+	UploadSessionErrorWhenUpdate = "Ошибка при обновлении сессии загрузки!"
+)
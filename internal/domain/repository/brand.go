@@ -68,6 +68,9 @@ func (r *BrandRepository) CreateBrand(ctx context.Context, dto model.CreateBrand
 
 	_, err := r.db.Exec(ctx, query, dto.Title, slug, dto.Description, dto.ImgPath)
 	if err != nil {
+		if slugTaken(err) {
+			return fall.NewErr(msg.SlugTaken, fall.STATUS_CONFLICT)
+		}
 		return fall.NewErr(msg.BrandCreateError, fall.STATUS_INTERNAL_ERROR)
 	}
 	return nil
@@ -152,6 +155,9 @@ func (r *BrandRepository) UpdateBrand(ctx context.Context, dto model.UpdateBrand
 		q := "UPDATE brand SET " + strings.Join(queries, ",") + " WHERE brand_id = $1;"
 		_, err := r.db.Exec(ctx, q, id)
 		if err != nil {
+			if slugTaken(err) {
+				return fall.NewErr(msg.SlugTaken, fall.STATUS_CONFLICT)
+			}
 			return fall.ServerError(fmt.Sprintf("%s, details: \n %s", msg.BrandUpdateError, err.Error()))
 		}
 		return nil
@@ -0,0 +1,456 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type CatalogImportRepository struct {
+	db db.PostgresClient
+}
+
+func NewCatalogImportRepository(db db.PostgresClient) *CatalogImportRepository {
+	return &CatalogImportRepository{db: db}
+}
+
+type importCategory struct {
+	id          int
+	hasChildren bool
+	parentSlug  string
+}
+
+// Import writes rows in batches of batchSize, every batch in its own transaction with a savepoint per row.
+// A batch with a failed row is rolled back as a whole and the import stops after it.
+// A dry run writes all rows in one transaction and rolls it back, so its report lists every row error
+// and counts brands or options that several rows share only once.
+func (r *CatalogImportRepository) Import(ctx context.Context, rows []model.CatalogRow, batchSize int, dryRun bool) (*model.CatalogImportReport, fall.Error) {
+	report := &model.CatalogImportReport{ImportId: uuid.New().String(), DryRun: dryRun, Rows: len(rows),
+		Errors: []model.CatalogImportError{}}
+	categories := make(map[string]*importCategory)
+
+	if dryRun {
+		batchSize = len(rows)
+	}
+
+	for start := 0; start < len(rows); start += batchSize {
+		batch := rows[start:min(start+batchSize, len(rows))]
+
+		tx, err := r.db.Begin(ctx)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+
+		err = trackStock(ctx, tx, model.StockSource{Type: model.StockImport, ImportId: &report.ImportId})
+		if err != nil {
+			tx.Rollback(ctx)
+			return nil, fall.ServerError(err.Error())
+		}
+
+		counts := model.CatalogImportCounts{}
+		var rowErrors []model.CatalogImportError
+
+		for _, row := range batch {
+			rowErr, ex := r.importRowSavepoint(ctx, tx, row, categories, &counts)
+			if ex != nil {
+				tx.Rollback(ctx)
+				return nil, ex
+			}
+			if rowErr != nil {
+				rowErrors = append(rowErrors, *rowErr)
+			}
+		}
+
+		report.Errors = append(report.Errors, rowErrors...)
+
+		if dryRun || len(rowErrors) > 0 {
+			tx.Rollback(ctx)
+		} else if err := tx.Commit(ctx); err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+
+		if len(rowErrors) == 0 || dryRun {
+			addImportCounts(&report.CatalogImportCounts, counts)
+		}
+
+		if dryRun {
+			continue
+		}
+		if len(rowErrors) > 0 {
+			report.Errors = append(report.Errors, model.CatalogImportError{Row: batch[0].Line, Column: "",
+				Message: msg.CatalogImportBatchRolledBack})
+			break
+		}
+		report.Imported += len(batch)
+	}
+
+	return report, nil
+}
+
+func addImportCounts(total *model.CatalogImportCounts, c model.CatalogImportCounts) {
+	total.ProductsCreated += c.ProductsCreated
+	total.ModelsCreated += c.ModelsCreated
+	total.ModelsUpdated += c.ModelsUpdated
+	total.BrandsCreated += c.BrandsCreated
+	total.OptionsCreated += c.OptionsCreated
+	total.ValuesCreated += c.ValuesCreated
+	total.SizesCreated += c.SizesCreated
+}
+
+// importRowSavepoint writes one row inside a savepoint, a failed row is rolled back alone
+// and its counts are dropped, so the rest of the transaction stays usable.
+func (r *CatalogImportRepository) importRowSavepoint(ctx context.Context, tx db.Transaction, row model.CatalogRow,
+	categories map[string]*importCategory, counts *model.CatalogImportCounts) (*model.CatalogImportError, fall.Error) {
+
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	rowCounts := model.CatalogImportCounts{}
+	rowErr := r.importRow(ctx, sp, row, categories, &rowCounts)
+
+	if rowErr != nil {
+		if err := sp.Rollback(ctx); err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		return rowErr, nil
+	}
+
+	if err := sp.Commit(ctx); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	addImportCounts(counts, rowCounts)
+
+	return nil, nil
+}
+
+func importRowError(row model.CatalogRow, column string, err error) *model.CatalogImportError {
+	return &model.CatalogImportError{Row: row.Line, Column: column,
+		Message: fmt.Sprintf("%s, details: %s", msg.CatalogImportRowError, err.Error())}
+}
+
+func (r *CatalogImportRepository) importRow(ctx context.Context, tx db.Transaction, row model.CatalogRow,
+	categories map[string]*importCategory, counts *model.CatalogImportCounts) *model.CatalogImportError {
+
+	category, rowErr := r.findCategory(ctx, tx, row, categories)
+	if rowErr != nil {
+		return rowErr
+	}
+
+	brandId, brandSlug, err := r.resolveBrand(ctx, tx, row, counts)
+	if err != nil {
+		return importRowError(row, "brand", err)
+	}
+
+	modelId, err := r.upsertModel(ctx, tx, row, category, brandId, brandSlug, counts)
+	if err != nil {
+		return importRowError(row, "article", err)
+	}
+
+	for _, img := range row.Images {
+		q := `INSERT INTO product_model_img (img_path, product_model_id) SELECT $1, $2
+		WHERE NOT EXISTS (SELECT 1 FROM product_model_img WHERE product_model_id = $2 AND img_path = $1);`
+		if _, err := tx.Exec(ctx, q, img, modelId); err != nil {
+			return importRowError(row, "images", err)
+		}
+	}
+
+	for _, size := range row.Sizes {
+		if err := r.upsertModelSize(ctx, tx, modelId, size, counts); err != nil {
+			return importRowError(row, "sizes", err)
+		}
+	}
+
+	for _, option := range row.Options {
+		if err := r.addModelOption(ctx, tx, modelId, option, counts); err != nil {
+			return importRowError(row, "options", err)
+		}
+	}
+
+	return nil
+}
+
+// findCategory resolves the category slug of a row, products can only be placed into categories without children.
+// parentSlug is the same third level parent CreateModel takes model slugs from.
+func (r *CatalogImportRepository) findCategory(ctx context.Context, tx db.Transaction, row model.CatalogRow,
+	categories map[string]*importCategory) (*importCategory, *model.CatalogImportError) {
+
+	c, ok := categories[row.Category]
+	if !ok {
+		q := `
+		WITH RECURSIVE recursive_cte AS (
+			SELECT category_id, parent_category_id, slug, 1 AS level
+			FROM category
+			WHERE slug = $1
+			UNION ALL
+			SELECT t.category_id, t.parent_category_id, t.slug, r.level + 1
+			FROM category t
+			INNER JOIN recursive_cte r ON r.parent_category_id = t.category_id
+			WHERE r.level < 3
+		)
+		SELECT c.category_id,
+		EXISTS (SELECT 1 FROM category ch WHERE ch.parent_category_id = c.category_id),
+		COALESCE((SELECT slug FROM recursive_cte WHERE level = 3), c.slug)
+		FROM category c WHERE c.slug = $1;
+		`
+		c = &importCategory{}
+		err := tx.QueryRow(ctx, q, row.Category).Scan(&c.id, &c.hasChildren, &c.parentSlug)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c = nil
+			} else {
+				return nil, importRowError(row, "category", err)
+			}
+		}
+		categories[row.Category] = c
+	}
+
+	if c == nil {
+		return nil, &model.CatalogImportError{Row: row.Line, Column: "category", Message: msg.CategoryNotFound}
+	}
+	if c.hasChildren {
+		return nil, &model.CatalogImportError{Row: row.Line, Column: "category", Message: msg.ProductInvalidCategory}
+	}
+
+	return c, nil
+}
+
+// resolveBrand maps the brand by title or slug and creates it when neither is known.
+func (r *CatalogImportRepository) resolveBrand(ctx context.Context, tx db.Transaction, row model.CatalogRow,
+	counts *model.CatalogImportCounts) (int, string, error) {
+
+	var id int
+	var slug string
+
+	q := `SELECT brand_id, slug FROM brand WHERE lower(title) = lower($1) OR slug = $2
+	ORDER BY lower(title) = lower($1) DESC LIMIT 1;`
+
+	err := tx.QueryRow(ctx, q, row.Brand, row.BrandSlug).Scan(&id, &slug)
+	if err == nil {
+		return id, slug, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, "", err
+	}
+
+	q = "INSERT INTO brand (title, slug) VALUES ($1, $2) RETURNING brand_id, slug;"
+	err = tx.QueryRow(ctx, q, row.Brand, row.BrandSlug).Scan(&id, &slug)
+	if err != nil {
+		return 0, "", err
+	}
+	counts.BrandsCreated++
+
+	return id, slug, nil
+}
+
+// upsertModel updates the model with the row's article together with its product,
+// or creates the model under a product with the same title, brand and category.
+func (r *CatalogImportRepository) upsertModel(ctx context.Context, tx db.Transaction, row model.CatalogRow,
+	category *importCategory, brandId int, brandSlug string, counts *model.CatalogImportCounts) (int, error) {
+
+	var modelId, productId int
+
+	if row.Article != "" {
+		q := "SELECT product_model_id, product_id FROM product_model WHERE article = $1;"
+		err := tx.QueryRow(ctx, q, row.Article).Scan(&modelId, &productId)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return 0, err
+		}
+	}
+
+	if modelId != 0 {
+		q := "UPDATE product SET title = $1, description = $2, category_id = $3, brand_id = $4 WHERE product_id = $5;"
+		if _, err := tx.Exec(ctx, q, row.Product, row.Description, category.id, brandId, productId); err != nil {
+			return 0, err
+		}
+
+		var err error
+		if row.KeepPrice {
+			q = `UPDATE product_model SET main_image_path = COALESCE(NULLIF($1, ''), main_image_path),
+			updated_at = CURRENT_TIMESTAMP WHERE product_model_id = $2;`
+			_, err = tx.Exec(ctx, q, row.MainImage, modelId)
+		} else {
+			q = `UPDATE product_model SET price = $1, discount = $2, main_image_path = COALESCE(NULLIF($3, ''), main_image_path),
+			updated_at = CURRENT_TIMESTAMP WHERE product_model_id = $4;`
+			_, err = tx.Exec(ctx, q, row.Price, row.Discount, row.MainImage, modelId)
+		}
+		if err != nil {
+			return 0, err
+		}
+		counts.ModelsUpdated++
+
+		return modelId, nil
+	}
+
+	q := "SELECT product_id FROM product WHERE title = $1 AND brand_id = $2 AND category_id = $3 ORDER BY product_id LIMIT 1;"
+	err := tx.QueryRow(ctx, q, row.Product, brandId, category.id).Scan(&productId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		q = "INSERT INTO product (title, description, category_id, brand_id) VALUES ($1, $2, $3, $4) RETURNING product_id;"
+		err = tx.QueryRow(ctx, q, row.Product, row.Description, category.id, brandId).Scan(&productId)
+		if err != nil {
+			return 0, err
+		}
+		counts.ProductsCreated++
+	} else if err != nil {
+		return 0, err
+	}
+
+	q = `
+	WITH generated AS (
+		SELECT COALESCE(NULLIF($1::text, ''), LEFT(REPLACE(uuid_generate_v4()::text, '-', ''), 12)) AS article
+	)
+	INSERT INTO product_model (article, slug, price, discount, main_image_path, product_id)
+	SELECT article, $2 || '-' || article, $3, $4, $5, $6 FROM generated
+	RETURNING product_model_id;
+	`
+	slug := fmt.Sprintf("%s-%s-%s", category.parentSlug, brandSlug, row.TitleSlug)
+
+	err = tx.QueryRow(ctx, q, row.Article, slug, row.Price, row.Discount, row.MainImage, productId).Scan(&modelId)
+	if err != nil {
+		return 0, err
+	}
+	counts.ModelsCreated++
+
+	return modelId, nil
+}
+
+// upsertModelSize sets literal and stock of a model size, sizes are never removed here:
+// ordered model sizes are referenced by order items.
+func (r *CatalogImportRepository) upsertModelSize(ctx context.Context, tx db.Transaction, modelId int,
+	size model.CatalogRowSize, counts *model.CatalogImportCounts) error {
+
+	sizeId, err := r.resolveSize(ctx, tx, size.Value, counts)
+	if err != nil {
+		return err
+	}
+
+	q := "UPDATE model_sizes SET literal_size = $3, in_stock = $4 WHERE product_model_id = $1 AND size_id = $2;"
+	tag, err := tx.Exec(ctx, q, modelId, sizeId, size.Literal, size.InStock)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	q = "INSERT INTO model_sizes (product_model_id, size_id, literal_size, in_stock) VALUES ($1, $2, $3, $4);"
+	_, err = tx.Exec(ctx, q, modelId, sizeId, size.Literal, size.InStock)
+
+	return err
+}
+
+func (r *CatalogImportRepository) resolveSize(ctx context.Context, tx db.Transaction, value string,
+	counts *model.CatalogImportCounts) (int, error) {
+
+	var sizeId int
+
+	q := "SELECT size_id FROM sizes WHERE size_value = $1 ORDER BY size_id LIMIT 1;"
+	err := tx.QueryRow(ctx, q, value).Scan(&sizeId)
+	if err == nil {
+		return sizeId, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+
+	q = "INSERT INTO sizes (size_value) VALUES ($1) RETURNING size_id;"
+	if err := tx.QueryRow(ctx, q, value).Scan(&sizeId); err != nil {
+		return 0, err
+	}
+	counts.SizesCreated++
+
+	return sizeId, nil
+}
+
+// addModelOption maps the option by title or slug and its value by text, creating what is missing.
+func (r *CatalogImportRepository) addModelOption(ctx context.Context, tx db.Transaction, modelId int,
+	option model.CatalogRowOption, counts *model.CatalogImportCounts) error {
+
+	var optionId, valueId int
+
+	q := `SELECT option_id FROM option WHERE lower(title) = lower($1) OR slug = $2
+	ORDER BY lower(title) = lower($1) DESC LIMIT 1;`
+	err := tx.QueryRow(ctx, q, option.Option, option.OptionSlug).Scan(&optionId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		q = "INSERT INTO option (title, slug) VALUES ($1, $2) RETURNING option_id;"
+		if err := tx.QueryRow(ctx, q, option.Option, option.OptionSlug).Scan(&optionId); err != nil {
+			return err
+		}
+		counts.OptionsCreated++
+	} else if err != nil {
+		return err
+	}
+
+	q = "SELECT option_value_id FROM option_value WHERE option_id = $1 AND lower(value) = lower($2) ORDER BY option_value_id LIMIT 1;"
+	err = tx.QueryRow(ctx, q, optionId, option.Value).Scan(&valueId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		q = "INSERT INTO option_value (value, option_id) VALUES ($1, $2) RETURNING option_value_id;"
+		if err := tx.QueryRow(ctx, q, option.Value, optionId).Scan(&valueId); err != nil {
+			return err
+		}
+		counts.ValuesCreated++
+	} else if err != nil {
+		return err
+	}
+
+	q = `INSERT INTO product_model_option (product_model_id, option_id, option_value_id) SELECT $1, $2, $3
+	WHERE NOT EXISTS (SELECT 1 FROM product_model_option WHERE product_model_id = $1 AND option_value_id = $3);`
+	_, err = tx.Exec(ctx, q, modelId, optionId, valueId)
+
+	return err
+}
+
+// Export returns every model as an import row, so an exported file imports back without changes.
+func (r *CatalogImportRepository) Export(ctx context.Context) ([]model.CatalogRow, fall.Error) {
+	q := `
+	SELECT p.title, p.description, b.title, c.slug, pm.article, pm.price, pm.discount, pm.main_image_path,
+	ARRAY(SELECT i.img_path FROM product_model_img i WHERE i.product_model_id = pm.product_model_id
+		ORDER BY i.product_img_id),
+	COALESCE((SELECT jsonb_agg(jsonb_build_object('value', s.size_value, 'literal', ms.literal_size, 'in_stock', ms.in_stock)
+		ORDER BY ms.model_size_id)
+		FROM model_sizes ms INNER JOIN sizes s ON s.size_id = ms.size_id
+		WHERE ms.product_model_id = pm.product_model_id), '[]'),
+	COALESCE((SELECT jsonb_agg(jsonb_build_object('option', o.title, 'value', v.value) ORDER BY pmo.product_model_option_id)
+		FROM product_model_option pmo
+		INNER JOIN option o ON o.option_id = pmo.option_id
+		INNER JOIN option_value v ON v.option_value_id = pmo.option_value_id
+		WHERE pmo.product_model_id = pm.product_model_id), '[]')
+	FROM product_model pm
+	INNER JOIN product p ON pm.product_id = p.product_id
+	INNER JOIN brand b ON p.brand_id = b.brand_id
+	INNER JOIN category c ON p.category_id = c.category_id
+	ORDER BY p.product_id, pm.product_model_id;
+	`
+
+	rows, err := r.db.Query(ctx, q)
+	if err != nil {
+		return nil, fall.ServerError(fmt.Sprintf("%s, details: %s", msg.CatalogExportError, err.Error()))
+	}
+	defer rows.Close()
+
+	var result []model.CatalogRow
+
+	for rows.Next() {
+		row := model.CatalogRow{}
+		err := rows.Scan(&row.Product, &row.Description, &row.Brand, &row.Category, &row.Article, &row.Price,
+			&row.Discount, &row.MainImage, &row.Images, &row.Sizes, &row.Options)
+		if err != nil {
+			return nil, fall.ServerError(fmt.Sprintf("%s, details: %s", msg.CatalogExportError, err.Error()))
+		}
+		result = append(result, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(fmt.Sprintf("%s, details: %s", msg.CatalogExportError, err.Error()))
+	}
+
+	return result, nil
+}
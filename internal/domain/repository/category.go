@@ -163,6 +163,9 @@ func (r *CategoryRepository) Create(ctx context.Context, dto model.CreateCategor
 	_, err := r.db.Exec(ctx, query, dto.Title, dto.ImgPath, dto.ParentId, slug, dto.ShortTitle)
 
 	if err != nil {
+		if slugTaken(err) {
+			return fall.NewErr(msg.SlugTaken, fall.STATUS_CONFLICT)
+		}
 		return fall.NewErr(msg.CategoryCreateError, fall.STATUS_INTERNAL_ERROR)
 	}
 
@@ -357,6 +360,44 @@ func (r *CategoryRepository) GetParentTopLevel(ctx context.Context, id int) (*mo
 	return &c, nil
 }
 
+// GetPathSlugs returns the slugs of the category and all of its parents.
+func (r *CategoryRepository) GetPathSlugs(ctx context.Context, id int) ([]string, fall.Error) {
+	query := `
+	WITH RECURSIVE recursive_cte AS (
+		SELECT category_id, parent_category_id, slug
+		FROM category
+		WHERE category_id = $1
+		UNION ALL
+		SELECT t.category_id, t.parent_category_id, t.slug
+		FROM category t
+		INNER JOIN recursive_cte r ON r.parent_category_id = t.category_id
+	)
+	SELECT slug FROM recursive_cte;
+	`
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	var slugs []string
+
+	for rows.Next() {
+		var slug string
+		err := rows.Scan(&slug)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		slugs = append(slugs, slug)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return slugs, nil
+}
+
 func (r *CategoryRepository) GetAll(ctx context.Context) ([]*model.Category, fall.Error) {
 	query := `
 	WITH RECURSIVE category_tree AS (
@@ -492,6 +533,9 @@ func (r *CategoryRepository) Update(ctx context.Context, dto model.UpdateCategor
 		q := "UPDATE category SET " + strings.Join(queries, ",") + " WHERE category_id = $1;"
 		_, err := r.db.Exec(ctx, q, id)
 		if err != nil {
+			if slugTaken(err) {
+				return fall.NewErr(msg.SlugTaken, fall.STATUS_CONFLICT)
+			}
 			return fall.ServerError(fmt.Sprintf("%s, details: \n %s", msg.CategoryUpdateError, err.Error()))
 		}
 		return nil
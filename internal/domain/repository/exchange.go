@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+const exchangeOrdersLimit = 500
+
+// ExchangeRepository writes what 1C sends through the CommerceML exchange, product_model.article is the shared key.
+// Unlike the file import a failed entry is skipped and reported, the rest of its batch is committed.
+type ExchangeRepository struct {
+	db      db.PostgresClient
+	catalog *CatalogImportRepository
+}
+
+func NewExchangeRepository(db db.PostgresClient, catalog *CatalogImportRepository) *ExchangeRepository {
+	return &ExchangeRepository{db: db, catalog: catalog}
+}
+
+func (r *ExchangeRepository) ImportProducts(ctx context.Context, rows []model.CatalogRow) (*model.ExchangeReport, fall.Error) {
+	report := &model.ExchangeReport{ImportId: uuid.New().String(), Errors: []string{}}
+	categories := make(map[string]*importCategory)
+
+	for start := 0; start < len(rows); start += model.CatalogImportBatchSize {
+		batch := rows[start:min(start+model.CatalogImportBatchSize, len(rows))]
+
+		tx, err := r.db.Begin(ctx)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+
+		err = trackStock(ctx, tx, model.StockSource{Type: model.StockImport, ImportId: &report.ImportId})
+		if err != nil {
+			tx.Rollback(ctx)
+			return nil, fall.ServerError(err.Error())
+		}
+
+		counts := model.CatalogImportCounts{}
+
+		for _, row := range batch {
+			rowErr, ex := r.catalog.importRowSavepoint(ctx, tx, row, categories, &counts)
+			if ex != nil {
+				tx.Rollback(ctx)
+				return nil, ex
+			}
+			if rowErr != nil {
+				report.Skipped++
+				report.Errors = append(report.Errors, fmt.Sprintf("%s (%s): %s", row.Article, rowErr.Column, rowErr.Message))
+				continue
+			}
+			report.Processed++
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+	}
+
+	return report, nil
+}
+
+func (r *ExchangeRepository) ImportOffers(ctx context.Context, offers []model.ExchangeOffer) (*model.ExchangeReport, fall.Error) {
+	report := &model.ExchangeReport{ImportId: uuid.New().String(), Errors: []string{}}
+
+	for start := 0; start < len(offers); start += model.CatalogImportBatchSize {
+		batch := offers[start:min(start+model.CatalogImportBatchSize, len(offers))]
+
+		tx, err := r.db.Begin(ctx)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+
+		err = trackStock(ctx, tx, model.StockSource{Type: model.StockImport, ImportId: &report.ImportId})
+		if err != nil {
+			tx.Rollback(ctx)
+			return nil, fall.ServerError(err.Error())
+		}
+
+		for _, offer := range batch {
+			sp, err := tx.Begin(ctx)
+			if err != nil {
+				tx.Rollback(ctx)
+				return nil, fall.ServerError(err.Error())
+			}
+
+			err = r.importOffer(ctx, sp, offer)
+			if err != nil {
+				sp.Rollback(ctx)
+				report.Skipped++
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", offer.Article, err.Error()))
+				continue
+			}
+
+			if err := sp.Commit(ctx); err != nil {
+				tx.Rollback(ctx)
+				return nil, fall.ServerError(err.Error())
+			}
+			report.Processed++
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+	}
+
+	return report, nil
+}
+
+// importOffer sets the price of the model and the stock of one of its sizes.
+// 1C counts the units of orders it hasn't received yet as in stock, so their reservations are taken out of its quantity.
+func (r *ExchangeRepository) importOffer(ctx context.Context, tx db.Transaction, offer model.ExchangeOffer) error {
+	var modelId int
+
+	q := "SELECT product_model_id FROM product_model WHERE article = $1;"
+	err := tx.QueryRow(ctx, q, offer.Article).Scan(&modelId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errors.New(msg.ProductModelNotFound)
+		}
+		return err
+	}
+
+	if offer.Price != nil {
+		q = "UPDATE product_model SET price = $1, updated_at = CURRENT_TIMESTAMP WHERE product_model_id = $2 AND price <> $1;"
+		if _, err := tx.Exec(ctx, q, *offer.Price, modelId); err != nil {
+			return err
+		}
+	}
+
+	if offer.Size == nil || offer.Stock == nil {
+		return nil
+	}
+
+	sizeId, err := r.catalog.resolveSize(ctx, tx, *offer.Size, &model.CatalogImportCounts{})
+	if err != nil {
+		return err
+	}
+
+	q = `
+	UPDATE model_sizes ms SET in_stock = GREATEST($3 - COALESCE((
+		SELECT SUM(sr.quantity) FROM stock_reservation sr
+		INNER JOIN public.order o ON o.order_id = sr.order_id
+		WHERE sr.model_size_id = ms.model_size_id AND sr.reservation_status IN ('active', 'sold') AND o.exported_at IS NULL
+	), 0), 0)
+	WHERE ms.product_model_id = $1 AND ms.size_id = $2;
+	`
+	tag, err := tx.Exec(ctx, q, modelId, sizeId, *offer.Stock)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	q = "INSERT INTO model_sizes (product_model_id, size_id, literal_size, in_stock) VALUES ($1, $2, $3, $4);"
+	_, err = tx.Exec(ctx, q, modelId, sizeId, *offer.Size, max(*offer.Stock, 0))
+
+	return err
+}
+
+// GetNewOrders returns confirmed orders that were not sent to 1C yet, oldest first.
+func (r *ExchangeRepository) GetNewOrders(ctx context.Context) ([]model.ExchangeOrder, fall.Error) {
+	q := `
+	SELECT o.order_id, o.created_at, o.order_status, o.order_payment_method, o.conditions, o.total_price,
+	o.promo_discount, o.delivery_price, u.user_id, u.email, o.recipient_firstname, o.recipient_lastname, o.recipient_phone,
+	pm.article, p.title, sz.size_value, om.quantity, om.price, om.discount
+	FROM public.order o
+	INNER JOIN public.user u ON u.user_id = o.user_id
+	INNER JOIN order_model om ON om.order_id = o.order_id
+	INNER JOIN model_sizes ms ON ms.model_size_id = om.model_size_id
+	INNER JOIN sizes sz ON sz.size_id = ms.size_id
+	INNER JOIN product_model pm ON pm.product_model_id = ms.product_model_id
+	INNER JOIN product p ON p.product_id = pm.product_id
+	WHERE o.order_id IN (
+		SELECT order_id FROM public.order
+		WHERE exported_at IS NULL AND order_status NOT IN ('waiting_for_activation', 'waiting_for_payment', 'canceled')
+		ORDER BY created_at LIMIT $1
+	)
+	ORDER BY o.created_at, o.order_id, om.order_model_id;
+	`
+
+	rows, err := r.db.Query(ctx, q, exchangeOrdersLimit)
+	if err != nil {
+		return nil, fall.ServerError(fmt.Sprintf("%s, details: %s", msg.ExchangeOrdersError, err.Error()))
+	}
+	defer rows.Close()
+
+	var orders []model.ExchangeOrder
+
+	for rows.Next() {
+		o := model.ExchangeOrder{}
+		item := model.ExchangeOrderItem{}
+
+		err := rows.Scan(&o.Id, &o.CreatedAt, &o.Status, &o.PaymentMethod, &o.Conditions, &o.TotalPrice,
+			&o.PromoDiscount, &o.DeliveryPrice, &o.UserId, &o.UserEmail, &o.RecipientFirstname, &o.RecipientLastname,
+			&o.RecipientPhone, &item.Article, &item.Title, &item.Size, &item.Quantity, &item.Price, &item.Discount)
+		if err != nil {
+			return nil, fall.ServerError(fmt.Sprintf("%s, details: %s", msg.ExchangeOrdersError, err.Error()))
+		}
+
+		if len(orders) == 0 || orders[len(orders)-1].Id != o.Id {
+			orders = append(orders, o)
+		}
+		last := &orders[len(orders)-1]
+		last.Items = append(last.Items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(fmt.Sprintf("%s, details: %s", msg.ExchangeOrdersError, err.Error()))
+	}
+
+	return orders, nil
+}
+
+func (r *ExchangeRepository) MarkOrdersExported(ctx context.Context, ids []string) fall.Error {
+	q := "UPDATE public.order SET exported_at = CURRENT_TIMESTAMP WHERE order_id = ANY($1::uuid[]) AND exported_at IS NULL;"
+
+	_, err := r.db.Exec(ctx, q, ids)
+	if err != nil {
+		return fall.ServerError(fmt.Sprintf("%s, details: %s", msg.ExchangeOrdersError, err.Error()))
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type FeedRepository struct {
+	db db.PostgresClient
+}
+
+func NewFeedRepository(db db.PostgresClient) *FeedRepository {
+	return &FeedRepository{db: db}
+}
+
+// GetCatalog reads listed models from the catalog projection with their descriptions and options.
+func (r *FeedRepository) GetCatalog(ctx context.Context) (*model.FeedCatalog, fall.Error) {
+	catalog := &model.FeedCatalog{}
+
+	rows, err := r.db.Query(ctx, "SELECT category_id, parent_category_id, title FROM category ORDER BY category_id;")
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	for rows.Next() {
+		c := model.FeedCategory{}
+		if err := rows.Scan(&c.Id, &c.ParentId, &c.Title); err != nil {
+			rows.Close()
+			return nil, fall.ServerError(err.Error())
+		}
+		catalog.Categories = append(catalog.Categories, c)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	q := `
+	SELECT cm.product_model_id, cm.title, p.description, cm.slug, cm.article, cm.price, cm.discount, cm.brand_title,
+	cm.category_id, cm.main_image_path,
+	ARRAY(SELECT img->>'img_path' FROM jsonb_array_elements(cm.images) img),
+	cm.sizes,
+	COALESCE((SELECT jsonb_agg(jsonb_build_object('name', o.title, 'value', v.value) ORDER BY pmo.product_model_option_id)
+		FROM product_model_option pmo
+		INNER JOIN option o ON o.option_id = pmo.option_id
+		INNER JOIN option_value v ON v.option_value_id = pmo.option_value_id
+		WHERE pmo.product_model_id = cm.product_model_id), '[]')
+	FROM catalog_model cm
+	INNER JOIN product p ON p.product_id = cm.product_id
+	WHERE cm.listed
+	ORDER BY cm.product_model_id;
+	`
+
+	rows, err = r.db.Query(ctx, q)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := model.FeedModel{}
+		err := rows.Scan(&m.Id, &m.Title, &m.Description, &m.Slug, &m.Article, &m.Price, &m.Discount, &m.Brand,
+			&m.CategoryId, &m.MainImage, &m.Images, &m.Sizes, &m.Params)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		catalog.Models = append(catalog.Models, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return catalog, nil
+}
+
+func (r *FeedRepository) StartGeneration(ctx context.Context, feed string) fall.Error {
+	q := `INSERT INTO feed_generation (feed, feed_status) VALUES ($1, $2)
+	ON CONFLICT (feed) DO UPDATE SET feed_status = EXCLUDED.feed_status, started_at = CURRENT_TIMESTAMP, finished_at = NULL;`
+
+	_, err := r.db.Exec(ctx, q, feed, model.FeedRunning)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	return nil
+}
+
+// FinishGeneration stores the result of a run, a failed run keeps the path of the last feed that was written.
+func (r *FeedRepository) FinishGeneration(ctx context.Context, g model.FeedGeneration) fall.Error {
+	q := `UPDATE feed_generation SET feed_status = $2, finished_at = CURRENT_TIMESTAMP, offers = $3,
+	path = COALESCE($4, path), error = $5, warnings = $6 WHERE feed = $1;`
+
+	_, err := r.db.Exec(ctx, q, g.Feed, g.Status, g.Offers, g.Path, g.Error, g.Warnings)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	return nil
+}
+
+func (r *FeedRepository) GetGenerations(ctx context.Context) ([]model.FeedGeneration, fall.Error) {
+	q := `SELECT feed, feed_status, started_at, finished_at, offers, path, error, warnings
+	FROM feed_generation ORDER BY feed;`
+
+	rows, err := r.db.Query(ctx, q)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	generations := []model.FeedGeneration{}
+
+	for rows.Next() {
+		g := model.FeedGeneration{}
+		err := rows.Scan(&g.Feed, &g.Status, &g.StartedAt, &g.FinishedAt, &g.Offers, &g.Path, &g.Error, &g.Warnings)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		generations = append(generations, g)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return generations, nil
+}
+
+func (r *FeedRepository) GetPath(ctx context.Context, feed string) (string, fall.Error) {
+	var path *string
+
+	err := r.db.QueryRow(ctx, "SELECT path FROM feed_generation WHERE feed = $1;", feed).Scan(&path)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", fall.ServerError(err.Error())
+	}
+	if path == nil {
+		return "", fall.NewErr(msg.FeedNotFound, fall.STATUS_NOT_FOUND)
+	}
+
+	return *path, nil
+}
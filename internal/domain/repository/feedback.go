@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 )
 
 type FeedbackRepository struct {
@@ -120,24 +123,47 @@ func (r *FeedbackRepository) GetModelFeedback(ctx context.Context, modelId int,
 	return &feedbackResponse, nil
 }
 
-func (r *FeedbackRepository) GetAll(ctx context.Context, order string, page int, filter string) (*model.AdminAllFeedbackResponse, fall.Error) {
+func (r *FeedbackRepository) GetAll(ctx context.Context, order string, page pagination.Page, filter string) (*model.AdminAllFeedbackResponse, fall.Error) {
 
-	limit := 16
-
-	offset := page*limit - limit
-	pagination := fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+	desc := order == "DESC"
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
 
-	where := ""
+	var conditions []string
+	var args []any
 
 	switch filter {
-	case string(model.All):
-		where = ""
 	case string(model.OnlyActive):
-		where = "WHERE f.is_hidden = FALSE"
+		conditions = append(conditions, "f.is_hidden = FALSE")
 	case string(model.OnlyHidden):
-		where = "WHERE f.is_hidden = TRUE"
-	default:
-		where = ""
+		conditions = append(conditions, "f.is_hidden = TRUE")
+	}
+
+	totalWhere := ""
+	if len(conditions) > 0 {
+		totalWhere = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if page.Cursor != nil {
+		args = append(args, page.Cursor.Key, page.Cursor.Id)
+		conditions = append(conditions, pagination.After("f.created_at", "f.feedback_id", desc,
+			fmt.Sprintf("$%d::timestamp", len(args)-1), fmt.Sprintf("$%d::int", len(args))))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, page.Limit+1)
+	limit := len(args)
+
+	offset := ""
+	if page.Offset > 0 {
+		args = append(args, page.Offset)
+		offset = fmt.Sprintf(" OFFSET $%d", len(args))
 	}
 
 	query := fmt.Sprintf(`
@@ -145,46 +171,64 @@ func (r *FeedbackRepository) GetAll(ctx context.Context, order string, page int,
 	f.product_model_id as f_model_id, f.is_hidden as f_hidden,
 	u.user_id as u_id, u.email as u_email,
 	u.avatar_path as u_avatar_path, u.first_name as u_first_name, u.last_name as u_last_name,
-	(select count(distinct f.feedback_id) 
+	(select count(distinct f.feedback_id)
 	FROM feedback as f
 	INNER JOIN public.user as u ON f.user_id = u.user_id
 	INNER JOIN product_model as pm ON pm.product_model_id = f.product_model_id
 	%s
-	) as total_count
+	) as total_count, f.created_at::text as f_cursor
 	FROM feedback as f
 	INNER JOIN public.user as u ON f.user_id = u.user_id
 	INNER JOIN product_model as pm ON pm.product_model_id = f.product_model_id
 	%s
-	ORDER BY f.created_at %s %s;
-	`, where, where, order, pagination)
+	ORDER BY f.created_at %[3]s, f.feedback_id %[3]s
+	LIMIT $%[4]d%[5]s;
+	`, totalWhere, where, direction, limit, offset)
 
-	rows, err := r.db.Query(ctx, query)
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fall.ServerError(err.Error())
 	}
 	defer rows.Close()
-	var result []model.Feedback
+
+	type feedbackRow struct {
+		feedback model.Feedback
+		cursor   string
+	}
+
+	var result []feedbackRow
 	var total int
 	for rows.Next() {
 
-		f := model.Feedback{}
+		row := feedbackRow{}
+		f := &row.feedback
 
 		err := rows.Scan(&f.Id, &f.CreatedAt, &f.UpdatedAt, &f.Text, &f.Rate, &f.ModelId, &f.Hidden,
-			&f.User.Id, &f.User.Email, &f.User.Avatar, &f.User.FirstName, &f.User.LastName, &total,
+			&f.User.Id, &f.User.Email, &f.User.Avatar, &f.User.FirstName, &f.User.LastName, &total, &row.cursor,
 		)
 		if err != nil {
 			return nil, fall.ServerError(err.Error())
 		}
-		result = append(result, f)
+		result = append(result, row)
 	}
 
 	if rows.Err() != nil {
 		return nil, fall.ServerError(rows.Err().Error())
 	}
 
+	result, nextCursor := pagination.Trim(result, page.Limit, model.FeedbackSort(order), func(row feedbackRow) (string, string) {
+		return row.cursor, strconv.Itoa(row.feedback.Id)
+	})
+
+	feedback := make([]model.Feedback, 0, len(result))
+	for _, row := range result {
+		feedback = append(feedback, row.feedback)
+	}
+
 	return &model.AdminAllFeedbackResponse{
-		Feedback: result,
-		Total:    total,
+		Feedback:   feedback,
+		Total:      total,
+		NextCursor: nextCursor,
 	}, nil
 }
 
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/imageproc"
+)
+
+type ImageRepository struct {
+	db db.PostgresClient
+}
+
+func NewImageRepository(db db.PostgresClient) *ImageRepository {
+	return &ImageRepository{db: db}
+}
+
+const unprocessedImages = `
+	SELECT $1::text AS kind, product_img_id AS id, img_path AS path FROM product_model_img
+	WHERE img_path !~ $3 AND NOT (img_path = ANY($4))
+	UNION ALL
+	SELECT $2::text, product_model_id, main_image_path FROM product_model
+	WHERE main_image_path !~ $3 AND NOT (main_image_path = ANY($4))
+`
+
+// GetUnprocessed finds photos and main images that aren't renditions yet, skip lists paths that already failed.
+func (r *ImageRepository) GetUnprocessed(ctx context.Context, limit int, skip []string) ([]model.ImageRecord, fall.Error) {
+	q := unprocessedImages + "ORDER BY path, kind, id LIMIT $5;"
+
+	rows, err := r.db.Query(ctx, q, model.ImageModelPhoto, model.ImageModelMain, imageproc.KeyPattern, skip, limit)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	records := []model.ImageRecord{}
+	for rows.Next() {
+		rec := model.ImageRecord{}
+		if err := rows.Scan(&rec.Kind, &rec.Id, &rec.Path); err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		records = append(records, rec)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return records, nil
+}
+
+func (r *ImageRepository) CountUnprocessed(ctx context.Context, skip []string) (int, fall.Error) {
+	q := "SELECT count(*) FROM (" + unprocessedImages + ") images;"
+
+	var count int
+	err := r.db.QueryRow(ctx, q, model.ImageModelPhoto, model.ImageModelMain, imageproc.KeyPattern, skip).Scan(&count)
+	if err != nil {
+		return 0, fall.ServerError(err.Error())
+	}
+
+	return count, nil
+}
+
+// UpdatePath points the record at the processed image and returns the product it belongs to.
+func (r *ImageRepository) UpdatePath(ctx context.Context, rec model.ImageRecord, path string) (int, fall.Error) {
+	q := `UPDATE product_model_img SET img_path = $1 WHERE product_img_id = $2
+	RETURNING (SELECT product_id FROM product_model pm WHERE pm.product_model_id = product_model_img.product_model_id);`
+	if rec.Kind == model.ImageModelMain {
+		q = "UPDATE product_model SET main_image_path = $1 WHERE product_model_id = $2 RETURNING product_id;"
+	}
+
+	var productId int
+
+	err := r.db.QueryRow(ctx, q, path, rec.Id).Scan(&productId)
+	if err != nil {
+		return 0, fall.ServerError(fmt.Sprintf("%s, details: \n %s", msg.ImageUpdatePathError, err.Error()))
+	}
+
+	return productId, nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/generator"
 )
 
 type OptionRepository struct {
@@ -69,6 +70,47 @@ func (r *OptionRepository) CreateValue(ctx context.Context, dto model.CreateOpti
 	return nil
 }
 
+// catalogScopes select the affected product_model_id values for each scope, $1 is the id the write is keyed on.
+var catalogScopes = map[model.CatalogScope]string{
+	model.ScopeModel:       "SELECT product_model_id FROM product_model WHERE product_model_id = $1",
+	model.ScopeModelSize:   "SELECT product_model_id FROM model_sizes WHERE model_size_id = $1",
+	model.ScopeModelOption: "SELECT product_model_id FROM product_model_option WHERE product_model_option_id = $1",
+	model.ScopeOption:      "SELECT product_model_id FROM product_model_option WHERE option_id = $1",
+	model.ScopeValue:       "SELECT product_model_id FROM product_model_option WHERE option_value_id = $1",
+	model.ScopeSize:        "SELECT product_model_id FROM model_sizes WHERE size_id = $1",
+}
+
+// FindCatalogTargets returns what shows the models the scope refers to. It has to run before a delete.
+func (r *OptionRepository) FindCatalogTargets(ctx context.Context, scope model.CatalogScope, id int) (*model.CatalogTargets, fall.Error) {
+	models, ok := catalogScopes[scope]
+	if !ok {
+		return nil, fall.ServerError("unknown catalog scope: " + string(scope))
+	}
+
+	q := fmt.Sprintf(`
+	WITH RECURSIVE products AS (
+		SELECT DISTINCT p.product_id, p.category_id FROM product p
+		INNER JOIN product_model pm ON pm.product_id = p.product_id
+		WHERE pm.product_model_id IN (%s)
+	), category_path AS (
+		SELECT c.category_id, c.parent_category_id, c.slug FROM category c
+		WHERE c.category_id IN (SELECT category_id FROM products)
+		UNION
+		SELECT c.category_id, c.parent_category_id, c.slug FROM category c
+		INNER JOIN category_path cp ON cp.parent_category_id = c.category_id
+	)
+	SELECT ARRAY(SELECT product_id FROM products), ARRAY(SELECT slug FROM category_path);`, models)
+
+	targets := model.CatalogTargets{}
+
+	err := r.db.QueryRow(ctx, q, id).Scan(&targets.ProductIds, &targets.CategorySlugs)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return &targets, nil
+}
+
 func (r *OptionRepository) DeleteOption(ctx context.Context, id int) fall.Error {
 	query := `
   DELETE FROM option WHERE option_id = $1;
@@ -279,14 +321,30 @@ func (r *OptionRepository) AddOptionToProductModel(ctx context.Context, dto mode
 	return nil
 }
 
-func (r *OptionRepository) AddSizeToProductModel(ctx context.Context, dto model.AddSizeToProductModelDto) fall.Error {
+func (r *OptionRepository) AddSizeToProductModel(ctx context.Context, userId int, dto model.AddSizeToProductModelDto) fall.Error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+	defer tx.Rollback(ctx)
+
+	// the opening stock is an adjustment by whoever added the size
+	comment := "size added"
+	err = trackStock(ctx, tx, model.StockSource{Type: model.StockAdjustment, UserId: &userId, Comment: &comment})
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
 	query := `INSERT INTO model_sizes (product_model_id, size_id, literal_size, in_stock) VALUES ($1,$2,$3,$4);`
 
-	_, err := r.db.Exec(ctx, query, dto.ProductModelId, dto.SizeId, dto.Literal, dto.InStock)
+	_, err = tx.Exec(ctx, query, dto.ProductModelId, dto.SizeId, dto.Literal, dto.InStock)
 	if err != nil {
 		return fall.ServerError(msg.AddSizeToProductError)
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return fall.ServerError(msg.AddSizeToProductError)
+	}
 	return nil
 }
 
@@ -301,7 +359,9 @@ func (r *OptionRepository) CreateSize(ctx context.Context, value string) fall.Er
 	return nil
 }
 
-func (r *OptionRepository) GetCatalogFilters(ctx context.Context, categorySlug string) (*model.CatalogFilters, fall.Error) {
+func (r *OptionRepository) GetCatalogFilters(ctx context.Context, params generator.CatalogParams) (*model.CatalogFilters, fall.Error) {
+
+	categorySlug := params.Slug
 
 	mainQuery := `
 	FROM product as p
@@ -399,6 +459,29 @@ func (r *OptionRepository) GetCatalogFilters(ctx context.Context, categorySlug s
 	sizes := make([]model.CatalogSize, 0, len(sizeMap))
 	brands := make([]model.CatalogBrand, 0, len(brandsMap))
 
+	counts, ex := r.getFacetCounts(ctx, generator.GenerateFacetQuery(params))
+	if ex != nil {
+		return nil, ex
+	}
+
+	for key, v := range valuesMap {
+		v.Count = counts[generator.FacetOption][key]
+		v.Disabled = v.Count == 0
+		valuesMap[key] = v
+	}
+
+	for key, sz := range sizeMap {
+		sz.Count = counts[generator.FacetSize][key]
+		sz.Disabled = sz.Count == 0
+		sizeMap[key] = sz
+	}
+
+	for key, b := range brandsMap {
+		b.Count = counts[generator.FacetBrand][key]
+		b.Disabled = b.Count == 0
+		brandsMap[key] = b
+	}
+
 	for _, key := range valuesOrder {
 		v := valuesMap[key]
 		opt := optionsMap[v.OptionId]
@@ -440,6 +523,57 @@ func (r *OptionRepository) GetCatalogFilters(ctx context.Context, categorySlug s
 
 }
 
+// getFacetCounts returns the number of matching models per value id for every facet kind.
+func (r *OptionRepository) getFacetCounts(ctx context.Context, sql generator.GeneratedFacetQuery) (map[generator.FacetKind]map[int]int, fall.Error) {
+	parts := make([]string, 0, len(sql.Facets))
+
+	for _, f := range sql.Facets {
+		parts = append(parts, fmt.Sprintf(`(SELECT '%s' as kind, %s as value_id, count(DISTINCT cm.product_model_id) as models
+		%s %s %s
+		GROUP BY %s)`, f.Kind, f.Column, sql.From, f.Joins, f.Where, f.Column))
+	}
+
+	query := fmt.Sprintf(`
+	WITH RECURSIVE category_tree AS (
+		SELECT category_id, title, short_title, slug, parent_category_id
+		FROM category
+		WHERE slug = $1
+		UNION ALL
+		SELECT c.category_id, c.title, c.short_title, c.slug, c.parent_category_id
+		FROM category c
+		INNER JOIN category_tree ct ON c.parent_category_id = ct.category_id
+	)
+	%s;`, strings.Join(parts, "\n\tUNION ALL\n\t"))
+
+	rows, err := r.db.Query(ctx, query, sql.Args...)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	counts := map[generator.FacetKind]map[int]int{
+		generator.FacetBrand:  {},
+		generator.FacetSize:   {},
+		generator.FacetOption: {},
+	}
+
+	for rows.Next() {
+		var kind generator.FacetKind
+		var valueId, models int
+		err := rows.Scan(&kind, &valueId, &models)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		counts[kind][valueId] = models
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return counts, nil
+}
+
 func (r *OptionRepository) UpdateOption(ctx context.Context, dto model.UpdateOptionDto, id int) fall.Error {
 
 	var queries []string
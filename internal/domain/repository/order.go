@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -11,28 +13,57 @@ import (
 	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/payment"
 )
 
 type orderProductRepository interface {
 	ReturnQuantityInStock(ctx context.Context, modelSizeId int, quantity int, tx db.Transaction) fall.Error
-	ReduceQuantityInStock(ctx context.Context, modelSizeId int, quantity int, tx db.Transaction) fall.Error
+}
+
+type orderReservationRepository interface {
+	Reserve(ctx context.Context, tx db.Transaction, orderId string, items []model.StockChange, ttl time.Duration) fall.Error
+	Sell(ctx context.Context, tx db.Transaction, orderId string) fall.Error
+	Release(ctx context.Context, tx db.Transaction, orderId string) fall.Error
+	Extend(ctx context.Context, tx db.Transaction, orderId string, ttl time.Duration) fall.Error
 }
 
 type orderWishRepository interface {
 	RemoveSeveralItems(ctx context.Context, tx db.Transaction, cartIds []int) fall.Error
 }
 
+type orderPromoRepository interface {
+	Redeem(ctx context.Context, tx db.Transaction, id int, orderId string, userId int, discount int) fall.Error
+	Release(ctx context.Context, tx db.Transaction, orderId string) fall.Error
+}
+
+type orderReturnRepository interface {
+	RequestRefund(ctx context.Context, tx db.Transaction, orderId string, returnId *int, paymentId string, amount float64) fall.Error
+	FindByOrder(ctx context.Context, orderId string) ([]model.OrderReturn, fall.Error)
+	FindRefundsByOrder(ctx context.Context, orderId string) ([]model.OrderRefund, fall.Error)
+}
+
+type orderOutboxRepository interface {
+	Add(ctx context.Context, tx db.Transaction, topic string, payload any) (*int64, fall.Error)
+}
+
 type OrderRepository struct {
-	db                db.PostgresClient
-	wishRepository    orderWishRepository
-	productRepository orderProductRepository
-	paymentService    *payment.PaymentService
+	db                    db.PostgresClient
+	wishRepository        orderWishRepository
+	productRepository     orderProductRepository
+	promoRepository       orderPromoRepository
+	returnRepository      orderReturnRepository
+	outboxRepository      orderOutboxRepository
+	reservationRepository orderReservationRepository
 }
 
 func NewOrderRepository(db db.PostgresClient, wishRepository orderWishRepository,
-	productRepository orderProductRepository, paymentService *payment.PaymentService) *OrderRepository {
-	return &OrderRepository{db: db, wishRepository: wishRepository, productRepository: productRepository, paymentService: paymentService}
+	productRepository orderProductRepository, promoRepository orderPromoRepository,
+	returnRepository orderReturnRepository, outboxRepository orderOutboxRepository,
+	reservationRepository orderReservationRepository) *OrderRepository {
+	return &OrderRepository{db: db, wishRepository: wishRepository, productRepository: productRepository,
+		promoRepository: promoRepository, returnRepository: returnRepository, outboxRepository: outboxRepository,
+		reservationRepository: reservationRepository}
 }
 
 func (r *OrderRepository) Create(ctx context.Context, input model.CreateOrderInput, userId int) (*model.CreateOrderResponse, fall.Error) {
@@ -60,10 +91,10 @@ func (r *OrderRepository) Create(ctx context.Context, input model.CreateOrderInp
 		status = model.WaitingForPayment
 	}
 
-	query := `INSERT INTO public.order (order_payment_method,conditions,products_price,total_price,total_discount,delivery_price,recipient_firstname,recipient_lastname,recipient_phone,user_id, order_status) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+	query := `INSERT INTO public.order (order_payment_method,conditions,products_price,total_price,total_discount,promo_discount,delivery_price,recipient_firstname,recipient_lastname,recipient_phone,user_id, order_status) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
 	RETURNING order_id;`
 
-	row := tx.QueryRow(ctx, query, input.PaymentMethod, input.Conditions, input.ProductsPrice, input.TotalPrice, input.TotalDiscount, input.DeliveryPrice, input.RecipientFirstname, input.RecipientLastname, input.RecipientPhone, userId, status)
+	row := tx.QueryRow(ctx, query, input.PaymentMethod, input.Conditions, input.ProductsPrice, input.TotalPrice, input.TotalDiscount, input.PromoDiscount, input.DeliveryPrice, input.RecipientFirstname, input.RecipientLastname, input.RecipientPhone, userId, status)
 
 	var orderId string
 
@@ -73,6 +104,11 @@ func (r *OrderRepository) Create(ctx context.Context, input model.CreateOrderInp
 		return nil, ex
 	}
 
+	ex = r.addStatusHistory(ctx, tx, orderId, nil, model.OrderStatusChange{Status: status, ActorType: model.ActorUser, ActorId: &userId})
+	if ex != nil {
+		return nil, ex
+	}
+
 	for _, item := range input.CartItems {
 		ex = r.createOrderModel(ctx, tx, orderId, item)
 		if ex != nil {
@@ -81,6 +117,13 @@ func (r *OrderRepository) Create(ctx context.Context, input model.CreateOrderInp
 
 	}
 
+	if input.PromoCodeId != nil {
+		ex = r.promoRepository.Redeem(ctx, tx, *input.PromoCodeId, orderId, userId, input.PromoDiscount)
+		if ex != nil {
+			return nil, ex
+		}
+	}
+
 	ex = r.AddDeliveryPoint(ctx, tx, orderId, input.DeliveryPointId)
 	if ex != nil {
 		return nil, ex
@@ -102,14 +145,29 @@ func (r *OrderRepository) Create(ctx context.Context, input model.CreateOrderInp
 		}
 	}
 
+	stock := make([]model.StockChange, 0, len(input.CartItems))
 	for _, v := range input.CartItems {
-		ex = r.productRepository.ReduceQuantityInStock(ctx, v.ModelSizeId, v.Quantity, tx)
-		if ex != nil {
-			return nil, ex
-		}
+		stock = append(stock, model.StockChange{ModelSizeId: v.ModelSizeId, Quantity: v.Quantity})
+	}
+
+	ex = r.reservationRepository.Reserve(ctx, tx, orderId, stock, model.ReservationTTL(input.PaymentMethod))
+	if ex != nil {
+		return nil, ex
 	}
 
-	return &model.CreateOrderResponse{Link: *link, Id: orderId, Total: input.TotalPrice}, nil
+	var outboxId *int64
+
+	if input.PaymentMethod == model.Online {
+		outboxId, ex = r.outboxRepository.Add(ctx, tx, model.TopicPaymentCreate,
+			model.PaymentCreatePayload{OrderId: orderId, Total: input.TotalPrice})
+	} else {
+		outboxId, ex = r.outboxRepository.Add(ctx, tx, model.TopicOrderEmail, activationEmail(orderId, input.Email, *link))
+	}
+	if ex != nil {
+		return nil, ex
+	}
+
+	return &model.CreateOrderResponse{Link: *link, Id: orderId, Total: input.TotalPrice, OutboxId: outboxId}, nil
 }
 
 func (r *OrderRepository) createOrderModel(
@@ -163,10 +221,10 @@ func (or *OrderRepository) FindActivationLink(ctx context.Context, tx db.Transac
 func (or *OrderRepository) UpdateActivationLink(ctx context.Context, tx db.Transaction, activationId int) (*string, fall.Error) {
 	query := `UPDATE order_activation SET
 	link = uuid_generate_v4(),
-	end_time = CURRENT_TIMESTAMP + INTERVAL '4 hours'
+	end_time = CURRENT_TIMESTAMP + make_interval(secs => $2)
 	WHERE order_activation_id = $1 RETURNING link;`
 
-	row := tx.QueryRow(ctx, query, activationId)
+	row := tx.QueryRow(ctx, query, activationId, model.ActivationLinkTTL.Seconds())
 
 	var link string
 
@@ -184,9 +242,9 @@ func (or *OrderRepository) AddActivationLink(ctx context.Context, tx db.Transact
 
 	if err != nil {
 		query := `
-		INSERT INTO order_activation (order_id) VALUES ($1) RETURNING link;
+		INSERT INTO order_activation (order_id, end_time) VALUES ($1, CURRENT_TIMESTAMP + make_interval(secs => $2)) RETURNING link;
 		`
-		row := tx.QueryRow(ctx, query, orderId)
+		row := tx.QueryRow(ctx, query, orderId, model.ActivationLinkTTL.Seconds())
 		var link string
 		err := row.Scan(&link)
 		if err != nil {
@@ -201,17 +259,31 @@ func (or *OrderRepository) AddActivationLink(ctx context.Context, tx db.Transact
 	return link, nil
 }
 
-func (or *OrderRepository) ActivateOrder(link string) fall.Error {
-	ctx := context.Background()
-	query := `SELECT order_id FROM order_activation WHERE link = $1 AND end_time > CURRENT_TIMESTAMP;`
+func activationEmail(orderId string, to string, link string) model.OrderEmailPayload {
+	return model.OrderEmailPayload{
+		To:      to,
+		Subject: fmt.Sprintf("Подтверждение оформления заказа №: %s!", orderId),
+		Link:    fmt.Sprintf("/api/order/activate/%s", link),
+	}
+}
+
+func (or *OrderRepository) ActivateOrder(ctx context.Context, link string) fall.Error {
+	query := `SELECT oa.order_id, o.user_id, o.order_status FROM order_activation as oa
+	INNER JOIN public.order as o ON oa.order_id = o.order_id
+	WHERE oa.link = $1 AND oa.end_time > CURRENT_TIMESTAMP;`
 
 	row := or.db.QueryRow(ctx, query, link)
 
 	var orderId string
+	var userId int
+	var status model.OrderStatusEnum
 
-	err := row.Scan(&orderId)
+	err := row.Scan(&orderId, &userId, &status)
 
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fall.NewErr(msg.OrderActivationLinkNotFound, fall.STATUS_NOT_FOUND)
+		}
 		return fall.ServerError(msg.OrderErrorWhenActivate)
 	}
 
@@ -222,6 +294,10 @@ func (or *OrderRepository) ActivateOrder(link string) fall.Error {
 		return fall.ServerError(msg.OrderErrorWhenActivate)
 	}
 
+	if status == model.WaitingForActivation {
+		return or.changeStatus(ctx, orderId, model.OrderStatusChange{Status: model.InProcessing, ActorType: model.ActorUser, ActorId: &userId}, nil)
+	}
+
 	return nil
 
 }
@@ -317,7 +393,7 @@ func (or *OrderRepository) GetUserOrders(ctx context.Context, userId int) ([]*mo
 func (or *OrderRepository) GetOrder(ctx context.Context, id string) (*model.Order, fall.Error) {
 
 	query := `
-	SELECT o.order_id as o_id, o.payment_id as o_payment_id, o.created_at as o_created_at, o.updated_at as o_updated_at,
+	SELECT o.order_id as o_id, o.payment_id as o_payment_id, o.payment_url as o_payment_url, o.created_at as o_created_at, o.updated_at as o_updated_at,
 	o.delivery_date as o_delivery_date, o.is_activated as o_is_activated,
 	o.order_status as o_order_status, o.order_payment_method as o_payment_method,
 	o.conditions as o_conditions, o.products_price as o_products_price,
@@ -362,7 +438,7 @@ func (or *OrderRepository) GetOrder(ctx context.Context, id string) (*model.Orde
 
 	for rows.Next() {
 		m := model.OrderModel{}
-		err := rows.Scan(&o.Id, &o.PaymentId, &o.CreatedAt, &o.UpdatedAt, &o.DeliveryDate, &o.IsActivated, &o.Status, &o.PaymentMethod, &o.Conditions,
+		err := rows.Scan(&o.Id, &o.PaymentId, &o.PaymentUrl, &o.CreatedAt, &o.UpdatedAt, &o.DeliveryDate, &o.IsActivated, &o.Status, &o.PaymentMethod, &o.Conditions,
 			&o.ProductsPrice, &o.TotalPrice, &o.TotalDiscount, &o.PromoDiscount, &o.DeliveryPrice, &o.User.FirstName, &o.User.LastName,
 			&o.User.Phone, &o.User.Id, &o.User.Email, &m.OrderModelId, &m.Quantity, &m.Price, &m.Discount, &m.Size.SizeModelId, &m.Size.ModelId, &m.Size.SizeId, &m.Size.Literal, &m.Size.Value, &m.Size.InStock, &m.MainImagePath, &m.Product.ProductId, &m.Product.Title, &m.Slug, &m.Article,
 			&m.Product.Category.Id, &m.Product.Category.Title, &m.Product.Category.Slug,
@@ -389,62 +465,75 @@ func (or *OrderRepository) GetOrder(ctx context.Context, id string) (*model.Orde
 		return nil, fall.NewErr(msg.OrderNotFound, fall.STATUS_NOT_FOUND)
 	}
 
+	returns, ex := or.returnRepository.FindByOrder(ctx, o.Id)
+	if ex != nil {
+		return nil, ex
+	}
+	o.Returns = returns
+
+	refunds, ex := or.returnRepository.FindRefundsByOrder(ctx, o.Id)
+	if ex != nil {
+		return nil, ex
+	}
+	o.Refunds = refunds
+
+	history, ex := or.getStatusHistory(ctx, o.Id)
+	if ex != nil {
+		return nil, ex
+	}
+	o.History = history
+
 	return &o, nil
 }
 
-func (or *OrderRepository) GetAdminOrders(ctx context.Context, page int, fromDate *string, toDate *string) (*model.AllOrdersResponse, fall.Error) {
+func (or *OrderRepository) GetAdminOrders(ctx context.Context, page pagination.Page, fromDate *string, toDate *string) (*model.AllOrdersResponse, fall.Error) {
 
-	limit := 24
+	var conditions []string
+	var args []any
 
-	offset := page*limit - limit
+	if fromDate != nil {
+		args = append(args, *fromDate)
+		conditions = append(conditions, fmt.Sprintf("o.created_at >= $%d::timestamp", len(args)))
+	}
 
-	whereFilter := ""
+	if toDate != nil {
+		args = append(args, *toDate)
+		conditions = append(conditions, fmt.Sprintf("o.created_at <= $%d::timestamp", len(args)))
+	}
 
-	if fromDate != nil && toDate == nil {
-		whereFilter = fmt.Sprintf("WHERE o.created_at >= '%s'", *fromDate)
+	totalWhere := ""
+	if len(conditions) > 0 {
+		totalWhere = "WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	if fromDate == nil && toDate != nil {
-		whereFilter = fmt.Sprintf("WHERE o.created_at <= '%s'", *toDate)
+	if page.Cursor != nil {
+		args = append(args, page.Cursor.Key, page.Cursor.Id)
+		conditions = append(conditions, pagination.After("o.created_at", "o.order_id", true,
+			fmt.Sprintf("$%d::timestamp", len(args)-1), fmt.Sprintf("$%d::uuid", len(args))))
 	}
 
-	if fromDate != nil && toDate != nil {
-		whereFilter = fmt.Sprintf("WHERE o.created_at >= '%s' AND o.created_at <= '%s'", *fromDate, *toDate)
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
 
+	args = append(args, page.Limit+1)
+	limit := len(args)
+
+	offset := ""
+	if page.Offset > 0 {
+		args = append(args, page.Offset)
+		offset = fmt.Sprintf(" OFFSET $%d", len(args))
 	}
 
 	query := fmt.Sprintf(`
-	SELECT DISTINCT o.order_id as o_id, o.created_at,
-	(SELECT count(distinct o.order_id)
+	SELECT o.order_id, o.created_at::text, (SELECT count(*) FROM public.order as o %s) as total
 	FROM public.order as o
-	INNER JOIN public.user as u ON o.user_id = u.user_id
-	INNER JOIN order_model as om ON o.order_id = om.order_id
-	INNER JOIN model_sizes as ms ON om.model_size_id = ms.model_size_id
-	INNER JOIN product_model as pm ON ms.product_model_id = pm.product_model_id
-	INNER JOIN sizes as sz ON ms.size_id = sz.size_id
-	INNER JOIN product as p ON p.product_id = pm.product_id
-	INNER JOIN category as c on p.category_id = c.category_id
-	INNER JOIN brand as b on p.brand_id = b.brand_id
-	INNER JOIN order_delivery_point as odp ON o.order_id = odp.order_id
-	INNER JOIN delivery_point as dp ON odp.delivery_point_id = dp.delivery_point_id
-	) as total
-	FROM public.order as o
-	INNER JOIN public.user as u ON o.user_id = u.user_id
-	INNER JOIN order_model as om ON o.order_id = om.order_id
-	INNER JOIN model_sizes as ms ON om.model_size_id = ms.model_size_id
-	INNER JOIN product_model as pm ON ms.product_model_id = pm.product_model_id
-	INNER JOIN sizes as sz ON ms.size_id = sz.size_id
-	INNER JOIN product as p ON p.product_id = pm.product_id
-	INNER JOIN category as c on p.category_id = c.category_id
-	INNER JOIN brand as b on p.brand_id = b.brand_id
-	INNER JOIN order_delivery_point as odp ON o.order_id = odp.order_id
-	INNER JOIN delivery_point as dp ON odp.delivery_point_id = dp.delivery_point_id
 	%s
-	ORDER BY o.created_at DESC
-	LIMIT $1 OFFSET $2
-	;`, whereFilter)
+	ORDER BY o.created_at DESC, o.order_id DESC
+	LIMIT $%d%s;`, totalWhere, where, limit, offset)
 
-	rows, err := or.db.Query(ctx, query, limit, offset)
+	rows, err := or.db.Query(ctx, query, args...)
 
 	if err != nil {
 
@@ -452,23 +541,37 @@ func (or *OrderRepository) GetAdminOrders(ctx context.Context, page int, fromDat
 	}
 	defer rows.Close()
 
+	type orderRow struct {
+		id        string
+		createdAt string
+	}
+
 	var total int
-	var ordersOrder []string
+	var ordersPage []orderRow
 
 	for rows.Next() {
-		var orderId string
-		err := rows.Scan(&orderId, nil, &total)
+		row := orderRow{}
+		err := rows.Scan(&row.id, &row.createdAt, &total)
 		if err != nil {
 			return nil, fall.ServerError(err.Error())
 		}
 
-		ordersOrder = append(ordersOrder, orderId)
+		ordersPage = append(ordersPage, row)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fall.ServerError(err.Error())
 	}
 
+	ordersPage, nextCursor := pagination.Trim(ordersPage, page.Limit, model.AdminOrdersSort, func(row orderRow) (string, string) {
+		return row.createdAt, row.id
+	})
+
+	ordersOrder := make([]string, 0, len(ordersPage))
+	for _, row := range ordersPage {
+		ordersOrder = append(ordersOrder, row.id)
+	}
+
 	query = `
 	SELECT o.order_id as o_id, o.created_at as o_created_at, o.updated_at as o_updated_at,
 	o.delivery_date as o_delivery_date, o.is_activated as o_is_activated,
@@ -546,11 +649,14 @@ func (or *OrderRepository) GetAdminOrders(ctx context.Context, page int, fromDat
 	}
 
 	return &model.AllOrdersResponse{
-		Orders: orders, Total: total,
+		Orders: orders, Total: total, NextCursor: nextCursor,
 	}, nil
 }
 
-func (or *OrderRepository) SendNewActivationLink(ctx context.Context, orderId string) (*string, fall.Error) {
+// SendNewActivationLink replaces the activation link of an order that still waits for activation and queues
+// the email with it. The link and the reservation are renewed from the same timestamp, so the stock is held
+// for at least as long as the link is valid.
+func (or *OrderRepository) SendNewActivationLink(ctx context.Context, orderId string, user *model.LocalSession) (*int64, fall.Error) {
 
 	var ex fall.Error = nil
 
@@ -568,15 +674,21 @@ func (or *OrderRepository) SendNewActivationLink(ctx context.Context, orderId st
 		}
 	}()
 
-	query := "SELECT is_activated FROM public.order WHERE order_id = $1;"
+	// the lock keeps the expiry job from canceling the order while its reservation is extended
+	query := "SELECT is_activated, order_status FROM public.order WHERE order_id = $1 AND user_id = $2 FOR UPDATE;"
 
-	row := tx.QueryRow(ctx, query, orderId)
+	row := tx.QueryRow(ctx, query, orderId, user.UserId)
 
 	var isActivated bool
+	var status model.OrderStatusEnum
 
-	err = row.Scan(&isActivated)
+	err = row.Scan(&isActivated, &status)
 
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ex = fall.NewErr(msg.OrderNotFound, fall.STATUS_NOT_FOUND)
+			return nil, ex
+		}
 		ex = fall.ServerError(err.Error())
 		return nil, ex
 	}
@@ -585,14 +697,39 @@ func (or *OrderRepository) SendNewActivationLink(ctx context.Context, orderId st
 		ex = fall.NewErr(msg.OrderAlreadyActivated, fall.STATUS_BAD_REQUEST)
 		return nil, ex
 	}
+	if status != model.WaitingForActivation {
+		ex = fall.NewErr(msg.OrderNotWaitingForActivation, fall.STATUS_BAD_REQUEST)
+		return nil, ex
+	}
+
 	link, ex := or.AddActivationLink(ctx, tx, orderId)
 	if ex != nil {
 		return nil, ex
 	}
-	return link, nil
+
+	ex = or.reservationRepository.Extend(ctx, tx, orderId, model.ActivationLinkTTL)
+	if ex != nil {
+		return nil, ex
+	}
+
+	outboxId, ex := or.outboxRepository.Add(ctx, tx, model.TopicOrderEmail, activationEmail(orderId, user.Email, *link))
+	if ex != nil {
+		return nil, ex
+	}
+	return outboxId, nil
 }
 
 func (r *OrderRepository) CancelOrder(ctx context.Context, orderId string, userId int) fall.Error {
+	return r.changeStatus(ctx, orderId, model.OrderStatusChange{Status: model.Canceled, ActorType: model.ActorUser, ActorId: &userId}, &userId)
+}
+
+func (r *OrderRepository) ChangeStatus(ctx context.Context, orderId string, change model.OrderStatusChange) fall.Error {
+	return r.changeStatus(ctx, orderId, change, nil)
+}
+
+// changeStatus locks the order row, so concurrent changes of the same order are applied one by one
+// and a second cancellation can't return stock or refund twice.
+func (r *OrderRepository) changeStatus(ctx context.Context, orderId string, change model.OrderStatusChange, ownerId *int) fall.Error {
 
 	var ex fall.Error = nil
 
@@ -611,47 +748,220 @@ func (r *OrderRepository) CancelOrder(ctx context.Context, orderId string, userI
 		}
 	}()
 
-	order, ex := r.GetOrder(ctx, orderId)
+	q := "SELECT order_status, user_id, order_payment_method FROM public.order WHERE order_id = $1 FOR UPDATE;"
 
-	if ex != nil {
+	var current model.OrderStatusEnum
+	var orderUserId int
+	var method model.PaymentMethodEnum
+
+	err = tx.QueryRow(ctx, q, orderId).Scan(&current, &orderUserId, &method)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ex = fall.NewErr(msg.OrderNotFound, fall.STATUS_NOT_FOUND)
+			return ex
+		}
+		ex = fall.ServerError(err.Error())
+		return ex
+	}
+
+	if ownerId != nil && *ownerId != orderUserId {
+		ex = fall.NewErr(msg.OrderNotFound, fall.STATUS_NOT_FOUND)
+		return ex
+	}
+
+	if !model.CanChangeOrderStatus(current, change.Status) {
+		ex = fall.NewErr(fmt.Sprintf(msg.OrderIllegalStatusTransition, current, change.Status), fall.STATUS_BAD_REQUEST)
 		return ex
 	}
 
-	q := "UPDATE public.order SET order_status = 'canceled' WHERE order_id = $1 AND user_id = $2;"
+	q = "UPDATE public.order SET order_status = $1, updated_at = CURRENT_TIMESTAMP WHERE order_id = $2;"
 
-	_, err = tx.Exec(ctx, q, orderId, userId)
+	_, err = tx.Exec(ctx, q, change.Status, orderId)
 
 	if err != nil {
-		ex = fall.ServerError(msg.OrderErrorWhenCancel)
+		ex = fall.ServerError(msg.OrderErrorWhenChangeStatus)
+		return ex
+	}
+
+	ex = r.addStatusHistory(ctx, tx, orderId, &current, change)
+	if ex != nil {
 		return ex
 	}
 
-	for _, v := range order.Models {
-		ex = r.productRepository.ReturnQuantityInStock(ctx, v.Size.SizeModelId, v.Quantity, tx)
+	if model.IsSaleStatus(change.Status) {
+		ex = r.reservationRepository.Sell(ctx, tx, orderId)
 		if ex != nil {
 			return ex
 		}
 	}
 
-	if order.PaymentMethod == model.Online && order.PaymentId != nil {
-		refund, err := r.paymentService.RefundPayment(*order.PaymentId, order.TotalPrice)
+	if change.Status == model.Canceled {
+		ex = r.reservationRepository.Release(ctx, tx, orderId)
+		if ex != nil {
+			return ex
+		}
+
+		err = trackStock(ctx, tx, model.StockSource{Type: model.StockCancellation, OrderId: &orderId, UserId: change.ActorId})
 		if err != nil {
 			ex = fall.ServerError(err.Error())
 			return ex
 		}
-		if refund.Status == "canceled" {
-			if refund.RefundDetails != nil {
-				ex = fall.ServerError(fmt.Sprintf("Party: %s;Reason: %s.", refund.RefundDetails.Party, refund.RefundDetails.Reason))
-				return ex
-			}
-			ex = fall.ServerError("Ошибка при возврате средств. Попробуйте позже.")
+
+		ex = r.returnItems(ctx, tx, orderId)
+		if ex != nil {
+			return ex
+		}
+
+		ex = r.promoRepository.Release(ctx, tx, orderId)
+		if ex != nil {
+			return ex
+		}
+
+		if method == model.Online {
+			ex = r.releasePayment(ctx, tx, orderId, current)
 			return ex
 		}
 	}
+
 	return nil
 }
 
-func (r *OrderRepository) ChangeStatus(ctx context.Context, orderId string, status model.OrderStatusEnum) fall.Error {
+func (r *OrderRepository) addStatusHistory(ctx context.Context, tx db.Transaction, orderId string,
+	from *model.OrderStatusEnum, change model.OrderStatusChange) fall.Error {
+	q := `INSERT INTO order_status_history (order_id, from_status, to_status, actor_type, actor_id, reason)
+	VALUES ($1, $2, $3, $4, $5, $6);`
+
+	_, err := tx.Exec(ctx, q, orderId, from, change.Status, change.ActorType, change.ActorId, change.Reason)
+	if err != nil {
+		return fall.ServerError(msg.OrderErrorWhenAddStatusHistory)
+	}
+
+	return nil
+}
+
+func (r *OrderRepository) getStatusHistory(ctx context.Context, orderId string) ([]model.OrderStatusHistory, fall.Error) {
+	q := `SELECT order_status_history_id, created_at, from_status, to_status, actor_type, actor_id, reason
+	FROM order_status_history WHERE order_id = $1 ORDER BY created_at, order_status_history_id;`
+
+	rows, err := r.db.Query(ctx, q, orderId)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	history := []model.OrderStatusHistory{}
+
+	for rows.Next() {
+		h := model.OrderStatusHistory{}
+		err := rows.Scan(&h.Id, &h.CreatedAt, &h.FromStatus, &h.ToStatus, &h.ActorType, &h.ActorId, &h.Reason)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		history = append(history, h)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return history, nil
+}
+
+// releasePayment asks the provider to cancel a payment that is still pending
+// and to refund what is left of a captured one. The calls themselves are made by the outbox.
+func (r *OrderRepository) releasePayment(ctx context.Context, tx db.Transaction, orderId string,
+	status model.OrderStatusEnum) fall.Error {
+	p, ex := r.payment(ctx, tx, orderId)
+	if ex != nil || p.id == nil {
+		return ex
+	}
+
+	if status == model.WaitingForPayment {
+		_, ex := r.outboxRepository.Add(ctx, tx, model.TopicPaymentCancel,
+			model.PaymentCancelPayload{OrderId: orderId, PaymentId: *p.id})
+		return ex
+	}
+
+	return r.refundRemainder(ctx, tx, orderId, p)
+}
+
+func (r *OrderRepository) refundRemainder(ctx context.Context, tx db.Transaction, orderId string, p *orderPayment) fall.Error {
+	if p.remainder <= 0 {
+		return nil
+	}
+
+	return r.returnRepository.RequestRefund(ctx, tx, orderId, nil, *p.id, p.remainder)
+}
+
+// orderPayment is the payment of an order and what is left of it after the refunds that were not canceled.
+type orderPayment struct {
+	id        *string
+	remainder float64
+}
+
+// payment reads the payment in the transaction that locked the order, so refunds saved meanwhile are counted.
+func (r *OrderRepository) payment(ctx context.Context, tx db.Transaction, orderId string) (*orderPayment, fall.Error) {
+	q := `SELECT o.payment_id, o.total_price - coalesce((SELECT sum(f.amount) FROM order_refund f
+	WHERE f.order_id = o.order_id AND f.refund_status <> $2), 0)
+	FROM public.order o WHERE o.order_id = $1;`
+
+	p := orderPayment{}
+
+	err := tx.QueryRow(ctx, q, orderId, payment.StatusCanceled).Scan(&p.id, &p.remainder)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fall.NewErr(msg.OrderNotFound, fall.STATUS_NOT_FOUND)
+		}
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return &p, nil
+}
+
+// returnItems puts the quantities of the order back in stock, reading them in the same transaction.
+func (r *OrderRepository) returnItems(ctx context.Context, tx db.Transaction, orderId string) fall.Error {
+	q := "SELECT model_size_id, quantity FROM order_model WHERE order_id = $1;"
+
+	rows, err := tx.Query(ctx, q, orderId)
+
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	type orderItem struct {
+		modelSizeId int
+		quantity    int
+	}
+
+	var items []orderItem
+
+	for rows.Next() {
+		var item orderItem
+		err = rows.Scan(&item.modelSizeId, &item.quantity)
+		if err != nil {
+			rows.Close()
+			return fall.ServerError(err.Error())
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	for _, item := range items {
+		ex := r.productRepository.ReturnQuantityInStock(ctx, item.modelSizeId, item.quantity, tx)
+		if ex != nil {
+			return ex
+		}
+	}
+
+	return nil
+}
+
+// RefundRemainder is used when a payment succeeds after its order has been canceled.
+func (r *OrderRepository) RefundRemainder(ctx context.Context, orderId string) fall.Error {
 
 	var ex fall.Error = nil
 
@@ -670,46 +980,25 @@ func (r *OrderRepository) ChangeStatus(ctx context.Context, orderId string, stat
 		}
 	}()
 
-	order, ex := r.GetOrder(ctx, orderId)
+	q := "SELECT order_id FROM public.order WHERE order_id = $1 FOR UPDATE;"
 
-	if ex != nil {
+	_, err = tx.Exec(ctx, q, orderId)
+	if err != nil {
+		ex = fall.ServerError(err.Error())
 		return ex
 	}
 
-	q := "UPDATE public.order SET order_status = $1 WHERE order_id = $2;"
-
-	_, err = tx.Exec(ctx, q, status, orderId)
-
-	if err != nil {
-		ex = fall.ServerError(msg.OrderErrorWhenChangeStatus)
+	p, ex := r.payment(ctx, tx, orderId)
+	if ex != nil {
 		return ex
 	}
-	if status == model.Canceled {
-		for _, v := range order.Models {
-			ex = r.productRepository.ReturnQuantityInStock(ctx, v.Size.SizeModelId, v.Quantity, tx)
-			if ex != nil {
-				return ex
-			}
-		}
 
-		if order.PaymentId != nil {
-			refund, err := r.paymentService.RefundPayment(*order.PaymentId, order.TotalPrice)
-			if err != nil {
-				ex = fall.ServerError(err.Error())
-				return ex
-			}
-			if refund.Status == "canceled" {
-				if refund.RefundDetails != nil {
-					ex = fall.ServerError(fmt.Sprintf("Party: %s;Reason: %s.", refund.RefundDetails.Party, refund.RefundDetails.Reason))
-					return ex
-				}
-				ex = fall.ServerError("Ошибка при возврате средств. Попробуйте позже.")
-				return ex
-			}
-		}
+	if p.id == nil {
+		return nil
 	}
 
-	return nil
+	ex = r.refundRemainder(ctx, tx, orderId, p)
+	return ex
 }
 
 func (r *OrderRepository) ChangeDeliveryDate(ctx context.Context, orderId string, date time.Time) fall.Error {
@@ -728,10 +1017,10 @@ func (r *OrderRepository) ChangeDeliveryDate(ctx context.Context, orderId string
 
 }
 
-func (r *OrderRepository) SetPaymentId(ctx context.Context, paymentId string, orderId string) fall.Error {
-	q := "UPDATE public.order SET payment_id = $1 WHERE order_id = $2;"
+func (r *OrderRepository) SetPayment(ctx context.Context, orderId string, paymentId string, paymentUrl string) fall.Error {
+	q := "UPDATE public.order SET payment_id = $1, payment_url = $2 WHERE order_id = $3;"
 
-	_, err := r.db.Exec(ctx, q, paymentId, orderId)
+	_, err := r.db.Exec(ctx, q, paymentId, paymentUrl, orderId)
 
 	if err != nil {
 		return fall.ServerError(msg.OrderErrorWhenSetPaymentID)
@@ -739,3 +1028,166 @@ func (r *OrderRepository) SetPaymentId(ctx context.Context, paymentId string, or
 
 	return nil
 }
+
+func (r *OrderRepository) FindOrderIdByPaymentId(ctx context.Context, paymentId string) (*string, fall.Error) {
+	q := "SELECT order_id FROM public.order WHERE payment_id = $1;"
+
+	var orderId string
+
+	err := r.db.QueryRow(ctx, q, paymentId).Scan(&orderId)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fall.NewErr(msg.OrderNotFound, fall.STATUS_NOT_FOUND)
+		}
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return &orderId, nil
+}
+
+// FindPendingPayments returns online orders that have been waiting for payment for longer than olderThan.
+func (r *OrderRepository) FindPendingPayments(ctx context.Context, olderThan time.Duration, limit int) ([]model.PendingPayment, fall.Error) {
+	q := `SELECT order_id, payment_id FROM public.order WHERE order_payment_method = $1 AND order_status = $2
+	AND payment_id IS NOT NULL AND updated_at < CURRENT_TIMESTAMP - make_interval(secs => $3)
+	ORDER BY updated_at LIMIT $4;`
+
+	rows, err := r.db.Query(ctx, q, model.Online, model.WaitingForPayment, olderThan.Seconds(), limit)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	var pending []model.PendingPayment
+
+	for rows.Next() {
+		p := model.PendingPayment{}
+		if err := rows.Scan(&p.OrderId, &p.PaymentId); err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		pending = append(pending, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return pending, nil
+}
+
+// ApplyPaymentEvent stores the event and moves the order to the new status in one transaction,
+// so repeated notifications with the same event and object id are no-ops.
+func (r *OrderRepository) ApplyPaymentEvent(ctx context.Context, event model.PaymentEvent) fall.Error {
+
+	var ex fall.Error = nil
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		ex = fall.ServerError(err.Error())
+		return ex
+	}
+
+	defer func() {
+		if ex != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	q := `INSERT INTO payment_event (event_type, object_id, payment_id, order_id) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (event_type, object_id) DO NOTHING;`
+
+	tag, err := tx.Exec(ctx, q, event.Event, event.ObjectId, event.PaymentId, event.OrderId)
+
+	if err != nil {
+		ex = fall.ServerError(msg.OrderErrorWhenApplyPaymentEvent)
+		return ex
+	}
+
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	if event.Event == payment.EventRefundSucceeded {
+		q = "UPDATE order_refund SET refund_status = $1 WHERE payment_refund_id = $2;"
+
+		_, err = tx.Exec(ctx, q, payment.StatusSucceeded, event.ObjectId)
+		if err != nil {
+			ex = fall.ServerError(msg.RefundErrorWhenSave)
+			return ex
+		}
+	}
+
+	if event.Status == "" {
+		return nil
+	}
+
+	q = `SELECT order_status, EXISTS (SELECT 1 FROM order_refund WHERE order_refund.order_id = $1)
+	FROM public.order WHERE order_id = $1 FOR UPDATE;`
+
+	var current model.OrderStatusEnum
+	var refunded bool
+
+	err = tx.QueryRow(ctx, q, event.OrderId).Scan(&current, &refunded)
+	if err != nil {
+		ex = fall.ServerError(err.Error())
+		return ex
+	}
+
+	// refunds made by the shop are already reflected in the order
+	if refunded || !slices.Contains(event.FromStatuses, current) || !model.CanChangeOrderStatus(current, event.Status) {
+		return nil
+	}
+
+	q = "UPDATE public.order SET order_status = $1, updated_at = CURRENT_TIMESTAMP WHERE order_id = $2;"
+
+	_, err = tx.Exec(ctx, q, event.Status, event.OrderId)
+
+	if err != nil {
+		ex = fall.ServerError(msg.OrderErrorWhenChangeStatus)
+		return ex
+	}
+
+	reason := event.Event
+	ex = r.addStatusHistory(ctx, tx, event.OrderId, &current,
+		model.OrderStatusChange{Status: event.Status, ActorType: model.ActorPayment, Reason: &reason})
+	if ex != nil {
+		return ex
+	}
+
+	if model.IsSaleStatus(event.Status) {
+		ex = r.reservationRepository.Sell(ctx, tx, event.OrderId)
+		if ex != nil {
+			return ex
+		}
+	}
+
+	if !event.ReturnStock {
+		return nil
+	}
+
+	ex = r.reservationRepository.Release(ctx, tx, event.OrderId)
+	if ex != nil {
+		return ex
+	}
+
+	err = trackStock(ctx, tx, model.StockSource{Type: model.StockCancellation, OrderId: &event.OrderId})
+	if err != nil {
+		ex = fall.ServerError(err.Error())
+		return ex
+	}
+
+	ex = r.returnItems(ctx, tx, event.OrderId)
+	if ex != nil {
+		return ex
+	}
+
+	ex = r.promoRepository.Release(ctx, tx, event.OrderId)
+	if ex != nil {
+		return ex
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+// outboxLease is how long a claimed message is hidden from other workers.
+const outboxLease = 5 * time.Minute
+
+type OutboxRepository struct {
+	db db.PostgresClient
+}
+
+func NewOutboxRepository(db db.PostgresClient) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Add must be called inside the transaction that makes the change the message belongs to.
+func (r *OutboxRepository) Add(ctx context.Context, tx db.Transaction, topic string, payload any) (*int64, fall.Error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	q := "INSERT INTO outbox (topic, payload) VALUES ($1, $2) RETURNING outbox_id;"
+
+	var id int64
+
+	err = tx.QueryRow(ctx, q, topic, body).Scan(&id)
+	if err != nil {
+		return nil, fall.ServerError(msg.OutboxErrorWhenAdd)
+	}
+
+	return &id, nil
+}
+
+// Claim leases up to limit due messages. Messages of a crashed worker become due again when the lease ends.
+func (r *OutboxRepository) Claim(ctx context.Context, limit int) ([]model.OutboxMessage, fall.Error) {
+	q := `UPDATE outbox SET locked_until = CURRENT_TIMESTAMP + make_interval(secs => $2), attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
+	WHERE outbox_id IN (
+		SELECT outbox_id FROM outbox
+		WHERE outbox_status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+		AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+		ORDER BY outbox_id LIMIT $1 FOR UPDATE SKIP LOCKED
+	)
+	RETURNING outbox_id, created_at, topic, payload, attempts;`
+
+	return r.claim(ctx, q, limit, outboxLease.Seconds())
+}
+
+func (r *OutboxRepository) ClaimById(ctx context.Context, id int64) (*model.OutboxMessage, fall.Error) {
+	q := `UPDATE outbox SET locked_until = CURRENT_TIMESTAMP + make_interval(secs => $2), attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
+	WHERE outbox_id = (
+		SELECT outbox_id FROM outbox
+		WHERE outbox_id = $1 AND outbox_status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+		AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING outbox_id, created_at, topic, payload, attempts;`
+
+	messages, ex := r.claim(ctx, q, id, outboxLease.Seconds())
+	if ex != nil {
+		return nil, ex
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	return &messages[0], nil
+}
+
+func (r *OutboxRepository) claim(ctx context.Context, q string, args ...any) ([]model.OutboxMessage, fall.Error) {
+	rows, err := r.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	var messages []model.OutboxMessage
+
+	for rows.Next() {
+		m := model.OutboxMessage{}
+		err := rows.Scan(&m.Id, &m.CreatedAt, &m.Topic, &m.Payload, &m.Attempts)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		messages = append(messages, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return messages, nil
+}
+
+func (r *OutboxRepository) Complete(ctx context.Context, id int64) fall.Error {
+	q := `UPDATE outbox SET outbox_status = 'done', locked_until = NULL, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+	WHERE outbox_id = $1;`
+
+	_, err := r.db.Exec(ctx, q, id)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	return nil
+}
+
+// Retry schedules the next attempt, a nil nextAttempt moves the message to the dead letter state.
+func (r *OutboxRepository) Retry(ctx context.Context, id int64, nextAttempt *time.Time, lastError string) fall.Error {
+	status := model.OutboxPending
+	if nextAttempt == nil {
+		status = model.OutboxDead
+	}
+
+	q := `UPDATE outbox SET outbox_status = $2, next_attempt_at = COALESCE($3, next_attempt_at),
+	locked_until = NULL, last_error = $4, updated_at = CURRENT_TIMESTAMP
+	WHERE outbox_id = $1;`
+
+	_, err := r.db.Exec(ctx, q, id, status, nextAttempt, lastError)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	return nil
+}
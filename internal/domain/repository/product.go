@@ -7,13 +7,16 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/generator"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 )
 
 type ProductRepository struct {
@@ -38,25 +41,14 @@ func (r *ProductRepository) CreateProduct(ctx context.Context, dto model.CreateP
 	return nil
 }
 
-func (r *ProductRepository) CreateModel(ctx context.Context, dto model.CreateProductModelDto, slug string) fall.Error {
+func (r *ProductRepository) CreateModel(ctx context.Context, dto model.CreateProductModelDto, article string, slug string) fall.Error {
 
 	q := `
-	WITH generated AS (
-		SELECT uuid_generate_v4() AS generated_article,
-		$1::integer as price_param,
-		$2::smallint as discount_param,
-		$3::text as img_param,
-		$4::integer as product_id_param
-	)
 	INSERT INTO product_model (article, slug, price, discount, main_image_path, product_id)
-	SELECT
-	LEFT(REPLACE(generated_article::text, '-', ''), 12),
-	$5 || '-' || LEFT(REPLACE(generated_article::text, '-', ''), 12),
-	price_param,discount_param,img_param,product_id_param
-	FROM generated;
+	VALUES ($1, $2, $3, $4, $5, $6);
 	`
 
-	_, err := r.db.Exec(ctx, q, dto.Price, dto.Discount, dto.ImagePath, dto.ProductId, slug)
+	_, err := r.db.Exec(ctx, q, article, slug, dto.Price, dto.Discount, dto.ImagePath, dto.ProductId)
 
 	if err != nil {
 		return fall.NewErr(msg.ProductCreateModelError, fall.STATUS_INTERNAL_ERROR)
@@ -90,26 +82,47 @@ func (r *ProductRepository) DeleteProductModel(ctx context.Context, id int) fall
 	return nil
 }
 
-func (r *ProductRepository) UpdateProduct(ctx context.Context, dto model.UpdateProductDto, id int) fall.Error {
+// UpdateProduct moves the models to modelSlugs, keyed by model id, in the same transaction,
+// so a renamed product never keeps slugs built from its old title.
+func (r *ProductRepository) UpdateProduct(ctx context.Context, dto model.UpdateProductDto, id int, modelSlugs map[int]string) fall.Error {
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+	defer tx.Rollback(ctx)
 
 	var queries []string
+	args := []any{id}
 
 	if dto.Description != nil {
-		queries = append(queries, fmt.Sprintf("description = '%s'", *dto.Description))
+		args = append(args, *dto.Description)
+		queries = append(queries, fmt.Sprintf("description = $%d", len(args)))
 	}
 
 	if dto.Title != nil {
-		queries = append(queries, fmt.Sprintf("title = '%s'", *dto.Title))
+		args = append(args, *dto.Title)
+		queries = append(queries, fmt.Sprintf("title = $%d", len(args)))
 	}
 
 	if len(queries) > 0 {
 		q := "UPDATE product SET " + strings.Join(queries, ",") + " WHERE product_id = $1;"
-		_, err := r.db.Exec(ctx, q, id)
+		_, err := tx.Exec(ctx, q, args...)
+		if err != nil {
+			return fall.ServerError(fmt.Sprintf("%s, details: \n %s", msg.ProductUpdateError, err.Error()))
+		}
+	}
+
+	for modelId, slug := range modelSlugs {
+		_, err := tx.Exec(ctx, "UPDATE product_model SET slug = $1 WHERE product_model_id = $2;", slug, modelId)
 		if err != nil {
 			return fall.ServerError(fmt.Sprintf("%s, details: \n %s", msg.ProductUpdateError, err.Error()))
 		}
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return fall.ServerError(err.Error())
+	}
 	return nil
 }
 
@@ -213,15 +226,21 @@ func (r *ProductRepository) AddPhoto(ctx context.Context, dto model.CreateProduc
 	return nil
 }
 
-func (r *ProductRepository) RemovePhoto(ctx context.Context, photoId int) fall.Error {
-	query := "DELETE FROM product_model_img WHERE product_img_id = $1;"
+// RemovePhoto deletes the photo and returns the id of the model it belonged to.
+func (r *ProductRepository) RemovePhoto(ctx context.Context, photoId int) (int, fall.Error) {
+	query := "DELETE FROM product_model_img WHERE product_img_id = $1 RETURNING product_model_id;"
 
-	_, err := r.db.Exec(ctx, query, photoId)
+	var modelId int
+
+	err := r.db.QueryRow(ctx, query, photoId).Scan(&modelId)
 	if err != nil {
-		return fall.NewErr(msg.ProductAddPhotoError, fall.STATUS_INTERNAL_ERROR)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fall.NewErr(msg.ProductAddPhotoError, fall.STATUS_INTERNAL_ERROR)
 	}
 
-	return nil
+	return modelId, nil
 }
 
 func (r *ProductRepository) GetProductPage(ctx context.Context, slug string) (*model.ProductRelation, fall.Error) {
@@ -554,56 +573,123 @@ func (r *ProductRepository) FindModelSizeById(ctx context.Context, id int) (*mod
 	return &m, nil
 }
 
+// ReduceQuantityInStock decrements a single model size with a conditional update,
+// so concurrent buyers can't take the stock below zero.
 func (r *ProductRepository) ReduceQuantityInStock(ctx context.Context, modelSizeId int, quantity int, tx db.Transaction) fall.Error {
-	m, ex := r.FindModelSizeById(ctx, modelSizeId)
-	if ex != nil {
-		return ex
+	query := "UPDATE model_sizes SET in_stock = in_stock - $1 WHERE model_size_id = $2 AND in_stock >= $1;"
+
+	var tag pgconn.CommandTag
+	var err error
+
+	if tx != nil {
+		tag, err = tx.Exec(ctx, query, quantity, modelSizeId)
+	} else {
+		tag, err = r.db.Exec(ctx, query, quantity, modelSizeId)
+	}
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	if tag.RowsAffected() == 0 {
+		_, ex := r.FindModelSizeById(ctx, modelSizeId)
+		if ex != nil {
+			return ex
+		}
+		return fall.NewStockErr(msg.ProductNotEnoughInStock, []int{modelSizeId})
 	}
 
-	newQuantity := m.InStock - quantity
+	return nil
+}
+
+// ReduceStock locks all affected model sizes in id order and decrements them only if every one has enough units.
+// Otherwise nothing is changed and the error lists all model sizes that are short.
+func (r *ProductRepository) ReduceStock(ctx context.Context, tx db.Transaction, changes []model.StockChange) fall.Error {
+	required := make(map[int]int)
+	var ids []int
 
-	if newQuantity < 0 {
-		return fall.NewErr(msg.ProductInStockCannotBeLessThanZero, fall.STATUS_BAD_REQUEST)
+	for _, c := range changes {
+		if _, ok := required[c.ModelSizeId]; !ok {
+			ids = append(ids, c.ModelSizeId)
+		}
+		required[c.ModelSizeId] += c.Quantity
 	}
 
-	query := "UPDATE model_sizes SET in_stock = $1 WHERE model_size_id = $2"
+	if len(ids) == 0 {
+		return nil
+	}
 
-	if tx != nil {
-		_, err := tx.Exec(ctx, query, newQuantity, modelSizeId)
+	sort.Ints(ids)
+
+	query := `SELECT model_size_id, in_stock FROM model_sizes WHERE model_size_id = ANY($1)
+	ORDER BY model_size_id FOR UPDATE;`
+
+	rows, err := tx.Query(ctx, query, ids)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	inStock := make(map[int]int)
+
+	for rows.Next() {
+		var id, quantity int
+		err := rows.Scan(&id, &quantity)
 		if err != nil {
+			rows.Close()
 			return fall.ServerError(err.Error())
 		}
-		return nil
+		inStock[id] = quantity
 	}
-	_, err := r.db.Exec(ctx, query, newQuantity, modelSizeId)
-	if err != nil {
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
 		return fall.ServerError(err.Error())
 	}
-	return nil
-}
 
-func (r *ProductRepository) ReturnQuantityInStock(ctx context.Context, modelSizeId int, quantity int, tx db.Transaction) fall.Error {
-	m, ex := r.FindModelSizeById(ctx, modelSizeId)
+	var short []int
 
-	if ex != nil {
-		return ex
+	for _, id := range ids {
+		quantity, ok := inStock[id]
+		if !ok {
+			return fall.NewErr(msg.ProductNotFound, fall.STATUS_NOT_FOUND)
+		}
+		if quantity < required[id] {
+			short = append(short, id)
+		}
 	}
 
-	newQuantity := m.InStock + quantity
+	if len(short) > 0 {
+		return fall.NewStockErr(msg.ProductNotEnoughInStock, short)
+	}
 
-	query := "UPDATE model_sizes SET in_stock = $1 WHERE model_size_id = $2"
+	for _, id := range ids {
+		ex := r.ReduceQuantityInStock(ctx, id, required[id], tx)
+		if ex != nil {
+			return ex
+		}
+	}
+
+	return nil
+}
+
+func (r *ProductRepository) ReturnQuantityInStock(ctx context.Context, modelSizeId int, quantity int, tx db.Transaction) fall.Error {
+	query := "UPDATE model_sizes SET in_stock = in_stock + $1 WHERE model_size_id = $2;"
+
+	var tag pgconn.CommandTag
+	var err error
 
 	if tx != nil {
-		_, err := tx.Exec(ctx, query, newQuantity, modelSizeId)
-		if err != nil {
-			return fall.ServerError(err.Error())
-		}
-		return nil
+		tag, err = tx.Exec(ctx, query, quantity, modelSizeId)
+	} else {
+		tag, err = r.db.Exec(ctx, query, quantity, modelSizeId)
 	}
-	_, err := r.db.Exec(ctx, query, newQuantity, modelSizeId)
 	if err != nil {
 		return fall.ServerError(err.Error())
 	}
+
+	if tag.RowsAffected() == 0 {
+		return fall.NewErr(msg.ProductNotFound, fall.STATUS_NOT_FOUND)
+	}
+
 	return nil
 }
 
@@ -740,41 +826,118 @@ func (r *ProductRepository) GetModelOptions(ctx context.Context, modelId int) ([
 	return options, nil
 }
 
-func (r *ProductRepository) Search(ctx context.Context, term string) ([]model.SearchProductModel, fall.Error) {
-	q := `
+const searchTimeout = 700 * time.Millisecond
+
+// Search looks the term up in the full-text index and falls back to trigram similarity
+// when the first page has no exact matches.
+func (r *ProductRepository) Search(ctx context.Context, term string, page pagination.Page) (*model.SearchResponse, fall.Error) {
+	ctx, cancel := context.WithTimeout(ctx, searchTimeout)
+	defer cancel()
+
+	if page.Sort == model.SearchSortSimilarity {
+		return r.searchModels(ctx, term, page, model.SearchSortSimilarity)
+	}
+
+	res, ex := r.searchModels(ctx, term, page, model.SearchSortRelevance)
+	if ex != nil {
+		return nil, ex
+	}
+
+	if len(res.Models) == 0 && page.Cursor == nil {
+		return r.searchModels(ctx, term, page, model.SearchSortSimilarity)
+	}
+
+	return res, nil
+}
+
+func (r *ProductRepository) searchModels(ctx context.Context, term string, page pagination.Page, sort string) (*model.SearchResponse, fall.Error) {
+	rank := "ts_rank_cd(ps.document, q.query)"
+	match := "ps.document @@ q.query"
+	highlight := `ts_headline('russian', ranked.headline_text, q.query,
+		'StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=5, MaxFragments=2')`
+
+	if sort == model.SearchSortSimilarity {
+		rank = "word_similarity(q.term, ps.search_text)"
+		match = "q.term <% ps.search_text"
+		highlight = "NULL::text"
+	}
+
+	args := []any{term}
+	after := ""
+
+	if page.Cursor != nil {
+		args = append(args, page.Cursor.Key, page.Cursor.Id)
+		after = "WHERE " + pagination.After("ranked.rank", "pm.product_model_id", true, "$2::text::numeric", "$3::text::int")
+	}
+
+	args = append(args, page.Limit+1)
+
+	q := fmt.Sprintf(`
+	WITH q AS (
+		SELECT websearch_to_tsquery('russian', $1) AS query, lower($1) AS term
+	), ranked AS (
+		SELECT ps.product_id, ps.headline_text, (%s)::numeric AS rank
+		FROM product_search ps, q
+		WHERE %s
+	)
 	SELECT p.product_id as p_id, p.title as p_title,
 	b.brand_id as b_id, b.title as b_title, b.slug as b_slug, ct.category_id as ct_id, ct.title as ct_title, ct.slug as ct_slug,
 	pm.product_model_id as m_id, pm.slug as m_slug, pm.article as m_article, pm.price as m_price, pm.discount as m_discount,
-	pm.main_image_path as m_main_img
-	FROM product p INNER JOIN category ct ON p.category_id = ct.category_id 
+	pm.main_image_path as m_main_img, ranked.rank::text as rank, %s as highlight
+	FROM ranked CROSS JOIN q
+	INNER JOIN product p ON p.product_id = ranked.product_id
+	INNER JOIN category ct ON p.category_id = ct.category_id
 	INNER JOIN brand b on p.brand_id = b.brand_id
 	INNER JOIN product_model pm ON pm.product_id = p.product_id
-	WHERE b.title ILIKE $1 OR ct.title ILIKE $1 OR p.title ILIKE $1;
-	`
-	rows, err := r.db.Query(ctx, q, "%"+term+"%")
+	%s
+	ORDER BY ranked.rank DESC, pm.product_model_id DESC
+	LIMIT $%d;
+	`, rank, match, highlight, after, len(args))
+
+	rows, err := r.db.Query(ctx, q, args...)
 	if err != nil {
 		return nil, fall.ServerError(err.Error())
 	}
 	defer rows.Close()
 
-	var models []model.SearchProductModel
+	type searchRow struct {
+		model model.SearchProductModel
+		rank  string
+	}
+
+	var found []searchRow
 
 	for rows.Next() {
-		m := model.SearchProductModel{}
+		row := searchRow{}
+		m := &row.model
 		err := rows.Scan(&m.ProductId, &m.Title, &m.Brand.Id, &m.Brand.Title, &m.Brand.Slug, &m.Category.Id,
-			&m.Category.Title, &m.Category.Slug, &m.ModelId, &m.Slug, &m.Article, &m.Price, &m.Discount, &m.MainImagePath)
+			&m.Category.Title, &m.Category.Slug, &m.ModelId, &m.Slug, &m.Article, &m.Price, &m.Discount, &m.MainImagePath,
+			&row.rank, &m.Highlight)
 
 		if err != nil {
 			return nil, fall.ServerError(err.Error())
 		}
-		models = append(models, m)
+		found = append(found, row)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fall.ServerError(err.Error())
 	}
 
-	return models, nil
+	found, nextCursor := pagination.Trim(found, page.Limit, sort, func(row searchRow) (string, string) {
+		return row.rank, strconv.Itoa(row.model.ModelId)
+	})
+
+	models := make([]model.SearchProductModel, 0, len(found))
+	for _, row := range found {
+		models = append(models, row.model)
+	}
+
+	return &model.SearchResponse{
+		Models:     models,
+		NextCursor: nextCursor,
+		Fuzzy:      sort == model.SearchSortSimilarity,
+	}, nil
 }
 
 func (r *ProductRepository) SearchByArticle(ctx context.Context, article string) ([]model.SearchProductModel, fall.Error) {
@@ -816,260 +979,136 @@ func (r *ProductRepository) SearchByArticle(ctx context.Context, article string)
 	return models, nil
 }
 
-func (r *ProductRepository) GetCatalogModels(ctx context.Context, categorySlug string, sql generator.GeneratedCatalogQuery) (*model.CatalogResponse, fall.Error) {
+// catalogModelColumns are scanned by scanCatalogModels, every listing query selects them from catalog_model cm.
+const catalogModelColumns = `cm.product_id, cm.title, cm.brand_id, cm.brand_title, cm.brand_slug,
+	cm.category_id, cm.category_title, cm.category_short_title, cm.category_slug,
+	cm.product_model_id, cm.slug, cm.article, cm.price, cm.discount, cm.main_image_path, cm.images, cm.sizes`
 
-	query := fmt.Sprintf(`
-	WITH RECURSIVE category_tree AS (
-		SELECT category_id, title, short_title, slug, parent_category_id
-		FROM category
-		WHERE slug = $1
-		UNION ALL
-		SELECT c.category_id, c.title, c.short_title, c.slug, c.parent_category_id
-		FROM category c
-		INNER JOIN category_tree ct ON c.parent_category_id = ct.category_id
-	)
-	SELECT distinct pm.product_model_id as model_id,
-	(select count(distinct pm.product_model_id)%s %s
-	) as total_count
-	%s %s  %s;`, sql.MainJoins, sql.MainQuery, sql.MainJoins, sql.MainQuery, sql.Pagination)
+func scanCatalogModel(row pgx.Row, extra ...any) (*model.CatalogProductModel, error) {
+	m := &model.CatalogProductModel{}
 
-	rows, err := r.db.Query(ctx, query, categorySlug)
+	dest := []any{&m.ProductId, &m.Title, &m.Brand.Id, &m.Brand.Title, &m.Brand.Slug,
+		&m.Category.Id, &m.Category.Title, &m.Category.ShortTitle, &m.Category.Slug,
+		&m.ModelId, &m.Slug, &m.Article, &m.Price, &m.Discount, &m.MainImagePath, &m.Images, &m.Sizes}
 
+	err := row.Scan(append(dest, extra...)...)
 	if err != nil {
-		return nil, fall.ServerError(err.Error())
-	}
-	defer rows.Close()
-
-	var total int
-	var modelIds []int
-
-	for rows.Next() {
-		var modelId int
-		err := rows.Scan(&modelId, &total)
-		if err != nil {
-			return nil, fall.ServerError(err.Error())
-		}
-
-		modelIds = append(modelIds, modelId)
+		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fall.ServerError(err.Error())
+	for _, img := range m.Images {
+		img.ProductModelId = m.ModelId
 	}
 
-	query = fmt.Sprintf(`
-	SELECT p.product_id as p_id, p.title as p_title,
-	b.brand_id as b_id, b.title as b_title, b.slug as b_slug, ct.category_id as ct_id, ct.title as ct_title,
-	ct.short_title as ct_short_title, ct.slug as ct_slug,
-	pm.product_model_id as model_id, pm.slug as m_slug, pm.article as m_article, pm.price as model_price, pm.discount as model_discount,
-	pm.main_image_path as pm_main_img,
-	pimg.product_img_id as pimg_id, pimg.product_model_id as pimg_model_id, pimg.img_path as pimg_img_path, 
-	sz.size_id as size_id, sz.size_value as size_value, ms.literal_size as literal_size,
-	ms.product_model_id as ms_pm_id, ms.in_stock as ms_in_stock,
-	ms.model_size_id as ms_m_sz_id,
-	(
-		SELECT COUNT(om.order_model_id) 
-		FROM order_model as om
-		LEFT JOIN model_sizes as ms ON om.model_size_id = ms.model_size_id
-		LEFT JOIN product_model as pm1 ON ms.product_model_id = pm1.product_model_id
-		WHERE pm1.product_model_id = pm.product_model_id) AS order_count
-	FROM product p INNER JOIN category ct ON p.category_id = ct.category_id 
-	INNER JOIN brand b on p.brand_id = b.brand_id
-	INNER JOIN product_model pm ON pm.product_id = p.product_id
-	inner join model_sizes ms on ms.product_model_id = pm.product_model_id
-	inner join sizes sz on ms.size_id = sz.size_id
-	inner join product_model_img as pimg on pimg.product_model_id = pm.product_model_id
-	WHERE pm.product_model_id = ANY ($1)
-	%s;
-	`, sql.SortStatement)
+	sort.Slice(m.Sizes, func(i, j int) bool {
+		a := m.Sizes[i].Value
+		b := m.Sizes[j].Value
+		aSize, aErr := strconv.Atoi(a)
+		bSize, bErr := strconv.Atoi(b)
+		if aErr != nil || bErr != nil {
+			return false
+		}
+		return aSize > bSize
+	})
 
-	rows, err = r.db.Query(ctx, query, modelIds)
+	return m, nil
+}
 
+func (r *ProductRepository) queryCatalogModels(ctx context.Context, q string, args ...any) ([]*model.CatalogProductModel, fall.Error) {
+	rows, err := r.db.Query(ctx, q, args...)
 	if err != nil {
-
 		return nil, fall.ServerError(err.Error())
 	}
 	defer rows.Close()
 
-	imagesMap := make(map[int]*model.ProductModelImg)
-	sizesMap := make(map[int]*model.ProductModelSize)
-	modelsMap := make(map[int]*model.CatalogProductModel)
-	var modelOrder []int
-
-	var imgOrder []int
-	var sizeOrder []int
+	var result []*model.CatalogProductModel
 
 	for rows.Next() {
-		sz := model.ProductModelSize{}
-		img := model.ProductModelImg{}
-		m := model.CatalogProductModel{}
-
-		err := rows.Scan(&m.ProductId, &m.Title, &m.Brand.Id, &m.Brand.Title, &m.Brand.Slug,
-			&m.Category.Id, &m.Category.Title, &m.Category.ShortTitle, &m.Category.Slug, &m.ModelId, &m.Slug, &m.Article, &m.Price, &m.Discount,
-			&m.MainImagePath, &img.Id, &img.ProductModelId, &img.ImgPath, &sz.SizeId, &sz.Value, &sz.Literal, &sz.ModelId, &sz.InStock, &sz.SizeModelId, nil,
-		)
+		m, err := scanCatalogModel(rows)
 		if err != nil {
-
 			return nil, fall.ServerError(err.Error())
 		}
-		_, ok := modelsMap[m.ModelId]
-		if !ok {
-			modelsMap[m.ModelId] = &m
-			modelOrder = append(modelOrder, m.ModelId)
-		}
-		_, ok = imagesMap[img.Id]
-		if !ok {
-			imagesMap[img.Id] = &img
-			imgOrder = append(imgOrder, img.Id)
-		}
-		_, ok = sizesMap[sz.SizeModelId]
-		if !ok {
-			sizesMap[sz.SizeModelId] = &sz
-			sizeOrder = append(sizeOrder, sz.SizeModelId)
-		}
+		result = append(result, m)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fall.ServerError(err.Error())
 	}
 
-	for _, v := range imgOrder {
-		img := imagesMap[v]
-		m := modelsMap[img.ProductModelId]
-		m.Images = append(m.Images, img)
-	}
-
-	for _, v := range sizeOrder {
-		sz := sizesMap[v]
-		m := modelsMap[sz.ModelId]
-		m.Sizes = append(m.Sizes, sz)
-	}
-
-	var result []*model.CatalogProductModel
-
-	for _, id := range modelOrder {
-		m := modelsMap[id]
-		sort.Slice(m.Sizes, func(i, j int) bool {
-			a := m.Sizes[i].Value
-			b := m.Sizes[j].Value
-			aSize, aErr := strconv.Atoi(a)
-			bSize, bErr := strconv.Atoi(b)
-			if aErr != nil || bErr != nil {
-				return false
-			}
-			return aSize > bSize
-		})
-
-		result = append(result, m)
-	}
-
-	return &model.CatalogResponse{
-		Models:     result,
-		TotalCount: total,
-	}, nil
+	return result, nil
 }
 
-func (r *ProductRepository) GetSimilarProducts(ctx context.Context, filter model.SimilarProductsFilter, mainFilterId int,
-	secondaryFilterId int, modelId int) ([]*model.CatalogProductModel, fall.Error) {
+func (r *ProductRepository) GetCatalogModels(ctx context.Context, sql generator.GeneratedCatalogQuery) (*model.CatalogResponse, fall.Error) {
 
-	q := fmt.Sprintf(`
-	SELECT p.product_id as p_id, p.title as p_title,
-	b.brand_id as b_id, b.title as b_title, b.slug as b_slug, ct.category_id as ct_id, ct.title as ct_title,
-	ct.short_title as ct_short_title, ct.slug as ct_slug,
-	pm.product_model_id as model_id, pm.slug as m_slug, pm.article as m_article, pm.price as model_price, pm.discount as model_discount,
-	pm.main_image_path as pm_main_img,
-	pimg.product_img_id as pimg_id, pimg.product_model_id as pimg_model_id, pimg.img_path as pimg_img_path, 
-	sz.size_id as size_id, sz.size_value as size_value, ms.literal_size as literal_size,
-	ms.product_model_id as ms_pm_id, ms.in_stock as ms_in_stock,
-	ms.model_size_id as ms_m_sz_id
-	FROM product p INNER JOIN category ct ON p.category_id = ct.category_id 
-	INNER JOIN brand b on p.brand_id = b.brand_id
-	INNER JOIN product_model pm ON pm.product_id = p.product_id
-	inner join model_sizes ms on ms.product_model_id = pm.product_model_id
-	inner join sizes sz on ms.size_id = sz.size_id
-	inner join product_model_img as pimg on pimg.product_model_id = pm.product_model_id
-	%s AND pm.product_model_id != $3 LIMIT 28;`, filter)
+	query := fmt.Sprintf(`
+	WITH RECURSIVE category_tree AS (
+		SELECT category_id, title, short_title, slug, parent_category_id
+		FROM category
+		WHERE slug = $1
+		UNION ALL
+		SELECT c.category_id, c.title, c.short_title, c.slug, c.parent_category_id
+		FROM category c
+		INNER JOIN category_tree ct ON c.parent_category_id = ct.category_id
+	)
+	SELECT %[1]s, (%[2]s)::numeric::text, (SELECT count(*) FROM catalog_model cm %[3]s) as total_count
+	FROM catalog_model cm %[3]s %[4]s
+	ORDER BY %[5]s %[6]s;`, catalogModelColumns, sql.SortKey, sql.Where, sql.After, sql.OrderBy, sql.Pagination)
 
-	rows, err := r.db.Query(ctx, q, mainFilterId, secondaryFilterId, modelId)
+	rows, err := r.db.Query(ctx, query, sql.Args...)
 
 	if err != nil {
-
 		return nil, fall.ServerError(err.Error())
 	}
 	defer rows.Close()
 
-	imagesMap := make(map[int]*model.ProductModelImg)
-	sizesMap := make(map[int]*model.ProductModelSize)
-	modelsMap := make(map[int]*model.CatalogProductModel)
-	var modelsOrder []int
-	var imgOrder []int
-	var sizeOrder []int
+	type catalogRow struct {
+		model   *model.CatalogProductModel
+		sortKey string
+	}
 
-	for rows.Next() {
-		sz := model.ProductModelSize{}
-		img := model.ProductModelImg{}
-		m := model.CatalogProductModel{}
+	var total int
+	var page []catalogRow
 
-		err := rows.Scan(&m.ProductId, &m.Title, &m.Brand.Id, &m.Brand.Title, &m.Brand.Slug,
-			&m.Category.Id, &m.Category.Title, &m.Category.ShortTitle, &m.Category.Slug, &m.ModelId, &m.Slug, &m.Article, &m.Price, &m.Discount,
-			&m.MainImagePath, &img.Id, &img.ProductModelId, &img.ImgPath, &sz.SizeId, &sz.Value, &sz.Literal, &sz.ModelId, &sz.InStock, &sz.SizeModelId,
-		)
+	for rows.Next() {
+		row := catalogRow{}
+		m, err := scanCatalogModel(rows, &row.sortKey, &total)
 		if err != nil {
-
 			return nil, fall.ServerError(err.Error())
 		}
-		_, ok := modelsMap[m.ModelId]
-		if !ok {
-			modelsMap[m.ModelId] = &m
-			modelsOrder = append(modelsOrder, m.ModelId)
-		}
-		_, ok = imagesMap[img.Id]
-		if !ok {
-			imagesMap[img.Id] = &img
-			imgOrder = append(imgOrder, img.Id)
-		}
-		_, ok = sizesMap[sz.SizeModelId]
-		if !ok {
-			sizesMap[sz.SizeModelId] = &sz
-			sizeOrder = append(sizeOrder, sz.SizeModelId)
-		}
+		row.model = m
+
+		page = append(page, row)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fall.ServerError(err.Error())
 	}
 
-	for _, v := range imgOrder {
-		img := imagesMap[v]
-		m := modelsMap[img.ProductModelId]
-		m.Images = append(m.Images, img)
-	}
+	page, nextCursor := pagination.Trim(page, sql.Limit, string(sql.Sort), func(row catalogRow) (string, string) {
+		return row.sortKey, strconv.Itoa(row.model.ModelId)
+	})
 
-	for _, v := range sizeOrder {
-		sz := sizesMap[v]
-		m := modelsMap[sz.ModelId]
-		m.Sizes = append(m.Sizes, sz)
+	result := make([]*model.CatalogProductModel, 0, len(page))
+	for _, row := range page {
+		result = append(result, row.model)
 	}
 
-	var result []*model.CatalogProductModel
+	return &model.CatalogResponse{
+		Models:     result,
+		TotalCount: total,
+		NextCursor: nextCursor,
+	}, nil
+}
 
-	for _, id := range modelsOrder {
-		m := modelsMap[id]
-		sort.Slice(m.Sizes, func(i, j int) bool {
-			a := m.Sizes[i].Value
-			b := m.Sizes[j].Value
-			aSize, aErr := strconv.Atoi(a)
-			bSize, bErr := strconv.Atoi(b)
-			if aErr != nil || bErr != nil {
-				return false
-			}
-			return aSize > bSize
-		})
+func (r *ProductRepository) GetSimilarProducts(ctx context.Context, filter model.SimilarProductsFilter, mainFilterId int,
+	secondaryFilterId int, modelId int) ([]*model.CatalogProductModel, fall.Error) {
 
-		result = append(result, m)
-	}
+	q := fmt.Sprintf(`
+	SELECT %s FROM catalog_model cm
+	%s AND cm.product_model_id != $3 AND cm.listed
+	ORDER BY cm.order_count DESC, cm.product_model_id DESC LIMIT 28;`, catalogModelColumns, filter)
 
-	return result, nil
+	return r.queryCatalogModels(ctx, q, mainFilterId, secondaryFilterId, modelId)
 }
 
 func (r *ProductRepository) GetModelViews(ctx context.Context, modelId int) *int {
@@ -1139,141 +1178,17 @@ func (r *ProductRepository) AddToViewHistory(ctx context.Context, userId int, mo
 }
 
 func (r *ProductRepository) GetViewHistory(ctx context.Context, userId int, modelId int) ([]*model.CatalogProductModel, fall.Error) {
-	q := `
-	SELECT pm.product_model_id  as model_id
-	FROM product_model pm
-	inner join views_history as vh on pm.product_model_id = vh.product_model_id
-	where vh.user_id = $1 AND vh.product_model_id != $2
-	order by vh.created_at DESC LIMIT 28;
-	`
-
-	rows, err := r.db.Query(ctx, q, userId, modelId)
-
-	if err != nil {
-		return nil, fall.ServerError(err.Error())
-	}
-
-	defer rows.Close()
-
-	var modelOrder []int
-
-	for rows.Next() {
-		var modelId int
-		err := rows.Scan(&modelId)
-		if err != nil {
-			return nil, fall.ServerError(err.Error())
-		}
-
-		modelOrder = append(modelOrder, modelId)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fall.ServerError(err.Error())
-	}
-
-	q = `
-	SELECT p.product_id as p_id, p.title as p_title,
-	b.brand_id as b_id, b.title as b_title, b.slug as b_slug, ct.category_id as ct_id, ct.title as ct_title,
-	ct.short_title as ct_short_title, ct.slug as ct_slug,
-	pm.product_model_id as model_id, pm.slug as m_slug, pm.article as m_article, pm.price as model_price, pm.discount as model_discount,
-	pm.main_image_path as pm_main_img,
-	pimg.product_img_id as pimg_id, pimg.product_model_id as pimg_model_id, pimg.img_path as pimg_img_path, 
-	sz.size_id as size_id, sz.size_value as size_value, ms.literal_size as literal_size,
-	ms.product_model_id as ms_pm_id, ms.in_stock as ms_in_stock,
-	ms.model_size_id as ms_m_sz_id
-	FROM product p INNER JOIN category ct ON p.category_id = ct.category_id 
-	INNER JOIN brand b on p.brand_id = b.brand_id
-	INNER JOIN product_model pm ON pm.product_id = p.product_id
-	inner join model_sizes ms on ms.product_model_id = pm.product_model_id
-	inner join sizes sz on ms.size_id = sz.size_id
-	inner join product_model_img as pimg on pimg.product_model_id = pm.product_model_id
-	WHERE pm.product_model_id = ANY ($1);
-	`
-
-	rows, err = r.db.Query(ctx, q, modelOrder)
-
-	if err != nil {
-		return nil, fall.ServerError(err.Error())
-	}
-
-	defer rows.Close()
-
-	imagesMap := make(map[int]*model.ProductModelImg)
-	sizesMap := make(map[int]*model.ProductModelSize)
-	modelsMap := make(map[int]*model.CatalogProductModel)
-	var imgOrder []int
-	var sizeOrder []int
-
-	for rows.Next() {
-		sz := model.ProductModelSize{}
-		img := model.ProductModelImg{}
-		m := model.CatalogProductModel{}
-
-		err := rows.Scan(&m.ProductId, &m.Title, &m.Brand.Id, &m.Brand.Title, &m.Brand.Slug,
-			&m.Category.Id, &m.Category.Title, &m.Category.ShortTitle, &m.Category.Slug, &m.ModelId, &m.Slug, &m.Article, &m.Price, &m.Discount,
-			&m.MainImagePath, &img.Id, &img.ProductModelId, &img.ImgPath, &sz.SizeId, &sz.Value, &sz.Literal, &sz.ModelId, &sz.InStock, &sz.SizeModelId,
-		)
-		if err != nil {
-
-			return nil, fall.ServerError(err.Error())
-		}
-
-		_, ok := modelsMap[m.ModelId]
-		if !ok {
-			modelsMap[m.ModelId] = &m
-		}
-
-		_, ok = imagesMap[img.Id]
-		if !ok {
-			imagesMap[img.Id] = &img
-			imgOrder = append(imgOrder, img.Id)
-		}
-		_, ok = sizesMap[sz.SizeModelId]
-		if !ok {
-			sizesMap[sz.SizeModelId] = &sz
-			sizeOrder = append(sizeOrder, sz.SizeModelId)
-		}
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fall.ServerError(err.Error())
-	}
-
-	for _, v := range imgOrder {
-		img := imagesMap[v]
-		m := modelsMap[img.ProductModelId]
-		m.Images = append(m.Images, img)
-	}
-
-	for _, v := range sizeOrder {
-		sz := sizesMap[v]
-		m := modelsMap[sz.ModelId]
-		m.Sizes = append(m.Sizes, sz)
-	}
-
-	var result []*model.CatalogProductModel
-
-	for _, id := range modelOrder {
-		m := modelsMap[id]
-		sort.Slice(m.Sizes, func(i, j int) bool {
-			a := m.Sizes[i].Value
-			b := m.Sizes[j].Value
-			aSize, aErr := strconv.Atoi(a)
-			bSize, bErr := strconv.Atoi(b)
-			if aErr != nil || bErr != nil {
-				return false
-			}
-			return aSize > bSize
-		})
-
-		result = append(result, m)
-	}
+	q := fmt.Sprintf(`
+	SELECT %s FROM catalog_model cm
+	INNER JOIN views_history vh ON vh.product_model_id = cm.product_model_id
+	WHERE vh.user_id = $1 AND vh.product_model_id != $2 AND cm.listed
+	ORDER BY vh.created_at DESC LIMIT 28;`, catalogModelColumns)
 
-	return result, nil
+	return r.queryCatalogModels(ctx, q, userId, modelId)
 }
 
 func (r *ProductRepository) GetPopularProducts(ctx context.Context, slug string) ([]*model.CatalogProductModel, fall.Error) {
-	q := `
+	q := fmt.Sprintf(`
 	WITH RECURSIVE category_tree AS (
 		SELECT category_id, title, short_title, slug, parent_category_id
 		FROM category
@@ -1283,146 +1198,9 @@ func (r *ProductRepository) GetPopularProducts(ctx context.Context, slug string)
 		FROM category c
 		INNER JOIN category_tree ct ON c.parent_category_id = ct.category_id
 	)
-	SELECT DISTINCT pm.product_model_id, (
-		SELECT COUNT(om.order_model_id) 
-		FROM order_model as om
-		INNER JOIN model_sizes as ms ON om.model_size_id = ms.model_size_id
-		INNER JOIN product_model as pm1 ON ms.product_model_id = pm1.product_model_id
-		WHERE pm1.product_model_id = pm.product_model_id
-	) AS order_count
-	FROM product_model pm
-	INNER JOIN product p ON pm.product_id = p.product_id
-	INNER JOIN brand b on p.brand_id = b.brand_id
-	INNER JOIN category_tree ct ON p.category_id = ct.category_id
-	inner join model_sizes ms on ms.product_model_id = pm.product_model_id
-	inner join sizes sz on ms.size_id = sz.size_id
-	inner join product_model_img as pimg on pimg.product_model_id = pm.product_model_id
-	inner join order_model as om ON ms.model_size_id = om.model_size_id
-	ORDER BY order_count DESC
-	LIMIT 28;
-	`
-
-	rows, err := r.db.Query(ctx, q, slug)
-
-	if err != nil {
-		return nil, fall.ServerError(err.Error())
-	}
-
-	defer rows.Close()
-
-	var modelOrder []int
-
-	for rows.Next() {
-		var modelId int
-		err := rows.Scan(&modelId, nil)
-		if err != nil {
-			return nil, fall.ServerError(err.Error())
-		}
-
-		modelOrder = append(modelOrder, modelId)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fall.ServerError(err.Error())
-	}
-
-	q = `
-	SELECT p.product_id as p_id, p.title as p_title,
-	b.brand_id as b_id, b.title as b_title, b.slug as b_slug, ct.category_id as ct_id, ct.title as ct_title,
-	ct.short_title as ct_short_title, ct.slug as ct_slug,
-	pm.product_model_id as model_id, pm.slug as m_slug, pm.article as m_article, pm.price as model_price, pm.discount as model_discount,
-	pm.main_image_path as pm_main_img,
-	pimg.product_img_id as pimg_id, pimg.product_model_id as pimg_model_id, pimg.img_path as pimg_img_path, 
-	sz.size_id as size_id, sz.size_value as size_value, ms.literal_size as literal_size,
-	ms.product_model_id as ms_pm_id, ms.in_stock as ms_in_stock,
-	ms.model_size_id as ms_m_sz_id
-	FROM product p INNER JOIN category ct ON p.category_id = ct.category_id 
-	INNER JOIN brand b on p.brand_id = b.brand_id
-	INNER JOIN product_model pm ON pm.product_id = p.product_id
-	inner join model_sizes ms on ms.product_model_id = pm.product_model_id
-	inner join sizes sz on ms.size_id = sz.size_id
-	inner join product_model_img as pimg on pimg.product_model_id = pm.product_model_id
-	WHERE pm.product_model_id = ANY ($1);
-	`
-
-	rows, err = r.db.Query(ctx, q, modelOrder)
-
-	if err != nil {
-		return nil, fall.ServerError(err.Error())
-	}
-
-	defer rows.Close()
-
-	imagesMap := make(map[int]*model.ProductModelImg)
-	sizesMap := make(map[int]*model.ProductModelSize)
-	modelsMap := make(map[int]*model.CatalogProductModel)
-	var imgOrder []int
-	var sizeOrder []int
-
-	for rows.Next() {
-		sz := model.ProductModelSize{}
-		img := model.ProductModelImg{}
-		m := model.CatalogProductModel{}
-
-		err := rows.Scan(&m.ProductId, &m.Title, &m.Brand.Id, &m.Brand.Title, &m.Brand.Slug,
-			&m.Category.Id, &m.Category.Title, &m.Category.ShortTitle, &m.Category.Slug, &m.ModelId, &m.Slug, &m.Article, &m.Price, &m.Discount,
-			&m.MainImagePath, &img.Id, &img.ProductModelId, &img.ImgPath, &sz.SizeId, &sz.Value, &sz.Literal, &sz.ModelId, &sz.InStock, &sz.SizeModelId,
-		)
-		if err != nil {
-
-			return nil, fall.ServerError(err.Error())
-		}
-
-		_, ok := modelsMap[m.ModelId]
-		if !ok {
-			modelsMap[m.ModelId] = &m
-		}
+	SELECT %s FROM catalog_model cm
+	WHERE cm.category_id IN (SELECT category_id FROM category_tree) AND cm.listed AND cm.order_count > 0
+	ORDER BY cm.order_count DESC, cm.product_model_id DESC LIMIT 28;`, catalogModelColumns)
 
-		_, ok = imagesMap[img.Id]
-		if !ok {
-			imagesMap[img.Id] = &img
-			imgOrder = append(imgOrder, img.Id)
-		}
-		_, ok = sizesMap[sz.SizeModelId]
-		if !ok {
-			sizesMap[sz.SizeModelId] = &sz
-			sizeOrder = append(sizeOrder, sz.SizeModelId)
-		}
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fall.ServerError(err.Error())
-	}
-
-	for _, v := range imgOrder {
-		img := imagesMap[v]
-		m := modelsMap[img.ProductModelId]
-		m.Images = append(m.Images, img)
-	}
-
-	for _, v := range sizeOrder {
-		sz := sizesMap[v]
-		m := modelsMap[sz.ModelId]
-		m.Sizes = append(m.Sizes, sz)
-	}
-
-	var result []*model.CatalogProductModel
-
-	for _, id := range modelOrder {
-		m := modelsMap[id]
-		sort.Slice(m.Sizes, func(i, j int) bool {
-			a := m.Sizes[i].Value
-			b := m.Sizes[j].Value
-			aSize, aErr := strconv.Atoi(a)
-			bSize, bErr := strconv.Atoi(b)
-			if aErr != nil || bErr != nil {
-				return false
-			}
-			return aSize > bSize
-		})
-
-		result = append(result, m)
-	}
-
-	return result, nil
+	return r.queryCatalogModels(ctx, q, slug)
 }
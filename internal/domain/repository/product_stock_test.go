@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+// stockTx stands in for the transaction ReduceStock runs in: it answers the locking select from inStock
+// and records which model sizes were locked and decremented, in order.
+type stockTx struct {
+	db.Transaction
+	inStock map[int]int
+	locked  [][]int
+	updated [][2]int
+}
+
+func (t *stockTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	ids := args[0].([]int)
+	t.locked = append(t.locked, ids)
+
+	rows := &stockRows{i: -1}
+	for _, id := range ids {
+		if quantity, ok := t.inStock[id]; ok {
+			rows.rows = append(rows.rows, [2]int{id, quantity})
+		}
+	}
+	return rows, nil
+}
+
+func (t *stockTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	quantity, id := args[0].(int), args[1].(int)
+	if t.inStock[id] < quantity {
+		return pgconn.NewCommandTag("UPDATE 0"), nil
+	}
+	t.inStock[id] -= quantity
+	t.updated = append(t.updated, [2]int{id, quantity})
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+type stockRows struct {
+	pgx.Rows
+	rows [][2]int
+	i    int
+}
+
+func (r *stockRows) Next() bool {
+	r.i++
+	return r.i < len(r.rows)
+}
+
+func (r *stockRows) Scan(dest ...any) error {
+	*dest[0].(*int), *dest[1].(*int) = r.rows[r.i][0], r.rows[r.i][1]
+	return nil
+}
+
+func (r *stockRows) Close() {}
+
+func (r *stockRows) Err() error { return nil }
+
+func TestReduceStock(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []model.StockChange
+		inStock map[int]int
+		locked  [][]int
+		updated [][2]int
+		short   []int
+		status  int
+	}{
+		{
+			name:    "locks and decrements in id order",
+			changes: []model.StockChange{{ModelSizeId: 9, Quantity: 1}, {ModelSizeId: 2, Quantity: 2}, {ModelSizeId: 5, Quantity: 1}},
+			inStock: map[int]int{2: 2, 5: 1, 9: 4},
+			locked:  [][]int{{2, 5, 9}},
+			updated: [][2]int{{2, 2}, {5, 1}, {9, 1}},
+		},
+		{
+			name:    "repeated size is locked once for the sum",
+			changes: []model.StockChange{{ModelSizeId: 5, Quantity: 1}, {ModelSizeId: 2, Quantity: 1}, {ModelSizeId: 5, Quantity: 2}},
+			inStock: map[int]int{2: 1, 5: 3},
+			locked:  [][]int{{2, 5}},
+			updated: [][2]int{{2, 1}, {5, 3}},
+		},
+		{
+			name:    "every short size is reported and nothing is changed",
+			changes: []model.StockChange{{ModelSizeId: 7, Quantity: 3}, {ModelSizeId: 3, Quantity: 2}, {ModelSizeId: 9, Quantity: 1}},
+			inStock: map[int]int{3: 1, 7: 2, 9: 5},
+			locked:  [][]int{{3, 7, 9}},
+			short:   []int{3, 7},
+			status:  fall.STATUS_CONFLICT,
+		},
+		{
+			name:    "repeated size short only in sum",
+			changes: []model.StockChange{{ModelSizeId: 4, Quantity: 1}, {ModelSizeId: 4, Quantity: 1}},
+			inStock: map[int]int{4: 1},
+			locked:  [][]int{{4}},
+			short:   []int{4},
+			status:  fall.STATUS_CONFLICT,
+		},
+		{
+			name:    "missing size",
+			changes: []model.StockChange{{ModelSizeId: 1, Quantity: 1}, {ModelSizeId: 8, Quantity: 1}},
+			inStock: map[int]int{1: 5},
+			locked:  [][]int{{1, 8}},
+			status:  fall.STATUS_NOT_FOUND,
+		},
+		{
+			name: "no changes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &stockTx{inStock: tt.inStock}
+
+			ex := (&ProductRepository{}).ReduceStock(context.Background(), tx, tt.changes)
+
+			if tt.status == 0 && ex != nil {
+				t.Fatalf("unexpected error: %s", ex.Message())
+			}
+			if tt.status != 0 && (ex == nil || ex.Status() != tt.status) {
+				t.Fatalf("got %v, want status %d", ex, tt.status)
+			}
+
+			var short []int
+			if stockErr, ok := ex.(fall.StockError); ok {
+				short = stockErr.ModelSizeIds
+			}
+			if !reflect.DeepEqual(short, tt.short) {
+				t.Errorf("short %v, want %v", short, tt.short)
+			}
+			if !reflect.DeepEqual(tx.locked, tt.locked) {
+				t.Errorf("locked %v, want %v", tx.locked, tt.locked)
+			}
+			if !reflect.DeepEqual(tx.updated, tt.updated) {
+				t.Errorf("updated %v, want %v", tx.updated, tt.updated)
+			}
+		})
+	}
+}
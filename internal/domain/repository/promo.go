@@ -0,0 +1,382 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type PromoRepository struct {
+	db db.PostgresClient
+}
+
+func NewPromoRepository(db db.PostgresClient) *PromoRepository {
+	return &PromoRepository{db: db}
+}
+
+func (r *PromoRepository) Create(ctx context.Context, dto model.CreatePromoCodeDto) fall.Error {
+
+	var ex fall.Error = nil
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		ex = fall.ServerError(err.Error())
+		return ex
+	}
+
+	defer func() {
+		if ex != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	q := `INSERT INTO promo_code (code,discount_type,discount_value,min_basket_price,start_date,end_date,usage_limit,usage_limit_per_user)
+	VALUES ($1,$2,$3,$4,COALESCE($5, CURRENT_TIMESTAMP),$6,$7,$8) RETURNING promo_code_id;`
+
+	row := tx.QueryRow(ctx, q, strings.ToUpper(dto.Code), dto.DiscountType, dto.DiscountValue, dto.MinBasketPrice, dto.StartDate,
+		dto.EndDate, dto.UsageLimit, dto.UsageLimitPerUser)
+
+	var id int
+
+	err = row.Scan(&id)
+	if err != nil {
+		ex = fall.ServerError(fmt.Sprintf("%s, details: \n %s", msg.PromoCodeCreateError, err.Error()))
+		return ex
+	}
+
+	ex = r.setRestrictions(ctx, tx, id, dto.BrandIds, dto.CategoryIds)
+	if ex != nil {
+		return ex
+	}
+
+	return nil
+}
+
+func (r *PromoRepository) setRestrictions(ctx context.Context, tx db.Transaction, id int, brandIds []int, categoryIds []int) fall.Error {
+	if brandIds != nil {
+		_, err := tx.Exec(ctx, "DELETE FROM promo_code_brand WHERE promo_code_id = $1;", id)
+		if err != nil {
+			return fall.ServerError(err.Error())
+		}
+		q := "INSERT INTO promo_code_brand (promo_code_id,brand_id) SELECT $1, unnest($2::int[]) ON CONFLICT DO NOTHING;"
+		_, err = tx.Exec(ctx, q, id, brandIds)
+		if err != nil {
+			return fall.ServerError(err.Error())
+		}
+	}
+
+	if categoryIds != nil {
+		_, err := tx.Exec(ctx, "DELETE FROM promo_code_category WHERE promo_code_id = $1;", id)
+		if err != nil {
+			return fall.ServerError(err.Error())
+		}
+		q := "INSERT INTO promo_code_category (promo_code_id,category_id) SELECT $1, unnest($2::int[]) ON CONFLICT DO NOTHING;"
+		_, err = tx.Exec(ctx, q, id, categoryIds)
+		if err != nil {
+			return fall.ServerError(err.Error())
+		}
+	}
+	return nil
+}
+
+func (r *PromoRepository) FindById(ctx context.Context, id int) (*model.PromoCode, fall.Error) {
+	return r.findByField(ctx, "pc.promo_code_id", id)
+}
+
+func (r *PromoRepository) FindByCode(ctx context.Context, code string) (*model.PromoCode, fall.Error) {
+	return r.findByField(ctx, "pc.code", strings.ToUpper(code))
+}
+
+func (r *PromoRepository) findByField(ctx context.Context, field string, value any) (*model.PromoCode, fall.Error) {
+	q := fmt.Sprintf(`
+	SELECT pc.promo_code_id, pc.created_at, pc.updated_at, pc.code, pc.discount_type, pc.discount_value, pc.min_basket_price,
+	pc.start_date, pc.end_date, pc.usage_limit, pc.usage_limit_per_user, pc.used_count, pc.is_activated,
+	ARRAY(SELECT brand_id FROM promo_code_brand WHERE promo_code_id = pc.promo_code_id ORDER BY brand_id),
+	ARRAY(SELECT category_id FROM promo_code_category WHERE promo_code_id = pc.promo_code_id ORDER BY category_id)
+	FROM promo_code AS pc WHERE %s = $1;`, field)
+
+	row := r.db.QueryRow(ctx, q, value)
+
+	p := model.PromoCode{}
+
+	err := row.Scan(&p.Id, &p.CreatedAt, &p.UpdatedAt, &p.Code, &p.DiscountType, &p.DiscountValue, &p.MinBasketPrice,
+		&p.StartDate, &p.EndDate, &p.UsageLimit, &p.UsageLimitPerUser, &p.UsedCount, &p.IsActivated, &p.BrandIds, &p.CategoryIds)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fall.NewErr(msg.PromoCodeNotFound, fall.STATUS_NOT_FOUND)
+		}
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return &p, nil
+}
+
+func (r *PromoRepository) GetAll(ctx context.Context) ([]model.PromoCode, fall.Error) {
+	q := `
+	SELECT pc.promo_code_id, pc.created_at, pc.updated_at, pc.code, pc.discount_type, pc.discount_value, pc.min_basket_price,
+	pc.start_date, pc.end_date, pc.usage_limit, pc.usage_limit_per_user, pc.used_count, pc.is_activated,
+	ARRAY(SELECT brand_id FROM promo_code_brand WHERE promo_code_id = pc.promo_code_id ORDER BY brand_id),
+	ARRAY(SELECT category_id FROM promo_code_category WHERE promo_code_id = pc.promo_code_id ORDER BY category_id)
+	FROM promo_code AS pc ORDER BY pc.created_at DESC;`
+
+	rows, err := r.db.Query(ctx, q)
+
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	codes := []model.PromoCode{}
+
+	for rows.Next() {
+		p := model.PromoCode{}
+
+		err := rows.Scan(&p.Id, &p.CreatedAt, &p.UpdatedAt, &p.Code, &p.DiscountType, &p.DiscountValue, &p.MinBasketPrice,
+			&p.StartDate, &p.EndDate, &p.UsageLimit, &p.UsageLimitPerUser, &p.UsedCount, &p.IsActivated, &p.BrandIds, &p.CategoryIds)
+
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		codes = append(codes, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return codes, nil
+}
+
+func (r *PromoRepository) Update(ctx context.Context, dto model.UpdatePromoCodeDto, id int) fall.Error {
+
+	var ex fall.Error = nil
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		ex = fall.ServerError(err.Error())
+		return ex
+	}
+
+	defer func() {
+		if ex != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	var queries []string
+	var args []any
+
+	set := func(column string, value any) {
+		args = append(args, value)
+		queries = append(queries, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if dto.DiscountType != nil {
+		set("discount_type", *dto.DiscountType)
+	}
+
+	if dto.DiscountValue != nil {
+		set("discount_value", *dto.DiscountValue)
+	}
+
+	if dto.MinBasketPrice != nil {
+		set("min_basket_price", *dto.MinBasketPrice)
+	}
+
+	if dto.StartDate != nil {
+		set("start_date", *dto.StartDate)
+	}
+
+	if dto.EndDate != nil {
+		set("end_date", *dto.EndDate)
+	}
+
+	if dto.UsageLimit != nil {
+		set("usage_limit", *dto.UsageLimit)
+	}
+
+	if dto.UsageLimitPerUser != nil {
+		set("usage_limit_per_user", *dto.UsageLimitPerUser)
+	}
+
+	if dto.IsActivated != nil {
+		set("is_activated", *dto.IsActivated)
+	}
+
+	if len(queries) > 0 {
+		queries = append(queries, "updated_at = CURRENT_TIMESTAMP")
+		args = append(args, id)
+		q := fmt.Sprintf("UPDATE promo_code SET %s WHERE promo_code_id = $%d;", strings.Join(queries, ","), len(args))
+		_, err := tx.Exec(ctx, q, args...)
+		if err != nil {
+			ex = fall.ServerError(fmt.Sprintf("%s, details: \n %s", msg.PromoCodeUpdateError, err.Error()))
+			return ex
+		}
+	}
+
+	ex = r.setRestrictions(ctx, tx, id, dto.BrandIds, dto.CategoryIds)
+	if ex != nil {
+		return ex
+	}
+
+	return nil
+}
+
+func (r *PromoRepository) Delete(ctx context.Context, id int) fall.Error {
+	q := "DELETE FROM promo_code WHERE promo_code_id = $1;"
+
+	_, err := r.db.Exec(ctx, q, id)
+
+	if err != nil {
+		return fall.ServerError(fmt.Sprintf("%s, details: \n %s", msg.PromoCodeDeleteError, err.Error()))
+	}
+
+	return nil
+}
+
+func (r *PromoRepository) CountUserRedemptions(ctx context.Context, id int, userId int) (int, fall.Error) {
+	q := "SELECT COUNT(*) FROM promo_code_redemption WHERE promo_code_id = $1 AND user_id = $2;"
+
+	var count int
+
+	err := r.db.QueryRow(ctx, q, id, userId).Scan(&count)
+	if err != nil {
+		return 0, fall.ServerError(err.Error())
+	}
+
+	return count, nil
+}
+
+// FindApplicableModels returns the subset of modelIds covered by the promo code brand and category
+// restrictions. Category restrictions include every nested subcategory.
+func (r *PromoRepository) FindApplicableModels(ctx context.Context, id int, modelIds []int) (map[int]bool, fall.Error) {
+	q := `
+	WITH RECURSIVE category_tree AS (
+		SELECT c.category_id
+		FROM category c
+		INNER JOIN promo_code_category pcc ON pcc.category_id = c.category_id
+		WHERE pcc.promo_code_id = $1
+		UNION ALL
+		SELECT c.category_id
+		FROM category c
+		INNER JOIN category_tree ct ON c.parent_category_id = ct.category_id
+	)
+	SELECT pm.product_model_id
+	FROM product_model pm
+	INNER JOIN product p ON p.product_id = pm.product_id
+	WHERE pm.product_model_id = ANY ($2)
+	AND (NOT EXISTS (SELECT 1 FROM promo_code_brand WHERE promo_code_id = $1)
+		OR p.brand_id IN (SELECT brand_id FROM promo_code_brand WHERE promo_code_id = $1))
+	AND (NOT EXISTS (SELECT 1 FROM promo_code_category WHERE promo_code_id = $1)
+		OR p.category_id IN (SELECT category_id FROM category_tree));
+	`
+
+	rows, err := r.db.Query(ctx, q, id, modelIds)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	result := make(map[int]bool)
+
+	for rows.Next() {
+		var modelId int
+		err := rows.Scan(&modelId)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		result[modelId] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return result, nil
+}
+
+// Redeem records the promo code usage inside the order transaction. The promo code row is locked
+// with FOR UPDATE, so concurrent checkouts with the same code are serialized and limits hold.
+func (r *PromoRepository) Redeem(ctx context.Context, tx db.Transaction, id int, orderId string, userId int, discount int) fall.Error {
+	q := `SELECT usage_limit, usage_limit_per_user, used_count, is_activated,
+	CURRENT_TIMESTAMP BETWEEN start_date AND end_date
+	FROM promo_code WHERE promo_code_id = $1 FOR UPDATE;`
+
+	var usageLimit *int
+	var usageLimitPerUser *int
+	var usedCount int
+	var isActivated bool
+	var inWindow bool
+
+	err := tx.QueryRow(ctx, q, id).Scan(&usageLimit, &usageLimitPerUser, &usedCount, &isActivated, &inWindow)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fall.NewErr(msg.PromoCodeNotFound, fall.STATUS_NOT_FOUND)
+		}
+		return fall.ServerError(err.Error())
+	}
+
+	if !isActivated || !inWindow {
+		return fall.NewErr(msg.PromoCodeNotActive, fall.STATUS_BAD_REQUEST)
+	}
+
+	if usageLimit != nil && usedCount >= *usageLimit {
+		return fall.NewErr(msg.PromoCodeUsageLimit, fall.STATUS_BAD_REQUEST)
+	}
+
+	if usageLimitPerUser != nil {
+		var userCount int
+		q = "SELECT COUNT(*) FROM promo_code_redemption WHERE promo_code_id = $1 AND user_id = $2;"
+		err := tx.QueryRow(ctx, q, id, userId).Scan(&userCount)
+		if err != nil {
+			return fall.ServerError(err.Error())
+		}
+		if userCount >= *usageLimitPerUser {
+			return fall.NewErr(msg.PromoCodeUserUsageLimit, fall.STATUS_BAD_REQUEST)
+		}
+	}
+
+	q = "INSERT INTO promo_code_redemption (promo_code_id,order_id,user_id,discount) VALUES ($1,$2,$3,$4);"
+	_, err = tx.Exec(ctx, q, id, orderId, userId, discount)
+	if err != nil {
+		return fall.ServerError(msg.PromoCodeErrorWhenRedeem)
+	}
+
+	q = "UPDATE promo_code SET used_count = used_count + 1 WHERE promo_code_id = $1;"
+	_, err = tx.Exec(ctx, q, id)
+	if err != nil {
+		return fall.ServerError(msg.PromoCodeErrorWhenRedeem)
+	}
+
+	return nil
+}
+
+// Release returns the promo code usage of a canceled order back to the pool.
+func (r *PromoRepository) Release(ctx context.Context, tx db.Transaction, orderId string) fall.Error {
+	q := `
+	WITH deleted AS (
+		DELETE FROM promo_code_redemption WHERE order_id = $1 RETURNING promo_code_id
+	)
+	UPDATE promo_code SET used_count = GREATEST(used_count - 1, 0)
+	WHERE promo_code_id IN (SELECT promo_code_id FROM deleted);
+	`
+	_, err := tx.Exec(ctx, q, orderId)
+	if err != nil {
+		return fall.ServerError(msg.PromoCodeErrorWhenRelease)
+	}
+	return nil
+}
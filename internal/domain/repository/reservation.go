@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type reservationProductRepository interface {
+	ReduceStock(ctx context.Context, tx db.Transaction, changes []model.StockChange) fall.Error
+}
+
+// ReservationRepository keeps in_stock as the quantity available for sale:
+// a reservation takes units out of it and only a release puts them back.
+type ReservationRepository struct {
+	db                db.PostgresClient
+	productRepository reservationProductRepository
+}
+
+func NewReservationRepository(db db.PostgresClient, productRepository reservationProductRepository) *ReservationRepository {
+	return &ReservationRepository{db: db, productRepository: productRepository}
+}
+
+// Reserve takes all items of the order out of stock at once, either every item is reserved or none.
+func (r *ReservationRepository) Reserve(ctx context.Context, tx db.Transaction, orderId string, items []model.StockChange,
+	ttl time.Duration) fall.Error {
+	err := trackStock(ctx, tx, model.StockSource{Type: model.StockSale, OrderId: &orderId})
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	ex := r.productRepository.ReduceStock(ctx, tx, items)
+	if ex != nil {
+		return ex
+	}
+
+	q := `INSERT INTO stock_reservation (order_id, model_size_id, quantity, expires_at)
+	VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4));`
+
+	for _, item := range items {
+		_, err := tx.Exec(ctx, q, orderId, item.ModelSizeId, item.Quantity, ttl.Seconds())
+		if err != nil {
+			return fall.ServerError(msg.ReservationErrorWhenCreate)
+		}
+	}
+
+	return nil
+}
+
+// Sell turns the active reservations of the order into a sale, the stock stays reduced.
+func (r *ReservationRepository) Sell(ctx context.Context, tx db.Transaction, orderId string) fall.Error {
+	return r.setStatus(ctx, tx, orderId, model.ReservationSold)
+}
+
+// Release only marks the reservations, the caller returns the stock together with the rest of the order.
+func (r *ReservationRepository) Release(ctx context.Context, tx db.Transaction, orderId string) fall.Error {
+	return r.setStatus(ctx, tx, orderId, model.ReservationReleased)
+}
+
+func (r *ReservationRepository) setStatus(ctx context.Context, tx db.Transaction, orderId string, status model.ReservationStatus) fall.Error {
+	q := `UPDATE stock_reservation SET reservation_status = $1, updated_at = CURRENT_TIMESTAMP
+	WHERE order_id = $2 AND reservation_status = $3;`
+
+	_, err := tx.Exec(ctx, q, status, orderId, model.ReservationActive)
+	if err != nil {
+		return fall.ServerError(msg.ReservationErrorWhenUpdate)
+	}
+
+	return nil
+}
+
+// Extend keeps the active reservations of the order for at least ttl from now, they are never shortened.
+func (r *ReservationRepository) Extend(ctx context.Context, tx db.Transaction, orderId string, ttl time.Duration) fall.Error {
+	q := `UPDATE stock_reservation SET expires_at = GREATEST(expires_at, CURRENT_TIMESTAMP + make_interval(secs => $1)),
+	updated_at = CURRENT_TIMESTAMP WHERE order_id = $2 AND reservation_status = $3;`
+
+	_, err := tx.Exec(ctx, q, ttl.Seconds(), orderId, model.ReservationActive)
+	if err != nil {
+		return fall.ServerError(msg.ReservationErrorWhenUpdate)
+	}
+
+	return nil
+}
+
+// FindExpiredOrders returns orders that still wait for payment or activation after their reservation has expired.
+func (r *ReservationRepository) FindExpiredOrders(ctx context.Context, limit int) ([]string, fall.Error) {
+	q := `SELECT DISTINCT sr.order_id FROM stock_reservation as sr
+	INNER JOIN public.order as o ON sr.order_id = o.order_id
+	WHERE sr.reservation_status = $1 AND sr.expires_at < CURRENT_TIMESTAMP
+	AND o.order_status IN ($2, $3)
+	LIMIT $4;`
+
+	rows, err := r.db.Query(ctx, q, model.ReservationActive, model.WaitingForPayment, model.WaitingForActivation, limit)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	var orderIds []string
+
+	for rows.Next() {
+		var orderId string
+		err := rows.Scan(&orderId)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		orderIds = append(orderIds, orderId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return orderIds, nil
+}
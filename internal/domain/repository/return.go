@@ -0,0 +1,426 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/payment"
+)
+
+type returnProductRepository interface {
+	ReturnQuantityInStock(ctx context.Context, modelSizeId int, quantity int, tx db.Transaction) fall.Error
+}
+
+type returnOutboxRepository interface {
+	Add(ctx context.Context, tx db.Transaction, topic string, payload any) (*int64, fall.Error)
+}
+
+type ReturnRepository struct {
+	db                db.PostgresClient
+	productRepository returnProductRepository
+	outboxRepository  returnOutboxRepository
+}
+
+func NewReturnRepository(db db.PostgresClient, productRepository returnProductRepository,
+	outboxRepository returnOutboxRepository) *ReturnRepository {
+	return &ReturnRepository{db: db, productRepository: productRepository, outboxRepository: outboxRepository}
+}
+
+type returnOrderModel struct {
+	modelSizeId int
+	quantity    int
+	returned    int
+	price       int
+	discount    *byte
+}
+
+// Create checks the requested quantities against what is left in the order and
+// calculates the refund amount: item discount applied, promo discount split proportionally.
+func (r *ReturnRepository) Create(ctx context.Context, dto model.CreateOrderReturnDto, orderId string, userId int) (*int, fall.Error) {
+	var ex fall.Error = nil
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		ex = fall.ServerError(err.Error())
+		return nil, ex
+	}
+
+	defer func() {
+		if ex != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	q := `SELECT user_id, order_status, products_price, total_discount, promo_discount
+	FROM public.order WHERE order_id = $1 FOR UPDATE;`
+
+	var orderUserId int
+	var status model.OrderStatusEnum
+	var productsPrice float64
+	var totalDiscount float64
+	var promoDiscount int
+
+	err = tx.QueryRow(ctx, q, orderId).Scan(&orderUserId, &status, &productsPrice, &totalDiscount, &promoDiscount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ex = fall.NewErr(msg.OrderNotFound, fall.STATUS_NOT_FOUND)
+			return nil, ex
+		}
+		ex = fall.ServerError(err.Error())
+		return nil, ex
+	}
+
+	if orderUserId != userId {
+		ex = fall.NewErr(msg.OrderNotFound, fall.STATUS_NOT_FOUND)
+		return nil, ex
+	}
+
+	if status != model.Completed {
+		ex = fall.NewErr(msg.ReturnOrderNotCompleted, fall.STATUS_BAD_REQUEST)
+		return nil, ex
+	}
+
+	q = `SELECT om.order_model_id, om.model_size_id, om.quantity, om.price, om.discount,
+	COALESCE((SELECT SUM(orm.quantity) FROM order_return_model as orm
+	INNER JOIN order_return as ort ON orm.order_return_id = ort.order_return_id
+	WHERE orm.order_model_id = om.order_model_id AND ort.return_status <> 'rejected'), 0)
+	FROM order_model as om WHERE om.order_id = $1;`
+
+	rows, err := tx.Query(ctx, q, orderId)
+	if err != nil {
+		ex = fall.ServerError(err.Error())
+		return nil, ex
+	}
+
+	orderModels := make(map[int]returnOrderModel)
+
+	for rows.Next() {
+		var id int
+		var m returnOrderModel
+		err = rows.Scan(&id, &m.modelSizeId, &m.quantity, &m.price, &m.discount, &m.returned)
+		if err != nil {
+			rows.Close()
+			ex = fall.ServerError(err.Error())
+			return nil, ex
+		}
+		orderModels[id] = m
+	}
+	rows.Close()
+
+	basePrice := productsPrice - totalDiscount
+
+	amounts := make([]float64, len(dto.Models))
+	var total float64 = 0
+
+	for i, item := range dto.Models {
+		m, ok := orderModels[item.OrderModelId]
+		if !ok {
+			ex = fall.NewErr(msg.ReturnOrderModelNotFound, fall.STATUS_NOT_FOUND)
+			return nil, ex
+		}
+		if item.Quantity > m.quantity-m.returned {
+			ex = fall.NewErr(msg.ReturnQuantityExceeded, fall.STATUS_BAD_REQUEST)
+			return nil, ex
+		}
+
+		unitPrice := float64(m.price)
+		if m.discount != nil {
+			unitPrice -= (float64(m.price) / 100) * float64(*m.discount)
+		}
+		line := unitPrice * float64(item.Quantity)
+		if promoDiscount > 0 && basePrice > 0 {
+			line -= float64(promoDiscount) * line / basePrice
+		}
+		amounts[i] = math.Floor(line*100) / 100
+		total += amounts[i]
+	}
+
+	q = `INSERT INTO order_return (order_id, user_id, reason, amount) VALUES ($1, $2, $3, $4) RETURNING order_return_id;`
+
+	var returnId int
+
+	err = tx.QueryRow(ctx, q, orderId, userId, dto.Reason, total).Scan(&returnId)
+	if err != nil {
+		ex = fall.ServerError(msg.ReturnErrorWhenCreate)
+		return nil, ex
+	}
+
+	q = `INSERT INTO order_return_model (order_return_id, order_model_id, quantity, amount) VALUES ($1, $2, $3, $4);`
+
+	for i, item := range dto.Models {
+		_, err = tx.Exec(ctx, q, returnId, item.OrderModelId, item.Quantity, amounts[i])
+		if err != nil {
+			ex = fall.ServerError(msg.ReturnErrorWhenCreate)
+			return nil, ex
+		}
+	}
+
+	return &returnId, nil
+}
+
+// Approve restocks the returned units and requests a refund of the return amount.
+// Orders paid upon receipt are refunded by the pick-up point, so only the record is saved.
+func (r *ReturnRepository) Approve(ctx context.Context, id int, comment *string) fall.Error {
+	var ex fall.Error = nil
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		ex = fall.ServerError(err.Error())
+		return ex
+	}
+
+	defer func() {
+		if ex != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	q := `SELECT ort.order_id, ort.return_status, ort.amount, o.order_payment_method, o.payment_id
+	FROM order_return as ort
+	INNER JOIN public.order as o ON ort.order_id = o.order_id
+	WHERE ort.order_return_id = $1 FOR UPDATE OF ort;`
+
+	var orderId string
+	var status model.OrderReturnStatus
+	var amount float64
+	var paymentMethod model.PaymentMethodEnum
+	var paymentId *string
+
+	err = tx.QueryRow(ctx, q, id).Scan(&orderId, &status, &amount, &paymentMethod, &paymentId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ex = fall.NewErr(msg.ReturnNotFound, fall.STATUS_NOT_FOUND)
+			return ex
+		}
+		ex = fall.ServerError(err.Error())
+		return ex
+	}
+
+	if status != model.ReturnPending {
+		ex = fall.NewErr(msg.ReturnAlreadyProcessed, fall.STATUS_BAD_REQUEST)
+		return ex
+	}
+
+	q = `UPDATE order_return SET return_status = $1, admin_comment = $2, updated_at = CURRENT_TIMESTAMP WHERE order_return_id = $3;`
+
+	_, err = tx.Exec(ctx, q, model.ReturnApproved, comment, id)
+	if err != nil {
+		ex = fall.ServerError(msg.ReturnErrorWhenChangeState)
+		return ex
+	}
+
+	q = `SELECT om.model_size_id, orm.quantity FROM order_return_model as orm
+	INNER JOIN order_model as om ON orm.order_model_id = om.order_model_id
+	WHERE orm.order_return_id = $1;`
+
+	rows, err := tx.Query(ctx, q, id)
+	if err != nil {
+		ex = fall.ServerError(err.Error())
+		return ex
+	}
+
+	var items []returnOrderModel
+
+	for rows.Next() {
+		var item returnOrderModel
+		err = rows.Scan(&item.modelSizeId, &item.quantity)
+		if err != nil {
+			rows.Close()
+			ex = fall.ServerError(err.Error())
+			return ex
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	err = trackStock(ctx, tx, model.StockSource{Type: model.StockReturn, OrderId: &orderId})
+	if err != nil {
+		ex = fall.ServerError(err.Error())
+		return ex
+	}
+
+	for _, item := range items {
+		ex = r.productRepository.ReturnQuantityInStock(ctx, item.modelSizeId, item.quantity, tx)
+		if ex != nil {
+			return ex
+		}
+	}
+
+	if paymentMethod == model.Online && paymentId != nil && amount > 0 {
+		ex = r.RequestRefund(ctx, tx, orderId, &id, *paymentId, amount)
+		return ex
+	}
+
+	_, ex = r.SaveRefund(ctx, tx, model.OrderRefundInput{OrderId: orderId, OrderReturnId: &id, Amount: amount, Status: payment.StatusSucceeded})
+	return ex
+}
+
+func (r *ReturnRepository) Reject(ctx context.Context, id int, comment *string) fall.Error {
+	q := `UPDATE order_return SET return_status = $1, admin_comment = $2, updated_at = CURRENT_TIMESTAMP
+	WHERE order_return_id = $3 AND return_status = $4;`
+
+	tag, err := r.db.Exec(ctx, q, model.ReturnRejected, comment, id, model.ReturnPending)
+	if err != nil {
+		return fall.ServerError(msg.ReturnErrorWhenChangeState)
+	}
+
+	if tag.RowsAffected() == 0 {
+		_, ex := r.FindById(ctx, id)
+		if ex != nil {
+			return ex
+		}
+		return fall.NewErr(msg.ReturnAlreadyProcessed, fall.STATUS_BAD_REQUEST)
+	}
+
+	return nil
+}
+
+func (r *ReturnRepository) SaveRefund(ctx context.Context, tx db.Transaction, input model.OrderRefundInput) (*int, fall.Error) {
+	q := `INSERT INTO order_refund (order_id, order_return_id, payment_refund_id, amount, refund_status)
+	VALUES ($1, $2, $3, $4, $5) RETURNING order_refund_id;`
+
+	var id int
+
+	err := tx.QueryRow(ctx, q, input.OrderId, input.OrderReturnId, input.PaymentRefundId, input.Amount, input.Status).Scan(&id)
+	if err != nil {
+		return nil, fall.ServerError(msg.RefundErrorWhenSave)
+	}
+
+	return &id, nil
+}
+
+// RequestRefund saves a pending refund and leaves the call to the payment provider to the outbox.
+func (r *ReturnRepository) RequestRefund(ctx context.Context, tx db.Transaction, orderId string, returnId *int,
+	paymentId string, amount float64) fall.Error {
+	id, ex := r.SaveRefund(ctx, tx, model.OrderRefundInput{OrderId: orderId, OrderReturnId: returnId, Amount: amount, Status: payment.StatusPending})
+	if ex != nil {
+		return ex
+	}
+
+	_, ex = r.outboxRepository.Add(ctx, tx, model.TopicPaymentRefund,
+		model.PaymentRefundPayload{RefundId: *id, PaymentId: paymentId, Amount: amount})
+	return ex
+}
+
+func (r *ReturnRepository) FinishRefund(ctx context.Context, id int, paymentRefundId string, status string) fall.Error {
+	q := `UPDATE order_refund SET payment_refund_id = $2, refund_status = $3 WHERE order_refund_id = $1;`
+
+	_, err := r.db.Exec(ctx, q, id, paymentRefundId, status)
+	if err != nil {
+		return fall.ServerError(msg.RefundErrorWhenSave)
+	}
+
+	return nil
+}
+
+func (r *ReturnRepository) FindById(ctx context.Context, id int) (*model.OrderReturn, fall.Error) {
+	returns, ex := r.find(ctx, "WHERE ort.order_return_id = $1", id)
+	if ex != nil {
+		return nil, ex
+	}
+	if len(returns) == 0 {
+		return nil, fall.NewErr(msg.ReturnNotFound, fall.STATUS_NOT_FOUND)
+	}
+	return &returns[0], nil
+}
+
+func (r *ReturnRepository) FindByOrder(ctx context.Context, orderId string) ([]model.OrderReturn, fall.Error) {
+	return r.find(ctx, "WHERE ort.order_id = $1", orderId)
+}
+
+func (r *ReturnRepository) GetAll(ctx context.Context, status *model.OrderReturnStatus) ([]model.OrderReturn, fall.Error) {
+	if status != nil {
+		return r.find(ctx, "WHERE ort.return_status = $1", *status)
+	}
+	return r.find(ctx, "")
+}
+
+func (r *ReturnRepository) find(ctx context.Context, where string, args ...any) ([]model.OrderReturn, fall.Error) {
+	q := fmt.Sprintf(`SELECT ort.order_return_id, ort.created_at, ort.updated_at, ort.order_id, ort.user_id,
+	ort.return_status, ort.reason, ort.admin_comment, ort.amount,
+	orm.order_return_model_id, orm.order_model_id, om.model_size_id, orm.quantity, orm.amount
+	FROM order_return as ort
+	INNER JOIN order_return_model as orm ON ort.order_return_id = orm.order_return_id
+	INNER JOIN order_model as om ON orm.order_model_id = om.order_model_id
+	%s
+	ORDER BY ort.created_at DESC, orm.order_return_model_id;`, where)
+
+	rows, err := r.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	returnsMap := make(map[int]*model.OrderReturn)
+	var returnsOrder []int
+
+	for rows.Next() {
+		o := model.OrderReturn{}
+		m := model.OrderReturnModel{}
+		err := rows.Scan(&o.Id, &o.CreatedAt, &o.UpdatedAt, &o.OrderId, &o.UserId, &o.Status, &o.Reason, &o.Comment, &o.Amount,
+			&m.Id, &m.OrderModelId, &m.ModelSizeId, &m.Quantity, &m.Amount)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+
+		current, ok := returnsMap[o.Id]
+		if !ok {
+			current = &o
+			returnsMap[o.Id] = current
+			returnsOrder = append(returnsOrder, o.Id)
+		}
+		current.Models = append(current.Models, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	returns := make([]model.OrderReturn, 0, len(returnsOrder))
+	for _, id := range returnsOrder {
+		returns = append(returns, *returnsMap[id])
+	}
+
+	return returns, nil
+}
+
+func (r *ReturnRepository) FindRefundsByOrder(ctx context.Context, orderId string) ([]model.OrderRefund, fall.Error) {
+	q := `SELECT order_refund_id, created_at, order_id, order_return_id, payment_refund_id, amount, refund_status
+	FROM order_refund WHERE order_id = $1 ORDER BY created_at;`
+
+	rows, err := r.db.Query(ctx, q, orderId)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	refunds := []model.OrderRefund{}
+
+	for rows.Next() {
+		f := model.OrderRefund{}
+		err := rows.Scan(&f.Id, &f.CreatedAt, &f.OrderId, &f.OrderReturnId, &f.PaymentRefundId, &f.Amount, &f.Status)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		refunds = append(refunds, f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return refunds, nil
+}
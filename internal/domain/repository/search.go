@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type SearchRepository struct {
+	db db.PostgresClient
+}
+
+func NewSearchRepository(db db.PostgresClient) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Suggest finds brands, categories, products and past queries whose title starts with the term
+// or has a word starting with it, limit applies to every group.
+func (r *SearchRepository) Suggest(ctx context.Context, term string, limit int) (*model.Suggestions, fall.Error) {
+	escaped := likeEscaper.Replace(term)
+	prefix := escaped + "%"
+	wordPrefix := "% " + escaped + "%"
+
+	q := `
+	(SELECT 'brand' AS kind, b.brand_id AS id, b.title, b.slug, 0 AS hits FROM brand b
+	WHERE lower(b.title) LIKE $1 OR lower(b.title) LIKE $2
+	ORDER BY lower(b.title) LIKE $1 DESC, length(b.title) LIMIT $3)
+	UNION ALL
+	(SELECT 'category', c.category_id, c.title, c.slug, 0 FROM category c
+	WHERE lower(c.title) LIKE $1 OR lower(c.title) LIKE $2
+	ORDER BY lower(c.title) LIKE $1 DESC, length(c.title) LIMIT $3)
+	UNION ALL
+	(SELECT 'product', p.product_id, p.title,
+	(SELECT pm.slug FROM product_model pm WHERE pm.product_id = p.product_id ORDER BY pm.product_model_id LIMIT 1), 0
+	FROM product p
+	WHERE (lower(p.title) LIKE $1 OR lower(p.title) LIKE $2)
+	AND EXISTS (SELECT 1 FROM product_model pm WHERE pm.product_id = p.product_id)
+	ORDER BY lower(p.title) LIKE $1 DESC, length(p.title) LIMIT $3)
+	UNION ALL
+	(SELECT 'query', 0, sq.query, sq.query, sq.hits FROM search_query sq
+	WHERE sq.query LIKE $1 AND sq.results > 0
+	ORDER BY sq.hits DESC LIMIT $3);
+	`
+
+	rows, err := r.db.Query(ctx, q, prefix, wordPrefix, limit)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	s := &model.Suggestions{
+		Brands:     []model.SuggestItem{},
+		Categories: []model.SuggestItem{},
+		Products:   []model.SuggestItem{},
+		Queries:    []model.SuggestQuery{},
+	}
+
+	for rows.Next() {
+		var kind string
+		var hits int
+		item := model.SuggestItem{}
+
+		err := rows.Scan(&kind, &item.Id, &item.Title, &item.Slug, &hits)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+
+		switch kind {
+		case "brand":
+			s.Brands = append(s.Brands, item)
+		case "category":
+			s.Categories = append(s.Categories, item)
+		case "product":
+			s.Products = append(s.Products, item)
+		case "query":
+			s.Queries = append(s.Queries, model.SuggestQuery{Query: item.Title, Hits: hits})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return s, nil
+}
+
+func (r *SearchRepository) LogQuery(ctx context.Context, query string, results int) fall.Error {
+	q := `INSERT INTO search_query (query, results) VALUES ($1, $2)
+	ON CONFLICT (query) DO UPDATE SET hits = search_query.hits + 1, results = EXCLUDED.results,
+	last_searched_at = CURRENT_TIMESTAMP;`
+
+	_, err := r.db.Exec(ctx, q, query, results)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	return nil
+}
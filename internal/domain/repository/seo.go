@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type SeoRepository struct {
+	db db.PostgresClient
+}
+
+func NewSeoRepository(db db.PostgresClient) *SeoRepository {
+	return &SeoRepository{db: db}
+}
+
+var seoTables = map[string]struct {
+	table    string
+	idColumn string
+}{
+	model.SeoCategory: {"category", "category_id"},
+	model.SeoBrand:    {"brand", "brand_id"},
+	model.SeoProduct:  {"product", "product_id"},
+	model.SeoModel:    {"product_model", "product_model_id"},
+}
+
+// GetSource finds a storefront page by slug, a model falls back to the meta fields of its product.
+func (r *SeoRepository) GetSource(ctx context.Context, entity string, slug string) (*model.SeoSource, fall.Error) {
+	var q string
+
+	switch entity {
+	case model.SeoCategory:
+		q = `SELECT title, slug, '', '', '', 0, meta_title, meta_description, canonical FROM category WHERE slug = $1;`
+	case model.SeoBrand:
+		q = `SELECT title, slug, '', '', '', 0, meta_title, meta_description, canonical FROM brand WHERE slug = $1;`
+	case model.SeoModel:
+		q = `
+		SELECT p.title, pm.slug, b.title, c.title, pm.article,
+		pm.price - CEIL(pm.price::numeric / 100 * COALESCE(pm.discount, 0))::int,
+		COALESCE(pm.meta_title, p.meta_title), COALESCE(pm.meta_description, p.meta_description),
+		COALESCE(pm.canonical, p.canonical)
+		FROM product_model pm
+		INNER JOIN product p ON p.product_id = pm.product_id
+		INNER JOIN brand b ON b.brand_id = p.brand_id
+		INNER JOIN category c ON c.category_id = p.category_id
+		WHERE pm.slug = $1;`
+	default:
+		return nil, fall.NewErr(msg.SeoUnknownEntity, fall.STATUS_BAD_REQUEST)
+	}
+
+	s := &model.SeoSource{}
+
+	err := r.db.QueryRow(ctx, q, slug).Scan(&s.Title, &s.Slug, &s.Brand, &s.Category, &s.Article, &s.Price,
+		&s.MetaTitle, &s.MetaDescription, &s.Canonical)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fall.NewErr(msg.SeoPageNotFound, fall.STATUS_NOT_FOUND)
+		}
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return s, nil
+}
+
+func (r *SeoRepository) Update(ctx context.Context, entity string, id int, dto model.UpdateSeoDto) fall.Error {
+	t, ok := seoTables[entity]
+	if !ok {
+		return fall.NewErr(msg.SeoUnknownEntity, fall.STATUS_BAD_REQUEST)
+	}
+
+	var queries []string
+	args := []any{id}
+
+	set := func(column string, value *string) {
+		if value == nil {
+			return
+		}
+		args = append(args, strings.TrimSpace(*value))
+		queries = append(queries, fmt.Sprintf("%s = NULLIF($%d, '')", column, len(args)))
+	}
+
+	set("meta_title", dto.MetaTitle)
+	set("meta_description", dto.MetaDescription)
+	set("canonical", dto.Canonical)
+
+	if len(queries) == 0 {
+		return fall.NewErr(msg.SeoEmptyUpdate, fall.STATUS_BAD_REQUEST)
+	}
+
+	q := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $1;", t.table, strings.Join(queries, ","), t.idColumn)
+
+	tag, err := r.db.Exec(ctx, q, args...)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fall.NewErr(msg.SeoPageNotFound, fall.STATUS_NOT_FOUND)
+	}
+
+	return nil
+}
+
+// GetSitemapUrls lists every category, brands with listed models and listed models with their latest change.
+func (r *SeoRepository) GetSitemapUrls(ctx context.Context) ([]model.SitemapUrl, fall.Error) {
+	q := `
+	(SELECT 1 AS kind, c.category_id AS id, c.slug, c.canonical, c.updated_at FROM category c)
+	UNION ALL
+	(SELECT 2, b.brand_id, b.slug, b.canonical, b.updated_at FROM brand b
+	WHERE EXISTS (SELECT 1 FROM catalog_model cm WHERE cm.brand_id = b.brand_id AND cm.listed))
+	UNION ALL
+	(SELECT 3, pm.product_model_id, pm.slug, COALESCE(pm.canonical, p.canonical), GREATEST(pm.updated_at, p.updated_at)
+	FROM catalog_model cm
+	INNER JOIN product_model pm ON pm.product_model_id = cm.product_model_id
+	INNER JOIN product p ON p.product_id = pm.product_id
+	WHERE cm.listed)
+	ORDER BY kind, id;
+	`
+
+	rows, err := r.db.Query(ctx, q)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	paths := map[int]string{1: model.CategoryPagePath, 2: model.BrandPagePath, 3: model.ProductPagePath}
+
+	urls := []model.SitemapUrl{}
+
+	for rows.Next() {
+		var kind, id int
+		var slug string
+		u := model.SitemapUrl{}
+
+		if err := rows.Scan(&kind, &id, &slug, &u.Canonical, &u.LastMod); err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+
+		u.Path = fmt.Sprintf(paths[kind], slug)
+		urls = append(urls, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return urls, nil
+}
+
+// SaveSitemap replaces the last sitemap.
+func (r *SeoRepository) SaveSitemap(ctx context.Context, g model.SitemapGeneration) fall.Error {
+	q := `INSERT INTO sitemap_generation (version, urls, files, paths, generated_at) VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (sitemap_generation_id) DO UPDATE SET version = EXCLUDED.version, urls = EXCLUDED.urls,
+	files = EXCLUDED.files, paths = EXCLUDED.paths, generated_at = EXCLUDED.generated_at;`
+
+	_, err := r.db.Exec(ctx, q, g.Version, g.Urls, g.Files, g.Paths, g.GeneratedAt)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	return nil
+}
+
+func (r *SeoRepository) GetSitemap(ctx context.Context) (*model.SitemapGeneration, fall.Error) {
+	q := "SELECT version, urls, files, paths, generated_at FROM sitemap_generation;"
+
+	g := model.SitemapGeneration{}
+
+	err := r.db.QueryRow(ctx, q).Scan(&g.Version, &g.Urls, &g.Files, &g.Paths, &g.GeneratedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fall.NewErr(msg.SitemapNotFound, fall.STATUS_NOT_FOUND)
+		}
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return &g, nil
+}
+
+// GetSitemapPath returns the storage path of one file of the last sitemap.
+func (r *SeoRepository) GetSitemapPath(ctx context.Context, name string) (string, fall.Error) {
+	var path *string
+
+	err := r.db.QueryRow(ctx, "SELECT paths ->> $1 FROM sitemap_generation;", name).Scan(&path)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", fall.ServerError(err.Error())
+	}
+	if path == nil {
+		return "", fall.NewErr(msg.SitemapNotFound, fall.STATUS_NOT_FOUND)
+	}
+
+	return *path, nil
+}
+
+// GetVersion changes whenever a sitemap page is added, removed or updated.
+func (r *SeoRepository) GetVersion(ctx context.Context) (string, fall.Error) {
+	q := `
+	SELECT concat_ws('|',
+	(SELECT count(*) || ':' || COALESCE(max(updated_at)::text, '') FROM category),
+	(SELECT count(*) || ':' || COALESCE(max(updated_at)::text, '') FROM brand),
+	(SELECT count(*) || ':' || COALESCE(max(updated_at)::text, '') FROM product),
+	(SELECT count(*) || ':' || COALESCE(max(updated_at)::text, '') FROM product_model),
+	(SELECT count(*) FROM catalog_model WHERE listed));
+	`
+
+	var version string
+	if err := r.db.QueryRow(ctx, q).Scan(&version); err != nil {
+		return "", fall.ServerError(err.Error())
+	}
+
+	return version, nil
+}
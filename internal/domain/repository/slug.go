@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type SlugRepository struct {
+	db db.PostgresClient
+}
+
+func NewSlugRepository(db db.PostgresClient) *SlugRepository {
+	return &SlugRepository{db: db}
+}
+
+var slugTables = map[string]struct {
+	table    string
+	idColumn string
+}{
+	model.SlugBrand:    {"brand", "brand_id"},
+	model.SlugCategory: {"category", "category_id"},
+	model.SlugModel:    {"product_model", "product_model_id"},
+}
+
+// Unique returns base or, when another entity already has it, base with the first free numeric suffix: "nike-2", "nike-3".
+// id is the entity that takes the slug, 0 for a new one.
+func (r *SlugRepository) Unique(ctx context.Context, entity string, base string, id int) (string, fall.Error) {
+	t, ok := slugTables[entity]
+	if !ok {
+		return "", fall.ServerError(msg.SlugUnknownEntity)
+	}
+
+	q := fmt.Sprintf("SELECT slug FROM %s WHERE (slug = $1 OR slug LIKE $2) AND %s <> $3;", t.table, t.idColumn)
+
+	rows, err := r.db.Query(ctx, q, base, likeEscaper.Replace(base)+"-%", id)
+	if err != nil {
+		return "", fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	taken := map[string]bool{}
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return "", fall.ServerError(err.Error())
+		}
+		taken[slug] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", fall.ServerError(err.Error())
+	}
+
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	return slug, nil
+}
+
+// slugTaken tells whether a write lost its slug to a concurrent one that took it after Unique had returned it.
+func slugTaken(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && strings.HasSuffix(pgErr.ConstraintName, "_slug_key")
+}
+
+// FindCurrent returns the slug that the entity which once had the retired slug has now.
+func (r *SlugRepository) FindCurrent(ctx context.Context, entity string, retired string) (string, fall.Error) {
+	t, ok := slugTables[entity]
+	if !ok {
+		return "", fall.ServerError(msg.SlugUnknownEntity)
+	}
+
+	q := fmt.Sprintf(`SELECT t.slug FROM slug_history h INNER JOIN %s t ON t.%s = h.entity_id
+	WHERE h.entity = $1 AND h.slug = $2;`, t.table, t.idColumn)
+
+	var slug string
+	err := r.db.QueryRow(ctx, q, entity, retired).Scan(&slug)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fall.NewErr(msg.SlugHistoryNotFound, fall.STATUS_NOT_FOUND)
+		}
+		return "", fall.ServerError(err.Error())
+	}
+
+	return slug, nil
+}
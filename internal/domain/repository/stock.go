@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
+)
+
+// trackStock names the cause of the stock changes that follow in tx, the ledger trigger on model_sizes
+// records it with each of them. It lasts until the transaction ends or the next call.
+func trackStock(ctx context.Context, tx db.Transaction, source model.StockSource) error {
+	raw, err := json.Marshal(source)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "SELECT set_config('stock.source', $1, true);", string(raw))
+	return err
+}
+
+// StockRepository reads the stock movement ledger and changes stock by hand. Every other change is
+// recorded by the trigger from the source its transaction sets with trackStock.
+type StockRepository struct {
+	db db.PostgresClient
+}
+
+func NewStockRepository(db db.PostgresClient) *StockRepository {
+	return &StockRepository{db: db}
+}
+
+func (r *StockRepository) GetHistory(ctx context.Context, modelSizeId int, page pagination.Page) (*model.StockHistory, fall.Error) {
+	history := model.StockHistory{ModelSizeId: modelSizeId, Movements: []model.StockMovement{}}
+
+	q := `SELECT ms.in_stock, COALESCE(SUM(sm.quantity), 0)::int, COUNT(sm.stock_movement_id)::int FROM model_sizes ms
+	LEFT JOIN stock_movement sm ON sm.model_size_id = ms.model_size_id
+	WHERE ms.model_size_id = $1 GROUP BY ms.model_size_id;`
+
+	err := r.db.QueryRow(ctx, q, modelSizeId).Scan(&history.InStock, &history.LedgerStock, &history.Total)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fall.NewErr(msg.StockModelSizeNotFound, fall.STATUS_NOT_FOUND)
+		}
+		return nil, fall.ServerError(err.Error())
+	}
+
+	args := []any{modelSizeId}
+	where := ""
+
+	if page.Cursor != nil {
+		args = append(args, page.Cursor.Key, page.Cursor.Id)
+		where = "AND " + pagination.After("created_at", "stock_movement_id", true, "$2::timestamp", "$3::bigint")
+	}
+
+	args = append(args, page.Limit+1)
+
+	q = fmt.Sprintf(`SELECT stock_movement_id, created_at, model_size_id, movement_type, quantity, balance, order_id, user_id,
+	import_id, comment FROM stock_movement WHERE model_size_id = $1 %s
+	ORDER BY created_at DESC, stock_movement_id DESC LIMIT $%d;`, where, len(args))
+
+	rows, err := r.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := model.StockMovement{}
+		err := rows.Scan(&m.Id, &m.CreatedAt, &m.ModelSizeId, &m.Type, &m.Quantity, &m.Balance, &m.OrderId, &m.UserId,
+			&m.ImportId, &m.Comment)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		history.Movements = append(history.Movements, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	history.Movements, history.NextCursor = pagination.Trim(history.Movements, page.Limit, model.StockMovementsSort,
+		func(m model.StockMovement) (string, string) {
+			return m.CreatedAt.Format("2006-01-02 15:04:05.000"), strconv.FormatInt(m.Id, 10)
+		})
+
+	return &history, nil
+}
+
+// Adjust changes the stock by quantity, it never takes it below zero. It returns the model of the size.
+func (r *StockRepository) Adjust(ctx context.Context, modelSizeId int, quantity int, source model.StockSource) (int, fall.Error) {
+	var modelId int
+
+	err := r.inTx(ctx, source, func(tx pgx.Tx) fall.Error {
+		q := `UPDATE model_sizes SET in_stock = in_stock + $1 WHERE model_size_id = $2 AND in_stock + $1 >= 0
+		RETURNING product_model_id;`
+
+		err := tx.QueryRow(ctx, q, quantity, modelSizeId).Scan(&modelId)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return fall.ServerError(msg.StockErrorWhenUpdate)
+		}
+
+		var exists bool
+		err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM model_sizes WHERE model_size_id = $1);", modelSizeId).Scan(&exists)
+		if err != nil {
+			return fall.ServerError(err.Error())
+		}
+		if !exists {
+			return fall.NewErr(msg.StockModelSizeNotFound, fall.STATUS_NOT_FOUND)
+		}
+		return fall.NewErr(msg.StockNegative, fall.STATUS_BAD_REQUEST)
+	})
+
+	return modelId, err
+}
+
+// StockTake sets the stock to the counted quantity less the units of orders that aren't on the way yet.
+// A count that matches the stock is recorded too, with a zero quantity.
+func (r *StockRepository) StockTake(ctx context.Context, modelSizeId int, counted int, source model.StockSource) (int, fall.Error) {
+	var modelId int
+
+	err := r.inTx(ctx, source, func(tx pgx.Tx) fall.Error {
+		var current int
+
+		q := "SELECT product_model_id, in_stock FROM model_sizes WHERE model_size_id = $1 FOR UPDATE;"
+		err := tx.QueryRow(ctx, q, modelSizeId).Scan(&modelId, &current)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fall.NewErr(msg.StockModelSizeNotFound, fall.STATUS_NOT_FOUND)
+			}
+			return fall.ServerError(err.Error())
+		}
+
+		q = `SELECT COALESCE(SUM(om.quantity), 0)::int FROM order_model om
+		INNER JOIN public.order o ON o.order_id = om.order_id
+		WHERE om.model_size_id = $1 AND o.order_status IN ($2, $3, $4, $5);`
+
+		var unshipped int
+		err = tx.QueryRow(ctx, q, modelSizeId, model.WaitingForActivation, model.WaitingForPayment, model.Paid,
+			model.InProcessing).Scan(&unshipped)
+		if err != nil {
+			return fall.ServerError(err.Error())
+		}
+
+		stock := max(counted-unshipped, 0)
+
+		if stock == current {
+			q = `INSERT INTO stock_movement (model_size_id, movement_type, quantity, balance, user_id, comment)
+			VALUES ($1, $2, 0, $3, $4, $5);`
+			_, err = tx.Exec(ctx, q, modelSizeId, model.StockTake, current, source.UserId, source.Comment)
+		} else {
+			_, err = tx.Exec(ctx, "UPDATE model_sizes SET in_stock = $1 WHERE model_size_id = $2;", stock, modelSizeId)
+		}
+		if err != nil {
+			return fall.ServerError(msg.StockErrorWhenUpdate)
+		}
+		return nil
+	})
+
+	return modelId, err
+}
+
+// Reconcile finds model sizes whose stock differs from the sum of their ledger. Unless it is a dry run
+// the stock is taken as it is and a stocktake entry for the difference brings the ledger in line.
+func (r *StockRepository) Reconcile(ctx context.Context, dryRun bool, userId int) (*model.StockReconcileReport, fall.Error) {
+	report := &model.StockReconcileReport{DryRun: dryRun, Discrepancies: []model.StockDiscrepancy{}}
+
+	q := `SELECT ms.model_size_id, ms.in_stock, COALESCE(SUM(sm.quantity), 0)::int FROM model_sizes ms
+	LEFT JOIN stock_movement sm ON sm.model_size_id = ms.model_size_id
+	GROUP BY ms.model_size_id HAVING ms.in_stock <> COALESCE(SUM(sm.quantity), 0)
+	ORDER BY ms.model_size_id;`
+
+	rows, err := r.db.Query(ctx, q)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d := model.StockDiscrepancy{}
+		if err := rows.Scan(&d.ModelSizeId, &d.InStock, &d.LedgerStock); err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		report.Discrepancies = append(report.Discrepancies, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	rows.Close()
+
+	if dryRun {
+		return report, nil
+	}
+
+	comment := "reconciliation"
+	source := model.StockSource{Type: model.StockTake, UserId: &userId, Comment: &comment}
+
+	for _, d := range report.Discrepancies {
+		ex := r.inTx(ctx, source, func(tx pgx.Tx) fall.Error {
+			// read again under the lock, the stock may have moved since the report was built
+			q := `SELECT ms.in_stock, (SELECT COALESCE(SUM(quantity), 0)::int FROM stock_movement WHERE model_size_id = ms.model_size_id)
+			FROM model_sizes ms WHERE ms.model_size_id = $1 FOR UPDATE;`
+
+			var inStock, ledger int
+			err := tx.QueryRow(ctx, q, d.ModelSizeId).Scan(&inStock, &ledger)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return nil
+				}
+				return fall.ServerError(msg.StockErrorWhenReconcile)
+			}
+			if inStock == ledger {
+				return nil
+			}
+
+			q = `INSERT INTO stock_movement (model_size_id, movement_type, quantity, balance, user_id, comment)
+			VALUES ($1, $2, $3, $4, $5, $6);`
+			_, err = tx.Exec(ctx, q, d.ModelSizeId, model.StockTake, inStock-ledger, inStock, userId, comment)
+			if err != nil {
+				return fall.ServerError(msg.StockErrorWhenReconcile)
+			}
+			report.Fixed++
+			return nil
+		})
+		if ex != nil {
+			return nil, ex
+		}
+	}
+
+	return report, nil
+}
+
+func (r *StockRepository) inTx(ctx context.Context, source model.StockSource, fn func(tx pgx.Tx) fall.Error) fall.Error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+	defer tx.Rollback(ctx)
+
+	if err := trackStock(ctx, tx, source); err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	if ex := fn(tx); ex != nil {
+		return ex
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fall.ServerError(err.Error())
+	}
+	return nil
+}
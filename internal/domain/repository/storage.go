@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type StorageRepository struct {
+	db db.PostgresClient
+}
+
+func NewStorageRepository(db db.PostgresClient) *StorageRepository {
+	return &StorageRepository{db: db}
+}
+
+// GetReferences returns every file path a record holds, a new column with a path has to be added here
+// or the sweeper deletes its files.
+func (r *StorageRepository) GetReferences(ctx context.Context) ([]string, fall.Error) {
+	q := `
+	SELECT avatar_path FROM public.user WHERE avatar_path IS NOT NULL
+	UNION SELECT img_path FROM category WHERE img_path IS NOT NULL
+	UNION SELECT img_path FROM brand WHERE img_path IS NOT NULL
+	UNION SELECT img_path FROM public.action WHERE img_path IS NOT NULL
+	UNION SELECT main_image_path FROM product_model
+	UNION SELECT img_path FROM product_model_img
+	UNION SELECT path FROM feed_generation WHERE path IS NOT NULL;
+	`
+
+	rows, err := r.db.Query(ctx, q)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	paths := []string{}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		paths = append(paths, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type UploadSessionRepository struct {
+	db db.PostgresClient
+}
+
+func NewUploadSessionRepository(db db.PostgresClient) *UploadSessionRepository {
+	return &UploadSessionRepository{db: db}
+}
+
+const uploadSessionColumns = `upload_session_id, created_at, expires_at, confirmed_at, user_id, purpose, target_id, object_key,
+upload_status, path`
+
+func (r *UploadSessionRepository) Create(ctx context.Context, session model.UploadSession, ttl time.Duration) (*model.UploadSession, fall.Error) {
+	q := `INSERT INTO upload_session (upload_session_id, expires_at, user_id, purpose, target_id, object_key)
+	VALUES ($1, CURRENT_TIMESTAMP + make_interval(secs => $2), $3, $4, $5, $6) RETURNING ` + uploadSessionColumns + `;`
+
+	created, err := scanUploadSession(r.db.QueryRow(ctx, q, session.Id, ttl.Seconds(), session.UserId, session.Purpose,
+		session.TargetId, session.Key))
+	if err != nil {
+		return nil, fall.ServerError(msg.UploadSessionErrorWhenCreate)
+	}
+	return created, nil
+}
+
+func (r *UploadSessionRepository) FindById(ctx context.Context, id string) (*model.UploadSession, fall.Error) {
+	q := `SELECT ` + uploadSessionColumns + ` FROM upload_session WHERE upload_session_id = $1;`
+
+	session, err := scanUploadSession(r.db.QueryRow(ctx, q, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fall.NewErr(msg.UploadSessionNotFound, fall.STATUS_NOT_FOUND)
+		}
+		return nil, fall.ServerError(err.Error())
+	}
+	return session, nil
+}
+
+// Confirm closes a pending, unexpired session with the stored path. It reports false when another
+// request has closed the session first or it has expired meanwhile.
+func (r *UploadSessionRepository) Confirm(ctx context.Context, id string, path string) (bool, fall.Error) {
+	q := `UPDATE upload_session SET upload_status = $1, path = $2, confirmed_at = CURRENT_TIMESTAMP
+	WHERE upload_session_id = $3 AND upload_status = $4 AND expires_at > CURRENT_TIMESTAMP;`
+
+	tag, err := r.db.Exec(ctx, q, model.UploadConfirmed, path, id, model.UploadPending)
+	if err != nil {
+		return false, fall.ServerError(msg.UploadSessionErrorWhenUpdate)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Reopen undoes Confirm when the file could not be attached, so the upload can be confirmed again.
+func (r *UploadSessionRepository) Reopen(ctx context.Context, id string) fall.Error {
+	q := `UPDATE upload_session SET upload_status = $1, path = NULL, confirmed_at = NULL
+	WHERE upload_session_id = $2 AND upload_status = $3;`
+
+	_, err := r.db.Exec(ctx, q, model.UploadPending, id, model.UploadConfirmed)
+	if err != nil {
+		return fall.ServerError(msg.UploadSessionErrorWhenUpdate)
+	}
+	return nil
+}
+
+func (r *UploadSessionRepository) Reject(ctx context.Context, id string) fall.Error {
+	q := `UPDATE upload_session SET upload_status = $1 WHERE upload_session_id = $2 AND upload_status = $3;`
+
+	_, err := r.db.Exec(ctx, q, model.UploadRejected, id, model.UploadPending)
+	if err != nil {
+		return fall.ServerError(msg.UploadSessionErrorWhenUpdate)
+	}
+	return nil
+}
+
+// GetExpired returns sessions that were never confirmed and can no longer be.
+func (r *UploadSessionRepository) GetExpired(ctx context.Context, limit int) ([]model.UploadSession, fall.Error) {
+	q := `SELECT ` + uploadSessionColumns + ` FROM upload_session
+	WHERE upload_status <> $1 AND expires_at < CURRENT_TIMESTAMP ORDER BY expires_at LIMIT $2;`
+
+	rows, err := r.db.Query(ctx, q, model.UploadConfirmed, limit)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+	defer rows.Close()
+
+	sessions := []model.UploadSession{}
+	for rows.Next() {
+		session, err := scanUploadSession(rows)
+		if err != nil {
+			return nil, fall.ServerError(err.Error())
+		}
+		sessions = append(sessions, *session)
+	}
+
+	if rows.Err() != nil {
+		return nil, fall.ServerError(rows.Err().Error())
+	}
+
+	return sessions, nil
+}
+
+func (r *UploadSessionRepository) Delete(ctx context.Context, id string) fall.Error {
+	q := `DELETE FROM upload_session WHERE upload_session_id = $1 AND upload_status <> $2;`
+
+	_, err := r.db.Exec(ctx, q, id, model.UploadConfirmed)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+	return nil
+}
+
+func scanUploadSession(row pgx.Row) (*model.UploadSession, error) {
+	session := model.UploadSession{}
+	err := row.Scan(&session.Id, &session.CreatedAt, &session.ExpiresAt, &session.ConfirmedAt, &session.UserId, &session.Purpose,
+		&session.TargetId, &session.Key, &session.Status, &session.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
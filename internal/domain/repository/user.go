@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
@@ -10,6 +11,7 @@ import (
 	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/keys"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 )
 
 type userRoleRepository interface {
@@ -273,35 +275,63 @@ func (r *UserRepository) ChangePassword(ctx context.Context, userId int, newPass
 	return nil
 }
 
-func (r *UserRepository) GetAll(ctx context.Context, page int) (*model.GetAllUsersResponse, fall.Error) {
-	limit := 16
+func (r *UserRepository) GetAll(ctx context.Context, page pagination.Page) (*model.GetAllUsersResponse, fall.Error) {
+	var args []any
+	where := ""
 
-	offset := page*limit - limit
+	if page.Cursor != nil {
+		args = append(args, page.Cursor.Key, page.Cursor.Id)
+		where = "WHERE " + pagination.After("created_at", "user_id", false, "$1::timestamp", "$2::int")
+	}
+
+	args = append(args, page.Limit+1)
+	limit := len(args)
 
-	q := "SELECT user_id, (select COUNT(*) from public.user) as total FROM public.user ORDER BY public.user.created_at LIMIT $1 OFFSET $2;"
+	offset := ""
+	if page.Offset > 0 {
+		args = append(args, page.Offset)
+		offset = fmt.Sprintf(" OFFSET $%d", len(args))
+	}
 
-	rows, err := r.db.Query(ctx, q, limit, offset)
+	q := fmt.Sprintf(`SELECT user_id, created_at::text, (select COUNT(*) from public.user) as total FROM public.user
+	%s ORDER BY created_at, user_id LIMIT $%d%s;`, where, limit, offset)
+
+	rows, err := r.db.Query(ctx, q, args...)
 
 	if err != nil {
 		return nil, fall.ServerError(err.Error())
 	}
 	defer rows.Close()
 
-	var userIds []int
+	type userRow struct {
+		id        int
+		createdAt string
+	}
+
+	var usersPage []userRow
 	var total int
 
 	for rows.Next() {
-		var id int
-		err := rows.Scan(&id, &total)
+		row := userRow{}
+		err := rows.Scan(&row.id, &row.createdAt, &total)
 		if err != nil {
 			return nil, fall.ServerError(err.Error())
 		}
-		userIds = append(userIds, id)
+		usersPage = append(usersPage, row)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fall.ServerError(err.Error())
 	}
 
+	usersPage, nextCursor := pagination.Trim(usersPage, page.Limit, model.UsersSort, func(row userRow) (string, string) {
+		return row.createdAt, strconv.Itoa(row.id)
+	})
+
+	userIds := make([]int, 0, len(usersPage))
+	for _, row := range usersPage {
+		userIds = append(userIds, row.id)
+	}
+
 	q = `SELECT public.user.user_id, public.user.email, public.user.password_hash,
 	public.user.patronymic, public.user.first_name,	public.user.last_name, 
 	role.title, role.role_id, public.user.is_activated, public.user.gender, public.user.avatar_path, user_role.user_id as role_user_id,
@@ -364,8 +394,9 @@ func (r *UserRepository) GetAll(ctx context.Context, page int) (*model.GetAllUse
 	}
 
 	return &model.GetAllUsersResponse{
-		Users: result,
-		Total: total,
+		Users:      result,
+		Total:      total,
+		NextCursor: nextCursor,
 	}, nil
 
 }
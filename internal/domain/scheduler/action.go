@@ -5,16 +5,18 @@ import (
 	"log"
 
 	"github.com/go-co-op/gocron"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/cache"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
 )
 
 type ActionScheduler struct {
-	cron *gocron.Scheduler
-	db   db.PostgresClient
+	cron  *gocron.Scheduler
+	db    db.PostgresClient
+	cache cache.Cache
 }
 
-func NewActionScheduler(cron *gocron.Scheduler, db db.PostgresClient) *ActionScheduler {
-	return &ActionScheduler{cron: cron, db: db}
+func NewActionScheduler(cron *gocron.Scheduler, db db.PostgresClient, cache cache.Cache) *ActionScheduler {
+	return &ActionScheduler{cron: cron, db: db, cache: cache}
 }
 
 func (s *ActionScheduler) Start() {
@@ -87,8 +89,12 @@ func (s *ActionScheduler) discountCancellation(ctx context.Context) {
 			defer func() {
 				if txErr != nil {
 					tx.Rollback(ctx)
-				} else {
-					tx.Commit(ctx)
+				} else if tx.Commit(ctx) == nil {
+					tags := []string{cache.TagActions}
+					for _, id := range modelIds {
+						tags = append(tags, cache.ModelTag(id))
+					}
+					s.cache.Invalidate(ctx, tags...)
 				}
 			}()
 
@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+
+	"github.com/go-co-op/gocron"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type feedGenerator interface {
+	Generate(ctx context.Context) ([]model.FeedGeneration, fall.Error)
+}
+
+type FeedScheduler struct {
+	cron      *gocron.Scheduler
+	generator feedGenerator
+	interval  int
+}
+
+// NewFeedScheduler regenerates the feeds every interval minutes.
+func NewFeedScheduler(cron *gocron.Scheduler, generator feedGenerator, interval int) *FeedScheduler {
+	return &FeedScheduler{cron: cron, generator: generator, interval: interval}
+}
+
+func (s *FeedScheduler) Start() {
+
+	ctx := context.Background()
+
+	go s.generate(ctx)
+}
+
+func (s *FeedScheduler) generate(ctx context.Context) {
+	s.cron.Every(s.interval).Minutes().SingletonMode().Do(func() {
+		_, ex := s.generator.Generate(ctx)
+		if ex != nil {
+			log.Println(ex.Message())
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+
+	"github.com/go-co-op/gocron"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+const imageBackfillBatch = 50
+
+type imageBackfiller interface {
+	Backfill(ctx context.Context, batch int) (*model.ImageBackfillResult, fall.Error)
+}
+
+type ImageScheduler struct {
+	cron       *gocron.Scheduler
+	backfiller imageBackfiller
+}
+
+// NewImageScheduler converts stored originals to renditions a batch at a time, once they are all done a run is a single query.
+func NewImageScheduler(cron *gocron.Scheduler, backfiller imageBackfiller) *ImageScheduler {
+	return &ImageScheduler{cron: cron, backfiller: backfiller}
+}
+
+func (s *ImageScheduler) Start() {
+
+	ctx := context.Background()
+
+	go s.backfill(ctx)
+}
+
+func (s *ImageScheduler) backfill(ctx context.Context) {
+	s.cron.Every(1).Minutes().SingletonMode().Do(func() {
+		res, ex := s.backfiller.Backfill(ctx, imageBackfillBatch)
+		if ex != nil {
+			if ex.Status() != fall.STATUS_CONFLICT {
+				log.Println(ex.Message())
+			}
+			return
+		}
+		if res.Processed > 0 || res.Failed > 0 {
+			log.Printf("image backfill: %d processed, %d failed, %d remaining", res.Processed, res.Failed, res.Remaining)
+		}
+	})
+}
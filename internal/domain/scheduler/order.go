@@ -3,23 +3,28 @@ package scheduler
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/go-co-op/gocron"
-	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
-	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
-	"github.com/maximfedotov74/diploma-backend/internal/shared/payment"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 )
 
+const (
+	paymentBatchSize = 100
+	paymentCheckAge  = 15 * time.Minute
+)
+
+type paymentChecker interface {
+	CheckPendingPayments(ctx context.Context, olderThan time.Duration, limit int) (int, fall.Error)
+}
+
 type OrderScheduler struct {
 	cron    *gocron.Scheduler
-	db      db.PostgresClient
-	payment *payment.PaymentService
+	checker paymentChecker
 }
 
-func NewOrderScheduler(cron *gocron.Scheduler, db db.PostgresClient,
-	payment *payment.PaymentService,
-) *OrderScheduler {
-	return &OrderScheduler{cron: cron, db: db, payment: payment}
+func NewOrderScheduler(cron *gocron.Scheduler, checker paymentChecker) *OrderScheduler {
+	return &OrderScheduler{cron: cron, checker: checker}
 }
 
 func (s *OrderScheduler) Start() {
@@ -30,53 +35,16 @@ func (s *OrderScheduler) Start() {
 
 }
 
-type OP struct {
-	OrderId   string
-	PaymentId string
-}
-
+// CheckOrderPayment is a fallback for lost webhook notifications,
+// it only picks up orders that have been waiting for payment for a while.
 func (s *OrderScheduler) CheckOrderPayment(ctx context.Context) {
 
-	s.cron.Every(3).Minute().Do(func() {
-		q := "SELECT order_id, payment_id FROM public.order WHERE order_payment_method = $1 AND order_status = $2 AND payment_id IS NOT NULL;"
-
-		rows, err := s.db.Query(ctx, q, model.Online, model.WaitingForPayment)
-
-		if err != nil {
-			log.Println(err.Error())
+	s.cron.Every(15).Minute().SingletonMode().Do(func() {
+		resolved, ex := s.checker.CheckPendingPayments(ctx, paymentCheckAge, paymentBatchSize)
+		if ex != nil {
+			log.Println(ex.Message())
 			return
 		}
-		defer rows.Close()
-
-		var ops []OP
-
-		for rows.Next() {
-			var orderId string
-			var payemntId string
-			err := rows.Scan(&orderId, &payemntId)
-
-			if err != nil {
-				log.Println(err.Error())
-				return
-			}
-			ops = append(ops, OP{OrderId: orderId, PaymentId: payemntId})
-		}
-
-		for _, item := range ops {
-			p, err := s.payment.CheckPayment(item.PaymentId)
-			if err != nil {
-				log.Println(err.Error())
-				continue
-			}
-			if p.Status == "succeeded" {
-				q := "UPDATE public.order SET order_status = $1 WHERE order_id = $2;"
-				_, err := s.db.Exec(ctx, q, model.Paid, item.OrderId)
-				if err != nil {
-					log.Println(err.Error())
-					continue
-				}
-			}
-		}
-		log.Println("Order payment checker successfully completed!")
+		log.Printf("Order payment reconciliation successfully completed, %d payments resolved!", resolved)
 	})
 }
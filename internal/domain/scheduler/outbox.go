@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+
+	"github.com/go-co-op/gocron"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+const outboxBatchSize = 50
+
+type outboxDispatcher interface {
+	Dispatch(ctx context.Context, limit int) (int, fall.Error)
+}
+
+type OutboxScheduler struct {
+	cron       *gocron.Scheduler
+	dispatcher outboxDispatcher
+}
+
+func NewOutboxScheduler(cron *gocron.Scheduler, dispatcher outboxDispatcher) *OutboxScheduler {
+	return &OutboxScheduler{cron: cron, dispatcher: dispatcher}
+}
+
+func (s *OutboxScheduler) Start() {
+
+	ctx := context.Background()
+
+	go s.dispatch(ctx)
+}
+
+func (s *OutboxScheduler) dispatch(ctx context.Context) {
+	s.cron.Every(1).Minute().SingletonMode().Do(func() {
+		for {
+			done, ex := s.dispatcher.Dispatch(ctx, outboxBatchSize)
+			if ex != nil {
+				log.Println(ex.Message())
+				return
+			}
+			if done < outboxBatchSize {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+
+	"github.com/go-co-op/gocron"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+const reservationBatchSize = 50
+
+type reservationCanceler interface {
+	CancelExpiredOrders(ctx context.Context, limit int) (int, fall.Error)
+}
+
+type ReservationScheduler struct {
+	cron     *gocron.Scheduler
+	canceler reservationCanceler
+}
+
+func NewReservationScheduler(cron *gocron.Scheduler, canceler reservationCanceler) *ReservationScheduler {
+	return &ReservationScheduler{cron: cron, canceler: canceler}
+}
+
+func (s *ReservationScheduler) Start() {
+
+	ctx := context.Background()
+
+	go s.releaseExpired(ctx)
+}
+
+func (s *ReservationScheduler) releaseExpired(ctx context.Context) {
+	s.cron.Every(1).Minute().SingletonMode().Do(func() {
+		canceled, ex := s.canceler.CancelExpiredOrders(ctx, reservationBatchSize)
+		if ex != nil {
+			log.Println(ex.Message())
+			return
+		}
+		if canceled > 0 {
+			log.Printf("canceled %d orders with expired reservations", canceled)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+
+	"github.com/go-co-op/gocron"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type sitemapRefresher interface {
+	Refresh(ctx context.Context) fall.Error
+}
+
+type SitemapScheduler struct {
+	cron      *gocron.Scheduler
+	refresher sitemapRefresher
+	interval  int
+}
+
+// NewSitemapScheduler checks the catalog every interval minutes and rewrites the sitemap when it changed.
+func NewSitemapScheduler(cron *gocron.Scheduler, refresher sitemapRefresher, interval int) *SitemapScheduler {
+	return &SitemapScheduler{cron: cron, refresher: refresher, interval: interval}
+}
+
+func (s *SitemapScheduler) Start() {
+
+	ctx := context.Background()
+
+	go s.refresh(ctx)
+}
+
+func (s *SitemapScheduler) refresh(ctx context.Context) {
+	s.cron.Every(s.interval).Minutes().SingletonMode().Do(func() {
+		ex := s.refresher.Refresh(ctx)
+		if ex != nil && ex.Status() != fall.STATUS_CONFLICT {
+			log.Println(ex.Message())
+		}
+	})
+}
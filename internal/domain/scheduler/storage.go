@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+
+	"github.com/go-co-op/gocron"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type storageSweeper interface {
+	Sweep(ctx context.Context, dryRun bool) (*model.SweepReport, fall.Error)
+}
+
+type StorageScheduler struct {
+	cron     *gocron.Scheduler
+	sweeper  storageSweeper
+	interval int
+}
+
+// NewStorageScheduler deletes orphaned files every interval minutes.
+func NewStorageScheduler(cron *gocron.Scheduler, sweeper storageSweeper, interval int) *StorageScheduler {
+	return &StorageScheduler{cron: cron, sweeper: sweeper, interval: interval}
+}
+
+func (s *StorageScheduler) Start() {
+
+	ctx := context.Background()
+
+	go s.sweep(ctx)
+}
+
+func (s *StorageScheduler) sweep(ctx context.Context) {
+	s.cron.Every(s.interval).Minutes().SingletonMode().WaitForSchedule().Do(func() {
+		report, ex := s.sweeper.Sweep(ctx, false)
+		if ex != nil {
+			log.Println(ex.Message())
+			return
+		}
+		if report.Deleted > 0 {
+			log.Printf("storage sweep: %d of %d files deleted, %d bytes", report.Deleted, report.Scanned, report.Bytes)
+		}
+	})
+}
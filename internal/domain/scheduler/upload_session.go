@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+
+	"github.com/go-co-op/gocron"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+const uploadSessionBatchSize = 100
+
+type uploadSessionRemover interface {
+	RemoveExpired(ctx context.Context, limit int) (int, fall.Error)
+}
+
+type UploadSessionScheduler struct {
+	cron    *gocron.Scheduler
+	remover uploadSessionRemover
+}
+
+func NewUploadSessionScheduler(cron *gocron.Scheduler, remover uploadSessionRemover) *UploadSessionScheduler {
+	return &UploadSessionScheduler{cron: cron, remover: remover}
+}
+
+func (s *UploadSessionScheduler) Start() {
+
+	ctx := context.Background()
+
+	go s.removeExpired(ctx)
+}
+
+func (s *UploadSessionScheduler) removeExpired(ctx context.Context) {
+	s.cron.Every(1).Minute().SingletonMode().Do(func() {
+		removed, ex := s.remover.RemoveExpired(ctx, uploadSessionBatchSize)
+		if ex != nil {
+			log.Println(ex.Message())
+			return
+		}
+		if removed > 0 {
+			log.Printf("removed %d expired upload sessions", removed)
+		}
+	})
+}
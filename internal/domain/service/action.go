@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/cache"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 )
 
@@ -26,10 +27,18 @@ type actionProductService interface {
 type ActionService struct {
 	repo           actionRepository
 	productService actionProductService
+	cache          cache.Cache
 }
 
-func NewActionService(repo actionRepository, productService actionProductService) *ActionService {
-	return &ActionService{repo: repo, productService: productService}
+func NewActionService(repo actionRepository, productService actionProductService, cache cache.Cache) *ActionService {
+	return &ActionService{repo: repo, productService: productService, cache: cache}
+}
+
+func (s *ActionService) invalidate(ctx context.Context, ex fall.Error) fall.Error {
+	if ex == nil {
+		s.cache.Invalidate(ctx, cache.TagActions)
+	}
+	return ex
 }
 
 func (s *ActionService) FindById(ctx context.Context, id string) (*model.Action, fall.Error) {
@@ -37,16 +46,19 @@ func (s *ActionService) FindById(ctx context.Context, id string) (*model.Action,
 }
 
 func (s *ActionService) GetActionsByGender(ctx context.Context, gender model.ActionGender) ([]model.Action, fall.Error) {
-	return s.repo.GetActionsByGender(ctx, gender)
+	return cache.Load(ctx, s.cache, "actions:gender:"+string(gender), func() ([]model.Action, []string, fall.Error) {
+		actions, ex := s.repo.GetActionsByGender(ctx, gender)
+		return actions, []string{cache.TagActions}, ex
+	})
 }
 
 func (s *ActionService) DeleteAction(ctx context.Context, id string) fall.Error {
-	return s.repo.DeleteAction(ctx, id)
+	return s.invalidate(ctx, s.repo.DeleteAction(ctx, id))
 
 }
 
 func (s *ActionService) DeleteActionModel(ctx context.Context, actionModelId int) fall.Error {
-	return s.repo.DeleteActionModel(ctx, actionModelId)
+	return s.invalidate(ctx, s.repo.DeleteActionModel(ctx, actionModelId))
 }
 
 func (s *ActionService) GetModels(ctx context.Context, id string) ([]model.ActionModel, fall.Error) {
@@ -54,7 +66,7 @@ func (s *ActionService) GetModels(ctx context.Context, id string) ([]model.Actio
 }
 
 func (s *ActionService) Create(ctx context.Context, dto model.CreateActionDto) fall.Error {
-	return s.repo.Create(ctx, dto)
+	return s.invalidate(ctx, s.repo.Create(ctx, dto))
 }
 
 func (s *ActionService) AddModel(ctx context.Context, dto model.AddModelToActionDto) fall.Error {
@@ -66,11 +78,14 @@ func (s *ActionService) AddModel(ctx context.Context, dto model.AddModelToAction
 	if ex != nil {
 		return ex
 	}
-	return s.repo.AddModel(ctx, action.Id, model.Id)
+	return s.invalidate(ctx, s.repo.AddModel(ctx, action.Id, model.Id))
 }
 
 func (s *ActionService) GetAll(ctx context.Context) ([]model.Action, fall.Error) {
-	return s.repo.GetAll(ctx)
+	return cache.Load(ctx, s.cache, "actions:all", func() ([]model.Action, []string, fall.Error) {
+		actions, ex := s.repo.GetAll(ctx)
+		return actions, []string{cache.TagActions}, ex
+	})
 }
 
 func (s *ActionService) Update(ctx context.Context, dto model.UpdateActionDto, id string) fall.Error {
@@ -79,6 +94,6 @@ func (s *ActionService) Update(ctx context.Context, dto model.UpdateActionDto, i
 		return ex
 	}
 
-	return s.repo.Update(ctx, dto, id)
+	return s.invalidate(ctx, s.repo.Update(ctx, dto, id))
 
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/cache"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
 )
@@ -19,15 +20,20 @@ type brandRepository interface {
 }
 
 type BrandService struct {
-	repo brandRepository
+	repo  brandRepository
+	slugs slugRepository
+	cache cache.Cache
 }
 
-func NewBrandService(repo brandRepository) *BrandService {
-	return &BrandService{repo: repo}
+func NewBrandService(repo brandRepository, slugs slugRepository, cache cache.Cache) *BrandService {
+	return &BrandService{repo: repo, slugs: slugs, cache: cache}
 }
 
 func (s *BrandService) GetBrandsByGender(ctx context.Context, slug string) ([]model.Brand, fall.Error) {
-	return s.repo.GetBrandsByGender(ctx, slug)
+	return cache.Load(ctx, s.cache, "brands:gender:"+slug, func() ([]model.Brand, []string, fall.Error) {
+		brands, ex := s.repo.GetBrandsByGender(ctx, slug)
+		return brands, []string{cache.TagBrands, cache.CategoryTag(slug)}, ex
+	})
 }
 
 func (s *BrandService) Create(ctx context.Context, dto model.CreateBrandDto) fall.Error {
@@ -37,9 +43,12 @@ func (s *BrandService) Create(ctx context.Context, dto model.CreateBrandDto) fal
 		return fall.NewErr(msg.BrandExists, fall.STATUS_BAD_REQUEST)
 	}
 
-	slug := utils.GenerateSlug(dto.Title)
-
-	err := s.repo.CreateBrand(ctx, dto, slug)
+	err := withUniqueSlug(ctx, s.slugs, model.SlugBrand, utils.GenerateSlug(dto.Title), 0, func(slug string) fall.Error {
+		return s.repo.CreateBrand(ctx, dto, slug)
+	})
+	if err == nil {
+		s.cache.Invalidate(ctx, cache.TagBrands)
+	}
 
 	return err
 
@@ -52,26 +61,29 @@ func (s *BrandService) Update(ctx context.Context, dto model.UpdateBrandDto, id
 		return err
 	}
 
-	var slug *string
-
-	if dto.Title != nil {
-		if current.Title != *dto.Title {
-			b, _ := s.FindByTitle(ctx, *dto.Title)
-			if b != nil {
-				return fall.NewErr(msg.BrandExists, fall.STATUS_BAD_REQUEST)
-			}
-			newSlug := utils.GenerateSlug(*dto.Title)
-			slug = &newSlug
+	if dto.Title != nil && current.Title != *dto.Title {
+		b, _ := s.FindByTitle(ctx, *dto.Title)
+		if b != nil {
+			return fall.NewErr(msg.BrandExists, fall.STATUS_BAD_REQUEST)
 		}
-
+		err = withUniqueSlug(ctx, s.slugs, model.SlugBrand, utils.GenerateSlug(*dto.Title), id, func(slug string) fall.Error {
+			return s.repo.UpdateBrand(ctx, dto, &slug, id)
+		})
+	} else {
+		err = s.repo.UpdateBrand(ctx, dto, nil, id)
 	}
 
-	err = s.repo.UpdateBrand(ctx, dto, slug, id)
+	if err == nil {
+		s.cache.Invalidate(ctx, cache.TagBrands, cache.BrandTag(id))
+	}
 	return err
 }
 
 func (s *BrandService) GetAll(ctx context.Context) ([]model.Brand, fall.Error) {
-	return s.repo.GetAll(ctx)
+	return cache.Load(ctx, s.cache, "brands:all", func() ([]model.Brand, []string, fall.Error) {
+		brands, ex := s.repo.GetAll(ctx)
+		return brands, []string{cache.TagBrands}, ex
+	})
 }
 
 func (s *BrandService) FindByTitle(ctx context.Context, title string) (*model.Brand, fall.Error) {
@@ -81,10 +93,25 @@ func (s *BrandService) FindByTitle(ctx context.Context, title string) (*model.Br
 func (s *BrandService) FindBySlug(ctx context.Context, slug string) (*model.Brand, fall.Error) {
 	return s.repo.FindByFeild(ctx, "slug", slug)
 }
+
+// FindRedirect finds where a retired brand slug has moved.
+func (s *BrandService) FindRedirect(ctx context.Context, slug string) (*model.SlugRedirect, fall.Error) {
+	return findSlugRedirect(ctx, s.slugs, model.SlugBrand, slug, model.BrandPagePath)
+}
+
 func (s *BrandService) FindById(ctx context.Context, id int) (*model.Brand, fall.Error) {
 	return s.repo.FindByFeild(ctx, "brand_id", id)
 }
 
 func (s *BrandService) Delete(ctx context.Context, slug string) fall.Error {
-	return s.repo.Delete(ctx, slug)
+	tags := []string{cache.TagBrands}
+	if b, _ := s.FindBySlug(ctx, slug); b != nil {
+		tags = append(tags, cache.BrandTag(b.Id))
+	}
+
+	ex := s.repo.Delete(ctx, slug)
+	if ex == nil {
+		s.cache.Invalidate(ctx, tags...)
+	}
+	return ex
 }
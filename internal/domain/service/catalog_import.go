@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/cache"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/table"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
+)
+
+const catalogImportMaxRows = 10000
+
+var catalogRequiredColumns = []string{"product", "brand", "category", "price", "main_image"}
+
+type catalogImportRepository interface {
+	Import(ctx context.Context, rows []model.CatalogRow, batchSize int, dryRun bool) (*model.CatalogImportReport, fall.Error)
+	Export(ctx context.Context) ([]model.CatalogRow, fall.Error)
+}
+
+type CatalogImportService struct {
+	repo  catalogImportRepository
+	cache cache.Cache
+}
+
+func NewCatalogImportService(repo catalogImportRepository, cache cache.Cache) *CatalogImportService {
+	return &CatalogImportService{repo: repo, cache: cache}
+}
+
+// Import validates the whole file first, a file with invalid rows is reported without touching the database.
+// A dry run then writes the rows in a transaction that is rolled back, so database errors are reported too.
+func (s *CatalogImportService) Import(ctx context.Context, data []byte, format string, dryRun bool) (*model.CatalogImportReport, fall.Error) {
+	if format == "" {
+		return nil, fall.NewErr(msg.CatalogImportUnknownFormat, fall.STATUS_BAD_REQUEST)
+	}
+
+	records, err := table.Read(data, format)
+	if err != nil {
+		if errors.Is(err, table.ErrTooLarge) {
+			return nil, fall.NewErr(msg.CatalogImportTooManyRows, fall.STATUS_BAD_REQUEST)
+		}
+		return nil, fall.NewErr(msg.CatalogImportBadFile, fall.STATUS_BAD_REQUEST)
+	}
+
+	records = trimEmptyRecords(records)
+	if len(records) < 2 {
+		return nil, fall.NewErr(msg.CatalogImportEmptyFile, fall.STATUS_BAD_REQUEST)
+	}
+	if len(records)-1 > catalogImportMaxRows {
+		return nil, fall.NewErr(msg.CatalogImportTooManyRows, fall.STATUS_BAD_REQUEST)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range catalogRequiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fall.NewErr(fmt.Sprintf("%s: %s", msg.CatalogImportMissingColumn, name), fall.STATUS_BAD_REQUEST)
+		}
+	}
+
+	report := &model.CatalogImportReport{DryRun: dryRun, Rows: len(records) - 1, Errors: []model.CatalogImportError{}}
+	rows := make([]model.CatalogRow, 0, len(records)-1)
+	articles := make(map[string]int)
+
+	for i, record := range records[1:] {
+		line := i + 2
+		get := func(column string) string {
+			idx, ok := columns[column]
+			if !ok {
+				return ""
+			}
+			return strings.TrimSpace(record[idx])
+		}
+
+		row, rowErrors := parseCatalogRow(line, get)
+		if row.Article != "" {
+			key := strings.ToLower(row.Article)
+			if first, ok := articles[key]; ok {
+				rowErrors = append(rowErrors, model.CatalogImportError{Row: line, Column: "article",
+					Message: fmt.Sprintf("%s (%d)", msg.CatalogImportDuplicate, first)})
+			} else {
+				articles[key] = line
+			}
+		}
+
+		report.Errors = append(report.Errors, rowErrors...)
+		rows = append(rows, row)
+	}
+
+	if len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	report, ex := s.repo.Import(ctx, rows, model.CatalogImportBatchSize, dryRun)
+	if ex != nil {
+		return nil, ex
+	}
+
+	if !dryRun && report.Imported > 0 {
+		s.cache.Invalidate(ctx, cache.TagCatalog, cache.TagBrands)
+	}
+
+	return report, nil
+}
+
+// Export writes every model in the import format.
+func (s *CatalogImportService) Export(ctx context.Context, w io.Writer, format string) fall.Error {
+	if format != table.CSV && format != table.XLSX {
+		return fall.NewErr(msg.CatalogImportUnknownFormat, fall.STATUS_BAD_REQUEST)
+	}
+
+	rows, ex := s.repo.Export(ctx)
+	if ex != nil {
+		return ex
+	}
+
+	records := make([][]string, 0, len(rows)+1)
+	records = append(records, model.CatalogColumns)
+	for _, row := range rows {
+		records = append(records, formatCatalogRow(row))
+	}
+
+	if err := table.Write(w, format, records); err != nil {
+		return fall.ServerError(fmt.Sprintf("%s, details: %s", msg.CatalogExportError, err.Error()))
+	}
+
+	return nil
+}
+
+func trimEmptyRecords(records [][]string) [][]string {
+	result := records[:0]
+	for _, record := range records {
+		for _, cell := range record {
+			if strings.TrimSpace(cell) != "" {
+				result = append(result, record)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func parseCatalogRow(line int, get func(column string) string) (model.CatalogRow, []model.CatalogImportError) {
+	var errs []model.CatalogImportError
+	fail := func(column string, message string) {
+		errs = append(errs, model.CatalogImportError{Row: line, Column: column, Message: message})
+	}
+
+	row := model.CatalogRow{
+		Line:      line,
+		Product:   get("product"),
+		Brand:     get("brand"),
+		Category:  get("category"),
+		Article:   get("article"),
+		MainImage: get("main_image"),
+		Images:    []string{},
+		Sizes:     []model.CatalogRowSize{},
+		Options:   []model.CatalogRowOption{},
+	}
+
+	checkText := func(column string, value string, maxLength int) {
+		if value == "" {
+			fail(column, msg.CatalogImportRequired)
+		} else if utf8.RuneCountInString(value) > maxLength {
+			fail(column, msg.CatalogImportTooLong)
+		}
+	}
+
+	checkText("product", row.Product, 200)
+	if row.Product != "" && utf8.RuneCountInString(row.Product) < 3 {
+		fail("product", msg.CatalogImportTooShort)
+	}
+	checkText("brand", row.Brand, 100)
+	checkText("category", row.Category, 255)
+	checkText("main_image", row.MainImage, 1024)
+
+	if description := get("description"); description != "" {
+		if utf8.RuneCountInString(description) < 10 {
+			fail("description", msg.CatalogImportTooShort)
+		}
+		row.Description = &description
+	}
+
+	if row.Article != "" {
+		if len(row.Article) > 12 {
+			fail("article", msg.CatalogImportTooLong)
+		} else if !isAlphanumeric(row.Article) {
+			fail("article", msg.CatalogImportInvalidArticle)
+		}
+	}
+
+	price, ok := parseCatalogInt(get("price"))
+	if !ok || price < 1 {
+		fail("price", msg.CatalogImportInvalidNumber)
+	}
+	row.Price = price
+
+	if raw := get("discount"); raw != "" {
+		discount, ok := parseCatalogInt(raw)
+		if !ok || discount < 1 || discount > 99 {
+			fail("discount", msg.CatalogImportInvalidDiscount)
+		} else {
+			d := byte(discount)
+			row.Discount = &d
+		}
+	}
+
+	row.Images = append(row.Images, splitCatalogList(get("images"))...)
+
+	for _, item := range splitCatalogList(get("sizes")) {
+		parts := strings.Split(item, model.CatalogSizeSeparator)
+		if len(parts) != 3 {
+			fail("sizes", msg.CatalogImportInvalidSizes)
+			continue
+		}
+		size := model.CatalogRowSize{Value: strings.TrimSpace(parts[0]), Literal: strings.TrimSpace(parts[1])}
+		stock, ok := parseCatalogInt(strings.TrimSpace(parts[2]))
+		if !ok || stock < 0 || size.Value == "" || size.Literal == "" ||
+			utf8.RuneCountInString(size.Value) > 10 || utf8.RuneCountInString(size.Literal) > 10 {
+			fail("sizes", msg.CatalogImportInvalidSizes)
+			continue
+		}
+		size.InStock = stock
+		row.Sizes = append(row.Sizes, size)
+	}
+
+	for _, item := range splitCatalogList(get("options")) {
+		title, value, found := strings.Cut(item, model.CatalogOptionSeparator)
+		title, value = strings.TrimSpace(title), strings.TrimSpace(value)
+		if !found || title == "" || value == "" ||
+			utf8.RuneCountInString(title) > 100 || utf8.RuneCountInString(value) > 150 {
+			fail("options", msg.CatalogImportInvalidOptions)
+			continue
+		}
+		row.Options = append(row.Options, model.CatalogRowOption{Option: title, OptionSlug: utils.GenerateSlug(title), Value: value})
+	}
+
+	row.BrandSlug = utils.GenerateSlug(row.Brand)
+	row.TitleSlug = utils.GenerateSlug(row.Product)
+
+	return row, errs
+}
+
+func formatCatalogRow(row model.CatalogRow) []string {
+	sizes := make([]string, 0, len(row.Sizes))
+	for _, size := range row.Sizes {
+		sizes = append(sizes, strings.Join([]string{size.Value, size.Literal, strconv.Itoa(size.InStock)}, model.CatalogSizeSeparator))
+	}
+
+	options := make([]string, 0, len(row.Options))
+	for _, option := range row.Options {
+		options = append(options, option.Option+model.CatalogOptionSeparator+option.Value)
+	}
+
+	description := ""
+	if row.Description != nil {
+		description = *row.Description
+	}
+
+	discount := ""
+	if row.Discount != nil {
+		discount = strconv.Itoa(int(*row.Discount))
+	}
+
+	return []string{row.Product, description, row.Brand, row.Category, row.Article, strconv.Itoa(row.Price), discount,
+		row.MainImage, strings.Join(row.Images, model.CatalogListSeparator), strings.Join(sizes, model.CatalogListSeparator),
+		strings.Join(options, model.CatalogListSeparator)}
+}
+
+func splitCatalogList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, model.CatalogListSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseCatalogInt accepts integral floats as well, spreadsheets often store numbers as "1500.0".
+func parseCatalogInt(value string) (int, bool) {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n, n >= math.MinInt32 && n <= math.MaxInt32
+	}
+	f, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+		return 0, false
+	}
+	return int(f), true
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
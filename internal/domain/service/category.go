@@ -5,6 +5,7 @@ import (
 
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/cache"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
 )
@@ -23,14 +24,17 @@ type categoryRepository interface {
 	GetChildrenCount(ctx context.Context, id int) (*int, fall.Error)
 	GetWithoutChildren(ctx context.Context) ([]model.CategoryModel, fall.Error)
 	GetLastLevels(ctx context.Context, slug string) ([]model.CategoryModel, fall.Error)
+	GetPathSlugs(ctx context.Context, id int) ([]string, fall.Error)
 }
 
 type CategoryService struct {
-	repo categoryRepository
+	repo  categoryRepository
+	slugs slugRepository
+	cache cache.Cache
 }
 
-func NewCategoryService(repo categoryRepository) *CategoryService {
-	return &CategoryService{repo: repo}
+func NewCategoryService(repo categoryRepository, slugs slugRepository, cache cache.Cache) *CategoryService {
+	return &CategoryService{repo: repo, slugs: slugs, cache: cache}
 }
 
 func (s *CategoryService) GetLastLevels(ctx context.Context, slug string) ([]model.CategoryModel, fall.Error) {
@@ -54,8 +58,12 @@ func (s *CategoryService) Create(ctx context.Context, dto model.CreateCategoryDt
 	if c != nil {
 		return fall.NewErr(msg.CategoryExists, fall.STATUS_BAD_REQUEST)
 	}
-	slug := utils.GenerateSlug(dto.Title)
-	err := s.repo.Create(ctx, dto, slug)
+	err := withUniqueSlug(ctx, s.slugs, model.SlugCategory, utils.GenerateSlug(dto.Title), 0, func(slug string) fall.Error {
+		return s.repo.Create(ctx, dto, slug)
+	})
+	if err == nil {
+		s.cache.Invalidate(ctx, cache.TagCategories)
+	}
 	return err
 }
 
@@ -66,19 +74,20 @@ func (s *CategoryService) Update(ctx context.Context, dto model.UpdateCategoryDt
 		return err
 	}
 
-	var slug *string
-
-	if dto.Title != nil {
-		if current.Title != *dto.Title {
-			exist, _ := s.FindByTitle(ctx, *dto.Title)
-			if exist != nil {
-				return fall.NewErr(msg.CategoryTitleUnique, fall.STATUS_BAD_REQUEST)
-			}
-			newSlug := utils.GenerateSlug(*dto.Title)
-			slug = &newSlug
+	if dto.Title != nil && current.Title != *dto.Title {
+		exist, _ := s.FindByTitle(ctx, *dto.Title)
+		if exist != nil {
+			return fall.NewErr(msg.CategoryTitleUnique, fall.STATUS_BAD_REQUEST)
 		}
+		err = withUniqueSlug(ctx, s.slugs, model.SlugCategory, utils.GenerateSlug(*dto.Title), id, func(slug string) fall.Error {
+			return s.repo.Update(ctx, dto, &slug, id)
+		})
+	} else {
+		err = s.repo.Update(ctx, dto, nil, id)
+	}
+	if err == nil {
+		s.cache.Invalidate(ctx, cache.TagCategories, cache.CategoryTag(current.Slug))
 	}
-	err = s.repo.Update(ctx, dto, slug, id)
 	return err
 }
 
@@ -94,6 +103,11 @@ func (s *CategoryService) FindBySlug(ctx context.Context, slug string) (*model.C
 	return s.repo.FindByField(ctx, "slug", slug)
 }
 
+// FindRedirect finds where a retired category slug has moved.
+func (s *CategoryService) FindRedirect(ctx context.Context, slug string) (*model.SlugRedirect, fall.Error) {
+	return findSlugRedirect(ctx, s.slugs, model.SlugCategory, slug, model.CategoryPagePath)
+}
+
 func (s *CategoryService) GetParentSubLevel(ctx context.Context, id int) (*model.CategoryModel, fall.Error) {
 	return s.repo.GetParentSubLevel(ctx, id)
 }
@@ -111,11 +125,22 @@ func (s *CategoryService) FindBySlugRelation(ctx context.Context, slug string) (
 }
 
 func (s *CategoryService) Delete(ctx context.Context, slug string) fall.Error {
-	return s.repo.Delete(ctx, slug)
+	ex := s.repo.Delete(ctx, slug)
+	if ex == nil {
+		s.cache.Invalidate(ctx, cache.TagCategories, cache.CategoryTag(slug))
+	}
+	return ex
 }
 
 func (s *CategoryService) GetAll(ctx context.Context) ([]*model.Category, fall.Error) {
-	return s.repo.GetAll(ctx)
+	return cache.Load(ctx, s.cache, "categories:all", func() ([]*model.Category, []string, fall.Error) {
+		categories, ex := s.repo.GetAll(ctx)
+		return categories, []string{cache.TagCategories}, ex
+	})
+}
+
+func (s *CategoryService) GetPathSlugs(ctx context.Context, id int) ([]string, fall.Error) {
+	return s.repo.GetPathSlugs(ctx, id)
 }
 
 func (s *CategoryService) GetCatalogCategories(ctx context.Context, slug string) (*model.CatalogCategoryResponse, fall.Error) {
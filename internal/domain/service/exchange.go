@@ -0,0 +1,564 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/cache"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/commerceml"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
+)
+
+const (
+	exchangeSessionTTL = time.Hour
+	// exchangeSessionLimit bounds what one exchange may write to disk, pictures included
+	exchangeSessionLimit = 2 << 30
+	exchangeSizeName     = "размер"
+)
+
+var exchangeBrandProperties = []string{"бренд", "производитель", "торговая марка"}
+
+type exchangeRepository interface {
+	ImportProducts(ctx context.Context, rows []model.CatalogRow) (*model.ExchangeReport, fall.Error)
+	ImportOffers(ctx context.Context, offers []model.ExchangeOffer) (*model.ExchangeReport, fall.Error)
+	GetNewOrders(ctx context.Context) ([]model.ExchangeOrder, fall.Error)
+	MarkOrdersExported(ctx context.Context, ids []string) fall.Error
+}
+
+type exchangeFileClient interface {
+	Put(ctx context.Context, name string, contentType string, data []byte) (*model.UploadResponse, error)
+}
+
+// exchangeSession keeps what 1C uploaded between the requests of one exchange.
+// articles maps 1C product ids of the session's import.xml to articles, offers.xml often has no articles.
+// written counts the bytes saved since the last init.
+type exchangeSession struct {
+	dir      string
+	expires  time.Time
+	articles map[string]string
+	orders   []string
+	written  int64
+}
+
+type ExchangeService struct {
+	repo     exchangeRepository
+	files    exchangeFileClient
+	cache    cache.Cache
+	login    string
+	password string
+	dir      string
+	mu       sync.Mutex
+	sessions map[string]*exchangeSession
+}
+
+// NewExchangeService keeps uploaded files under dir, the exchange is disabled while login is empty.
+func NewExchangeService(repo exchangeRepository, files exchangeFileClient, cache cache.Cache, login string,
+	password string, dir string) *ExchangeService {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "commerceml")
+	}
+	return &ExchangeService{repo: repo, files: files, cache: cache, login: login, password: password, dir: dir,
+		sessions: make(map[string]*exchangeSession)}
+}
+
+func (s *ExchangeService) CheckAuth(login string, password string) (string, fall.Error) {
+	if s.login == "" || subtle.ConstantTimeCompare([]byte(login), []byte(s.login)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) != 1 {
+		return "", fall.NewErr(msg.ExchangeUnauthorized, fall.STATUS_UNAUTHORIZED)
+	}
+
+	token := uuid.New().String()
+	dir := filepath.Join(s.dir, token)
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fall.ServerError(err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, session := range s.sessions {
+		if session.expires.Before(now) {
+			os.RemoveAll(session.dir)
+			delete(s.sessions, id)
+		}
+	}
+
+	s.sessions[token] = &exchangeSession{dir: dir, expires: now.Add(exchangeSessionTTL), articles: make(map[string]string)}
+
+	return token, nil
+}
+
+// Init starts a new exchange in the session, files of the previous one are removed.
+func (s *ExchangeService) Init(token string) fall.Error {
+	session, ex := s.session(token)
+	if ex != nil {
+		return ex
+	}
+
+	if err := os.RemoveAll(session.dir); err != nil {
+		return fall.ServerError(err.Error())
+	}
+	if err := os.MkdirAll(session.dir, 0o700); err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	s.mu.Lock()
+	session.articles = make(map[string]string)
+	session.written = 0
+	s.mu.Unlock()
+
+	return nil
+}
+
+// SaveFile appends data to the file, 1C splits large files into several requests.
+func (s *ExchangeService) SaveFile(token string, filename string, data []byte) fall.Error {
+	session, ex := s.session(token)
+	if ex != nil {
+		return ex
+	}
+
+	path, ex := sessionPath(session, filename)
+	if ex != nil {
+		return ex
+	}
+
+	// the bytes are taken before writing, so parallel requests of one session can't pass the limit together
+	s.mu.Lock()
+	if session.written+int64(len(data)) > exchangeSessionLimit {
+		s.mu.Unlock()
+		return fall.NewErr(msg.ExchangeSessionFull, fall.STATUS_BAD_REQUEST)
+	}
+	session.written += int64(len(data))
+	s.mu.Unlock()
+
+	ex = appendFile(path, data)
+	if ex != nil {
+		s.mu.Lock()
+		session.written -= int64(len(data))
+		s.mu.Unlock()
+	}
+
+	return ex
+}
+
+func appendFile(path string, data []byte) fall.Error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fall.ServerError(msg.ExchangeErrorWhenSave)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fall.ServerError(msg.ExchangeErrorWhenSave)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return fall.ServerError(msg.ExchangeErrorWhenSave)
+	}
+
+	return nil
+}
+
+// Import applies an uploaded import.xml or offers.xml, entries that can't be applied are skipped and logged.
+func (s *ExchangeService) Import(ctx context.Context, token string, filename string) fall.Error {
+	session, ex := s.session(token)
+	if ex != nil {
+		return ex
+	}
+
+	path, ex := sessionPath(session, filename)
+	if ex != nil {
+		return ex
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fall.NewErr(msg.ExchangeFileNotFound, fall.STATUS_NOT_FOUND)
+		}
+		return fall.ServerError(err.Error())
+	}
+	defer f.Close()
+
+	doc, err := commerceml.Parse(f)
+	if err != nil {
+		return fall.NewErr(fmt.Sprintf("%s: %s", msg.ExchangeBadFile, err.Error()), fall.STATUS_BAD_REQUEST)
+	}
+
+	if doc.Catalog != nil {
+		rows := s.catalogRows(ctx, session, doc)
+
+		report, ex := s.repo.ImportProducts(ctx, rows)
+		if ex != nil {
+			return ex
+		}
+		logExchangeReport(filename, report)
+	}
+
+	if doc.Offers != nil {
+		offers, skipped := s.offers(session, doc.Offers)
+
+		report, ex := s.repo.ImportOffers(ctx, offers)
+		if ex != nil {
+			return ex
+		}
+		report.Skipped += len(skipped)
+		report.Errors = append(report.Errors, skipped...)
+		logExchangeReport(filename, report)
+	}
+
+	s.cache.Invalidate(ctx, cache.TagCatalog, cache.TagBrands)
+
+	return nil
+}
+
+// QueryOrders returns the orders document for 1C, they are marked as exported only after 1C confirms with mode=success.
+func (s *ExchangeService) QueryOrders(ctx context.Context, token string) ([]byte, fall.Error) {
+	session, ex := s.session(token)
+	if ex != nil {
+		return nil, ex
+	}
+
+	orders, ex := s.repo.GetNewOrders(ctx)
+	if ex != nil {
+		return nil, ex
+	}
+
+	documents := make([]commerceml.OrderDocument, 0, len(orders))
+	ids := make([]string, 0, len(orders))
+
+	for _, o := range orders {
+		documents = append(documents, orderDocument(o))
+		ids = append(ids, o.Id)
+	}
+
+	var buf bytes.Buffer
+	if err := commerceml.WriteOrders(&buf, documents, time.Now()); err != nil {
+		return nil, fall.ServerError(fmt.Sprintf("%s, details: %s", msg.ExchangeOrdersError, err.Error()))
+	}
+
+	s.mu.Lock()
+	session.orders = ids
+	s.mu.Unlock()
+
+	return buf.Bytes(), nil
+}
+
+func (s *ExchangeService) ConfirmOrders(ctx context.Context, token string) fall.Error {
+	session, ex := s.session(token)
+	if ex != nil {
+		return ex
+	}
+
+	s.mu.Lock()
+	ids := session.orders
+	session.orders = nil
+	s.mu.Unlock()
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return s.repo.MarkOrdersExported(ctx, ids)
+}
+
+func (s *ExchangeService) session(token string) (*exchangeSession, fall.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[token]
+	if !ok || session.expires.Before(time.Now()) {
+		return nil, fall.NewErr(msg.ExchangeSessionExpired, fall.STATUS_UNAUTHORIZED)
+	}
+
+	session.expires = time.Now().Add(exchangeSessionTTL)
+
+	return session, nil
+}
+
+// sessionPath keeps uploaded files inside the session directory, 1C sends pictures as "import_files/ab/name.jpg".
+func sessionPath(session *exchangeSession, filename string) (string, fall.Error) {
+	name := filepath.Clean(filepath.FromSlash(filename))
+	if filename == "" || filepath.IsAbs(name) || name == "." || strings.HasPrefix(name, "..") {
+		return "", fall.NewErr(msg.ExchangeInvalidFile, fall.STATUS_BAD_REQUEST)
+	}
+	return filepath.Join(session.dir, name), nil
+}
+
+func logExchangeReport(filename string, report *model.ExchangeReport) {
+	log.Printf("1C exchange %s (import %s): %d processed, %d skipped", filename, report.ImportId, report.Processed, report.Skipped)
+	for _, e := range report.Errors {
+		log.Printf("1C exchange %s: %s", filename, e)
+	}
+}
+
+// catalogRows maps 1C products to import rows: groups become categories by slug, the manufacturer
+// or a brand property becomes the brand and the remaining properties become options.
+func (s *ExchangeService) catalogRows(ctx context.Context, session *exchangeSession, doc *commerceml.Document) []model.CatalogRow {
+	groups := make(map[string]string)
+	properties := make(map[string]commerceml.Property)
+	values := make(map[string]string)
+
+	if doc.Classifier != nil {
+		var walk func([]commerceml.Group)
+		walk = func(list []commerceml.Group) {
+			for _, g := range list {
+				groups[g.Id] = g.Name
+				walk(g.Groups)
+			}
+		}
+		walk(doc.Classifier.Groups)
+
+		for _, p := range doc.Classifier.Properties {
+			properties[p.Id] = p
+			for _, v := range p.Values {
+				values[v.Id] = v.Value
+			}
+		}
+	}
+
+	var rows []model.CatalogRow
+
+	for i, p := range doc.Catalog.Products {
+		article := strings.TrimSpace(p.Article)
+		if p.Deleted() || article == "" || len(article) > 12 || !isAlphanumeric(article) {
+			log.Printf("1C exchange: product %s skipped, article %q is missing or invalid", p.Id, article)
+			continue
+		}
+
+		s.mu.Lock()
+		session.articles[p.Id] = article
+		s.mu.Unlock()
+
+		row := model.CatalogRow{
+			Line:      i + 1,
+			Product:   strings.TrimSpace(p.Name),
+			Brand:     strings.TrimSpace(p.Manufacturer),
+			Article:   article,
+			Images:    []string{},
+			Sizes:     []model.CatalogRowSize{},
+			Options:   []model.CatalogRowOption{},
+			KeepPrice: true,
+		}
+
+		if len(p.Groups) > 0 {
+			row.Category = utils.GenerateSlug(groups[p.Groups[0]])
+		}
+
+		if description := strings.TrimSpace(p.Description); utf8.RuneCountInString(description) >= 10 {
+			row.Description = &description
+		}
+
+		for _, pv := range p.Properties {
+			property, ok := properties[pv.Id]
+			if !ok {
+				continue
+			}
+			for _, raw := range pv.Values {
+				value := raw
+				if v, ok := values[raw]; ok {
+					value = v
+				}
+				value = strings.TrimSpace(value)
+				if value == "" || utf8.RuneCountInString(property.Name) > 100 || utf8.RuneCountInString(value) > 150 {
+					continue
+				}
+				if isExchangeBrandProperty(property.Name) {
+					if row.Brand == "" {
+						row.Brand = value
+					}
+					continue
+				}
+				row.Options = append(row.Options, model.CatalogRowOption{Option: property.Name,
+					OptionSlug: utils.GenerateSlug(property.Name), Value: value})
+			}
+		}
+
+		length := utf8.RuneCountInString(row.Product)
+		if length < 3 || length > 200 || row.Brand == "" || utf8.RuneCountInString(row.Brand) > 100 || row.Category == "" {
+			log.Printf("1C exchange: product %s (%s) skipped, title, brand or group is missing", p.Id, article)
+			continue
+		}
+
+		row.BrandSlug = utils.GenerateSlug(row.Brand)
+		row.TitleSlug = utils.GenerateSlug(row.Product)
+
+		for _, picture := range p.Pictures {
+			path, err := s.uploadPicture(ctx, session, picture)
+			if err != nil {
+				log.Printf("1C exchange: picture %s of %s skipped: %s", picture, article, err.Error())
+				continue
+			}
+			row.Images = append(row.Images, path)
+		}
+		if len(row.Images) > 0 {
+			row.MainImage = row.Images[0]
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func (s *ExchangeService) uploadPicture(ctx context.Context, session *exchangeSession, picture string) (string, error) {
+	path, ex := sessionPath(session, picture)
+	if ex != nil {
+		return "", errors.New(ex.Message())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	// named by content, so a picture sent with every exchange is stored and attached once
+	sum := sha256.Sum256(data)
+	ext := strings.ToLower(filepath.Ext(path))
+	res, err := s.files.Put(ctx, hex.EncodeToString(sum[:16])+ext, mime.TypeByExtension(ext), data)
+	if err != nil {
+		return "", err
+	}
+
+	return res.Path, nil
+}
+
+func isExchangeBrandProperty(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, p := range exchangeBrandProperties {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// offers maps 1C offers to price and stock updates, the size is the "Размер" characteristic or the only one.
+func (s *ExchangeService) offers(session *exchangeSession, pack *commerceml.OfferPack) ([]model.ExchangeOffer, []string) {
+	var offers []model.ExchangeOffer
+	var skipped []string
+
+	for _, o := range pack.Offers {
+		article := strings.TrimSpace(o.Article)
+		if article == "" {
+			s.mu.Lock()
+			article = session.articles[o.ProductId()]
+			s.mu.Unlock()
+		}
+		if article == "" {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", o.Id, msg.ProductModelNotFound))
+			continue
+		}
+
+		offer := model.ExchangeOffer{Article: article}
+
+		if price, ok := o.Price(); ok && price >= 1 && price <= math.MaxInt32 {
+			p := int(math.Round(price))
+			offer.Price = &p
+		}
+
+		for _, c := range o.Characteristics {
+			value := strings.TrimSpace(c.Value)
+			if value == "" || utf8.RuneCountInString(value) > 10 {
+				continue
+			}
+			if offer.Size == nil || strings.ToLower(strings.TrimSpace(c.Name)) == exchangeSizeName {
+				offer.Size = &value
+			}
+		}
+
+		if stock, ok := o.Stock(); ok && offer.Size != nil {
+			q := max(int(math.Round(stock)), 0)
+			offer.Stock = &q
+		}
+
+		offers = append(offers, offer)
+	}
+
+	return offers, skipped
+}
+
+func orderDocument(o model.ExchangeOrder) commerceml.OrderDocument {
+	doc := commerceml.NewOrderDocument(o.Id, o.CreatedAt, o.TotalPrice)
+
+	name := strings.TrimSpace(o.RecipientLastname + " " + o.RecipientFirstname)
+	doc.Counterparty = []commerceml.Counterparty{{
+		Id:        strconv.Itoa(o.UserId),
+		Name:      name,
+		Role:      "Покупатель",
+		FullName:  name,
+		LastName:  o.RecipientLastname,
+		FirstName: o.RecipientFirstname,
+		Contacts: []commerceml.Contact{
+			{Type: "Телефон рабочий", Value: o.RecipientPhone},
+			{Type: "Почта", Value: o.UserEmail},
+		},
+	}}
+
+	goods := []commerceml.Requisite{{Name: "ВидНоменклатуры", Value: "Товар"}, {Name: "ТипНоменклатуры", Value: "Товар"}}
+
+	for _, item := range o.Items {
+		price := float64(item.Price)
+		if item.Discount != nil {
+			price -= float64(item.Price) / 100 * float64(*item.Discount)
+		}
+		doc.Items = append(doc.Items, commerceml.OrderItem{
+			Id:              item.Article,
+			Article:         item.Article,
+			Name:            item.Title,
+			Unit:            commerceml.PieceUnit,
+			PricePerUnit:    commerceml.FormatNumber(price),
+			Quantity:        item.Quantity,
+			Sum:             commerceml.FormatNumber(price * float64(item.Quantity)),
+			Characteristics: []commerceml.Characteristic{{Name: "Размер", Value: item.Size}},
+			Requisites:      goods,
+		})
+	}
+
+	if o.DeliveryPrice > 0 {
+		delivery := float64(o.DeliveryPrice)
+		doc.Items = append(doc.Items, commerceml.OrderItem{
+			Id:           commerceml.DeliveryId,
+			Name:         "Доставка заказа",
+			Unit:         commerceml.PieceUnit,
+			PricePerUnit: commerceml.FormatNumber(delivery),
+			Quantity:     1,
+			Sum:          commerceml.FormatNumber(delivery),
+			Requisites:   []commerceml.Requisite{{Name: "ВидНоменклатуры", Value: "Услуга"}, {Name: "ТипНоменклатуры", Value: "Услуга"}},
+		})
+	}
+
+	doc.Requisites = []commerceml.Requisite{
+		{Name: "Статус заказа", Value: string(o.Status)},
+		{Name: "Метод оплаты", Value: string(o.PaymentMethod)},
+		{Name: "Заказ оплачен", Value: strconv.FormatBool(o.PaymentMethod == model.Online)},
+		{Name: "Условия доставки", Value: string(o.Conditions)},
+	}
+	if o.PromoDiscount > 0 {
+		doc.Requisites = append(doc.Requisites, commerceml.Requisite{Name: "Скидка по промокоду", Value: strconv.Itoa(o.PromoDiscount)})
+	}
+
+	return doc
+}
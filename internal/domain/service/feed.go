@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/feed"
+)
+
+const feedFolder = "feeds"
+
+type feedRepository interface {
+	GetCatalog(ctx context.Context) (*model.FeedCatalog, fall.Error)
+	StartGeneration(ctx context.Context, feed string) fall.Error
+	FinishGeneration(ctx context.Context, g model.FeedGeneration) fall.Error
+	GetGenerations(ctx context.Context) ([]model.FeedGeneration, fall.Error)
+	GetPath(ctx context.Context, feed string) (string, fall.Error)
+}
+
+type feedFileClient interface {
+	Put(ctx context.Context, name string, contentType string, data []byte) (*model.UploadResponse, error)
+}
+
+type FeedService struct {
+	repo  feedRepository
+	files feedFileClient
+	shop  feed.Shop
+	mu    sync.Mutex
+}
+
+func NewFeedService(repo feedRepository, files feedFileClient, shop feed.Shop) *FeedService {
+	return &FeedService{repo: repo, files: files, shop: shop}
+}
+
+// Generate rewrites every feed in the file bucket under a fixed name, so feed URLs never change.
+// A feed that fails keeps its previous file.
+func (s *FeedService) Generate(ctx context.Context) ([]model.FeedGeneration, fall.Error) {
+	if !s.mu.TryLock() {
+		return nil, fall.NewErr(msg.FeedAlreadyRunning, fall.STATUS_CONFLICT)
+	}
+	defer s.mu.Unlock()
+
+	for _, name := range model.Feeds {
+		if ex := s.repo.StartGeneration(ctx, name); ex != nil {
+			return nil, ex
+		}
+	}
+
+	catalog, catalogEx := s.repo.GetCatalog(ctx)
+
+	for _, name := range model.Feeds {
+		g := model.FeedGeneration{Feed: name, Status: model.FeedSucceeded, Warnings: []model.FeedWarning{}}
+
+		var err error
+		if catalogEx != nil {
+			err = fmt.Errorf("%s", catalogEx.Message())
+		} else {
+			g.Offers, g.Warnings, g.Path, err = s.write(ctx, name, catalog)
+		}
+
+		if err != nil {
+			message := fmt.Sprintf("%s, details: %s", msg.FeedErrorWhenGenerate, err.Error())
+			log.Printf("feed %s: %s", name, message)
+			g.Status = model.FeedFailed
+			g.Error = &message
+		}
+
+		if ex := s.repo.FinishGeneration(ctx, g); ex != nil {
+			return nil, ex
+		}
+	}
+
+	return s.repo.GetGenerations(ctx)
+}
+
+func (s *FeedService) write(ctx context.Context, name string, catalog *model.FeedCatalog) (int, []model.FeedWarning, *string, error) {
+	var buf bytes.Buffer
+
+	offers, warnings, err := feed.Write(&buf, name, s.shop, catalog, time.Now())
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	contentType := "application/xml; charset=utf-8"
+	if name == model.FeedGoogleTSV {
+		contentType = "text/tab-separated-values; charset=utf-8"
+	}
+
+	res, err := s.files.Put(ctx, path.Join(feedFolder, name), contentType, buf.Bytes())
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	return offers, warnings, &res.Path, nil
+}
+
+func (s *FeedService) GetGenerations(ctx context.Context) ([]model.FeedGeneration, fall.Error) {
+	return s.repo.GetGenerations(ctx)
+}
+
+func (s *FeedService) GetPath(ctx context.Context, name string) (string, fall.Error) {
+	return s.repo.GetPath(ctx, name)
+}
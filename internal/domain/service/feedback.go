@@ -6,12 +6,13 @@ import (
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 )
 
 type feedbackRepository interface {
 	AddFeedback(ctx context.Context, userId int, dto model.AddFeedbackDto) fall.Error
 	GetModelFeedback(ctx context.Context, modelId int, order string) (*model.ModelFeedbackResponse, fall.Error)
-	GetAll(ctx context.Context, order string, page int, filter string) (*model.AdminAllFeedbackResponse, fall.Error)
+	GetAll(ctx context.Context, order string, page pagination.Page, filter string) (*model.AdminAllFeedbackResponse, fall.Error)
 	DeleteFeedback(ctx context.Context, feedbackId int) fall.Error
 	ToggleHidden(ctx context.Context, feedbackId int) fall.Error
 	FindFeedback(ctx context.Context, userId int, modelId int) (*model.Feedback, fall.Error)
@@ -51,7 +52,7 @@ func (s *FeedbackService) GetModelFeedback(ctx context.Context, modelId int, ord
 	return s.repo.GetModelFeedback(ctx, modelId, order)
 }
 
-func (s *FeedbackService) GetAll(ctx context.Context, order string, page int, filter string) (*model.AdminAllFeedbackResponse, fall.Error) {
+func (s *FeedbackService) GetAll(ctx context.Context, order string, page pagination.Page, filter string) (*model.AdminAllFeedbackResponse, fall.Error) {
 	return s.repo.GetAll(ctx, order, page, filter)
 }
 
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/cache"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/file"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/imageproc"
+)
+
+type imageRepository interface {
+	GetUnprocessed(ctx context.Context, limit int, skip []string) ([]model.ImageRecord, fall.Error)
+	CountUnprocessed(ctx context.Context, skip []string) (int, fall.Error)
+	UpdatePath(ctx context.Context, rec model.ImageRecord, path string) (int, fall.Error)
+}
+
+type imageFileClient interface {
+	Get(ctx context.Context, p string, limit int64) ([]byte, error)
+	PutImage(ctx context.Context, data []byte) (*model.UploadResponse, error)
+}
+
+// originalMaxSize bounds the originals the backfill reads, they were stored before uploads had limits.
+const originalMaxSize = 50 * 1024 * 1024
+
+type ImageService struct {
+	repo  imageRepository
+	files imageFileClient
+	cache cache.Cache
+	mu    sync.Mutex
+	// failed holds paths the backfill couldn't process, so it doesn't retry them until restart, guarded by mu.
+	failed map[string]bool
+}
+
+func NewImageService(repo imageRepository, files imageFileClient, cache cache.Cache) *ImageService {
+	return &ImageService{repo: repo, files: files, cache: cache, failed: map[string]bool{}}
+}
+
+// Normalize returns the main rendition for a path that still points at an original in the bucket.
+// Renditions and paths outside the bucket are returned as they are.
+func (s *ImageService) Normalize(ctx context.Context, p string) (string, fall.Error) {
+	if imageproc.IsKey(p) {
+		return p, nil
+	}
+
+	res, err := s.process(ctx, p)
+	if err != nil {
+		if errors.Is(err, file.ErrNotInBucket) {
+			return p, nil
+		}
+		return "", fall.NewErr(fmt.Sprintf("%s, details: %s", msg.ImageProcessError, err.Error()), fall.STATUS_BAD_REQUEST)
+	}
+
+	return res, nil
+}
+
+// Backfill moves up to batch photos and main images of models from originals to renditions.
+func (s *ImageService) Backfill(ctx context.Context, batch int) (*model.ImageBackfillResult, fall.Error) {
+	if !s.mu.TryLock() {
+		return nil, fall.NewErr(msg.ImageBackfillRunning, fall.STATUS_CONFLICT)
+	}
+	defer s.mu.Unlock()
+
+	records, ex := s.repo.GetUnprocessed(ctx, batch, s.skipped())
+	if ex != nil {
+		return nil, ex
+	}
+
+	result := &model.ImageBackfillResult{}
+	// the same original is often both a photo and the main image
+	done := map[string]string{}
+	var tags []string
+
+	for _, rec := range records {
+		p, ok := done[rec.Path]
+		if !ok {
+			var err error
+			p, err = s.process(ctx, rec.Path)
+			if err != nil {
+				log.Printf("image backfill %s %d: %s", rec.Kind, rec.Id, err.Error())
+				s.failed[rec.Path] = true
+				result.Failed++
+				continue
+			}
+			done[rec.Path] = p
+		}
+
+		productId, ex := s.repo.UpdatePath(ctx, rec, p)
+		if ex != nil {
+			s.cache.Invalidate(ctx, tags...)
+			return nil, ex
+		}
+		tags = append(tags, cache.ProductTag(productId))
+		result.Processed++
+	}
+
+	// only product pages are cached with image paths, the catalog listing is read from its projection
+	s.cache.Invalidate(ctx, tags...)
+
+	result.Remaining, ex = s.repo.CountUnprocessed(ctx, s.skipped())
+	if ex != nil {
+		return nil, ex
+	}
+
+	return result, nil
+}
+
+func (s *ImageService) process(ctx context.Context, p string) (string, error) {
+	data, err := s.files.Get(ctx, p, originalMaxSize)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := s.files.PutImage(ctx, data)
+	if err != nil {
+		return "", err
+	}
+
+	return res.Path, nil
+}
+
+func (s *ImageService) skipped() []string {
+	skip := make([]string, 0, len(s.failed))
+	for p := range s.failed {
+		skip = append(skip, p)
+	}
+	return skip
+}
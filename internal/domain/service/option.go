@@ -2,10 +2,14 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/cache"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/generator"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 )
 
 type optionRepository interface {
@@ -22,26 +26,69 @@ type optionRepository interface {
 	DeleteOptionFromProductModel(ctx context.Context, productModelOptionId int) fall.Error
 	FindByField(ctx context.Context, field string, value any) (*model.Option, fall.Error)
 	AddOptionToProductModel(ctx context.Context, dto model.AddOptionToProductModelDto) fall.Error
-	AddSizeToProductModel(ctx context.Context, dto model.AddSizeToProductModelDto) fall.Error
-	GetCatalogFilters(ctx context.Context, categorySlug string) (*model.CatalogFilters, fall.Error)
+	AddSizeToProductModel(ctx context.Context, userId int, dto model.AddSizeToProductModelDto) fall.Error
+	GetCatalogFilters(ctx context.Context, params generator.CatalogParams) (*model.CatalogFilters, fall.Error)
 	CheckValueInOption(ctx context.Context, valueId int, optionId int) fall.Error
 	GetAllSizes(ctx context.Context) ([]model.Size, fall.Error)
+	FindCatalogTargets(ctx context.Context, scope model.CatalogScope, id int) (*model.CatalogTargets, fall.Error)
 }
 
 type OptionService struct {
-	repo optionRepository
+	repo  optionRepository
+	cache cache.Cache
 }
 
-func NewOptionService(repo optionRepository) *OptionService {
-	return &OptionService{repo: repo}
+func NewOptionService(repo optionRepository, cache cache.Cache) *OptionService {
+	return &OptionService{repo: repo, cache: cache}
+}
+
+// tags collects the product pages and category filters that show the models a write affects.
+// Like the product tags it runs before the write, a delete takes the rows it is looked up by.
+func (s *OptionService) tags(ctx context.Context, scope model.CatalogScope, id int) []string {
+	targets, ex := s.repo.FindCatalogTargets(ctx, scope, id)
+	if ex != nil {
+		return []string{cache.TagCatalog}
+	}
+
+	tags := make([]string, 0, len(targets.ProductIds)+len(targets.CategorySlugs))
+	for _, productId := range targets.ProductIds {
+		tags = append(tags, cache.ProductTag(productId))
+	}
+	for _, slug := range targets.CategorySlugs {
+		tags = append(tags, cache.CategoryTag(slug))
+	}
+	return tags
+}
+
+func (s *OptionService) invalidate(ctx context.Context, ex fall.Error, tags []string) fall.Error {
+	if ex == nil && len(tags) > 0 {
+		s.cache.Invalidate(ctx, tags...)
+	}
+	return ex
 }
 
 func (s *OptionService) GetAllSizes(ctx context.Context) ([]model.Size, fall.Error) {
 	return s.repo.GetAllSizes(ctx)
 }
 
-func (s *OptionService) GetCatalogFilters(ctx context.Context, categorySlug string) (*model.CatalogFilters, fall.Error) {
-	return s.repo.GetCatalogFilters(ctx, categorySlug)
+func (s *OptionService) GetCatalogFilters(ctx context.Context, params generator.CatalogParams) (*model.CatalogFilters, fall.Error) {
+	params.Page = pagination.Page{}
+	key, err := json.Marshal(params)
+	if err != nil {
+		return s.repo.GetCatalogFilters(ctx, params)
+	}
+
+	return cache.Load(ctx, s.cache, "filters:"+string(key), func() (*model.CatalogFilters, []string, fall.Error) {
+		filters, ex := s.repo.GetCatalogFilters(ctx, params)
+		if ex != nil {
+			return nil, nil, ex
+		}
+		tags := []string{cache.TagCatalog, cache.CategoryTag(params.Slug)}
+		for _, b := range filters.Brands {
+			tags = append(tags, cache.BrandTag(b.Id))
+		}
+		return filters, tags, nil
+	})
 }
 
 func (s *OptionService) GetAll(ctx context.Context) ([]*model.Option, fall.Error) {
@@ -63,9 +110,8 @@ func (s *OptionService) CreateOption(ctx context.Context, dto model.CreateOption
 		return fall.NewErr(msg.OptionAlreadyExists, fall.STATUS_BAD_REQUEST)
 	}
 
-	ex := s.repo.CreateOption(ctx, dto)
-
-	return ex
+	// nothing cached shows an option, value or size before a model has it
+	return s.repo.CreateOption(ctx, dto)
 }
 
 func (s *OptionService) CreateSize(ctx context.Context, dto model.CreateSizeDto) fall.Error {
@@ -77,23 +123,28 @@ func (s *OptionService) CreateValue(ctx context.Context, dto model.CreateOptionV
 }
 
 func (s *OptionService) DeleteOption(ctx context.Context, id int) fall.Error {
-	return s.repo.DeleteOption(ctx, id)
+	tags := s.tags(ctx, model.ScopeOption, id)
+	return s.invalidate(ctx, s.repo.DeleteOption(ctx, id), tags)
 }
 
 func (s *OptionService) DeleteValue(ctx context.Context, id int) fall.Error {
-	return s.repo.DeleteValue(ctx, id)
+	tags := s.tags(ctx, model.ScopeValue, id)
+	return s.invalidate(ctx, s.repo.DeleteValue(ctx, id), tags)
 }
 
 func (s *OptionService) DeleteSize(ctx context.Context, id int) fall.Error {
-	return s.repo.DeleteSize(ctx, id)
+	tags := s.tags(ctx, model.ScopeSize, id)
+	return s.invalidate(ctx, s.repo.DeleteSize(ctx, id), tags)
 }
 
 func (s *OptionService) DeleteSizeFromProductModel(ctx context.Context, modelSizeId int) fall.Error {
-	return s.repo.DeleteSizeFromProductModel(ctx, modelSizeId)
+	tags := s.tags(ctx, model.ScopeModelSize, modelSizeId)
+	return s.invalidate(ctx, s.repo.DeleteSizeFromProductModel(ctx, modelSizeId), tags)
 }
 
 func (s *OptionService) DeleteOptionFromProductModel(ctx context.Context, productModelOptionId int) fall.Error {
-	return s.repo.DeleteOptionFromProductModel(ctx, productModelOptionId)
+	tags := s.tags(ctx, model.ScopeModelOption, productModelOptionId)
+	return s.invalidate(ctx, s.repo.DeleteOptionFromProductModel(ctx, productModelOptionId), tags)
 }
 
 func (s *OptionService) AddOptionToProductModel(ctx context.Context, dto model.AddOptionToProductModelDto) fall.Error {
@@ -104,11 +155,13 @@ func (s *OptionService) AddOptionToProductModel(ctx context.Context, dto model.A
 		return err
 	}
 
-	return s.repo.AddOptionToProductModel(ctx, dto)
+	tags := s.tags(ctx, model.ScopeModel, dto.ProductModelId)
+	return s.invalidate(ctx, s.repo.AddOptionToProductModel(ctx, dto), tags)
 }
 
-func (s *OptionService) AddSizeToProductModel(ctx context.Context, dto model.AddSizeToProductModelDto) fall.Error {
-	return s.repo.AddSizeToProductModel(ctx, dto)
+func (s *OptionService) AddSizeToProductModel(ctx context.Context, userId int, dto model.AddSizeToProductModelDto) fall.Error {
+	tags := s.tags(ctx, model.ScopeModel, dto.ProductModelId)
+	return s.invalidate(ctx, s.repo.AddSizeToProductModel(ctx, userId, dto), tags)
 }
 
 func (s *OptionService) UpdateOption(ctx context.Context, dto model.UpdateOptionDto, id int) fall.Error {
@@ -127,9 +180,11 @@ func (s *OptionService) UpdateOption(ctx context.Context, dto model.UpdateOption
 			}
 		}
 	}
-	return s.repo.UpdateOption(ctx, dto, id)
+	tags := s.tags(ctx, model.ScopeOption, id)
+	return s.invalidate(ctx, s.repo.UpdateOption(ctx, dto, id), tags)
 }
 
 func (s *OptionService) UpdateOptionValue(ctx context.Context, dto model.UpdateOptionValueDto, id int) fall.Error {
-	return s.repo.UpdateOptionValue(ctx, dto, id)
+	tags := s.tags(ctx, model.ScopeValue, id)
+	return s.invalidate(ctx, s.repo.UpdateOptionValue(ctx, dto, id), tags)
 }
@@ -2,25 +2,34 @@ package service
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"errors"
+	"log"
 	"math"
 	"time"
 
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/payment"
 )
 
 type orderRepository interface {
 	Create(ctx context.Context, input model.CreateOrderInput, userId int) (*model.CreateOrderResponse, fall.Error)
-	GetAdminOrders(ctx context.Context, page int, fromDate *string, toDate *string) (*model.AllOrdersResponse, fall.Error)
+	GetAdminOrders(ctx context.Context, page pagination.Page, fromDate *string, toDate *string) (*model.AllOrdersResponse, fall.Error)
 	GetOrder(ctx context.Context, id string) (*model.Order, fall.Error)
 	GetUserOrders(ctx context.Context, userId int) ([]*model.Order, fall.Error)
 	CancelOrder(ctx context.Context, orderId string, userId int) fall.Error
-	ChangeStatus(ctx context.Context, orderId string, status model.OrderStatusEnum) fall.Error
+	ChangeStatus(ctx context.Context, orderId string, change model.OrderStatusChange) fall.Error
 	ChangeDeliveryDate(ctx context.Context, orderId string, date time.Time) fall.Error
-	SetPaymentId(ctx context.Context, paymentId string, orderId string) fall.Error
+	SetPayment(ctx context.Context, orderId string, paymentId string, paymentUrl string) fall.Error
+	RefundRemainder(ctx context.Context, orderId string) fall.Error
+	FindOrderIdByPaymentId(ctx context.Context, paymentId string) (*string, fall.Error)
+	ApplyPaymentEvent(ctx context.Context, event model.PaymentEvent) fall.Error
+	FindPendingPayments(ctx context.Context, olderThan time.Duration, limit int) ([]model.PendingPayment, fall.Error)
+	SendNewActivationLink(ctx context.Context, orderId string, user *model.LocalSession) (*int64, fall.Error)
+	ActivateOrder(ctx context.Context, link string) fall.Error
 }
 
 type orderUserService interface {
@@ -36,7 +45,22 @@ type orderDeliveryRepository interface {
 }
 
 type orderPaymentService interface {
-	CreatePayment(orderId string, totalPrice float64) (*payment.Payment, error)
+	CreatePayment(orderId string, totalPrice float64, idempotenceKey string) (*payment.Payment, error)
+	CheckPayment(paymentId string) (*payment.OrderPayment, error)
+	CheckRefund(refundId string) (*payment.RefundResponse, error)
+	CancelPayment(paymentId string, idempotenceKey string) (*payment.OrderPayment, error)
+}
+
+type orderOutboxService interface {
+	DispatchOne(ctx context.Context, id int64) bool
+}
+
+type orderReservationRepository interface {
+	FindExpiredOrders(ctx context.Context, limit int) ([]string, fall.Error)
+}
+
+type orderPromoService interface {
+	Apply(ctx context.Context, code string, userId int, items []*model.CartItemModel) (*model.AppliedPromoCode, fall.Error)
 }
 
 type orderWishService interface {
@@ -50,10 +74,14 @@ type OrderService struct {
 	deliveryRepo   orderDeliveryRepository
 	mailService    orderMailService
 	paymentService orderPaymentService
+	promoService   orderPromoService
+	outboxService  orderOutboxService
+	reservations   orderReservationRepository
 }
 
 func NewOrderService(repo orderRepository, wishService orderWishService, userService orderUserService,
-	deliveryRepo orderDeliveryRepository, mailService orderMailService, paymentService orderPaymentService) *OrderService {
+	deliveryRepo orderDeliveryRepository, mailService orderMailService, paymentService orderPaymentService,
+	promoService orderPromoService, outboxService orderOutboxService, reservations orderReservationRepository) *OrderService {
 	return &OrderService{
 		repo:           repo,
 		wishService:    wishService,
@@ -61,6 +89,9 @@ func NewOrderService(repo orderRepository, wishService orderWishService, userSer
 		deliveryRepo:   deliveryRepo,
 		mailService:    mailService,
 		paymentService: paymentService,
+		promoService:   promoService,
+		outboxService:  outboxService,
+		reservations:   reservations,
 	}
 }
 
@@ -68,15 +99,15 @@ func (s *OrderService) ChangeDeliveryDate(ctx context.Context, orderId string, d
 	return s.repo.ChangeDeliveryDate(ctx, orderId, date)
 }
 
-func (s *OrderService) ChangeStatus(ctx context.Context, orderId string, status model.OrderStatusEnum) fall.Error {
-	return s.repo.ChangeStatus(ctx, orderId, status)
+func (s *OrderService) ChangeStatus(ctx context.Context, orderId string, change model.OrderStatusChange) fall.Error {
+	return s.repo.ChangeStatus(ctx, orderId, change)
 }
 
 func (s *OrderService) CancelOrder(ctx context.Context, orderId string, userId int) fall.Error {
 	return s.repo.CancelOrder(ctx, orderId, userId)
 }
 
-func (s *OrderService) GetAdminOrders(ctx context.Context, page int, fromDate *string, toDate *string) (*model.AllOrdersResponse, fall.Error) {
+func (s *OrderService) GetAdminOrders(ctx context.Context, page pagination.Page, fromDate *string, toDate *string) (*model.AllOrdersResponse, fall.Error) {
 	return s.repo.GetAdminOrders(ctx, page, fromDate, toDate)
 }
 
@@ -123,13 +154,27 @@ func (s *OrderService) Create(ctx context.Context, dto model.CreateOrderDto, use
 	totalDiscount = math.Ceil(totalDiscount)
 	productsPrice = math.Ceil(productsPrice)
 
-	totalPrice := productsPrice - totalDiscount + deliveryPrice
+	var promoDiscount int = 0
+	var promoCodeId *int
+
+	if dto.PromoCode != nil && *dto.PromoCode != "" {
+		applied, ex := s.promoService.Apply(ctx, *dto.PromoCode, user.UserId, cartItems)
+		if ex != nil {
+			return nil, ex
+		}
+		promoDiscount = applied.Discount
+		promoCodeId = &applied.PromoCodeId
+	}
+
+	totalPrice := productsPrice - totalDiscount - float64(promoDiscount) + deliveryPrice
 
 	input := model.CreateOrderInput{
 		DeliveryPrice:      deliveryPrice,
 		TotalPrice:         totalPrice,
 		ProductsPrice:      productsPrice,
 		TotalDiscount:      totalDiscount,
+		PromoDiscount:      promoDiscount,
+		PromoCodeId:        promoCodeId,
 		RecipientFirstname: dto.RecipientFirstname,
 		RecipientLastname:  dto.RecipientLastname,
 		RecipientPhone:     dto.RecipientPhone,
@@ -137,6 +182,7 @@ func (s *OrderService) Create(ctx context.Context, dto model.CreateOrderDto, use
 		DeliveryPointId:    deliveryPoint.Id,
 		CartItems:          cartItems,
 		Conditions:         dto.Conditions,
+		Email:              user.Email,
 	}
 
 	resp, ex := s.repo.Create(ctx, input, user.UserId)
@@ -144,26 +190,109 @@ func (s *OrderService) Create(ctx context.Context, dto model.CreateOrderDto, use
 		return nil, ex
 	}
 
-	if dto.PaymentMethod == model.Online {
-		p, err := s.paymentService.CreatePayment(resp.Id, resp.Total)
-		if err != nil {
-			return nil, fall.ServerError("Ошибка при обработки платежа заказа №" + resp.Id)
-		}
-
-		ex := s.repo.SetPaymentId(ctx, p.ID, resp.Id)
+	// the payment is usually created right away, if the provider is unavailable
+	// the outbox worker retries and the link appears on the order later
+	dispatched := resp.OutboxId != nil && s.outboxService.DispatchOne(ctx, *resp.OutboxId)
 
+	if dto.PaymentMethod == model.Online && dispatched {
+		order, ex := s.repo.GetOrder(ctx, resp.Id)
 		if ex != nil {
 			return nil, ex
 		}
+		return order.PaymentUrl, nil
+	}
+
+	return nil, nil
+
+}
+
+// SendNewActivationLink gives an order paid upon receipt a new activation link and mails it,
+// the stock reservation is extended to match the link.
+func (s *OrderService) SendNewActivationLink(ctx context.Context, orderId string, user *model.LocalSession) fall.Error {
+	outboxId, ex := s.repo.SendNewActivationLink(ctx, orderId, user)
+	if ex != nil {
+		return ex
+	}
 
-		return &p.Confirmation.ConfirmationURL, nil
+	// the worker retries the email if it can't be sent right away
+	if outboxId != nil {
+		s.outboxService.DispatchOne(ctx, *outboxId)
 	}
+	return nil
+}
 
-	go s.mailService.SendOrderActivationEmail(user.Email, fmt.Sprintf("Подтверждение оформления заказа №: %s!", resp.Id),
-		fmt.Sprintf("/api/order/confirm/%s", resp.Id))
+func (s *OrderService) ActivateOrder(ctx context.Context, link string) fall.Error {
+	return s.repo.ActivateOrder(ctx, link)
+}
 
-	return nil, nil
+// ProcessCreatePayment is the outbox handler for model.TopicPaymentCreate.
+func (s *OrderService) ProcessCreatePayment(ctx context.Context, payload []byte) error {
+	var p model.PaymentCreatePayload
+	err := json.Unmarshal(payload, &p)
+	if err != nil {
+		return err
+	}
 
+	order, ex := s.repo.GetOrder(ctx, p.OrderId)
+	if ex != nil {
+		return errors.New(ex.Message())
+	}
+
+	if order.Status != model.WaitingForPayment || order.PaymentId != nil {
+		return nil
+	}
+
+	created, err := s.paymentService.CreatePayment(p.OrderId, p.Total, "order-"+p.OrderId)
+	if err != nil {
+		return err
+	}
+
+	ex = s.repo.SetPayment(ctx, p.OrderId, created.ID, created.Confirmation.ConfirmationURL)
+	if ex != nil {
+		return errors.New(ex.Message())
+	}
+
+	return nil
+}
+
+// ProcessCancelPayment is the outbox handler for model.TopicPaymentCancel.
+// A payment that succeeded while the order was being canceled is refunded instead.
+func (s *OrderService) ProcessCancelPayment(ctx context.Context, payload []byte) error {
+	var p model.PaymentCancelPayload
+	err := json.Unmarshal(payload, &p)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.paymentService.CheckPayment(p.PaymentId)
+	if err != nil {
+		return err
+	}
+
+	switch current.Status {
+	case payment.StatusCanceled:
+		return nil
+	case payment.StatusSucceeded:
+		ex := s.repo.RefundRemainder(ctx, p.OrderId)
+		if ex != nil {
+			return errors.New(ex.Message())
+		}
+		return nil
+	}
+
+	_, err = s.paymentService.CancelPayment(p.PaymentId, "cancel-"+p.PaymentId)
+	return err
+}
+
+// ProcessOrderEmail is the outbox handler for model.TopicOrderEmail.
+func (s *OrderService) ProcessOrderEmail(ctx context.Context, payload []byte) error {
+	var p model.OrderEmailPayload
+	err := json.Unmarshal(payload, &p)
+	if err != nil {
+		return err
+	}
+
+	return s.mailService.SendOrderActivationEmail(p.To, p.Subject, p.Link)
 }
 
 func (s *OrderService) ConfirmPayment(ctx context.Context, id string) fall.Error {
@@ -172,7 +301,17 @@ func (s *OrderService) ConfirmPayment(ctx context.Context, id string) fall.Error
 		return ex
 	}
 	if order.Status != model.Paid {
-		ex := s.repo.ChangeStatus(ctx, order.Id, model.Paid)
+		if order.PaymentId == nil {
+			return fall.NewErr(msg.OrderPaymentNotSucceeded, fall.STATUS_BAD_REQUEST)
+		}
+		p, err := s.paymentService.CheckPayment(*order.PaymentId)
+		if err != nil {
+			return fall.ServerError(err.Error())
+		}
+		if p.Status != payment.StatusSucceeded {
+			return fall.NewErr(msg.OrderPaymentNotSucceeded, fall.STATUS_BAD_REQUEST)
+		}
+		ex := s.repo.ChangeStatus(ctx, order.Id, model.OrderStatusChange{Status: model.Paid, ActorType: model.ActorPayment})
 		if ex != nil {
 			return ex
 		}
@@ -181,3 +320,136 @@ func (s *OrderService) ConfirmPayment(ctx context.Context, id string) fall.Error
 		return fall.NewErr(msg.OrderAlreadyPaid, fall.STATUS_BAD_REQUEST)
 	}
 }
+
+// HandlePaymentNotification never trusts the notification body: the payment (and refund)
+// are fetched from the provider again before the order status is changed.
+func (s *OrderService) HandlePaymentNotification(ctx context.Context, n payment.Notification) fall.Error {
+	paymentId := n.Object.ID
+
+	switch n.Event {
+	case payment.EventPaymentSucceeded, payment.EventPaymentCanceled:
+	case payment.EventRefundSucceeded:
+		refund, err := s.paymentService.CheckRefund(n.Object.ID)
+		if err != nil {
+			return fall.ServerError(err.Error())
+		}
+		if refund.Status != payment.StatusSucceeded {
+			return fall.NewErr(msg.OrderPaymentEventMismatch, fall.STATUS_BAD_REQUEST)
+		}
+		paymentId = refund.PaymentId
+	default:
+		return nil
+	}
+
+	p, err := s.paymentService.CheckPayment(paymentId)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	orderId, ex := s.repo.FindOrderIdByPaymentId(ctx, p.ID)
+	if ex != nil {
+		return ex
+	}
+
+	event, ok := paymentEvent(n.Event, n.Object.ID, p, *orderId)
+	if !ok {
+		return fall.NewErr(msg.OrderPaymentEventMismatch, fall.STATUS_BAD_REQUEST)
+	}
+
+	return s.repo.ApplyPaymentEvent(ctx, event)
+}
+
+// paymentEvent turns a payment fetched from the provider into the change of its order,
+// ok is false when the payment is not in the state the event claims.
+func paymentEvent(eventType string, objectId string, p *payment.OrderPayment, orderId string) (model.PaymentEvent, bool) {
+	event := model.PaymentEvent{Event: eventType, ObjectId: objectId, PaymentId: p.ID, OrderId: orderId}
+
+	switch eventType {
+	case payment.EventPaymentSucceeded:
+		if p.Status != payment.StatusSucceeded {
+			return event, false
+		}
+		event.Status = model.Paid
+		event.FromStatuses = []model.OrderStatusEnum{model.WaitingForPayment}
+	case payment.EventPaymentCanceled:
+		if p.Status != payment.StatusCanceled {
+			return event, false
+		}
+		event.Status = model.Canceled
+		event.FromStatuses = []model.OrderStatusEnum{model.WaitingForPayment}
+		event.ReturnStock = true
+	case payment.EventRefundSucceeded:
+		// partial refunds are only recorded, a full refund made outside of the shop cancels the order
+		if p.RefundedAmount != nil && p.RefundedAmount.Value == p.Amount.Value {
+			event.Status = model.Canceled
+			event.FromStatuses = []model.OrderStatusEnum{model.Paid, model.InProcessing, model.OnTheWay}
+			event.ReturnStock = true
+		}
+	}
+
+	return event, true
+}
+
+// CheckPendingPayments is the fallback for lost notifications: payments of orders that have waited longer
+// than olderThan are fetched from the provider, and the final ones are applied as if they were notified.
+// It returns the number of payments that reached a final status.
+func (s *OrderService) CheckPendingPayments(ctx context.Context, olderThan time.Duration, limit int) (int, fall.Error) {
+	pending, ex := s.repo.FindPendingPayments(ctx, olderThan, limit)
+	if ex != nil {
+		return 0, ex
+	}
+
+	resolved := 0
+
+	for _, item := range pending {
+		p, err := s.paymentService.CheckPayment(item.PaymentId)
+		if err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		var eventType string
+		switch p.Status {
+		case payment.StatusSucceeded:
+			eventType = payment.EventPaymentSucceeded
+		case payment.StatusCanceled:
+			eventType = payment.EventPaymentCanceled
+		default:
+			continue
+		}
+
+		// keyed like the notification, so whichever of the two comes second is skipped
+		event, _ := paymentEvent(eventType, p.ID, p, item.OrderId)
+
+		if ex := s.repo.ApplyPaymentEvent(ctx, event); ex != nil {
+			log.Println(ex.Message())
+			continue
+		}
+		resolved++
+	}
+
+	return resolved, nil
+}
+
+// CancelExpiredOrders cancels orders whose stock reservation expired before payment or activation
+// and returns the number of canceled orders.
+func (s *OrderService) CancelExpiredOrders(ctx context.Context, limit int) (int, fall.Error) {
+	orderIds, ex := s.reservations.FindExpiredOrders(ctx, limit)
+	if ex != nil {
+		return 0, ex
+	}
+
+	reason := msg.ReservationExpired
+	canceled := 0
+
+	for _, id := range orderIds {
+		ex := s.repo.ChangeStatus(ctx, id, model.OrderStatusChange{Status: model.Canceled, ActorType: model.ActorSystem, Reason: &reason})
+		if ex != nil {
+			log.Println(ex.Message())
+			continue
+		}
+		canceled++
+	}
+
+	return canceled, nil
+}
@@ -0,0 +1,182 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/repository"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/service"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+// The test runs against a migrated database named by TEST_DB_URL and is skipped without one.
+
+type stockTestCart struct {
+	modelSizeId int
+	modelId     int
+}
+
+func (c stockTestCart) FindModelInUserCart(ctx context.Context, modelSizeId int, userId int) (*model.CartItemModel, fall.Error) {
+	return &model.CartItemModel{UserId: userId, ModelSizeId: c.modelSizeId, ModelId: c.modelId, Price: 1000, Quantity: 1}, nil
+}
+
+type stockTestDelivery struct{}
+
+func (stockTestDelivery) FindById(ctx context.Context, id int) (*model.DeliveryPoint, fall.Error) {
+	return &model.DeliveryPoint{Id: id}, nil
+}
+
+type stockTestOutbox struct{}
+
+func (stockTestOutbox) DispatchOne(ctx context.Context, id int64) bool {
+	return false
+}
+
+func TestCreateOrderNeverOversells(t *testing.T) {
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+
+	const inStock = 3
+	const buyers = 20
+
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	var brandId, categoryId, productId, modelId, sizeId, modelSizeId, pointId int
+
+	fixture := []struct {
+		q    string
+		args []any
+		dest *int
+	}{
+		{"INSERT INTO brand (title, slug) VALUES ($1, $1) RETURNING brand_id;", []any{"stock-" + suffix}, &brandId},
+		{"INSERT INTO category (title, slug, short_title) VALUES ($1, $1, $1) RETURNING category_id;", []any{"stock-" + suffix}, &categoryId},
+		{"INSERT INTO product (title, category_id, brand_id) VALUES ('stock test', $1, $2) RETURNING product_id;",
+			[]any{&categoryId, &brandId}, &productId},
+		{`INSERT INTO product_model (price, slug, article, main_image_path, product_id)
+		VALUES (1000, $1, $2, '/storage/images/stock.jpg', $3) RETURNING product_model_id;`,
+			[]any{"stock-" + suffix, suffix[len(suffix)-12:], &productId}, &modelId},
+		{"INSERT INTO sizes (size_value) VALUES ('T') RETURNING size_id;", nil, &sizeId},
+		{`INSERT INTO model_sizes (product_model_id, size_id, literal_size, in_stock) VALUES ($1, $2, 'T', $3)
+		RETURNING model_size_id;`, []any{&modelId, &sizeId, inStock}, &modelSizeId},
+		{`INSERT INTO delivery_point (title, city, address, coords, with_fitting, work_schedule)
+		VALUES ('stock', 'stock', 'stock', '0,0', false, '24/7') RETURNING delivery_point_id;`, nil, &pointId},
+	}
+
+	for _, f := range fixture {
+		args := make([]any, len(f.args))
+		for i, a := range f.args {
+			if p, ok := a.(*int); ok {
+				a = *p
+			}
+			args[i] = a
+		}
+		if err := pool.QueryRow(ctx, f.q, args...).Scan(f.dest); err != nil {
+			t.Fatalf("fixture: %s", err)
+		}
+	}
+
+	users := make([]int, buyers)
+	for i := range users {
+		q := "INSERT INTO public.user (email, password_hash) VALUES ($1, 'x') RETURNING user_id;"
+		if err := pool.QueryRow(ctx, q, fmt.Sprintf("stock-%s-%d@test.local", suffix, i)).Scan(&users[i]); err != nil {
+			t.Fatalf("fixture: %s", err)
+		}
+	}
+
+	t.Cleanup(func() {
+		for _, q := range []string{
+			"DELETE FROM public.order WHERE user_id = ANY ($1);",
+			"DELETE FROM public.user WHERE user_id = ANY ($1);",
+		} {
+			if _, err := pool.Exec(ctx, q, users); err != nil {
+				t.Logf("cleanup: %s", err)
+			}
+		}
+		for _, c := range []struct {
+			q  string
+			id int
+		}{
+			{"DELETE FROM brand WHERE brand_id = $1;", brandId},
+			{"DELETE FROM category WHERE category_id = $1;", categoryId},
+			{"DELETE FROM sizes WHERE size_id = $1;", sizeId},
+			{"DELETE FROM delivery_point WHERE delivery_point_id = $1;", pointId},
+		} {
+			if _, err := pool.Exec(ctx, c.q, c.id); err != nil {
+				t.Logf("cleanup: %s", err)
+			}
+		}
+	})
+
+	productRepo := repository.NewProductRepository(pool)
+	outboxRepo := repository.NewOutboxRepository(pool)
+	reservationRepo := repository.NewReservationRepository(pool, productRepo)
+	returnRepo := repository.NewReturnRepository(pool, productRepo, outboxRepo)
+	orderRepo := repository.NewOrderRepository(pool, repository.NewWishRepository(pool), productRepo,
+		repository.NewPromoRepository(pool), returnRepo, outboxRepo, reservationRepo)
+
+	orders := service.NewOrderService(orderRepo, stockTestCart{modelSizeId: modelSizeId, modelId: modelId}, nil,
+		stockTestDelivery{}, nil, nil, nil, stockTestOutbox{}, reservationRepo)
+
+	dto := model.CreateOrderDto{
+		PaymentMethod:      model.UponReceipt,
+		DeliveryPointId:    pointId,
+		Conditions:         model.WithoutFitting,
+		RecipientFirstname: "Test",
+		RecipientLastname:  "Test",
+		RecipientPhone:     "+79990000000",
+		ModelSizeIds:       []int{modelSizeId},
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	start := make(chan struct{})
+
+	for _, userId := range users {
+		wg.Add(1)
+		go func(userId int) {
+			defer wg.Done()
+			<-start
+			_, ex := orders.Create(ctx, dto, &model.LocalSession{UserId: userId, Email: "stock@test.local"})
+			if ex != nil {
+				if ex.Status() != fall.STATUS_CONFLICT {
+					t.Errorf("unexpected error: %s", ex.Message())
+				}
+				return
+			}
+			mu.Lock()
+			succeeded++
+			mu.Unlock()
+		}(userId)
+	}
+
+	close(start)
+	wg.Wait()
+
+	if succeeded != inStock {
+		t.Errorf("%d orders succeeded, want %d", succeeded, inStock)
+	}
+
+	var left int
+	if err := pool.QueryRow(ctx, "SELECT in_stock FROM model_sizes WHERE model_size_id = $1;", modelSizeId).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Errorf("in_stock is %d, want 0", left)
+	}
+}
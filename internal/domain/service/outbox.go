@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+const (
+	outboxMaxAttempts = 10
+	outboxBaseDelay   = 30 * time.Second
+	outboxMaxDelay    = time.Hour
+)
+
+type outboxRepository interface {
+	Claim(ctx context.Context, limit int) ([]model.OutboxMessage, fall.Error)
+	ClaimById(ctx context.Context, id int64) (*model.OutboxMessage, fall.Error)
+	Complete(ctx context.Context, id int64) fall.Error
+	Retry(ctx context.Context, id int64, nextAttempt *time.Time, lastError string) fall.Error
+}
+
+type OutboxHandler func(ctx context.Context, payload []byte) error
+
+type OutboxService struct {
+	repo     outboxRepository
+	handlers map[string]OutboxHandler
+}
+
+func NewOutboxService(repo outboxRepository) *OutboxService {
+	return &OutboxService{repo: repo, handlers: make(map[string]OutboxHandler)}
+}
+
+func (s *OutboxService) Register(topic string, handler OutboxHandler) {
+	s.handlers[topic] = handler
+}
+
+// Dispatch processes up to limit due messages and returns how many of them succeeded.
+func (s *OutboxService) Dispatch(ctx context.Context, limit int) (int, fall.Error) {
+	messages, ex := s.repo.Claim(ctx, limit)
+	if ex != nil {
+		return 0, ex
+	}
+
+	done := 0
+	for _, m := range messages {
+		if s.process(ctx, m) {
+			done++
+		}
+	}
+
+	return done, nil
+}
+
+// DispatchOne tries to process a message right after its transaction has committed,
+// the worker picks it up later if this fails. It reports whether the message succeeded.
+func (s *OutboxService) DispatchOne(ctx context.Context, id int64) bool {
+	m, ex := s.repo.ClaimById(ctx, id)
+	if ex != nil {
+		log.Println(ex.Message())
+		return false
+	}
+	if m == nil {
+		return false
+	}
+	return s.process(ctx, *m)
+}
+
+func (s *OutboxService) process(ctx context.Context, m model.OutboxMessage) bool {
+	var err error
+
+	handler, ok := s.handlers[m.Topic]
+	if !ok {
+		err = fmt.Errorf("no handler for outbox topic %s", m.Topic)
+	} else {
+		err = handler(ctx, m.Payload)
+	}
+
+	if err == nil {
+		ex := s.repo.Complete(ctx, m.Id)
+		if ex != nil {
+			log.Println(ex.Message())
+		}
+		return true
+	}
+
+	var next *time.Time
+	if m.Attempts < outboxMaxAttempts {
+		t := time.Now().Add(outboxBackoff(m.Attempts))
+		next = &t
+	} else {
+		log.Printf("outbox message %d (%s) moved to dead letter: %s", m.Id, m.Topic, err.Error())
+	}
+
+	ex := s.repo.Retry(ctx, m.Id, next, err.Error())
+	if ex != nil {
+		log.Println(ex.Message())
+	}
+	return false
+}
+
+func outboxBackoff(attempts int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= outboxMaxDelay {
+			return outboxMaxDelay
+		}
+	}
+	return delay
+}
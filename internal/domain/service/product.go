@@ -3,31 +3,36 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
 
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/cache"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/generator"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/utils"
 )
 
 type productRepository interface {
 	FindModelsColored(ctx context.Context, id int) ([]model.ProductModelColors, fall.Error)
 	GetProductPage(ctx context.Context, slug string) (*model.ProductRelation, fall.Error)
-	RemovePhoto(ctx context.Context, photoId int) fall.Error
+	RemovePhoto(ctx context.Context, photoId int) (int, fall.Error)
 	AddPhoto(ctx context.Context, dto model.CreateProducModelImg) fall.Error
 	FindProductModelById(ctx context.Context, id int) (*model.ProductModel, fall.Error)
 	FindProductModelBySlug(ctx context.Context, slug string) (*model.ProductModel, fall.Error)
 	FindProductById(ctx context.Context, id int) (*model.Product, fall.Error)
 	UpdateProductModel(ctx context.Context, dto model.UpdateProductModelDto, modelId int) fall.Error
-	UpdateProduct(ctx context.Context, dto model.UpdateProductDto, id int) fall.Error
+	UpdateProduct(ctx context.Context, dto model.UpdateProductDto, id int, modelSlugs map[int]string) fall.Error
 	DeleteProductModel(ctx context.Context, id int) fall.Error
 	DeleteProduct(ctx context.Context, id int) fall.Error
-	CreateModel(ctx context.Context, dto model.CreateProductModelDto, slug string) fall.Error
+	CreateModel(ctx context.Context, dto model.CreateProductModelDto, article string, slug string) fall.Error
 	CreateProduct(ctx context.Context, dto model.CreateProductDto) fall.Error
 	AdminGetProducts(ctx context.Context, page int, brandId *int, categoryId *int) (*model.AdminProductResponse, fall.Error)
 	AdminGetProductModels(ctx context.Context, id int) ([]model.ProductModel, fall.Error)
-	GetCatalogModels(ctx context.Context, categorySlug string, sql generator.GeneratedCatalogQuery) (*model.CatalogResponse, fall.Error)
+	GetCatalogModels(ctx context.Context, sql generator.GeneratedCatalogQuery) (*model.CatalogResponse, fall.Error)
 	GetModelImages(ctx context.Context, modelId int) ([]model.ProductModelImg, fall.Error)
 	GetModelSizes(ctx context.Context, modelId int) ([]model.ProductModelSize, fall.Error)
 	GetModelOptions(ctx context.Context, modelId int) ([]*model.ProductModelOption, fall.Error)
@@ -39,35 +44,101 @@ type productRepository interface {
 	GetViewHistory(ctx context.Context, userId int, modelId int) ([]*model.CatalogProductModel, fall.Error)
 	AddToViewHistory(ctx context.Context, userId int, modelId int) fall.Error
 	GetPopularProducts(ctx context.Context, slug string) ([]*model.CatalogProductModel, fall.Error)
-	Search(ctx context.Context, term string) ([]model.SearchProductModel, fall.Error)
+	Search(ctx context.Context, term string, page pagination.Page) (*model.SearchResponse, fall.Error)
 }
 
 type productCategoryService interface {
 	FindById(ctx context.Context, id int) (*model.CategoryModel, fall.Error)
 	GetParentSubLevel(ctx context.Context, id int) (*model.CategoryModel, fall.Error)
 	CheckForChildren(ctx context.Context, id int) (*int, fall.Error)
+	GetPathSlugs(ctx context.Context, id int) ([]string, fall.Error)
 }
 
 type productBrandService interface {
 	FindById(ctx context.Context, id int) (*model.Brand, fall.Error)
 }
 
+type productImages interface {
+	Normalize(ctx context.Context, p string) (string, fall.Error)
+}
+
+type productSearchLogger interface {
+	LogQuery(ctx context.Context, query string, results int)
+}
+
 type ProductService struct {
 	repo            productRepository
 	brandService    productBrandService
 	categoryService productCategoryService
+	searchLogger    productSearchLogger
+	slugs           slugRepository
+	images          productImages
+	cache           cache.Cache
 }
 
-func NewProductService(repo productRepository, brandService productBrandService, categoryService productCategoryService) *ProductService {
+func NewProductService(repo productRepository, brandService productBrandService, categoryService productCategoryService,
+	searchLogger productSearchLogger, slugs slugRepository, images productImages, cache cache.Cache) *ProductService {
 	return &ProductService{
 		repo:            repo,
 		brandService:    brandService,
 		categoryService: categoryService,
+		searchLogger:    searchLogger,
+		slugs:           slugs,
+		images:          images,
+		cache:           cache,
+	}
+}
+
+// categoryTags tags catalog entries of the category and all of its parents, their listings include its products.
+func (s *ProductService) categoryTags(ctx context.Context, categoryId int) []string {
+	slugs, ex := s.categoryService.GetPathSlugs(ctx, categoryId)
+	if ex != nil {
+		return []string{cache.TagCatalog}
+	}
+	tags := make([]string, 0, len(slugs))
+	for _, slug := range slugs {
+		tags = append(tags, cache.CategoryTag(slug))
+	}
+	return tags
+}
+
+// productTags collects what a write to the product touches: pages of all its models and its category path.
+// It runs before the write, while the models and the category are still the ones the cache was built from.
+func (s *ProductService) productTags(ctx context.Context, productId int) []string {
+	p, ex := s.repo.FindProductById(ctx, productId)
+	if ex != nil {
+		return []string{cache.TagCatalog}
+	}
+	models, ex := s.repo.AdminGetProductModels(ctx, productId)
+	if ex != nil {
+		return []string{cache.TagCatalog}
+	}
+
+	tags := s.categoryTags(ctx, p.Category.Id)
+	for _, m := range models {
+		tags = append(tags, cache.ModelTag(m.Id))
+	}
+	return tags
+}
+
+func (s *ProductService) invalidate(ctx context.Context, ex fall.Error, tags []string) fall.Error {
+	if ex == nil {
+		s.cache.Invalidate(ctx, tags...)
 	}
+	return ex
 }
 
-func (s *ProductService) Search(ctx context.Context, term string) ([]model.SearchProductModel, fall.Error) {
-	return s.repo.Search(ctx, term)
+func (s *ProductService) Search(ctx context.Context, term string, page pagination.Page) (*model.SearchResponse, fall.Error) {
+	res, ex := s.repo.Search(ctx, term, page)
+	if ex != nil {
+		return nil, ex
+	}
+
+	if page.Cursor == nil {
+		s.searchLogger.LogQuery(ctx, term, len(res.Models))
+	}
+
+	return res, nil
 }
 
 func (s *ProductService) GetPopularProducts(ctx context.Context, slug string) ([]*model.CatalogProductModel, fall.Error) {
@@ -141,7 +212,8 @@ func (s *ProductService) CreateProduct(ctx context.Context, dto model.CreateProd
 	if ex != nil {
 		return ex
 	}
-	return s.repo.CreateProduct(ctx, dto)
+	tags := s.categoryTags(ctx, cat.Id)
+	return s.invalidate(ctx, s.repo.CreateProduct(ctx, dto), tags)
 }
 
 func (s *ProductService) CreateModel(ctx context.Context, dto model.CreateProductModelDto) fall.Error {
@@ -151,24 +223,79 @@ func (s *ProductService) CreateModel(ctx context.Context, dto model.CreateProduc
 		return ex
 	}
 
-	categoryParent, ex := s.categoryService.GetParentSubLevel(ctx, p.Category.Id)
+	base, ex := s.modelSlug(ctx, p, p.Title)
+	if ex != nil {
+		return ex
+	}
+
+	article := newArticle()
+
+	slug, ex := s.slugs.Unique(ctx, model.SlugModel, fmt.Sprintf("%s-%s", base, article), 0)
+	if ex != nil {
+		return ex
+	}
+
+	dto.ImagePath, ex = s.images.Normalize(ctx, dto.ImagePath)
 	if ex != nil {
 		return ex
 	}
 
-	slug := utils.GenerateSlug(fmt.Sprintf("%s-%s-%s", categoryParent.Slug, p.Brand.Slug, p.Title))
+	tags := s.categoryTags(ctx, p.Category.Id)
+	return s.invalidate(ctx, s.repo.CreateModel(ctx, dto, article, slug), tags)
+}
 
-	m, _ := s.repo.FindProductModelBySlug(ctx, slug)
+// newArticle makes the 12 character article of a new model.
+func newArticle() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")[:12]
+}
 
-	if m != nil {
-		return fall.NewErr(msg.ProductModelSlugUnique, fall.STATUS_BAD_REQUEST)
+// modelSlug is the slug of the product models without the article, which follows it after a dash.
+func (s *ProductService) modelSlug(ctx context.Context, p *model.Product, title string) (string, fall.Error) {
+	categoryParent, ex := s.categoryService.GetParentSubLevel(ctx, p.Category.Id)
+	if ex != nil {
+		return "", ex
 	}
 
-	return s.repo.CreateModel(ctx, dto, slug)
+	return utils.GenerateSlug(fmt.Sprintf("%s-%s-%s", categoryParent.Slug, p.Brand.Slug, title)), nil
 }
 
+// FindRedirect finds where a retired model slug has moved.
+func (s *ProductService) FindRedirect(ctx context.Context, slug string) (*model.SlugRedirect, fall.Error) {
+	return findSlugRedirect(ctx, s.slugs, model.SlugModel, slug, model.ProductPagePath)
+}
+
+// GetProductPage caches the page without trusting its stock: orders, returns and imports change it all the time,
+// so the sizes are filled with the current stock on every request.
 func (s *ProductService) GetProductPage(ctx context.Context, slug string) (*model.ProductRelation, fall.Error) {
-	return s.repo.GetProductPage(ctx, slug)
+	page, ex := cache.Load(ctx, s.cache, "product:page:"+slug, func() (*model.ProductRelation, []string, fall.Error) {
+		page, ex := s.repo.GetProductPage(ctx, slug)
+		if ex != nil {
+			return nil, nil, ex
+		}
+		tags := []string{cache.TagCatalog, cache.ProductTag(page.Id), cache.BrandTag(page.Brand.Id), cache.CategoryTag(page.Category.Slug)}
+		if page.CurrentModel.Id != nil {
+			tags = append(tags, cache.ModelTag(*page.CurrentModel.Id))
+		}
+		return page, tags, nil
+	})
+	if ex != nil || page.CurrentModel.Id == nil {
+		return page, ex
+	}
+
+	sizes, ex := s.repo.GetModelSizes(ctx, *page.CurrentModel.Id)
+	if ex != nil {
+		return nil, ex
+	}
+
+	stock := make(map[int]int, len(sizes))
+	for _, size := range sizes {
+		stock[size.SizeModelId] = size.InStock
+	}
+	for i, size := range page.CurrentModel.Sizes {
+		page.CurrentModel.Sizes[i].InStock = stock[size.SizeModelId]
+	}
+
+	return page, nil
 }
 
 func (s *ProductService) FindProductById(ctx context.Context, id int) (*model.Product, fall.Error) {
@@ -180,23 +307,42 @@ func (s *ProductService) FindProductModelById(ctx context.Context, id int) (*mod
 }
 
 func (s *ProductService) AddPhoto(ctx context.Context, dto model.CreateProducModelImg) fall.Error {
-	_, ex := s.FindProductModelById(ctx, dto.ProductModelId)
+	m, ex := s.FindProductModelById(ctx, dto.ProductModelId)
+	if ex != nil {
+		return ex
+	}
+
+	dto.ImgPath, ex = s.images.Normalize(ctx, dto.ImgPath)
 	if ex != nil {
 		return ex
 	}
-	return s.repo.AddPhoto(ctx, dto)
+
+	tags := s.productTags(ctx, m.ProductId)
+	return s.invalidate(ctx, s.repo.AddPhoto(ctx, dto), tags)
 }
 
 func (s *ProductService) RemovePhoto(ctx context.Context, photoId int) fall.Error {
-	return s.repo.RemovePhoto(ctx, photoId)
+	modelId, ex := s.repo.RemovePhoto(ctx, photoId)
+	if ex != nil {
+		return ex
+	}
+	if m, _ := s.FindProductModelById(ctx, modelId); m != nil {
+		s.cache.Invalidate(ctx, s.productTags(ctx, m.ProductId)...)
+	}
+	return nil
 }
 
 func (s *ProductService) DeleteProduct(ctx context.Context, id int) fall.Error {
-	return s.repo.DeleteProduct(ctx, id)
+	tags := s.productTags(ctx, id)
+	return s.invalidate(ctx, s.repo.DeleteProduct(ctx, id), tags)
 }
 
 func (s *ProductService) DeleteProductModel(ctx context.Context, id int) fall.Error {
-	return s.repo.DeleteProductModel(ctx, id)
+	tags := []string{cache.TagCatalog}
+	if m, _ := s.FindProductModelById(ctx, id); m != nil {
+		tags = s.productTags(ctx, m.ProductId)
+	}
+	return s.invalidate(ctx, s.repo.DeleteProductModel(ctx, id), tags)
 }
 
 func (s *ProductService) UpdateProduct(ctx context.Context, dto model.UpdateProductDto, id int) fall.Error {
@@ -206,7 +352,44 @@ func (s *ProductService) UpdateProduct(ctx context.Context, dto model.UpdateProd
 		return ex
 	}
 
-	return s.repo.UpdateProduct(ctx, dto, p.Id)
+	tags := s.productTags(ctx, p.Id)
+
+	var modelSlugs map[int]string
+	if dto.Title != nil && *dto.Title != p.Title {
+		modelSlugs, ex = s.renamedModelSlugs(ctx, p, *dto.Title)
+		if ex != nil {
+			return ex
+		}
+	}
+
+	return s.invalidate(ctx, s.repo.UpdateProduct(ctx, dto, p.Id, modelSlugs), tags)
+}
+
+// renamedModelSlugs returns the slugs built from the new title for the models whose slug changes,
+// the old ones are kept in the slug history and redirect.
+func (s *ProductService) renamedModelSlugs(ctx context.Context, p *model.Product, title string) (map[int]string, fall.Error) {
+	base, ex := s.modelSlug(ctx, p, title)
+	if ex != nil {
+		return nil, ex
+	}
+
+	models, ex := s.repo.AdminGetProductModels(ctx, p.Id)
+	if ex != nil {
+		return nil, ex
+	}
+
+	slugs := make(map[int]string, len(models))
+	for _, m := range models {
+		slug, ex := s.slugs.Unique(ctx, model.SlugModel, fmt.Sprintf("%s-%s", base, m.Article), m.Id)
+		if ex != nil {
+			return nil, ex
+		}
+		if slug != m.Slug {
+			slugs[m.Id] = slug
+		}
+	}
+
+	return slugs, nil
 }
 
 func (s *ProductService) UpdateProductModel(ctx context.Context, dto model.UpdateProductModelDto, id int) fall.Error {
@@ -215,7 +398,16 @@ func (s *ProductService) UpdateProductModel(ctx context.Context, dto model.Updat
 		return ex
 	}
 
-	return s.repo.UpdateProductModel(ctx, dto, m.Id)
+	if dto.ImagePath != nil {
+		imagePath, ex := s.images.Normalize(ctx, *dto.ImagePath)
+		if ex != nil {
+			return ex
+		}
+		dto.ImagePath = &imagePath
+	}
+
+	tags := s.productTags(ctx, m.ProductId)
+	return s.invalidate(ctx, s.repo.UpdateProductModel(ctx, dto, m.Id), tags)
 }
 
 func (s *ProductService) FindModelsColored(ctx context.Context, id int) ([]model.ProductModelColors, fall.Error) {
@@ -226,11 +418,11 @@ func (s *ProductService) AdminGetProducts(ctx context.Context, page int, brandId
 	return s.repo.AdminGetProducts(ctx, page, brandId, categoryId)
 }
 
-func (ps *ProductService) GetCatalogModels(ctx context.Context, query generator.CatalogFilters) (*model.CatalogResponse, fall.Error) {
+func (ps *ProductService) GetCatalogModels(ctx context.Context, params generator.CatalogParams) (*model.CatalogResponse, fall.Error) {
 
-	sql := generator.GenerateCatalogQuery(query)
+	sql := generator.GenerateCatalogQuery(params)
 
-	return ps.repo.GetCatalogModels(ctx, query.Slug, sql)
+	return ps.repo.GetCatalogModels(ctx, sql)
 }
 
 func (s *ProductService) UpdateViews(ctx context.Context, ip string, modelId int) {
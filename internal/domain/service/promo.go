@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type promoRepository interface {
+	Create(ctx context.Context, dto model.CreatePromoCodeDto) fall.Error
+	FindById(ctx context.Context, id int) (*model.PromoCode, fall.Error)
+	FindByCode(ctx context.Context, code string) (*model.PromoCode, fall.Error)
+	GetAll(ctx context.Context) ([]model.PromoCode, fall.Error)
+	Update(ctx context.Context, dto model.UpdatePromoCodeDto, id int) fall.Error
+	Delete(ctx context.Context, id int) fall.Error
+	CountUserRedemptions(ctx context.Context, id int, userId int) (int, fall.Error)
+	FindApplicableModels(ctx context.Context, id int, modelIds []int) (map[int]bool, fall.Error)
+}
+
+type PromoService struct {
+	repo promoRepository
+}
+
+func NewPromoService(repo promoRepository) *PromoService {
+	return &PromoService{repo: repo}
+}
+
+func (s *PromoService) Create(ctx context.Context, dto model.CreatePromoCodeDto) fall.Error {
+	p, _ := s.repo.FindByCode(ctx, dto.Code)
+	if p != nil {
+		return fall.NewErr(msg.PromoCodeExists, fall.STATUS_BAD_REQUEST)
+	}
+
+	if dto.DiscountType == model.PromoPercent && dto.DiscountValue > 100 {
+		return fall.NewErr(msg.PromoCodeInvalidPercent, fall.STATUS_BAD_REQUEST)
+	}
+
+	if dto.StartDate != nil && !dto.EndDate.After(*dto.StartDate) {
+		return fall.NewErr(msg.PromoCodeInvalidDates, fall.STATUS_BAD_REQUEST)
+	}
+
+	return s.repo.Create(ctx, dto)
+}
+
+func (s *PromoService) Update(ctx context.Context, dto model.UpdatePromoCodeDto, id int) fall.Error {
+	current, ex := s.repo.FindById(ctx, id)
+	if ex != nil {
+		return ex
+	}
+
+	discountType := current.DiscountType
+	if dto.DiscountType != nil {
+		discountType = *dto.DiscountType
+	}
+	discountValue := current.DiscountValue
+	if dto.DiscountValue != nil {
+		discountValue = *dto.DiscountValue
+	}
+	if discountType == model.PromoPercent && discountValue > 100 {
+		return fall.NewErr(msg.PromoCodeInvalidPercent, fall.STATUS_BAD_REQUEST)
+	}
+
+	startDate := current.StartDate
+	if dto.StartDate != nil {
+		startDate = *dto.StartDate
+	}
+	endDate := current.EndDate
+	if dto.EndDate != nil {
+		endDate = *dto.EndDate
+	}
+	if !endDate.After(startDate) {
+		return fall.NewErr(msg.PromoCodeInvalidDates, fall.STATUS_BAD_REQUEST)
+	}
+
+	return s.repo.Update(ctx, dto, current.Id)
+}
+
+func (s *PromoService) FindById(ctx context.Context, id int) (*model.PromoCode, fall.Error) {
+	return s.repo.FindById(ctx, id)
+}
+
+func (s *PromoService) GetAll(ctx context.Context) ([]model.PromoCode, fall.Error) {
+	return s.repo.GetAll(ctx)
+}
+
+func (s *PromoService) Delete(ctx context.Context, id int) fall.Error {
+	return s.repo.Delete(ctx, id)
+}
+
+// Apply validates the promo code against the user and the cart and calculates the discount.
+// Usage limits are checked here only to fail fast, they are enforced again when the order is saved.
+func (s *PromoService) Apply(ctx context.Context, code string, userId int, items []*model.CartItemModel) (*model.AppliedPromoCode, fall.Error) {
+	p, ex := s.repo.FindByCode(ctx, code)
+	if ex != nil {
+		return nil, ex
+	}
+
+	if !p.IsActivated {
+		return nil, fall.NewErr(msg.PromoCodeNotActive, fall.STATUS_BAD_REQUEST)
+	}
+
+	now := time.Now()
+
+	if now.Before(p.StartDate) {
+		return nil, fall.NewErr(msg.PromoCodeNotStarted, fall.STATUS_BAD_REQUEST)
+	}
+
+	if now.After(p.EndDate) {
+		return nil, fall.NewErr(msg.PromoCodeExpired, fall.STATUS_BAD_REQUEST)
+	}
+
+	if p.UsageLimit != nil && p.UsedCount >= *p.UsageLimit {
+		return nil, fall.NewErr(msg.PromoCodeUsageLimit, fall.STATUS_BAD_REQUEST)
+	}
+
+	if p.UsageLimitPerUser != nil {
+		count, ex := s.repo.CountUserRedemptions(ctx, p.Id, userId)
+		if ex != nil {
+			return nil, ex
+		}
+		if count >= *p.UsageLimitPerUser {
+			return nil, fall.NewErr(msg.PromoCodeUserUsageLimit, fall.STATUS_BAD_REQUEST)
+		}
+	}
+
+	modelIds := make([]int, 0, len(items))
+	for _, item := range items {
+		modelIds = append(modelIds, item.ModelId)
+	}
+
+	applicable, ex := s.repo.FindApplicableModels(ctx, p.Id, modelIds)
+	if ex != nil {
+		return nil, ex
+	}
+
+	var basketPrice float64 = 0
+	var applicablePrice float64 = 0
+
+	for _, item := range items {
+		price := itemPriceWithDiscount(item)
+		basketPrice += price
+		if applicable[item.ModelId] {
+			applicablePrice += price
+		}
+	}
+
+	if basketPrice < float64(p.MinBasketPrice) {
+		return nil, fall.NewErr(msg.PromoCodeMinBasketPrice, fall.STATUS_BAD_REQUEST)
+	}
+
+	if applicablePrice <= 0 {
+		return nil, fall.NewErr(msg.PromoCodeNotApplicable, fall.STATUS_BAD_REQUEST)
+	}
+
+	var discount float64
+
+	switch p.DiscountType {
+	case model.PromoPercent:
+		discount = applicablePrice / 100 * float64(p.DiscountValue)
+	default:
+		discount = math.Min(float64(p.DiscountValue), applicablePrice)
+	}
+
+	return &model.AppliedPromoCode{PromoCodeId: p.Id, Code: p.Code, Discount: int(math.Floor(discount))}, nil
+}
+
+func itemPriceWithDiscount(item *model.CartItemModel) float64 {
+	price := float64(item.Price) * float64(item.Quantity)
+	if item.Discount != nil {
+		price -= (float64(item.Price) / 100) * float64(*item.Discount) * float64(item.Quantity)
+	}
+	return price
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/payment"
+)
+
+type returnRepository interface {
+	Create(ctx context.Context, dto model.CreateOrderReturnDto, orderId string, userId int) (*int, fall.Error)
+	Approve(ctx context.Context, id int, comment *string) fall.Error
+	Reject(ctx context.Context, id int, comment *string) fall.Error
+	FindById(ctx context.Context, id int) (*model.OrderReturn, fall.Error)
+	GetAll(ctx context.Context, status *model.OrderReturnStatus) ([]model.OrderReturn, fall.Error)
+	FinishRefund(ctx context.Context, id int, paymentRefundId string, status string) fall.Error
+}
+
+type returnPaymentService interface {
+	RefundPayment(paymentId string, totalPrice float64, idempotenceKey string) (*payment.RefundResponse, error)
+}
+
+type ReturnService struct {
+	repo           returnRepository
+	paymentService returnPaymentService
+}
+
+func NewReturnService(repo returnRepository, paymentService returnPaymentService) *ReturnService {
+	return &ReturnService{repo: repo, paymentService: paymentService}
+}
+
+func (s *ReturnService) Create(ctx context.Context, dto model.CreateOrderReturnDto, orderId string, userId int) (*int, fall.Error) {
+	seen := make(map[int]bool, len(dto.Models))
+	for _, m := range dto.Models {
+		if seen[m.OrderModelId] {
+			return nil, fall.NewErr(msg.ReturnDuplicateOrderModel, fall.STATUS_BAD_REQUEST)
+		}
+		seen[m.OrderModelId] = true
+	}
+
+	return s.repo.Create(ctx, dto, orderId, userId)
+}
+
+func (s *ReturnService) Approve(ctx context.Context, id int, comment *string) fall.Error {
+	return s.repo.Approve(ctx, id, comment)
+}
+
+func (s *ReturnService) Reject(ctx context.Context, id int, comment *string) fall.Error {
+	return s.repo.Reject(ctx, id, comment)
+}
+
+func (s *ReturnService) FindById(ctx context.Context, id int) (*model.OrderReturn, fall.Error) {
+	return s.repo.FindById(ctx, id)
+}
+
+func (s *ReturnService) GetAll(ctx context.Context, status *model.OrderReturnStatus) ([]model.OrderReturn, fall.Error) {
+	return s.repo.GetAll(ctx, status)
+}
+
+// ProcessRefund is the outbox handler for model.TopicPaymentRefund.
+func (s *ReturnService) ProcessRefund(ctx context.Context, payload []byte) error {
+	var p model.PaymentRefundPayload
+	err := json.Unmarshal(payload, &p)
+	if err != nil {
+		return err
+	}
+
+	refund, err := s.paymentService.RefundPayment(p.PaymentId, p.Amount, fmt.Sprintf("refund-%d", p.RefundId))
+	if err != nil {
+		return err
+	}
+
+	if refund.Status == payment.StatusCanceled && refund.RefundDetails != nil {
+		log.Printf("refund %d canceled. Party: %s;Reason: %s.", p.RefundId, refund.RefundDetails.Party, refund.RefundDetails.Reason)
+	}
+
+	ex := s.repo.FinishRefund(ctx, p.RefundId, refund.Id, refund.Status)
+	if ex != nil {
+		return errors.New(ex.Message())
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/keyboard"
+)
+
+const (
+	suggestLimit       = 5
+	maxLoggedQueryRune = 100
+)
+
+type searchRepository interface {
+	Suggest(ctx context.Context, term string, limit int) (*model.Suggestions, fall.Error)
+	LogQuery(ctx context.Context, query string, results int) fall.Error
+}
+
+type SearchService struct {
+	repo searchRepository
+}
+
+func NewSearchService(repo searchRepository) *SearchService {
+	return &SearchService{repo: repo}
+}
+
+// Suggest tries the term as typed first, then its retyped and transliterated forms,
+// and returns the first variant that has any hits.
+func (s *SearchService) Suggest(ctx context.Context, term string) (*model.Suggestions, fall.Error) {
+	variants := keyboard.Variants(term)
+
+	var first *model.Suggestions
+
+	for i, variant := range variants {
+		suggestions, ex := s.repo.Suggest(ctx, variant, suggestLimit)
+		if ex != nil {
+			return nil, ex
+		}
+		if first == nil {
+			first = suggestions
+		}
+		if suggestions.Empty() {
+			continue
+		}
+		if i > 0 {
+			corrected := variant
+			suggestions.Corrected = &corrected
+		}
+		return suggestions, nil
+	}
+
+	if first == nil {
+		first = &model.Suggestions{Brands: []model.SuggestItem{}, Categories: []model.SuggestItem{},
+			Products: []model.SuggestItem{}, Queries: []model.SuggestQuery{}}
+	}
+
+	return first, nil
+}
+
+// LogQuery records a search so popular queries can be suggested, failures are only logged.
+func (s *SearchService) LogQuery(ctx context.Context, query string, results int) {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if query == "" {
+		return
+	}
+	if runes := []rune(query); len(runes) > maxLoggedQueryRune {
+		query = string(runes[:maxLoggedQueryRune])
+	}
+
+	ex := s.repo.LogQuery(ctx, query, results)
+	if ex != nil {
+		log.Println(ex.Message())
+	}
+}
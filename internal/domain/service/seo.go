@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/sitemap"
+)
+
+const (
+	sitemapFolder = "sitemap"
+	sitemapIndex  = "sitemap.xml"
+	// sitemapRoute is where the storefront proxies the files from, relative to the site url.
+	sitemapRoute = "/api/sitemap/"
+)
+
+type seoRepository interface {
+	GetSource(ctx context.Context, entity string, slug string) (*model.SeoSource, fall.Error)
+	Update(ctx context.Context, entity string, id int, dto model.UpdateSeoDto) fall.Error
+	GetSitemapUrls(ctx context.Context) ([]model.SitemapUrl, fall.Error)
+	GetVersion(ctx context.Context) (string, fall.Error)
+	SaveSitemap(ctx context.Context, g model.SitemapGeneration) fall.Error
+	GetSitemap(ctx context.Context) (*model.SitemapGeneration, fall.Error)
+	GetSitemapPath(ctx context.Context, name string) (string, fall.Error)
+}
+
+type seoFileClient interface {
+	Put(ctx context.Context, name string, contentType string, data []byte) (*model.UploadResponse, error)
+}
+
+type SeoService struct {
+	repo     seoRepository
+	files    seoFileClient
+	siteName string
+	siteUrl  string
+	// mu lets one generation run at a time, the sitemap it writes is served from the repository
+	mu sync.Mutex
+}
+
+func NewSeoService(repo seoRepository, files seoFileClient, siteName string, siteUrl string) *SeoService {
+	return &SeoService{repo: repo, files: files, siteName: siteName, siteUrl: strings.TrimSuffix(siteUrl, "/")}
+}
+
+// GetMeta returns the meta tags of a page: the fields an admin set, or the templates filled with the page values.
+func (s *SeoService) GetMeta(ctx context.Context, entity string, slug string) (*model.SeoMeta, fall.Error) {
+	source, ex := s.repo.GetSource(ctx, entity, slug)
+	if ex != nil {
+		return nil, ex
+	}
+
+	titleTemplate, descriptionTemplate, pagePath := model.CategoryTitleTemplate, model.CategoryDescriptionTemplate, model.CategoryPagePath
+	switch entity {
+	case model.SeoBrand:
+		titleTemplate, descriptionTemplate, pagePath = model.BrandTitleTemplate, model.BrandDescriptionTemplate, model.BrandPagePath
+	case model.SeoModel:
+		titleTemplate, descriptionTemplate, pagePath = model.ModelTitleTemplate, model.ModelDescriptionTemplate, model.ProductPagePath
+	}
+
+	if source.MetaTitle != nil {
+		titleTemplate = *source.MetaTitle
+	}
+	if source.MetaDescription != nil {
+		descriptionTemplate = *source.MetaDescription
+	}
+
+	canonical := fmt.Sprintf(pagePath, source.Slug)
+	if source.Canonical != nil {
+		canonical = *source.Canonical
+	}
+
+	r := strings.NewReplacer(
+		"{title}", source.Title,
+		"{brand}", source.Brand,
+		"{category}", source.Category,
+		"{article}", source.Article,
+		"{price}", strconv.Itoa(source.Price),
+		"{site}", s.siteName,
+	)
+
+	return &model.SeoMeta{
+		Title:       strings.Join(strings.Fields(r.Replace(titleTemplate)), " "),
+		Description: strings.Join(strings.Fields(r.Replace(descriptionTemplate)), " "),
+		Canonical:   s.absoluteUrl(canonical),
+	}, nil
+}
+
+func (s *SeoService) Update(ctx context.Context, entity string, id int, dto model.UpdateSeoDto) fall.Error {
+	return s.repo.Update(ctx, entity, id, dto)
+}
+
+// Refresh rewrites the sitemap only when the catalog changed since the last run.
+func (s *SeoService) Refresh(ctx context.Context) fall.Error {
+	_, ex := s.generate(ctx, false)
+	return ex
+}
+
+// Generate rewrites the sitemap now.
+func (s *SeoService) Generate(ctx context.Context) (*model.SitemapGeneration, fall.Error) {
+	return s.generate(ctx, true)
+}
+
+func (s *SeoService) GetSitemap(ctx context.Context) (*model.SitemapGeneration, fall.Error) {
+	return s.repo.GetSitemap(ctx)
+}
+
+func (s *SeoService) GetPath(ctx context.Context, name string) (string, fall.Error) {
+	return s.repo.GetSitemapPath(ctx, name)
+}
+
+func (s *SeoService) generate(ctx context.Context, force bool) (*model.SitemapGeneration, fall.Error) {
+	if !s.mu.TryLock() {
+		return nil, fall.NewErr(msg.SitemapAlreadyRunning, fall.STATUS_CONFLICT)
+	}
+	defer s.mu.Unlock()
+
+	version, ex := s.repo.GetVersion(ctx)
+	if ex != nil {
+		return nil, ex
+	}
+
+	last, ex := s.repo.GetSitemap(ctx)
+	if ex != nil && ex.Status() != fall.STATUS_NOT_FOUND {
+		return nil, ex
+	}
+
+	if !force && last != nil && version == last.Version {
+		return last, nil
+	}
+
+	rows, ex := s.repo.GetSitemapUrls(ctx)
+	if ex != nil {
+		return nil, ex
+	}
+
+	urls := make([]sitemap.Url, 0, len(rows))
+	seen := make(map[string]bool, len(rows))
+
+	// a page with a canonical pointing elsewhere is listed under that url, once
+	for _, row := range rows {
+		loc := row.Path
+		if row.Canonical != nil {
+			loc = *row.Canonical
+		}
+		loc = s.absoluteUrl(loc)
+
+		if seen[loc] {
+			continue
+		}
+		seen[loc] = true
+
+		urls = append(urls, sitemap.Url{Loc: loc, LastMod: row.LastMod})
+	}
+
+	paths := map[string]string{}
+	files := []string{}
+	index := []sitemap.Sitemap{}
+
+	for i, chunk := range sitemap.Split(urls) {
+		name := fmt.Sprintf("sitemap-%d.xml", i+1)
+
+		var buf bytes.Buffer
+		if err := sitemap.WriteUrlSet(&buf, chunk); err != nil {
+			return nil, s.failed(err)
+		}
+
+		p, err := s.put(ctx, name, buf.Bytes())
+		if err != nil {
+			return nil, s.failed(err)
+		}
+
+		paths[name] = p
+		files = append(files, name)
+		index = append(index, sitemap.Sitemap{Loc: s.siteUrl + sitemapRoute + name, LastMod: sitemap.LastMod(chunk)})
+	}
+
+	var buf bytes.Buffer
+	if err := sitemap.WriteIndex(&buf, index); err != nil {
+		return nil, s.failed(err)
+	}
+
+	p, err := s.put(ctx, sitemapIndex, buf.Bytes())
+	if err != nil {
+		return nil, s.failed(err)
+	}
+	paths[sitemapIndex] = p
+
+	g := model.SitemapGeneration{Urls: len(urls), Files: files, GeneratedAt: time.Now(), Version: version, Paths: paths}
+
+	if ex := s.repo.SaveSitemap(ctx, g); ex != nil {
+		return nil, ex
+	}
+
+	return &g, nil
+}
+
+func (s *SeoService) put(ctx context.Context, name string, data []byte) (string, error) {
+	res, err := s.files.Put(ctx, path.Join(sitemapFolder, name), "application/xml; charset=utf-8", data)
+	if err != nil {
+		return "", err
+	}
+	return res.Path, nil
+}
+
+func (s *SeoService) failed(err error) fall.Error {
+	message := fmt.Sprintf("%s, details: %s", msg.SitemapErrorWhenCreate, err.Error())
+	log.Println(message)
+	return fall.ServerError(message)
+}
+
+func (s *SeoService) absoluteUrl(p string) string {
+	if strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://") {
+		return p
+	}
+	return s.siteUrl + "/" + strings.TrimPrefix(p, "/")
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+type slugRepository interface {
+	Unique(ctx context.Context, entity string, base string, id int) (string, fall.Error)
+	FindCurrent(ctx context.Context, entity string, retired string) (string, fall.Error)
+}
+
+// slugAttempts bounds how many times a write is retried after concurrent writes took the slugs it was given.
+const slugAttempts = 3
+
+// withUniqueSlug writes with a free slug for base. When a concurrent write takes that slug first, the write fails
+// on the unique constraint and is repeated with the next free one, which Unique finds once the other row is committed.
+func withUniqueSlug(ctx context.Context, slugs slugRepository, entity string, base string, id int,
+	write func(slug string) fall.Error) fall.Error {
+	var ex fall.Error
+	for i := 0; i < slugAttempts; i++ {
+		var slug string
+		slug, ex = slugs.Unique(ctx, entity, base, id)
+		if ex != nil {
+			return ex
+		}
+
+		ex = write(slug)
+		if ex == nil || ex.Message() != msg.SlugTaken {
+			return ex
+		}
+	}
+	return ex
+}
+
+func findSlugRedirect(ctx context.Context, slugs slugRepository, entity string, retired string,
+	pagePath string) (*model.SlugRedirect, fall.Error) {
+	slug, ex := slugs.FindCurrent(ctx, entity, retired)
+	if ex != nil {
+		return nil, ex
+	}
+	return &model.SlugRedirect{Slug: slug, Path: fmt.Sprintf(pagePath, slug)}, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/cache"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
+)
+
+type stockRepository interface {
+	GetHistory(ctx context.Context, modelSizeId int, page pagination.Page) (*model.StockHistory, fall.Error)
+	Adjust(ctx context.Context, modelSizeId int, quantity int, source model.StockSource) (int, fall.Error)
+	StockTake(ctx context.Context, modelSizeId int, counted int, source model.StockSource) (int, fall.Error)
+	Reconcile(ctx context.Context, dryRun bool, userId int) (*model.StockReconcileReport, fall.Error)
+}
+
+type StockService struct {
+	repo  stockRepository
+	cache cache.Cache
+}
+
+func NewStockService(repo stockRepository, cache cache.Cache) *StockService {
+	return &StockService{repo: repo, cache: cache}
+}
+
+func (s *StockService) GetHistory(ctx context.Context, modelSizeId int, page pagination.Page) (*model.StockHistory, fall.Error) {
+	return s.repo.GetHistory(ctx, modelSizeId, page)
+}
+
+func (s *StockService) Adjust(ctx context.Context, userId int, modelSizeId int, dto model.StockAdjustmentDto) fall.Error {
+	modelId, ex := s.repo.Adjust(ctx, modelSizeId, dto.Quantity,
+		model.StockSource{Type: model.StockAdjustment, UserId: &userId, Comment: &dto.Comment})
+	return s.invalidate(ctx, modelId, ex)
+}
+
+func (s *StockService) StockTake(ctx context.Context, userId int, modelSizeId int, dto model.StockTakeDto) fall.Error {
+	modelId, ex := s.repo.StockTake(ctx, modelSizeId, *dto.Counted,
+		model.StockSource{Type: model.StockTake, UserId: &userId, Comment: dto.Comment})
+	return s.invalidate(ctx, modelId, ex)
+}
+
+// Reconcile only touches the ledger, the stock itself stays as it is.
+func (s *StockService) Reconcile(ctx context.Context, dryRun bool, userId int) (*model.StockReconcileReport, fall.Error) {
+	return s.repo.Reconcile(ctx, dryRun, userId)
+}
+
+func (s *StockService) invalidate(ctx context.Context, modelId int, ex fall.Error) fall.Error {
+	if ex == nil {
+		s.cache.Invalidate(ctx, cache.TagCatalog, cache.ModelTag(modelId))
+	}
+	return ex
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/imageproc"
+)
+
+type storageRepository interface {
+	GetReferences(ctx context.Context) ([]string, fall.Error)
+}
+
+type storageFileClient interface {
+	Key(p string) (string, bool)
+	List(ctx context.Context) ([]model.StoredObject, error)
+	Remove(ctx context.Context, key string) error
+}
+
+// generatedFolders are rewritten in place by their jobs and are served without a record pointing at them.
+var generatedFolders = []string{feedFolder + "/", sitemapFolder + "/"}
+
+type StorageService struct {
+	repo  storageRepository
+	files storageFileClient
+	grace time.Duration
+	mu    sync.Mutex
+}
+
+func NewStorageService(repo storageRepository, files storageFileClient, grace time.Duration) *StorageService {
+	return &StorageService{repo: repo, files: files, grace: grace}
+}
+
+// Sweep finds objects that no record refers to and that are older than the grace period, which covers
+// uploads whose record isn't saved yet. Unless it is a dry run they are deleted.
+func (s *StorageService) Sweep(ctx context.Context, dryRun bool) (*model.SweepReport, fall.Error) {
+	if !s.mu.TryLock() {
+		return nil, fall.NewErr(msg.FileSweepRunning, fall.STATUS_CONFLICT)
+	}
+	defer s.mu.Unlock()
+
+	// listed before references are read, so an object referenced in between is seen as referenced
+	objects, err := s.files.List(ctx)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	refs, ex := s.repo.GetReferences(ctx)
+	if ex != nil {
+		return nil, ex
+	}
+
+	if len(refs) == 0 && len(objects) > 0 {
+		return nil, fall.NewErr(msg.FileSweepNoReference, fall.STATUS_CONFLICT)
+	}
+
+	referenced := make(map[string]bool, len(refs))
+	// a record keeps only the main rendition, its siblings live in the same folder
+	renditionFolders := map[string]bool{}
+	for _, ref := range refs {
+		key, ok := s.files.Key(ref)
+		if !ok {
+			continue
+		}
+		referenced[key] = true
+		if imageproc.IsKey(key) {
+			renditionFolders[path.Dir(key)] = true
+		}
+	}
+
+	report := &model.SweepReport{DryRun: dryRun, Scanned: len(objects), Orphaned: []model.StoredObject{}}
+	cutoff := time.Now().Add(-s.grace)
+
+	for _, obj := range objects {
+		switch {
+		case referenced[obj.Key] || renditionFolders[path.Dir(obj.Key)] || generated(obj.Key):
+			report.Referenced++
+		case obj.LastModified.After(cutoff):
+			report.Recent++
+		default:
+			report.Orphaned = append(report.Orphaned, obj)
+			report.Bytes += obj.Size
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	for _, obj := range report.Orphaned {
+		if err := s.files.Remove(ctx, obj.Key); err != nil {
+			log.Printf("storage sweep %s: %s", obj.Key, err.Error())
+			continue
+		}
+		report.Deleted++
+	}
+
+	return report, nil
+}
+
+func generated(key string) bool {
+	for _, folder := range generatedFolders {
+		if strings.HasPrefix(key, folder) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/file"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/imageproc"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/upload"
+)
+
+type uploadSessionRepository interface {
+	Create(ctx context.Context, session model.UploadSession, ttl time.Duration) (*model.UploadSession, fall.Error)
+	FindById(ctx context.Context, id string) (*model.UploadSession, fall.Error)
+	Confirm(ctx context.Context, id string, path string) (bool, fall.Error)
+	Reopen(ctx context.Context, id string) fall.Error
+	Reject(ctx context.Context, id string) fall.Error
+	GetExpired(ctx context.Context, limit int) ([]model.UploadSession, fall.Error)
+	Delete(ctx context.Context, id string) fall.Error
+}
+
+type uploadFileClient interface {
+	Upload(ctx context.Context, data []byte, contentType string, ext string) (*model.UploadResponse, error)
+}
+
+// uploadPendingClient keeps files put straight to the storage until they are confirmed, in a private bucket
+// so nothing unchecked is ever served.
+type uploadPendingClient interface {
+	Presign(ctx context.Context, method string, key string, contentType string, expiry time.Duration) (string, error)
+	Read(ctx context.Context, key string, limit int64) ([]byte, error)
+	Remove(ctx context.Context, key string) error
+}
+
+type uploadProductService interface {
+	FindProductModelById(ctx context.Context, id int) (*model.ProductModel, fall.Error)
+	AddPhoto(ctx context.Context, dto model.CreateProducModelImg) fall.Error
+}
+
+type uploadBrandService interface {
+	FindById(ctx context.Context, id int) (*model.Brand, fall.Error)
+	Update(ctx context.Context, dto model.UpdateBrandDto, id int) fall.Error
+}
+
+type uploadActionService interface {
+	FindById(ctx context.Context, id string) (*model.Action, fall.Error)
+	Update(ctx context.Context, dto model.UpdateActionDto, id string) fall.Error
+}
+
+type uploadUserService interface {
+	Update(ctx context.Context, dto model.UpdateUserDto, id int) fall.Error
+}
+
+// UploadSessionService lets clients put a file straight to the storage: a session hands out a presigned url
+// for one purpose and target, confirming it checks the file like a regular upload and attaches it.
+type UploadSessionService struct {
+	repo     uploadSessionRepository
+	files    uploadFileClient
+	pending  uploadPendingClient
+	products uploadProductService
+	brands   uploadBrandService
+	actions  uploadActionService
+	users    uploadUserService
+	ttl      time.Duration
+}
+
+func NewUploadSessionService(repo uploadSessionRepository, files uploadFileClient, pending uploadPendingClient,
+	products uploadProductService, brands uploadBrandService, actions uploadActionService, users uploadUserService,
+	ttl time.Duration) *UploadSessionService {
+	return &UploadSessionService{repo: repo, files: files, pending: pending, products: products, brands: brands,
+		actions: actions, users: users, ttl: ttl}
+}
+
+// Create checks the declared type and size against the purpose and the target exists, the file itself
+// is only checked on Confirm.
+func (s *UploadSessionService) Create(ctx context.Context, userId int, dto model.CreateUploadSessionDto) (*model.UploadTicket, fall.Error) {
+	policy := upload.Policies[dto.Purpose]
+
+	if dto.Size > policy.MaxSize {
+		return nil, fall.NewErr(msg.FileTooLarge, fall.STATUS_BAD_REQUEST)
+	}
+	if !slices.Contains(policy.Types, dto.ContentType) {
+		return nil, fall.NewErr(msg.FileTypeNotAllowed, fall.STATUS_BAD_REQUEST)
+	}
+
+	target, ex := s.target(ctx, userId, dto)
+	if ex != nil {
+		return nil, ex
+	}
+
+	id := uuid.New().String()
+	key := id + upload.Extensions[dto.ContentType]
+
+	url, err := s.pending.Presign(ctx, http.MethodPut, key, dto.ContentType, s.ttl)
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	session, ex := s.repo.Create(ctx, model.UploadSession{Id: id, UserId: userId, Purpose: dto.Purpose, TargetId: target,
+		Key: key}, s.ttl)
+	if ex != nil {
+		return nil, ex
+	}
+
+	return &model.UploadTicket{Id: session.Id, Url: url, Method: http.MethodPut, ContentType: dto.ContentType,
+		MaxSize: policy.MaxSize, ExpiresAt: session.ExpiresAt}, nil
+}
+
+func (s *UploadSessionService) target(ctx context.Context, userId int, dto model.CreateUploadSessionDto) (string, fall.Error) {
+	if dto.Purpose == upload.PurposeAvatar {
+		return strconv.Itoa(userId), nil
+	}
+
+	if dto.Purpose == upload.PurposeAction {
+		if _, err := uuid.Parse(dto.TargetId); err != nil {
+			return "", fall.NewErr(msg.UploadSessionTarget, fall.STATUS_BAD_REQUEST)
+		}
+		_, ex := s.actions.FindById(ctx, dto.TargetId)
+		return dto.TargetId, ex
+	}
+
+	id, err := strconv.Atoi(dto.TargetId)
+	if err != nil || id < 1 {
+		return "", fall.NewErr(msg.UploadSessionTarget, fall.STATUS_BAD_REQUEST)
+	}
+
+	var ex fall.Error
+	switch dto.Purpose {
+	case upload.PurposeProduct:
+		_, ex = s.products.FindProductModelById(ctx, id)
+	case upload.PurposeBrand:
+		_, ex = s.brands.FindById(ctx, id)
+	}
+	return strconv.Itoa(id), ex
+}
+
+// Confirm stores the uploaded file the way a regular upload is stored and attaches it to the target.
+// A file that fails the checks closes the session, a missing one leaves it open until it expires.
+func (s *UploadSessionService) Confirm(ctx context.Context, userId int, id string) (*model.UploadResponse, fall.Error) {
+	session, ex := s.repo.FindById(ctx, id)
+	if ex != nil {
+		return nil, ex
+	}
+
+	if session.UserId != userId {
+		return nil, fall.NewErr(msg.UploadSessionNotFound, fall.STATUS_NOT_FOUND)
+	}
+	if session.Status != model.UploadPending {
+		return nil, fall.NewErr(msg.UploadSessionClosed, fall.STATUS_CONFLICT)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, fall.NewErr(msg.UploadSessionExpired, fall.STATUS_BAD_REQUEST)
+	}
+
+	policy := upload.Policies[session.Purpose]
+
+	// the client can still replace the object, so the size is enforced by the read itself
+	data, err := s.pending.Read(ctx, session.Key, policy.MaxSize)
+	if err != nil {
+		switch {
+		case errors.Is(err, file.ErrNotFound):
+			return nil, fall.NewErr(msg.UploadSessionNoFile, fall.STATUS_BAD_REQUEST)
+		case errors.Is(err, file.ErrTooLarge):
+			return nil, s.reject(ctx, session, fall.NewErr(msg.FileTooLarge, fall.STATUS_BAD_REQUEST))
+		}
+		return nil, fall.ServerError(err.Error())
+	}
+
+	contentType, err := upload.Check(policy, data, imageproc.Supported)
+	if err != nil {
+		message := msg.FileUnsafe
+		switch {
+		case errors.Is(err, upload.ErrTooLarge):
+			message = msg.FileTooLarge
+		case errors.Is(err, upload.ErrType):
+			message = msg.FileTypeNotAllowed
+		case errors.Is(err, upload.ErrPixels):
+			message = msg.FileTooManyPixels
+		}
+		return nil, s.reject(ctx, session, fall.NewErr(message, fall.STATUS_BAD_REQUEST))
+	}
+
+	res, err := s.files.Upload(ctx, data, contentType, upload.Extensions[contentType])
+	if err != nil {
+		return nil, fall.ServerError(err.Error())
+	}
+
+	confirmed, ex := s.repo.Confirm(ctx, session.Id, res.Path)
+	if ex != nil {
+		return nil, ex
+	}
+	if !confirmed {
+		return nil, fall.NewErr(msg.UploadSessionClosed, fall.STATUS_CONFLICT)
+	}
+
+	ex = s.attach(ctx, session, res.Path)
+	if ex != nil {
+		if reopenEx := s.repo.Reopen(ctx, session.Id); reopenEx != nil {
+			log.Println(reopenEx.Message())
+		}
+		return nil, ex
+	}
+
+	if err := s.pending.Remove(ctx, session.Key); err != nil {
+		log.Printf("upload session %s: %s", session.Id, err.Error())
+	}
+
+	return res, nil
+}
+
+func (s *UploadSessionService) attach(ctx context.Context, session *model.UploadSession, p string) fall.Error {
+	if session.Purpose == upload.PurposeAction {
+		return s.actions.Update(ctx, model.UpdateActionDto{ImgPath: &p}, session.TargetId)
+	}
+
+	id, err := strconv.Atoi(session.TargetId)
+	if err != nil {
+		return fall.ServerError(err.Error())
+	}
+
+	switch session.Purpose {
+	case upload.PurposeProduct:
+		return s.products.AddPhoto(ctx, model.CreateProducModelImg{ImgPath: p, ProductModelId: id})
+	case upload.PurposeBrand:
+		return s.brands.Update(ctx, model.UpdateBrandDto{ImgPath: &p}, id)
+	case upload.PurposeAvatar:
+		return s.users.Update(ctx, model.UpdateUserDto{AvatarPath: &p}, id)
+	}
+	return fall.NewErr(msg.FileUnknownPurpose, fall.STATUS_BAD_REQUEST)
+}
+
+// reject closes the session and drops the file, then returns ex.
+func (s *UploadSessionService) reject(ctx context.Context, session *model.UploadSession, ex fall.Error) fall.Error {
+	if rejectEx := s.repo.Reject(ctx, session.Id); rejectEx != nil {
+		return rejectEx
+	}
+	if err := s.pending.Remove(ctx, session.Key); err != nil {
+		log.Printf("upload session %s: %s", session.Id, err.Error())
+	}
+	return ex
+}
+
+// RemoveExpired deletes sessions that were never confirmed together with whatever was uploaded for them.
+func (s *UploadSessionService) RemoveExpired(ctx context.Context, limit int) (int, fall.Error) {
+	sessions, ex := s.repo.GetExpired(ctx, limit)
+	if ex != nil {
+		return 0, ex
+	}
+
+	removed := 0
+	for _, session := range sessions {
+		// the row is kept while the file is, so a failed removal is retried on the next run
+		if err := s.pending.Remove(ctx, session.Key); err != nil {
+			log.Printf("upload session %s: %s", session.Id, err.Error())
+			continue
+		}
+		if ex := s.repo.Delete(ctx, session.Id); ex != nil {
+			return removed, ex
+		}
+		removed++
+	}
+
+	return removed, nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/maximfedotov74/diploma-backend/internal/shared/db"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/jwt"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/password"
 )
 
@@ -34,7 +35,7 @@ type userRepository interface {
 	FindChangePasswordCode(ctx context.Context, userId int, code string) (*model.ChangePasswordCode, fall.Error)
 	RemoveChangePasswordCode(ctx context.Context, userId int, tx db.Transaction) error
 	ChangePassword(ctx context.Context, userId int, newPassword string) fall.Error
-	GetAll(ctx context.Context, page int) (*model.GetAllUsersResponse, fall.Error)
+	GetAll(ctx context.Context, page pagination.Page) (*model.GetAllUsersResponse, fall.Error)
 }
 
 type UserService struct {
@@ -48,7 +49,7 @@ func NewUserService(repo userRepository, sessionService userSessionServie,
 	return &UserService{repo: repo, sessionService: sessionService, mailService: mailService}
 }
 
-func (s *UserService) GetAll(ctx context.Context, page int) (*model.GetAllUsersResponse, fall.Error) {
+func (s *UserService) GetAll(ctx context.Context, page pagination.Page) (*model.GetAllUsersResponse, fall.Error) {
 	return s.repo.GetAll(ctx, page)
 }
 
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
+)
+
+const (
+	Memory = "memory"
+)
+
+const (
+	TagCategories = "categories"
+	TagBrands     = "brands"
+	TagActions    = "actions"
+	TagCatalog    = "catalog"
+)
+
+func CategoryTag(slug string) string {
+	return "category:" + slug
+}
+
+func BrandTag(id int) string {
+	return fmt.Sprintf("brand:%d", id)
+}
+
+func ModelTag(id int) string {
+	return fmt.Sprintf("model:%d", id)
+}
+
+func ProductTag(id int) string {
+	return fmt.Sprintf("product:%d", id)
+}
+
+// Cache stores encoded responses under a key together with the tags they were built from.
+// A backend that fails treats it as a miss, the caller then reads from the database.
+//
+// Every invalidation advances a generation. Set is given the generation its value was read at and drops
+// the value when one of its tags has been invalidated since, a write that lands during a read can't be
+// cached over.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Generation(ctx context.Context) uint64
+	Set(ctx context.Context, key string, value []byte, since uint64, tags ...string)
+	Invalidate(ctx context.Context, tags ...string)
+}
+
+type Config struct {
+	Backend string
+	Size    int
+	TTL     time.Duration
+}
+
+func New(cfg Config) (Cache, error) {
+	switch cfg.Backend {
+	case Memory, "":
+		return NewLRU(cfg.Size, cfg.TTL), nil
+	}
+	return nil, fmt.Errorf("unknown cache backend: %s", cfg.Backend)
+}
+
+// Load returns the value cached under key, or calls load and caches its result with the tags load reports.
+func Load[T any](ctx context.Context, c Cache, key string, load func() (T, []string, fall.Error)) (T, fall.Error) {
+	var value T
+
+	if raw, ok := c.Get(ctx, key); ok {
+		if err := json.Unmarshal(raw, &value); err == nil {
+			return value, nil
+		}
+	}
+
+	since := c.Generation(ctx)
+
+	value, tags, ex := load()
+	if ex != nil {
+		return value, ex
+	}
+
+	if raw, err := json.Marshal(value); err == nil {
+		c.Set(ctx, key, raw, since, tags...)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultSize = 1024
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	tags      []string
+	expiresAt time.Time
+}
+
+// LRU is an in-memory Cache that evicts the least recently used entry once it holds size entries.
+// A zero ttl keeps entries until they are evicted or invalidated.
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+	// generation counts invalidations, invalidated holds the generation each tag was last invalidated at.
+	// Once it outgrows the cache it is cleared and floor rejects every value read before that.
+	generation  uint64
+	invalidated map[string]uint64
+	floor       uint64
+}
+
+func NewLRU(size int, ttl time.Duration) *LRU {
+	if size < 1 {
+		size = defaultSize
+	}
+	return &LRU{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+		tags:  make(map[string]map[string]struct{}),
+
+		invalidated: make(map[string]uint64),
+	}
+}
+
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *LRU) Generation(ctx context.Context) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+func (c *LRU) Set(ctx context.Context, key string, value []byte, since uint64, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if since < c.floor {
+		return
+	}
+	for _, tag := range tags {
+		if c.invalidated[tag] > since {
+			return
+		}
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	entry := &lruEntry{key: key, value: value, tags: tags}
+	if c.ttl > 0 {
+		entry.expiresAt = time.Now().Add(c.ttl)
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRU) Invalidate(ctx context.Context, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	if len(c.invalidated) > c.size {
+		clear(c.invalidated)
+		c.floor = c.generation
+	}
+
+	for _, tag := range tags {
+		c.invalidated[tag] = c.generation
+		for key := range c.tags[tag] {
+			if el, ok := c.items[key]; ok {
+				c.remove(el)
+			}
+		}
+		delete(c.tags, tag)
+	}
+}
+
+func (c *LRU) remove(el *list.Element) {
+	entry := el.Value.(*lruEntry)
+	c.order.Remove(el)
+	delete(c.items, entry.key)
+	for _, tag := range entry.tags {
+		keys := c.tags[tag]
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package commerceml reads and writes the CommerceML 2 documents 1C exchanges with online stores.
+package commerceml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const SchemaVersion = "2.05"
+
+// Document is the root of import.xml and offers.xml, only one of Catalog and Offers is usually present.
+type Document struct {
+	XMLName    xml.Name    `xml:"КоммерческаяИнформация"`
+	Classifier *Classifier `xml:"Классификатор"`
+	Catalog    *Catalog    `xml:"Каталог"`
+	Offers     *OfferPack  `xml:"ПакетПредложений"`
+}
+
+type Classifier struct {
+	Groups     []Group    `xml:"Группы>Группа"`
+	Properties []Property `xml:"Свойства>Свойство"`
+}
+
+type Group struct {
+	Id     string  `xml:"Ид"`
+	Name   string  `xml:"Наименование"`
+	Groups []Group `xml:"Группы>Группа"`
+}
+
+type Property struct {
+	Id     string          `xml:"Ид"`
+	Name   string          `xml:"Наименование"`
+	Values []PropertyValue `xml:"ВариантыЗначений>Справочник"`
+}
+
+type PropertyValue struct {
+	Id    string `xml:"ИдЗначения"`
+	Value string `xml:"Значение"`
+}
+
+type Catalog struct {
+	ChangesOnly bool      `xml:"СодержитТолькоИзменения,attr"`
+	Products    []Product `xml:"Товары>Товар"`
+}
+
+type Product struct {
+	Id           string           `xml:"Ид"`
+	Status       string           `xml:"Статус,attr"`
+	Article      string           `xml:"Артикул"`
+	Name         string           `xml:"Наименование"`
+	Description  string           `xml:"Описание"`
+	Groups       []string         `xml:"Группы>Ид"`
+	Manufacturer string           `xml:"Изготовитель>Наименование"`
+	Pictures     []string         `xml:"Картинка"`
+	Properties   []PropertyValues `xml:"ЗначенияСвойств>ЗначенияСвойства"`
+}
+
+// Deleted reports whether 1C marked the product for deletion.
+func (p Product) Deleted() bool {
+	return p.Status == "Удален"
+}
+
+type PropertyValues struct {
+	Id     string   `xml:"Ид"`
+	Values []string `xml:"Значение"`
+}
+
+type OfferPack struct {
+	ChangesOnly bool    `xml:"СодержитТолькоИзменения,attr"`
+	Offers      []Offer `xml:"Предложения>Предложение"`
+}
+
+type Offer struct {
+	Id              string           `xml:"Ид"`
+	Article         string           `xml:"Артикул"`
+	Name            string           `xml:"Наименование"`
+	Characteristics []Characteristic `xml:"ХарактеристикиТовара>ХарактеристикаТовара"`
+	Prices          []Price          `xml:"Цены>Цена"`
+	Quantity        string           `xml:"Количество"`
+	Stocks          []string         `xml:"Остатки>Остаток>Количество"`
+}
+
+// ProductId returns the id of the offer's product, offers of product characteristics are "product#characteristic".
+func (o Offer) ProductId() string {
+	id, _, _ := strings.Cut(o.Id, "#")
+	return id
+}
+
+// Price returns the first price of the offer, 1C lists one price per price type and the store uses one type.
+func (o Offer) Price() (float64, bool) {
+	if len(o.Prices) == 0 {
+		return 0, false
+	}
+	return ParseNumber(o.Prices[0].PerUnit)
+}
+
+// Stock returns the quantity of the offer, newer schema versions put it into Остатки instead of Количество.
+func (o Offer) Stock() (float64, bool) {
+	if o.Quantity != "" {
+		return ParseNumber(o.Quantity)
+	}
+	if len(o.Stocks) > 0 {
+		return ParseNumber(o.Stocks[0])
+	}
+	return 0, false
+}
+
+type Characteristic struct {
+	Name  string `xml:"Наименование"`
+	Value string `xml:"Значение"`
+}
+
+type Price struct {
+	PerUnit string `xml:"ЦенаЗаЕдиницу"`
+}
+
+// Parse reads a UTF-8 document, 1C writes a byte order mark before the declaration.
+func Parse(r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		if strings.EqualFold(charset, "utf-8") {
+			return input, nil
+		}
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+
+	doc := &Document{}
+	if err := decoder.Decode(doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// ParseNumber reads 1C numbers, they may use a comma and spaces between thousands.
+func ParseNumber(s string) (float64, bool) {
+	s = strings.NewReplacer(" ", "", " ", "", ",", ".").Replace(strings.TrimSpace(s))
+	if s == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
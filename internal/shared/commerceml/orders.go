@@ -0,0 +1,111 @@
+package commerceml
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// DeliveryId is the product id the delivery line of an order is sent with.
+const DeliveryId = "ORDER_DELIVERY"
+
+type ordersDocument struct {
+	XMLName   xml.Name        `xml:"КоммерческаяИнформация"`
+	Version   string          `xml:"ВерсияСхемы,attr"`
+	CreatedAt string          `xml:"ДатаФормирования,attr"`
+	Documents []OrderDocument `xml:"Документ"`
+}
+
+type OrderDocument struct {
+	Id           string         `xml:"Ид"`
+	Number       string         `xml:"Номер"`
+	Date         string         `xml:"Дата"`
+	Time         string         `xml:"Время"`
+	Operation    string         `xml:"ХозОперация"`
+	Role         string         `xml:"Роль"`
+	Currency     string         `xml:"Валюта"`
+	Rate         int            `xml:"Курс"`
+	Sum          string         `xml:"Сумма"`
+	Counterparty []Counterparty `xml:"Контрагенты>Контрагент"`
+	Items        []OrderItem    `xml:"Товары>Товар"`
+	Requisites   []Requisite    `xml:"ЗначенияРеквизитов>ЗначениеРеквизита"`
+}
+
+type Counterparty struct {
+	Id        string    `xml:"Ид"`
+	Name      string    `xml:"Наименование"`
+	Role      string    `xml:"Роль"`
+	FullName  string    `xml:"ПолноеНаименование"`
+	LastName  string    `xml:"Фамилия"`
+	FirstName string    `xml:"Имя"`
+	Contacts  []Contact `xml:"Контакты>Контакт"`
+}
+
+type Contact struct {
+	Type  string `xml:"Тип"`
+	Value string `xml:"Значение"`
+}
+
+type OrderItem struct {
+	Id              string           `xml:"Ид"`
+	Article         string           `xml:"Артикул,omitempty"`
+	Name            string           `xml:"Наименование"`
+	Unit            Unit             `xml:"БазоваяЕдиница"`
+	PricePerUnit    string           `xml:"ЦенаЗаЕдиницу"`
+	Quantity        int              `xml:"Количество"`
+	Sum             string           `xml:"Сумма"`
+	Characteristics []Characteristic `xml:"ХарактеристикиТовара>ХарактеристикаТовара"`
+	Requisites      []Requisite      `xml:"ЗначенияРеквизитов>ЗначениеРеквизита"`
+}
+
+type Unit struct {
+	Code          string `xml:"Код,attr"`
+	FullName      string `xml:"НаименованиеПолное,attr"`
+	International string `xml:"МеждународноеСокращение,attr"`
+	Name          string `xml:",chardata"`
+}
+
+type Requisite struct {
+	Name  string `xml:"Наименование"`
+	Value string `xml:"Значение"`
+}
+
+// PieceUnit is the unit every store item is sold in.
+var PieceUnit = Unit{Code: "796", FullName: "Штука", International: "PCE", Name: "шт"}
+
+// NewOrderDocument fills the fields that are the same for every store order.
+func NewOrderDocument(id string, createdAt time.Time, sum float64) OrderDocument {
+	return OrderDocument{
+		Id:        id,
+		Number:    id,
+		Date:      createdAt.Format("2006-01-02"),
+		Time:      createdAt.Format("15:04:05"),
+		Operation: "Заказ товара",
+		Role:      "Продавец",
+		Currency:  "руб",
+		Rate:      1,
+		Sum:       FormatNumber(sum),
+	}
+}
+
+// WriteOrders writes the orders document 1C requests with mode=query.
+func WriteOrders(w io.Writer, documents []OrderDocument, now time.Time) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	err := encoder.Encode(ordersDocument{Version: SchemaVersion, CreatedAt: now.Format("2006-01-02T15:04:05"), Documents: documents})
+	if err != nil {
+		return err
+	}
+
+	return encoder.Flush()
+}
+
+func FormatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', 2, 64)
+}
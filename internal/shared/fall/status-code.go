@@ -5,6 +5,7 @@ const (
 	STATUS_FORBIDDEN      = 403
 	STATUS_UNAUTHORIZED   = 401
 	STATUS_BAD_REQUEST    = 400
+	STATUS_CONFLICT       = 409
 	STATUS_INTERNAL_ERROR = 500
 	STATUS_OK             = 200
 	STATUS_CREATED        = 201
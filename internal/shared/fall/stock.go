@@ -0,0 +1,20 @@
+package fall
+
+// StockError is returned when some of the requested model sizes don't have enough units in stock.
+type StockError struct {
+	MessageText  string `json:"message" example:"not enough in stock" validate:"required"`
+	StatusCode   int    `json:"status" example:"409" validate:"required"`
+	ModelSizeIds []int  `json:"model_size_ids" validate:"required"`
+}
+
+func NewStockErr(m string, modelSizeIds []int) Error {
+	return StockError{MessageText: m, StatusCode: STATUS_CONFLICT, ModelSizeIds: modelSizeIds}
+}
+
+func (err StockError) Status() int {
+	return err.StatusCode
+}
+
+func (err StockError) Message() string {
+	return err.MessageText
+}
@@ -21,6 +21,10 @@ func NewValidErr(e []validationErrorItem) ValidationError {
 	return ValidationError{Status: 400, Errors: e, Message: "Ошибка при валидации данных!"}
 }
 
+func NewFieldValidErr(key string, message string) ValidationError {
+	return NewValidErr([]validationErrorItem{{Key: key, Message: message}})
+}
+
 func error_message(fe validator.FieldError) string {
 	switch fe.Tag() {
 	case "required":
@@ -0,0 +1,199 @@
+// Package feed writes the catalog as Yandex Market YML and Google Merchant feeds, every model size is an offer.
+package feed
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/msg"
+)
+
+const (
+	maxTitleLength       = 150
+	maxDescriptionLength = 3000
+	maxImages            = 10
+	currency             = "RUB"
+	sizeParam            = "Размер"
+)
+
+type Shop struct {
+	Name    string
+	Company string
+	Url     string
+}
+
+// offer is a model size with everything the formats share already checked and converted.
+type offer struct {
+	Id           string
+	GroupId      int
+	Title        string
+	Description  string
+	Url          string
+	Images       []string
+	Price        int
+	SalePrice    int
+	Brand        string
+	Article      string
+	Size         string
+	Count        int
+	CategoryId   *int
+	CategoryPath string
+	Params       []model.FeedParam
+}
+
+// Write writes the feed with the given name and returns the number of offers and the warnings about them.
+func Write(w io.Writer, name string, shop Shop, catalog *model.FeedCatalog, now time.Time) (int, []model.FeedWarning, error) {
+	offers, warnings := prepare(shop, catalog)
+
+	var err error
+	switch name {
+	case model.FeedYandex:
+		err = writeYML(w, shop, catalog.Categories, offers, now)
+	case model.FeedGoogleXML:
+		err = writeGoogleXML(w, shop, offers)
+	case model.FeedGoogleTSV:
+		err = writeGoogleTSV(w, offers)
+	default:
+		err = fmt.Errorf("unknown feed: %s", name)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return len(offers), warnings, nil
+}
+
+func prepare(shop Shop, catalog *model.FeedCatalog) ([]offer, []model.FeedWarning) {
+	categories := make(map[int]model.FeedCategory, len(catalog.Categories))
+	for _, c := range catalog.Categories {
+		categories[c.Id] = c
+	}
+
+	var offers []offer
+	warnings := []model.FeedWarning{}
+
+	for _, m := range catalog.Models {
+		var modelWarnings []string
+		warn := func(message string) {
+			modelWarnings = append(modelWarnings, message)
+		}
+
+		if m.Price <= 0 {
+			for _, size := range m.Sizes {
+				warnings = append(warnings, model.FeedWarning{OfferId: strconv.Itoa(size.ModelSizeId), Message: msg.FeedInvalidPrice})
+			}
+			continue
+		}
+
+		title := m.Title
+		if utf8.RuneCountInString(title) > maxTitleLength {
+			title = truncate(title, maxTitleLength)
+			warn(msg.FeedTitleTruncated)
+		}
+
+		description := title
+		if m.Description == nil || strings.TrimSpace(*m.Description) == "" {
+			warn(msg.FeedNoDescription)
+		} else {
+			description = *m.Description
+			if utf8.RuneCountInString(description) > maxDescriptionLength {
+				description = truncate(description, maxDescriptionLength)
+				warn(msg.FeedDescriptionCut)
+			}
+		}
+
+		images := make([]string, 0, len(m.Images)+1)
+		seen := make(map[string]bool)
+		for _, img := range append([]string{m.MainImage}, m.Images...) {
+			if img == "" || seen[img] {
+				continue
+			}
+			seen[img] = true
+			images = append(images, absoluteUrl(shop.Url, img))
+		}
+		if len(images) == 0 {
+			warn(msg.FeedNoImages)
+		}
+		if len(images) > maxImages {
+			images = images[:maxImages]
+			warn(msg.FeedTooManyImages)
+		}
+
+		var categoryId *int
+		categoryPath := ""
+		if _, ok := categories[m.CategoryId]; ok {
+			id := m.CategoryId
+			categoryId = &id
+			categoryPath = path(categories, m.CategoryId)
+		} else {
+			warn(msg.FeedInvalidCategory)
+		}
+
+		for _, size := range m.Sizes {
+			o := offer{
+				Id:           strconv.Itoa(size.ModelSizeId),
+				GroupId:      m.Id,
+				Title:        title,
+				Description:  description,
+				Url:          absoluteUrl(shop.Url, fmt.Sprintf(model.ProductPagePath, m.Slug)),
+				Images:       images,
+				Price:        m.Price,
+				SalePrice:    m.SalePrice(),
+				Brand:        m.Brand,
+				Article:      m.Article,
+				Size:         size.Value,
+				Count:        max(size.InStock, 0),
+				CategoryId:   categoryId,
+				CategoryPath: categoryPath,
+				Params:       m.Params,
+			}
+			offers = append(offers, o)
+
+			for _, message := range modelWarnings {
+				warnings = append(warnings, model.FeedWarning{OfferId: o.Id, Message: message})
+			}
+			if o.Count == 0 {
+				warnings = append(warnings, model.FeedWarning{OfferId: o.Id, Message: msg.FeedOutOfStock})
+			}
+		}
+	}
+
+	return offers, warnings
+}
+
+// path returns the category with its ancestors as "Женщинам > Одежда > Куртки".
+func path(categories map[int]model.FeedCategory, id int) string {
+	var titles []string
+	for depth := 0; depth < 10; depth++ {
+		c, ok := categories[id]
+		if !ok {
+			break
+		}
+		titles = append([]string{c.Title}, titles...)
+		if c.ParentId == nil {
+			break
+		}
+		id = *c.ParentId
+	}
+	return strings.Join(titles, " > ")
+}
+
+func absoluteUrl(base string, p string) string {
+	if strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://") {
+		return p
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(p, "/")
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
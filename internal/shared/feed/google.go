@@ -0,0 +1,121 @@
+package feed
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const googleNamespace = "http://base.google.com/ns/1.0"
+
+type googleRSS struct {
+	XMLName   xml.Name      `xml:"rss"`
+	Version   string        `xml:"version,attr"`
+	Namespace string        `xml:"xmlns:g,attr"`
+	Channel   googleChannel `xml:"channel"`
+}
+
+type googleChannel struct {
+	Title       string       `xml:"title"`
+	Link        string       `xml:"link"`
+	Description string       `xml:"description"`
+	Items       []googleItem `xml:"item"`
+}
+
+type googleItem struct {
+	Id               string   `xml:"g:id"`
+	GroupId          string   `xml:"g:item_group_id"`
+	Title            string   `xml:"g:title"`
+	Description      string   `xml:"g:description"`
+	Link             string   `xml:"g:link"`
+	ImageLink        string   `xml:"g:image_link"`
+	AdditionalImages []string `xml:"g:additional_image_link"`
+	Availability     string   `xml:"g:availability"`
+	Price            string   `xml:"g:price"`
+	SalePrice        string   `xml:"g:sale_price,omitempty"`
+	Brand            string   `xml:"g:brand"`
+	Mpn              string   `xml:"g:mpn"`
+	Size             string   `xml:"g:size"`
+	Condition        string   `xml:"g:condition"`
+	ProductType      string   `xml:"g:product_type,omitempty"`
+	IdentifierExists string   `xml:"g:identifier_exists"`
+}
+
+var googleColumns = []string{"id", "item_group_id", "title", "description", "link", "image_link", "additional_image_link",
+	"availability", "price", "sale_price", "brand", "mpn", "size", "condition", "product_type", "identifier_exists"}
+
+func googleItemOf(o offer) googleItem {
+	item := googleItem{
+		Id:               o.Id,
+		GroupId:          strconv.Itoa(o.GroupId),
+		Title:            o.Title,
+		Description:      o.Description,
+		Link:             o.Url,
+		Availability:     "out_of_stock",
+		Price:            googlePrice(o.Price),
+		Brand:            o.Brand,
+		Mpn:              o.Article,
+		Size:             o.Size,
+		Condition:        "new",
+		ProductType:      o.CategoryPath,
+		IdentifierExists: "no",
+	}
+	if o.Count > 0 {
+		item.Availability = "in_stock"
+	}
+	if o.SalePrice < o.Price {
+		item.SalePrice = googlePrice(o.SalePrice)
+	}
+	if len(o.Images) > 0 {
+		item.ImageLink = o.Images[0]
+		item.AdditionalImages = o.Images[1:]
+	}
+	return item
+}
+
+func writeGoogleXML(w io.Writer, shop Shop, offers []offer) error {
+	doc := googleRSS{
+		Version:   "2.0",
+		Namespace: googleNamespace,
+		Channel:   googleChannel{Title: shop.Name, Link: shop.Url, Description: shop.Company},
+	}
+
+	for _, o := range offers {
+		doc.Channel.Items = append(doc.Channel.Items, googleItemOf(o))
+	}
+
+	return encodeXML(w, doc)
+}
+
+// writeGoogleTSV writes the tab separated variant of the Google feed, several additional images are comma separated.
+func writeGoogleTSV(w io.Writer, offers []offer) error {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.WriteString(strings.Join(googleColumns, "\t") + "\n"); err != nil {
+		return err
+	}
+
+	for _, o := range offers {
+		item := googleItemOf(o)
+		row := []string{item.Id, item.GroupId, item.Title, item.Description, item.Link, item.ImageLink,
+			strings.Join(item.AdditionalImages, ","), item.Availability, item.Price, item.SalePrice, item.Brand, item.Mpn,
+			item.Size, item.Condition, item.ProductType, item.IdentifierExists}
+		for i, cell := range row {
+			row[i] = tsvCleaner.Replace(cell)
+		}
+		if _, err := bw.WriteString(strings.Join(row, "\t") + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+var tsvCleaner = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+func googlePrice(price int) string {
+	return fmt.Sprintf("%d.00 %s", price, currency)
+}
@@ -0,0 +1,120 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+)
+
+type ymlCatalog struct {
+	XMLName xml.Name `xml:"yml_catalog"`
+	Date    string   `xml:"date,attr"`
+	Shop    ymlShop  `xml:"shop"`
+}
+
+type ymlShop struct {
+	Name       string        `xml:"name"`
+	Company    string        `xml:"company"`
+	Url        string        `xml:"url"`
+	Currencies []ymlCurrency `xml:"currencies>currency"`
+	Categories []ymlCategory `xml:"categories>category"`
+	Offers     []ymlOffer    `xml:"offers>offer"`
+}
+
+type ymlCurrency struct {
+	Id   string `xml:"id,attr"`
+	Rate string `xml:"rate,attr"`
+}
+
+type ymlCategory struct {
+	Id       int    `xml:"id,attr"`
+	ParentId *int   `xml:"parentId,attr,omitempty"`
+	Title    string `xml:",chardata"`
+}
+
+type ymlOffer struct {
+	Id          string     `xml:"id,attr"`
+	Available   bool       `xml:"available,attr"`
+	GroupId     int        `xml:"group_id,attr"`
+	Name        string     `xml:"name"`
+	Vendor      string     `xml:"vendor"`
+	VendorCode  string     `xml:"vendorCode"`
+	Url         string     `xml:"url"`
+	Price       int        `xml:"price"`
+	OldPrice    *int       `xml:"oldprice,omitempty"`
+	CurrencyId  string     `xml:"currencyId"`
+	CategoryId  *int       `xml:"categoryId,omitempty"`
+	Pictures    []string   `xml:"picture"`
+	Description string     `xml:"description"`
+	Params      []ymlParam `xml:"param"`
+	Count       int        `xml:"count"`
+}
+
+type ymlParam struct {
+	Name  string `xml:"name,attr"`
+	Unit  string `xml:"unit,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// writeYML writes the Yandex Market feed, sizes of a model share group_id so Market shows them as one card.
+func writeYML(w io.Writer, shop Shop, categories []model.FeedCategory, offers []offer, now time.Time) error {
+	doc := ymlCatalog{
+		Date: now.Format(time.RFC3339),
+		Shop: ymlShop{
+			Name:       shop.Name,
+			Company:    shop.Company,
+			Url:        shop.Url,
+			Currencies: []ymlCurrency{{Id: "RUR", Rate: "1"}},
+		},
+	}
+
+	for _, c := range categories {
+		doc.Shop.Categories = append(doc.Shop.Categories, ymlCategory{Id: c.Id, ParentId: c.ParentId, Title: c.Title})
+	}
+
+	for _, o := range offers {
+		yo := ymlOffer{
+			Id:          o.Id,
+			Available:   o.Count > 0,
+			GroupId:     o.GroupId,
+			Name:        o.Title,
+			Vendor:      o.Brand,
+			VendorCode:  o.Article,
+			Url:         o.Url,
+			Price:       o.SalePrice,
+			CurrencyId:  "RUR",
+			CategoryId:  o.CategoryId,
+			Pictures:    o.Images,
+			Description: o.Description,
+			Params:      []ymlParam{{Name: sizeParam, Unit: "RU", Value: o.Size}},
+			Count:       o.Count,
+		}
+		if o.SalePrice < o.Price {
+			price := o.Price
+			yo.OldPrice = &price
+		}
+		for _, p := range o.Params {
+			yo.Params = append(yo.Params, ymlParam{Name: p.Name, Value: p.Value})
+		}
+		doc.Shop.Offers = append(doc.Shop.Offers, yo)
+	}
+
+	return encodeXML(w, doc)
+}
+
+func encodeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	return encoder.Flush()
+}
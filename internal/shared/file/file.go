@@ -1,105 +1,102 @@
 package file
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"mime/multipart"
 	"path"
-	"path/filepath"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/maximfedotov74/diploma-backend/internal/shared/imageproc"
 )
 
-var once sync.Once
-var minioClient *minio.Client
+var ErrNotInBucket = errors.New("path is not in the bucket")
 
+// FileClient stores uploads in a BlobStore and hands out the public paths of the stored objects.
 type FileClient struct {
-	minio      *minio.Client
+	store      BlobStore
 	mainBucket string
 }
 
-func New(minioUrl string, user string, password string, bucket string, ctx context.Context) *FileClient {
-	once.Do(func() {
-		client, err := minio.New(minioUrl, &minio.Options{Creds: credentials.NewStaticV4(user, password, ""), Secure: false})
-		if err != nil {
-			log.Fatalf("Failed to connect to minio service, cause: %s", err.Error())
-		}
+func New(store BlobStore, bucket string) *FileClient {
+	return &FileClient{store: store, mainBucket: bucket}
+}
 
-		exists, err := client.BucketExists(ctx, bucket)
+// Upload stores a checked upload: pictures the pipeline decodes become renditions, anything else is kept as it is.
+func (c *FileClient) Upload(ctx context.Context, data []byte, contentType string, ext string) (*model.UploadResponse, error) {
+	if imageproc.Supported(contentType) {
+		return c.PutImage(ctx, data)
+	}
+	return c.Put(ctx, uuid.New().String()+ext, contentType, data)
+}
+
+// PutImage stores the renditions of a picture under keys derived from its content,
+// an upload of the same picture overwrites the same objects.
+func (c *FileClient) PutImage(ctx context.Context, data []byte) (*model.UploadResponse, error) {
+	renditions, err := imageproc.Process(data)
+	if err != nil {
+		return nil, fmt.Errorf("error when processing image, cause: %s", err.Error())
+	}
 
+	res := &model.UploadResponse{Variants: make(map[string]string, len(renditions))}
+	for _, r := range renditions {
+		stored, err := c.Put(ctx, imageproc.Key(data, r.Variant), imageproc.ContentType, r.Data)
 		if err != nil {
-			log.Fatalf("Failed to start File Client, cause: %s", err.Error())
+			return nil, err
 		}
+		res.Variants[r.Variant] = stored.Path
+	}
+	res.Path = res.Variants[imageproc.Main]
 
-		if !exists {
-			err = client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: ""})
-			if err != nil {
-				log.Fatalf("Failed when make new bucket, cause: %s", err.Error())
-			}
-			policy := `{"Version":"2012-10-17","Statement":[{"Action":["s3:GetObject"],"Effect":"Allow","Principal":"*","Resource":["arn:aws:s3:::` + bucket + `/*"],"Sid":""}]}`
+	return res, nil
+}
 
-			err = client.SetBucketPolicy(ctx, bucket, policy)
+// Get reads an object of at most limit bytes by the path Put returned for it.
+func (c *FileClient) Get(ctx context.Context, p string, limit int64) ([]byte, error) {
+	name, ok := c.Key(p)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotInBucket, p)
+	}
 
-			if err != nil {
-				log.Fatalf("Failed when set policy to bucket: %s, cause: %s", bucket, err.Error())
-			}
-		}
+	return c.Read(ctx, name, limit)
+}
 
-		minioClient = client
-	})
-	return &FileClient{minio: minioClient, mainBucket: bucket}
+// Read returns ErrNotFound when there is no object under key and ErrTooLarge when it is longer than limit.
+// The limit is applied while reading, a Stat beforehand can't vouch for an object that may be replaced meanwhile.
+func (c *FileClient) Read(ctx context.Context, key string, limit int64) ([]byte, error) {
+	return c.store.Read(ctx, key, limit)
 }
 
-func (c *FileClient) Upload(ctx context.Context, h *multipart.FileHeader) (*model.UploadResponse, error) {
-	file, err := h.Open()
-	if err != nil {
-		return nil, fmt.Errorf("error when open file, cause: %s", err.Error())
+// Put stores data under name as it is, callers pick unique names themselves.
+func (c *FileClient) Put(ctx context.Context, name string, contentType string, data []byte) (*model.UploadResponse, error) {
+	if err := c.store.Upload(ctx, name, contentType, data); err != nil {
+		return nil, err
 	}
-	defer file.Close()
-	contentType := h.Header.Get("Content-type")
-	fileBytes, err := io.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("error when get file bytes with io: %s", err.Error())
-	}
-	//splittedContentType := strings.Split(contentType, "/")
-	// fileType := splittedContentType[0]
-	// extType := splittedContentType[1]
-	ext := strings.TrimPrefix(filepath.Ext(h.Filename), filepath.Base(h.Filename))
-	fileName := uuid.New().String()
-	// if fileType == "image" && extType != "svg+xml" {
-	// 	compressOptions := bimg.Options{Quality: 50, Type: bimg.WEBP}
-	// 	webpBytes, err := bimg.Resize(fileBytes, compressOptions)
-	// 	if err != nil {
-	// 		return nil, fmt.Errorf("error when compressing image, cause: %s", err.Error())
-	// 	}
-	// 	newType := http.DetectContentType(webpBytes)
-	// 	newName := fileName + ".webp"
-	// 	reader := bytes.NewReader(webpBytes)
-	// 	_, err = c.minio.PutObject(ctx, c.mainBucket, newName, reader, reader.Size(), minio.PutObjectOptions{
-	// 		ContentType:  newType,
-	// 		UserMetadata: map[string]string{"x-amz-acl": "public-read"},
-	// 	})
-	// 	if err != nil {
-	// 		return nil, fmt.Errorf("error when uploading file, cause: %s", err.Error())
-	// 	}
-	// 	return &model.UploadResponse{Path: path.Join("/", "storage", c.mainBucket, newName)}, nil
-	// }
-	reader := bytes.NewReader(fileBytes)
-	newName := fileName + ext
-	_, err = c.minio.PutObject(ctx, c.mainBucket, newName, reader, reader.Size(), minio.PutObjectOptions{
-		ContentType:  contentType,
-		UserMetadata: map[string]string{"x-amz-acl": "public-read"},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error when uploading file, cause: %s", err.Error())
-	}
-	return &model.UploadResponse{Path: path.Join("/", "storage", c.mainBucket, newName)}, nil
+	return &model.UploadResponse{Path: path.Join("/", "storage", c.mainBucket, name)}, nil
+}
+
+// Key turns a path returned by Put back into the object name.
+func (c *FileClient) Key(p string) (string, bool) {
+	return strings.CutPrefix(p, path.Join("/", "storage", c.mainBucket)+"/")
+}
+
+// List returns every object in the bucket.
+func (c *FileClient) List(ctx context.Context) ([]model.StoredObject, error) {
+	return c.store.List(ctx, "")
+}
+
+func (c *FileClient) Remove(ctx context.Context, key string) error {
+	return c.store.Delete(ctx, key)
+}
+
+// Stat returns ErrNotFound when there is no object under key.
+func (c *FileClient) Stat(ctx context.Context, key string) (*model.StoredObject, error) {
+	return c.store.Stat(ctx, key)
+}
+
+func (c *FileClient) Presign(ctx context.Context, method string, key string, contentType string, expiry time.Duration) (string, error) {
+	return c.store.Presign(ctx, method, key, contentType, expiry)
 }
@@ -0,0 +1,266 @@
+package file
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+)
+
+const tempSuffix = ".part"
+
+// LocalStore keeps objects as files under <root>/<bucket>/<key>, for running without an object store.
+// Routes serves them under the same /storage/<bucket>/<key> paths MinIO does.
+type LocalStore struct {
+	dir     string
+	bucket  string
+	secret  []byte
+	private bool
+}
+
+// NewLocalStore signs presigned urls with secret. Without one a random secret is made,
+// so urls signed before a restart stop working. Objects of a private store are only served by presigned urls.
+func NewLocalStore(root string, bucket string, secret string, private bool) (*LocalStore, error) {
+	dir := filepath.Join(root, bucket)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage dir %s, cause: %s", dir, err.Error())
+	}
+
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate storage secret, cause: %s", err.Error())
+		}
+	}
+
+	return &LocalStore{dir: dir, bucket: bucket, secret: key, private: private}, nil
+}
+
+// file maps a key to its file, keys that would leave the bucket dir are refused.
+func (s *LocalStore) file(key string) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" || clean != key || strings.HasSuffix(clean, tempSuffix) {
+		return "", fmt.Errorf("invalid object key: %s", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalStore) Upload(ctx context.Context, key string, contentType string, data []byte) error {
+	name, err := s.file(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("error when uploading file, cause: %s", err.Error())
+	}
+
+	// readers never see a half written file, the rename replaces it at once
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*"+tempSuffix)
+	if err != nil {
+		return fmt.Errorf("error when uploading file, cause: %s", err.Error())
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		return fmt.Errorf("error when uploading file, cause: %s", err.Error())
+	}
+	return nil
+}
+
+func (s *LocalStore) Read(ctx context.Context, key string, limit int64) ([]byte, error) {
+	name, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error when reading file, cause: %s", err.Error())
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("error when reading file, cause: %s", err.Error())
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	name, err := s.file(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error when removing file, cause: %s", err.Error())
+	}
+	return nil
+}
+
+func (s *LocalStore) Stat(ctx context.Context, key string) (*model.StoredObject, error) {
+	name, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error when getting file info, cause: %s", err.Error())
+	}
+	if info.IsDir() {
+		return nil, ErrNotFound
+	}
+
+	return s.object(key, info), nil
+}
+
+func (s *LocalStore) List(ctx context.Context, prefix string) ([]model.StoredObject, error) {
+	objects := []model.StoredObject{}
+	err := filepath.WalkDir(s.dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(name, tempSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		objects = append(objects, *s.object(key, info))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing files, cause: %s", err.Error())
+	}
+	return objects, nil
+}
+
+func (s *LocalStore) object(key string, info fs.FileInfo) *model.StoredObject {
+	return &model.StoredObject{Key: key, Size: info.Size(), LastModified: info.ModTime(),
+		ContentType: mime.TypeByExtension(path.Ext(key))}
+}
+
+// Presign returns a relative url, Routes checks its signature. Objects of a public store are served
+// anyway, so there a GET url only differs from the plain path by the extra query.
+func (s *LocalStore) Presign(ctx context.Context, method string, key string, contentType string, expiry time.Duration) (string, error) {
+	if err := checkPresignMethod(method); err != nil {
+		return "", err
+	}
+	if _, err := s.file(key); err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	if method != http.MethodPut {
+		contentType = ""
+	}
+	query := url.Values{"expires": {expires}, "signature": {s.sign(method, key, contentType, expires)}}
+
+	return path.Join("/", "storage", s.bucket, key) + "?" + query.Encode(), nil
+}
+
+func (s *LocalStore) sign(method string, key string, contentType string, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(method + "\n" + key + "\n" + contentType + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalStore) verify(method string, key string, contentType string, expires string, signature string) bool {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(s.sign(method, key, contentType, expires)), []byte(signature))
+}
+
+// Routes serves the bucket under /storage/<bucket>/ and accepts uploads to presigned PUT urls.
+func (s *LocalStore) Routes(r fiber.Router) {
+	group := r.Group(path.Join("/", "storage", s.bucket))
+	group.Get("/*", s.serve)
+	group.Put("/*", s.receive)
+}
+
+func (s *LocalStore) serve(ctx *fiber.Ctx) error {
+	key, err := url.PathUnescape(ctx.Params("*"))
+	if err != nil {
+		return ctx.SendStatus(http.StatusNotFound)
+	}
+	if s.private && !s.verify(http.MethodGet, key, "", ctx.Query("expires"), ctx.Query("signature")) {
+		return ctx.SendStatus(http.StatusForbidden)
+	}
+
+	obj, err := s.Stat(ctx.Context(), key)
+	if err != nil {
+		return ctx.SendStatus(http.StatusNotFound)
+	}
+
+	name, _ := s.file(key)
+	if obj.ContentType != "" {
+		ctx.Set(fiber.HeaderContentType, obj.ContentType)
+	}
+	return ctx.SendFile(name)
+}
+
+func (s *LocalStore) receive(ctx *fiber.Ctx) error {
+	contentType := string(ctx.Request().Header.ContentType())
+
+	key, err := url.PathUnescape(ctx.Params("*"))
+	if err != nil || !s.verify(http.MethodPut, key, contentType, ctx.Query("expires"), ctx.Query("signature")) {
+		return ctx.SendStatus(http.StatusForbidden)
+	}
+
+	if err := s.Upload(ctx.Context(), key, contentType, ctx.Body()); err != nil {
+		return ctx.SendStatus(http.StatusBadRequest)
+	}
+	return ctx.SendStatus(http.StatusOK)
+}
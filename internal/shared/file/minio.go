@@ -0,0 +1,175 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type MinioStore struct {
+	client  *minio.Client
+	bucket  string
+	private bool
+	mu      sync.Mutex
+	// ready is set once the bucket is known to exist, guarded by mu.
+	ready bool
+}
+
+// NewMinioStore doesn't reach the server, the bucket is checked on first use and again after a failure,
+// so the app starts while MinIO is down and files work once it is up. A bucket it makes is public-read
+// unless private is set.
+func NewMinioStore(url string, user string, password string, bucket string, private bool) (*MinioStore, error) {
+	client, err := minio.New(url, &minio.Options{Creds: credentials.NewStaticV4(user, password, ""), Secure: false})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create minio client, cause: %s", err.Error())
+	}
+	return &MinioStore{client: client, bucket: bucket, private: private}, nil
+}
+
+func (s *MinioStore) ensureBucket(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ready {
+		return nil
+	}
+
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket %s, cause: %s", s.bucket, err.Error())
+	}
+
+	if !exists {
+		err = s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{Region: ""})
+		if err != nil {
+			return fmt.Errorf("failed when make new bucket, cause: %s", err.Error())
+		}
+		if !s.private {
+			policy := `{"Version":"2012-10-17","Statement":[{"Action":["s3:GetObject"],"Effect":"Allow","Principal":"*","Resource":["arn:aws:s3:::` + s.bucket + `/*"],"Sid":""}]}`
+
+			err = s.client.SetBucketPolicy(ctx, s.bucket, policy)
+			if err != nil {
+				return fmt.Errorf("failed when set policy to bucket: %s, cause: %s", s.bucket, err.Error())
+			}
+		}
+	}
+
+	s.ready = true
+	return nil
+}
+
+func (s *MinioStore) Upload(ctx context.Context, key string, contentType string, data []byte) error {
+	if err := s.ensureBucket(ctx); err != nil {
+		return err
+	}
+
+	opts := minio.PutObjectOptions{ContentType: contentType}
+	if !s.private {
+		opts.UserMetadata = map[string]string{"x-amz-acl": "public-read"}
+	}
+
+	reader := bytes.NewReader(data)
+	_, err := s.client.PutObject(ctx, s.bucket, key, reader, reader.Size(), opts)
+	if err != nil {
+		return fmt.Errorf("error when uploading file, cause: %s", err.Error())
+	}
+	return nil
+}
+
+func (s *MinioStore) Read(ctx context.Context, key string, limit int64) ([]byte, error) {
+	if err := s.ensureBucket(ctx); err != nil {
+		return nil, err
+	}
+
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error when getting file, cause: %s", err.Error())
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(io.LimitReader(obj, limit+1))
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error when reading file, cause: %s", err.Error())
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+func (s *MinioStore) Delete(ctx context.Context, key string) error {
+	if err := s.ensureBucket(ctx); err != nil {
+		return err
+	}
+
+	err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("error when removing file, cause: %s", err.Error())
+	}
+	return nil
+}
+
+func (s *MinioStore) Stat(ctx context.Context, key string) (*model.StoredObject, error) {
+	if err := s.ensureBucket(ctx); err != nil {
+		return nil, err
+	}
+
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error when getting file info, cause: %s", err.Error())
+	}
+
+	return &model.StoredObject{Key: info.Key, Size: info.Size, LastModified: info.LastModified,
+		ContentType: info.ContentType}, nil
+}
+
+func (s *MinioStore) List(ctx context.Context, prefix string) ([]model.StoredObject, error) {
+	if err := s.ensureBucket(ctx); err != nil {
+		return nil, err
+	}
+
+	objects := []model.StoredObject{}
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("error when listing files, cause: %s", obj.Err.Error())
+		}
+		objects = append(objects, model.StoredObject{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified,
+			ContentType: obj.ContentType})
+	}
+	return objects, nil
+}
+
+// Presign signs against the MinIO endpoint itself, the client has to be able to reach it.
+func (s *MinioStore) Presign(ctx context.Context, method string, key string, contentType string, expiry time.Duration) (string, error) {
+	if err := checkPresignMethod(method); err != nil {
+		return "", err
+	}
+	if err := s.ensureBucket(ctx); err != nil {
+		return "", err
+	}
+
+	var headers http.Header
+	if method == http.MethodPut {
+		headers = http.Header{"Content-Type": {contentType}}
+	}
+
+	u, err := s.client.PresignHeader(ctx, method, s.bucket, key, expiry, nil, headers)
+	if err != nil {
+		return "", fmt.Errorf("error when presigning url, cause: %s", err.Error())
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,63 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/maximfedotov74/diploma-backend/internal/domain/model"
+)
+
+const (
+	Minio = "minio"
+	Local = "local"
+)
+
+var (
+	ErrNotFound = errors.New("object not found")
+	ErrTooLarge = errors.New("object is larger than the limit")
+)
+
+// BlobStore keeps the objects of one bucket by key. Every backend serves them under /storage/<bucket>/<key>,
+// so stored paths don't depend on the backend. A private bucket is only served through presigned urls.
+type BlobStore interface {
+	Upload(ctx context.Context, key string, contentType string, data []byte) error
+	// Read returns ErrTooLarge without reading further once the object turns out longer than limit bytes.
+	Read(ctx context.Context, key string, limit int64) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (*model.StoredObject, error)
+	List(ctx context.Context, prefix string) ([]model.StoredObject, error)
+	// Presign returns a URL that allows method, GET or PUT, on the key until expiry runs out.
+	// A PUT url is signed for contentType, the upload has to send it as its Content-Type.
+	Presign(ctx context.Context, method string, key string, contentType string, expiry time.Duration) (string, error)
+}
+
+type Config struct {
+	Backend       string
+	Bucket        string
+	MinioUrl      string
+	MinioUser     string
+	MinioPassword string
+	LocalPath     string
+	LocalSecret   string
+	Private       bool
+}
+
+func NewStore(cfg Config) (BlobStore, error) {
+	switch cfg.Backend {
+	case Minio, "":
+		return NewMinioStore(cfg.MinioUrl, cfg.MinioUser, cfg.MinioPassword, cfg.Bucket, cfg.Private)
+	case Local:
+		return NewLocalStore(cfg.LocalPath, cfg.Bucket, cfg.LocalSecret, cfg.Private)
+	}
+	return nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+}
+
+func checkPresignMethod(method string) error {
+	if method != http.MethodGet && method != http.MethodPut {
+		return fmt.Errorf("presign: unsupported method %s", method)
+	}
+	return nil
+}
@@ -2,10 +2,15 @@ package generator
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
 )
 
+// CatalogFilters holds the raw catalog query parameters as they come from the request.
 type CatalogFilters struct {
 	Options          map[string]string
 	Slug             string
@@ -14,160 +19,384 @@ type CatalogFilters struct {
 	SortBy           string
 	OnlyWithDiscount string
 	Price            string
+	Cursor           string
+	Limit            string
 	Page             string
 }
 
-type GeneratedCatalogQuery struct {
-	SortStatement string
-	Pagination    string
-	MainQuery     string
-	MainJoins     string
-}
-
-func GenerateCatalogQuery(filters CatalogFilters) GeneratedCatalogQuery {
-
-	mainJoins := `FROM product p INNER JOIN category_tree ct ON p.category_id = ct.category_id 
-	INNER JOIN brand b on p.brand_id = b.brand_id
-	INNER JOIN product_model pm ON pm.product_id = p.product_id
-	inner join model_sizes ms on ms.product_model_id = pm.product_model_id
-	inner join sizes sz on ms.size_id = sz.size_id
-	inner join product_model_img as pimg on pimg.product_model_id = pm.product_model_id
-	`
-
-	var isWhereStatement bool = false
-	optionsJoins := ""
-	optionsWhere := ""
-	sizeWhere := ""
-	sortStatement := ""
-	pagination := ""
-	brandsWhere := ""
-	priceWhere := ""
-	onlyWithDiscountWhere := ""
+type CatalogSort string
 
-	if len(filters.Options) > 0 {
+const (
+	SortPopular   CatalogSort = "popular"
+	SortPriceAsc  CatalogSort = "price_asc"
+	SortPriceDesc CatalogSort = "price_desc"
+	SortDiscount  CatalogSort = "discount"
+	SortNew       CatalogSort = "new"
+)
+
+// CatalogParams are the parsed and validated catalog filters.
+type CatalogParams struct {
+	Slug             string
+	Options          map[string][]int
+	Sizes            []string
+	Brands           []int
+	SortBy           CatalogSort
+	OnlyWithDiscount bool
+	PriceFrom        *float64
+	PriceTo          *float64
+	Page             pagination.Page
+}
+
+// FilterError describes the first catalog parameter that failed to parse.
+type FilterError struct {
+	Key     string
+	Message string
+}
+
+func (e *FilterError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+const (
+	filterInvalidNumber = "Ожидается список целых чисел через запятую!"
+	filterInvalidPrice  = "Ожидается диапазон цен в формате от,до!"
+	filterInvalidFlag   = "Ожидается 0 или 1!"
+	filterInvalidSort   = "Неизвестный вид сортировки!"
+	filterInvalidOption = "Некорректный slug характеристики!"
+	filterInvalidSize   = "Некорректное значение размера!"
+	filterInvalidCursor = "Некорректный курсор пагинации!"
+)
 
-		optionsWhereStatement := ""
+var optionSlugRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
-		optIdx := 1
+const maxSizeLength = 64
 
+func ParseCatalogFilters(filters CatalogFilters) (*CatalogParams, *FilterError) {
+	params := &CatalogParams{Slug: filters.Slug, SortBy: SortPopular}
+
+	if len(filters.Options) > 0 {
+		params.Options = make(map[string][]int, len(filters.Options))
 		for optionSlug, v := range filters.Options {
-			if v != "" {
-				join := fmt.Sprintf(`
-				inner join product_model_option as pmop%[1]d on pmop%[1]d.product_model_id = pm.product_model_id
-				inner join option as op%[1]d on op%[1]d.option_id = pmop%[1]d.option_id
-				inner join option_value as v%[1]d on v%[1]d.option_value_id = pmop%[1]d.option_value_id`, optIdx)
-				optionsJoins += join
-				filterValues := strings.Split(v, ",")
-				where := fmt.Sprintf("op%d.slug = '%s' and v%d.option_value_id IN ", optIdx, optionSlug, optIdx)
-
-				if !isWhereStatement {
-					where = " WHERE " + where
-					isWhereStatement = true
-				} else {
-					where = " AND " + where
-				}
-				idsArr := make([]string, 0, len(filterValues))
-				for _, optionValue := range filterValues {
-					valueId, err := strconv.Atoi(optionValue)
-					if err != nil {
-						continue
-					}
-					idStr := fmt.Sprintf("%d", valueId)
-					idsArr = append(idsArr, idStr)
-				}
-				inStatement := fmt.Sprintf("(%s)", strings.Join(idsArr, ","))
-				where += inStatement
-				optionsWhereStatement += where + " "
-				optIdx++
+			if v == "" {
+				continue
+			}
+			if !optionSlugRegexp.MatchString(optionSlug) {
+				return nil, &FilterError{Key: optionSlug, Message: filterInvalidOption}
+			}
+			ids, err := parseIds(v)
+			if err != nil {
+				return nil, &FilterError{Key: optionSlug, Message: filterInvalidNumber}
 			}
+			params.Options[optionSlug] = ids
 		}
-		optionsWhere = optionsWhereStatement
 	}
 
 	if filters.Sizes != "" {
-		sizesValues := strings.Split(filters.Sizes, ",")
-		idsArr := make([]string, 0, len(sizesValues))
-		for _, size := range sizesValues {
-			idStr := fmt.Sprintf("'%s'", size)
-			idsArr = append(idsArr, idStr)
-		}
-		where := "sz.size_value IN "
-		if !isWhereStatement {
-			where = " WHERE " + where
-			isWhereStatement = true
-		} else {
-			where = " AND " + where
+		for _, size := range strings.Split(filters.Sizes, ",") {
+			size = strings.TrimSpace(size)
+			if size == "" || len(size) > maxSizeLength {
+				return nil, &FilterError{Key: "size", Message: filterInvalidSize}
+			}
+			params.Sizes = append(params.Sizes, size)
 		}
-		inStatement := fmt.Sprintf("(%s)", strings.Join(idsArr, ","))
-		where += inStatement
-		sizeWhere = where
 	}
 
 	if filters.Brands != "" {
-		where := "b.brand_id IN "
-		if !isWhereStatement {
-			where = " WHERE " + where
-			isWhereStatement = true
-		} else {
-			where = " AND " + where
+		ids, err := parseIds(filters.Brands)
+		if err != nil {
+			return nil, &FilterError{Key: "brands", Message: filterInvalidNumber}
 		}
-		inStatement := fmt.Sprintf("(%s)", filters.Brands)
-		where += inStatement
-		brandsWhere = where
+		params.Brands = ids
 	}
 
 	if filters.Price != "" {
 		limits := strings.Split(filters.Price, ",")
-		minValue, minParseErr := strconv.ParseFloat(limits[0], 32)
-		maxValue, maxParseErr := strconv.ParseFloat(limits[1], 32)
-		if maxParseErr == nil && minParseErr == nil {
-
-			if !isWhereStatement {
-				priceWhere = fmt.Sprintf(" WHERE pm.price BETWEEN %.2f AND %.2f", minValue, maxValue)
-				isWhereStatement = true
-			} else {
-				priceWhere = fmt.Sprintf(" AND pm.price BETWEEN %.2f AND %.2f", minValue, maxValue)
-			}
+		if len(limits) != 2 {
+			return nil, &FilterError{Key: "price", Message: filterInvalidPrice}
 		}
+		from, fromErr := strconv.ParseFloat(strings.TrimSpace(limits[0]), 64)
+		to, toErr := strconv.ParseFloat(strings.TrimSpace(limits[1]), 64)
+		if fromErr != nil || toErr != nil || from < 0 || from > to {
+			return nil, &FilterError{Key: "price", Message: filterInvalidPrice}
+		}
+		params.PriceFrom = &from
+		params.PriceTo = &to
+	}
+
+	switch filters.OnlyWithDiscount {
+	case "", "0":
+	case "1":
+		params.OnlyWithDiscount = true
+	default:
+		return nil, &FilterError{Key: "is_sale", Message: filterInvalidFlag}
 	}
 
-	if filters.OnlyWithDiscount != "" && filters.OnlyWithDiscount == "1" {
-		if !isWhereStatement {
-			onlyWithDiscountWhere = " WHERE pm.discount IS NOT NULL"
-			isWhereStatement = true
-		} else {
-			onlyWithDiscountWhere = " AND pm.discount IS NOT NULL"
+	switch sortBy := CatalogSort(filters.SortBy); sortBy {
+	case "":
+	case SortPopular, SortPriceAsc, SortPriceDesc, SortDiscount, SortNew:
+		params.SortBy = sortBy
+	default:
+		return nil, &FilterError{Key: "sort", Message: filterInvalidSort}
+	}
+
+	page, pageErr := pagination.Parse(filters.Cursor, filters.Limit, string(params.SortBy))
+	if pageErr != nil {
+		return nil, &FilterError{Key: pageErr.Key, Message: pageErr.Message}
+	}
+	if page.Cursor != nil {
+		_, keyErr := strconv.ParseFloat(page.Cursor.Key, 64)
+		_, idErr := strconv.Atoi(page.Cursor.Id)
+		if keyErr != nil || idErr != nil {
+			return nil, &FilterError{Key: "cursor", Message: filterInvalidCursor}
 		}
 	}
+	pageErr = page.Number(filters.Page)
+	if pageErr != nil {
+		return nil, &FilterError{Key: pageErr.Key, Message: pageErr.Message}
+	}
+	params.Page = *page
+
+	return params, nil
+}
+
+func parseIds(value string) ([]int, error) {
+	values := strings.Split(value, ",")
+	ids := make([]int, 0, len(values))
+	for _, v := range values {
+		id, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// GeneratedCatalogQuery holds SQL fragments over the catalog_model projection aliased as cm,
+// with positional placeholders whose values are Args in order. $1 is always the category slug
+// and the fragments expect a category_tree CTE built from it. After is empty for the first page.
+type GeneratedCatalogQuery struct {
+	Sort       CatalogSort
+	SortKey    string
+	Where      string
+	After      string
+	OrderBy    string
+	Pagination string
+	Limit      int
+	Args       []any
+}
+
+type queryArgs struct {
+	values []any
+}
+
+func (a *queryArgs) add(v any) string {
+	a.values = append(a.values, v)
+	return fmt.Sprintf("$%d", len(a.values))
+}
+
+const catalogFrom = "FROM catalog_model cm"
+
+const (
+	skipNothing = ""
+	skipBrands  = "brands"
+	skipSizes   = "size"
+)
+
+// catalogConditions builds the filter conditions, leaving out the dimension named by skip:
+// skipBrands, skipSizes or an option slug.
+func catalogConditions(params CatalogParams, args *queryArgs, skip string) []string {
+	conditions := []string{"cm.listed", "cm.category_id IN (SELECT category_id FROM category_tree)"}
+
+	for _, optionSlug := range sortedKeys(params.Options) {
+		if optionSlug == skip {
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf(`cm.option_value_ids && ARRAY(SELECT v.option_value_id FROM option_value v
+		INNER JOIN option op ON op.option_id = v.option_id WHERE op.slug = %s AND v.option_value_id = ANY(%s::int[]))`,
+			args.add(optionSlug), args.add(params.Options[optionSlug])))
+	}
+
+	if len(params.Sizes) > 0 && skip != skipSizes {
+		conditions = append(conditions, fmt.Sprintf("cm.size_values && %s::text[]", args.add(params.Sizes)))
+	}
 
-	switch filters.SortBy {
-	case "price_asc":
-		sortStatement = " ORDER BY pm.price ASC"
-	case "price_desc":
-		sortStatement = " ORDER BY pm.price DESC"
-	case "discount":
-		sortStatement = " ORDER BY pm.discount IS NULL, pm.discount DESC"
-	case "popular":
-		sortStatement = " ORDER BY order_count DESC"
-	case "new":
-		sortStatement = " ORDER BY pm.created_at DESC"
+	if len(params.Brands) > 0 && skip != skipBrands {
+		conditions = append(conditions, fmt.Sprintf("cm.brand_id = ANY(%s::int[])", args.add(params.Brands)))
+	}
+
+	if params.PriceFrom != nil && params.PriceTo != nil {
+		conditions = append(conditions, fmt.Sprintf("cm.price BETWEEN %s::numeric AND %s::numeric", args.add(*params.PriceFrom), args.add(*params.PriceTo)))
+	}
+
+	if params.OnlyWithDiscount {
+		conditions = append(conditions, "cm.discount IS NOT NULL")
+	}
+
+	return conditions
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func GenerateCatalogQuery(params CatalogParams) GeneratedCatalogQuery {
+
+	args := &queryArgs{}
+	args.add(params.Slug)
+
+	where := whereClause(catalogConditions(params, args, skipNothing))
+
+	var sortKey string
+	desc := true
+
+	switch params.SortBy {
+	case SortPriceAsc:
+		sortKey = "cm.price"
+		desc = false
+	case SortPriceDesc:
+		sortKey = "cm.price"
+	case SortDiscount:
+		sortKey = "COALESCE(cm.discount, -1)"
+	case SortNew:
+		sortKey = "extract(epoch from cm.created_at)"
 	default:
-		sortStatement = " ORDER BY order_count DESC"
+		sortKey = "cm.order_count"
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+
+	after := ""
+	if params.Page.Cursor != nil {
+		after = " AND " + pagination.After(sortKey, "cm.product_model_id", desc,
+			args.add(params.Page.Cursor.Key)+"::text::numeric", args.add(params.Page.Cursor.Id)+"::text::int")
 	}
 
-	limit := 16
-	page, err := strconv.Atoi(filters.Page)
-	if err != nil {
-		page = 1
+	limit := params.Page.Limit
+	if limit < 1 {
+		limit = pagination.DefaultLimit
 	}
-	offset := page*limit - limit
 
-	pagination = fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+	paginationClause := "LIMIT " + args.add(limit+1)
+	if params.Page.Offset > 0 {
+		paginationClause += " OFFSET " + args.add(params.Page.Offset)
+	}
 
 	return GeneratedCatalogQuery{
-		SortStatement: sortStatement,
-		MainQuery:     optionsJoins + optionsWhere + sizeWhere + brandsWhere + priceWhere + onlyWithDiscountWhere,
-		Pagination:    pagination,
-		MainJoins:     mainJoins,
+		Sort:       params.SortBy,
+		SortKey:    sortKey,
+		Where:      where,
+		After:      after,
+		OrderBy:    fmt.Sprintf("%[1]s %[2]s, cm.product_model_id %[2]s", sortKey, direction),
+		Pagination: paginationClause,
+		Limit:      limit,
+		Args:       args.values,
+	}
+}
+
+func sortedKeys(m map[string][]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type FacetKind string
+
+const (
+	FacetBrand  FacetKind = "brand"
+	FacetSize   FacetKind = "size"
+	FacetOption FacetKind = "option"
+)
+
+// FacetQuery counts models per value of one dimension, Column is the value id to group by.
+// Its filters are the catalog filters without the dimension's own one.
+type FacetQuery struct {
+	Kind   FacetKind
+	Column string
+	Joins  string
+	Where  string
+}
+
+// GeneratedFacetQuery shares one argument list between all facets, $1 is the category slug.
+// From selects the catalog_model projection aliased as cm.
+type GeneratedFacetQuery struct {
+	From   string
+	Facets []FacetQuery
+	Args   []any
+}
+
+const facetSizeJoin = "CROSS JOIN unnest(cm.size_ids) AS fsz(size_id)"
+
+const facetOptionJoin = `CROSS JOIN unnest(cm.option_value_ids) AS fv(option_value_id)
+		INNER JOIN option_value fov ON fov.option_value_id = fv.option_value_id
+		INNER JOIN option fop ON fop.option_id = fov.option_id`
+
+// GenerateFacetQuery builds per-dimension count queries with the same filters as GenerateCatalogQuery,
+// so facet counts and the catalog page always agree.
+func GenerateFacetQuery(params CatalogParams) GeneratedFacetQuery {
+	args := &queryArgs{}
+	args.add(params.Slug)
+
+	var facets []FacetQuery
+
+	conditions := catalogConditions(params, args, skipBrands)
+	facets = append(facets, FacetQuery{Kind: FacetBrand, Column: "cm.brand_id", Where: whereClause(conditions)})
+
+	conditions = catalogConditions(params, args, skipSizes)
+	facets = append(facets, FacetQuery{Kind: FacetSize, Column: "fsz.size_id", Joins: facetSizeJoin, Where: whereClause(conditions)})
+
+	filtered := sortedKeys(params.Options)
+
+	conditions = catalogConditions(params, args, skipNothing)
+	conditions = append(conditions, "fop.for_catalog = true")
+	if len(filtered) > 0 {
+		conditions = append(conditions, fmt.Sprintf("fop.slug <> ALL(%s::text[])", args.add(filtered)))
+	}
+	facets = append(facets, FacetQuery{Kind: FacetOption, Column: "fv.option_value_id",
+		Joins: facetOptionJoin, Where: whereClause(conditions)})
+
+	for _, optionSlug := range filtered {
+		conditions := catalogConditions(params, args, optionSlug)
+		conditions = append(conditions, "fop.for_catalog = true", fmt.Sprintf("fop.slug = %s", args.add(optionSlug)))
+		facets = append(facets, FacetQuery{Kind: FacetOption, Column: "fv.option_value_id",
+			Joins: facetOptionJoin, Where: whereClause(conditions)})
+	}
+
+	return GeneratedFacetQuery{From: catalogFrom, Facets: facets, Args: args.values}
+}
+
+var catalogReservedKeys = []string{"categorySlug", "size", "brands", "sort", "is_sale", "price", "cursor", "limit", "page"}
+
+// CatalogFiltersFromQuery splits request query parameters into the known catalog filters,
+// everything else is treated as an option filter keyed by option slug.
+func CatalogFiltersFromQuery(slug string, query map[string]string) CatalogFilters {
+	options := make(map[string]string, len(query))
+	for k, v := range query {
+		options[k] = v
+	}
+	for _, k := range catalogReservedKeys {
+		delete(options, k)
+	}
+
+	return CatalogFilters{
+		Options:          options,
+		Slug:             slug,
+		Sizes:            query["size"],
+		Brands:           query["brands"],
+		SortBy:           query["sort"],
+		OnlyWithDiscount: query["is_sale"],
+		Price:            query["price"],
+		Cursor:           query["cursor"],
+		Limit:            query["limit"],
+		Page:             query["page"],
 	}
 }
@@ -0,0 +1,258 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maximfedotov74/diploma-backend/internal/shared/pagination"
+)
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func TestParseCatalogFilters(t *testing.T) {
+	cursor := pagination.Cursor{Sort: string(SortPriceAsc), Key: "1500.5", Id: "42"}
+
+	tests := []struct {
+		name    string
+		filters CatalogFilters
+		want    *CatalogParams
+		wantKey string
+	}{
+		{
+			name:    "defaults",
+			filters: CatalogFilters{Slug: "shoes"},
+			want: &CatalogParams{Slug: "shoes", SortBy: SortPopular,
+				Page: pagination.Page{Limit: pagination.DefaultLimit, Sort: string(SortPopular)}},
+		},
+		{
+			name: "all filters",
+			filters: CatalogFilters{
+				Slug:             "shoes",
+				Options:          map[string]string{"color": "3, 5", "season": "", "material-type": "7"},
+				Sizes:            "S, M",
+				Brands:           "1,2",
+				Price:            "100, 2500.5",
+				OnlyWithDiscount: "1",
+				SortBy:           string(SortPriceDesc),
+				Limit:            "20",
+			},
+			want: &CatalogParams{
+				Slug:             "shoes",
+				Options:          map[string][]int{"color": {3, 5}, "material-type": {7}},
+				Sizes:            []string{"S", "M"},
+				Brands:           []int{1, 2},
+				PriceFrom:        ptr(100),
+				PriceTo:          ptr(2500.5),
+				OnlyWithDiscount: true,
+				SortBy:           SortPriceDesc,
+				Page:             pagination.Page{Limit: 20, Sort: string(SortPriceDesc)},
+			},
+		},
+		{
+			name:    "discount flag off",
+			filters: CatalogFilters{Slug: "shoes", OnlyWithDiscount: "0"},
+			want: &CatalogParams{Slug: "shoes", SortBy: SortPopular,
+				Page: pagination.Page{Limit: pagination.DefaultLimit, Sort: string(SortPopular)}},
+		},
+		{
+			name:    "cursor",
+			filters: CatalogFilters{Slug: "shoes", SortBy: string(SortPriceAsc), Cursor: cursor.Encode()},
+			want: &CatalogParams{Slug: "shoes", SortBy: SortPriceAsc,
+				Page: pagination.Page{Cursor: &cursor, Limit: pagination.DefaultLimit, Sort: string(SortPriceAsc)}},
+		},
+		{
+			name:    "page number",
+			filters: CatalogFilters{Slug: "shoes", Page: "3", Limit: "20"},
+			want: &CatalogParams{Slug: "shoes", SortBy: SortPopular,
+				Page: pagination.Page{Limit: 20, Sort: string(SortPopular), Offset: 40}},
+		},
+		{
+			name:    "cursor over page number",
+			filters: CatalogFilters{Slug: "shoes", SortBy: string(SortPriceAsc), Cursor: cursor.Encode(), Page: "3"},
+			want: &CatalogParams{Slug: "shoes", SortBy: SortPriceAsc,
+				Page: pagination.Page{Cursor: &cursor, Limit: pagination.DefaultLimit, Sort: string(SortPriceAsc)}},
+		},
+		{name: "page zero", filters: CatalogFilters{Page: "0"}, wantKey: "page"},
+		{name: "page too deep", filters: CatalogFilters{Page: "1001"}, wantKey: "page"},
+		{name: "page injection", filters: CatalogFilters{Page: "1 OFFSET 0"}, wantKey: "page"},
+		{name: "brands injection", filters: CatalogFilters{Brands: "1) OR (1=1"}, wantKey: "brands"},
+		{name: "brands not a number", filters: CatalogFilters{Brands: "1,two"}, wantKey: "brands"},
+		{name: "brands trailing comma", filters: CatalogFilters{Brands: "1,"}, wantKey: "brands"},
+		{name: "sort injection", filters: CatalogFilters{SortBy: "price;DROP"}, wantKey: "sort"},
+		{name: "sort column name", filters: CatalogFilters{SortBy: "cm.price"}, wantKey: "sort"},
+		{name: "option slug injection", filters: CatalogFilters{Options: map[string]string{"color' OR '1'='1": "1"}},
+			wantKey: "color' OR '1'='1"},
+		{name: "option value injection", filters: CatalogFilters{Options: map[string]string{"color": "1;DROP TABLE brand"}},
+			wantKey: "color"},
+		{name: "empty size", filters: CatalogFilters{Sizes: "S,,M"}, wantKey: "size"},
+		{name: "long size", filters: CatalogFilters{Sizes: strings.Repeat("X", maxSizeLength+1)}, wantKey: "size"},
+		{name: "price one bound", filters: CatalogFilters{Price: "100"}, wantKey: "price"},
+		{name: "price reversed", filters: CatalogFilters{Price: "500,100"}, wantKey: "price"},
+		{name: "price negative", filters: CatalogFilters{Price: "-1,100"}, wantKey: "price"},
+		{name: "price injection", filters: CatalogFilters{Price: "0,1);DROP"}, wantKey: "price"},
+		{name: "discount flag", filters: CatalogFilters{OnlyWithDiscount: "true"}, wantKey: "is_sale"},
+		{name: "limit", filters: CatalogFilters{Limit: "1000"}, wantKey: "limit"},
+		{name: "cursor garbage", filters: CatalogFilters{Cursor: "1' OR '1'='1"}, wantKey: "cursor"},
+		{name: "cursor of another sort", filters: CatalogFilters{Cursor: cursor.Encode()}, wantKey: "cursor"},
+		{
+			name: "cursor key injection",
+			filters: CatalogFilters{SortBy: string(SortPriceAsc),
+				Cursor: pagination.Cursor{Sort: string(SortPriceAsc), Key: "0) OR (1=1", Id: "1"}.Encode()},
+			wantKey: "cursor",
+		},
+		{
+			name: "cursor id injection",
+			filters: CatalogFilters{SortBy: string(SortPriceAsc),
+				Cursor: pagination.Cursor{Sort: string(SortPriceAsc), Key: "1", Id: "1;DROP"}.Encode()},
+			wantKey: "cursor",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCatalogFilters(tt.filters)
+
+			if tt.wantKey != "" {
+				if err == nil {
+					t.Fatalf("got %+v, want error for %q", got, tt.wantKey)
+				}
+				if err.Key != tt.wantKey {
+					t.Errorf("error key %q, want %q", err.Key, tt.wantKey)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateCatalogQuery(t *testing.T) {
+	const base = " WHERE cm.listed AND cm.category_id IN (SELECT category_id FROM category_tree)"
+
+	tests := []struct {
+		name       string
+		params     CatalogParams
+		where      string
+		after      string
+		orderBy    string
+		pagination string
+		args       []any
+	}{
+		{
+			name:       "no filters",
+			params:     CatalogParams{Slug: "shoes"},
+			where:      base,
+			orderBy:    "cm.order_count DESC, cm.product_model_id DESC",
+			pagination: "LIMIT $2",
+			args:       []any{"shoes", pagination.DefaultLimit + 1},
+		},
+		{
+			name: "brands, price and discount",
+			params: CatalogParams{Slug: "shoes", Brands: []int{1, 2}, PriceFrom: ptr(100), PriceTo: ptr(500),
+				OnlyWithDiscount: true, SortBy: SortPriceAsc, Page: pagination.Page{Limit: 10}},
+			where: base + " AND cm.brand_id = ANY($2::int[]) AND cm.price BETWEEN $3::numeric AND $4::numeric" +
+				" AND cm.discount IS NOT NULL",
+			orderBy:    "cm.price ASC, cm.product_model_id ASC",
+			pagination: "LIMIT $5",
+			args:       []any{"shoes", []int{1, 2}, 100.0, 500.0, 11},
+		},
+		{
+			name:       "sizes are bound, not inlined",
+			params:     CatalogParams{Slug: "shoes", Sizes: []string{"' OR 1=1 --"}, SortBy: SortDiscount},
+			where:      base + " AND cm.size_values && $2::text[]",
+			orderBy:    "COALESCE(cm.discount, -1) DESC, cm.product_model_id DESC",
+			pagination: "LIMIT $3",
+			args:       []any{"shoes", []string{"' OR 1=1 --"}, pagination.DefaultLimit + 1},
+		},
+		{
+			name:   "options in slug order",
+			params: CatalogParams{Slug: "shoes", Options: map[string][]int{"season": {9}, "color": {3, 5}}, SortBy: SortNew},
+			where: base + ` AND cm.option_value_ids && ARRAY(SELECT v.option_value_id FROM option_value v
+		INNER JOIN option op ON op.option_id = v.option_id WHERE op.slug = $2 AND v.option_value_id = ANY($3::int[]))` +
+				` AND cm.option_value_ids && ARRAY(SELECT v.option_value_id FROM option_value v
+		INNER JOIN option op ON op.option_id = v.option_id WHERE op.slug = $4 AND v.option_value_id = ANY($5::int[]))`,
+			orderBy:    "extract(epoch from cm.created_at) DESC, cm.product_model_id DESC",
+			pagination: "LIMIT $6",
+			args:       []any{"shoes", "color", []int{3, 5}, "season", []int{9}, pagination.DefaultLimit + 1},
+		},
+		{
+			name:       "page number",
+			params:     CatalogParams{Slug: "shoes", Page: pagination.Page{Limit: 16, Offset: 32}},
+			where:      base,
+			orderBy:    "cm.order_count DESC, cm.product_model_id DESC",
+			pagination: "LIMIT $2 OFFSET $3",
+			args:       []any{"shoes", 17, 32},
+		},
+		{
+			name: "cursor",
+			params: CatalogParams{Slug: "shoes", Brands: []int{4}, SortBy: SortPriceDesc,
+				Page: pagination.Page{Limit: 5, Cursor: &pagination.Cursor{Sort: string(SortPriceDesc), Key: "990", Id: "17"}}},
+			where:      base + " AND cm.brand_id = ANY($2::int[])",
+			after:      " AND (cm.price, cm.product_model_id) < ($3::text::numeric, $4::text::int)",
+			orderBy:    "cm.price DESC, cm.product_model_id DESC",
+			pagination: "LIMIT $5",
+			args:       []any{"shoes", []int{4}, "990", "17", 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GenerateCatalogQuery(tt.params)
+
+			if got.Where != tt.where {
+				t.Errorf("where\n got: %s\nwant: %s", got.Where, tt.where)
+			}
+			if got.After != tt.after {
+				t.Errorf("after\n got: %s\nwant: %s", got.After, tt.after)
+			}
+			if got.OrderBy != tt.orderBy {
+				t.Errorf("order by\n got: %s\nwant: %s", got.OrderBy, tt.orderBy)
+			}
+			if got.Pagination != tt.pagination {
+				t.Errorf("pagination\n got: %s\nwant: %s", got.Pagination, tt.pagination)
+			}
+			if !reflect.DeepEqual(got.Args, tt.args) {
+				t.Errorf("args\n got: %#v\nwant: %#v", got.Args, tt.args)
+			}
+		})
+	}
+}
+
+// Whatever passes parsing must reach the query only as arguments.
+func TestCatalogQueryBindsInput(t *testing.T) {
+	params, err := ParseCatalogFilters(CatalogFilters{
+		Slug:    "shoes'; DROP TABLE product; --",
+		Options: map[string]string{"color": "1"},
+		Sizes:   "M'--,L\"",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	q := GenerateCatalogQuery(*params)
+	sql := q.Where + q.After + q.OrderBy + q.Pagination
+
+	for _, s := range []string{"DROP", "'", "\"", "--", "shoes", "color"} {
+		if strings.Contains(sql, s) {
+			t.Errorf("%q leaked into the query: %s", s, sql)
+		}
+	}
+
+	facets := GenerateFacetQuery(*params)
+	for _, f := range facets.Facets {
+		for _, s := range []string{"DROP", "shoes", "color", "M'"} {
+			if strings.Contains(f.Where, s) {
+				t.Errorf("%q leaked into the %s facet: %s", s, f.Kind, f.Where)
+			}
+		}
+	}
+}
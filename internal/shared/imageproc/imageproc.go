@@ -0,0 +1,100 @@
+// Package imageproc turns an uploaded picture into renditions of fixed widths with metadata stripped
+// and orientation applied. Built with the vips tag it uses libvips through bimg and writes WebP,
+// without it falls back to the standard library and writes JPEG. The app refuses to start when IMAGE_FORMAT,
+// webp by default, names another format than the build writes, so a build without the tag needs IMAGE_FORMAT=jpg.
+package imageproc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// Folder is where renditions are stored in the bucket.
+const Folder = "images"
+
+// Main is the rendition that is saved in records holding a single image path,
+// the others sit next to it under the same key.
+const Main = "zoom"
+
+type Variant struct {
+	Name  string
+	Width int
+}
+
+// Variants are ordered from the smallest, a picture narrower than a variant is not enlarged.
+var Variants = []Variant{
+	{Name: "thumb", Width: 240},
+	{Name: "card", Width: 600},
+	{Name: Main, Width: 1600},
+}
+
+type Rendition struct {
+	Variant string
+	Width   int
+	Height  int
+	Data    []byte
+}
+
+// MaxPixels bounds the pictures that are decoded, a decoded picture takes 4 bytes a pixel whatever its file size.
+const MaxPixels = 40_000_000
+
+var (
+	ErrUnsupported = errors.New("unsupported image format")
+	ErrTooLarge    = errors.New("image has too many pixels")
+)
+
+// KeyPattern matches paths of renditions, it is shared with SQL to find records that still hold originals.
+const KeyPattern = `(^|/)` + Folder + `/[0-9a-f]{32}/[a-z]+\.(webp|jpg)$`
+
+var keyRegexp = regexp.MustCompile(KeyPattern)
+
+// Process decodes data once and renders every variant from it.
+func Process(data []byte) ([]Rendition, error) {
+	src, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	renditions := make([]Rendition, 0, len(Variants))
+	for _, v := range Variants {
+		width := v.Width
+		if src.width() < width {
+			width = src.width()
+		}
+
+		out, w, h, err := src.render(width)
+		if err != nil {
+			return nil, fmt.Errorf("render %s: %w", v.Name, err)
+		}
+
+		renditions = append(renditions, Rendition{Variant: v.Name, Width: w, Height: h, Data: out})
+	}
+
+	return renditions, nil
+}
+
+// Key is the storage name of a variant, the same picture always gets the same key.
+func Key(data []byte, variant string) string {
+	sum := sha256.Sum256(data)
+	return path.Join(Folder, hex.EncodeToString(sum[:16]), variant+"."+Ext)
+}
+
+// IsKey tells whether p already points at a rendition.
+func IsKey(p string) bool {
+	return keyRegexp.MatchString(p)
+}
+
+// Fits reports whether a picture of width by height pixels may be decoded.
+func Fits(width int, height int) bool {
+	return width > 0 && height > 0 && int64(width)*int64(height) <= MaxPixels
+}
+
+// Supported reports whether the content type is a raster format the pipeline decodes.
+func Supported(contentType string) bool {
+	_, ok := formats[contentType]
+	return ok
+}
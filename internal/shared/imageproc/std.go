@@ -0,0 +1,207 @@
+//go:build !vips
+
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+)
+
+const (
+	Ext         = "jpg"
+	ContentType = "image/jpeg"
+	quality     = 85
+)
+
+var formats = map[string]struct{}{
+	"image/jpeg": {},
+	"image/png":  {},
+	"image/gif":  {},
+}
+
+type source struct {
+	img *image.RGBA
+}
+
+// decode flattens the picture onto white, JPEG has no alpha, and turns it upright by its EXIF orientation.
+// Re-encoding with image/jpeg writes no metadata, so EXIF is dropped along the way.
+func decode(data []byte) (*source, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if err == image.ErrFormat {
+			return nil, ErrUnsupported
+		}
+		return nil, err
+	}
+	if !Fits(cfg.Width, cfg.Height) {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+
+	return &source{img: orient(flat, orientation(data))}, nil
+}
+
+func (s *source) width() int {
+	return s.img.Bounds().Dx()
+}
+
+func (s *source) render(width int) ([]byte, int, int, error) {
+	out := resize(s.img, width)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, out, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, 0, 0, err
+	}
+
+	return buf.Bytes(), out.Bounds().Dx(), out.Bounds().Dy(), nil
+}
+
+// resize scales down to width keeping the aspect ratio, every target pixel is the average of the box it covers.
+func resize(src *image.RGBA, width int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if width >= sw {
+		return src
+	}
+
+	height := max(1, (sh*width+sw/2)/sw)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, max((y+1)*sh/height, y*sh/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, max((x+1)*sw/width, x*sw/width+1)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// orient applies an EXIF orientation, 5 to 8 swap width and height.
+func orient(src *image.RGBA, o int) *image.RGBA {
+	if o < 2 || o > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch o {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+
+	return dst
+}
+
+// orientation reads the Orientation tag from the EXIF segment of a JPEG, 1 when there is none.
+func orientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || size < 2 || i+2+size > len(data) {
+			return 1
+		}
+
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(segment) > 14 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+
+		i += 2 + size
+	}
+
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 1
+}
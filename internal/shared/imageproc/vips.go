@@ -0,0 +1,76 @@
+//go:build vips
+
+package imageproc
+
+import "github.com/h2non/bimg"
+
+const (
+	Ext         = "webp"
+	ContentType = "image/webp"
+	quality     = 80
+)
+
+var formats = map[string]struct{}{
+	"image/jpeg": {},
+	"image/png":  {},
+	"image/gif":  {},
+	"image/webp": {},
+	"image/tiff": {},
+	"image/heif": {},
+	"image/avif": {},
+}
+
+type source struct {
+	data []byte
+	size bimg.ImageSize
+}
+
+func decode(data []byte) (*source, error) {
+	if !bimg.IsImageTypeSupportedByVips(bimg.DetermineImageType(data)).Load {
+		return nil, ErrUnsupported
+	}
+
+	// the header is read without decoding the pixels
+	original, err := bimg.NewImage(data).Size()
+	if err != nil {
+		return nil, err
+	}
+	if !Fits(original.Width, original.Height) {
+		return nil, ErrTooLarge
+	}
+
+	rotated, err := bimg.NewImage(data).AutoRotate()
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := bimg.NewImage(rotated).Size()
+	if err != nil {
+		return nil, err
+	}
+
+	return &source{data: rotated, size: size}, nil
+}
+
+func (s *source) width() int {
+	return s.size.Width
+}
+
+func (s *source) render(width int) ([]byte, int, int, error) {
+	out, err := bimg.NewImage(s.data).Process(bimg.Options{
+		Width:         width,
+		Type:          bimg.WEBP,
+		Quality:       quality,
+		StripMetadata: true,
+	})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	size, err := bimg.NewImage(out).Size()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return out, size.Width, size.Height, nil
+}
@@ -0,0 +1,128 @@
+package keyboard
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	latinKeys    = "qwertyuiop[]asdfghjkl;'zxcvbnm,.`"
+	cyrillicKeys = "йцукенгшщзхъфывапролджэячсмитьбюё"
+)
+
+var toCyrillic, toLatin = func() (map[rune]rune, map[rune]rune) {
+	latin := []rune(latinKeys)
+	cyrillic := []rune(cyrillicKeys)
+	lc := make(map[rune]rune, len(latin))
+	cl := make(map[rune]rune, len(cyrillic))
+	for i := range latin {
+		lc[latin[i]] = cyrillic[i]
+		cl[cyrillic[i]] = latin[i]
+	}
+	return lc, cl
+}()
+
+// translit is ordered so that longer sequences are replaced first.
+var translit = []struct {
+	latin    string
+	cyrillic string
+}{
+	{"shch", "щ"}, {"sch", "щ"}, {"yo", "ё"}, {"zh", "ж"}, {"kh", "х"}, {"ts", "ц"}, {"ch", "ч"},
+	{"sh", "ш"}, {"yu", "ю"}, {"ya", "я"}, {"a", "а"}, {"b", "б"}, {"v", "в"}, {"g", "г"},
+	{"d", "д"}, {"e", "е"}, {"z", "з"}, {"i", "и"}, {"j", "й"}, {"y", "ы"}, {"k", "к"}, {"l", "л"},
+	{"m", "м"}, {"n", "н"}, {"o", "о"}, {"p", "п"}, {"r", "р"}, {"s", "с"}, {"t", "т"}, {"u", "у"},
+	{"f", "ф"}, {"h", "х"}, {"c", "к"}, {"w", "в"}, {"x", "кс"}, {"q", "к"},
+}
+
+// SwitchLayout retypes text entered with the wrong keyboard layout: "rehnrf" becomes "куртка" and "тшлу" becomes "nike".
+func SwitchLayout(s string) string {
+	s = strings.ToLower(s)
+
+	var table map[rune]rune
+	switch {
+	case hasLatin(s) && !hasCyrillic(s):
+		table = toCyrillic
+	case hasCyrillic(s) && !hasLatin(s):
+		table = toLatin
+	default:
+		return s
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		if c, ok := table[r]; ok {
+			b.WriteRune(c)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Transliterate turns latin transliteration into cyrillic: "kurtka" becomes "куртка".
+func Transliterate(s string) string {
+	s = strings.ToLower(s)
+	var b strings.Builder
+	for len(s) > 0 {
+		matched := false
+		for _, t := range translit {
+			if strings.HasPrefix(s, t.latin) {
+				b.WriteString(t.cyrillic)
+				s = s[len(t.latin):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			r := []rune(s)[0]
+			b.WriteRune(r)
+			s = s[len(string(r)):]
+		}
+	}
+	return b.String()
+}
+
+// Variants returns the normalized term followed by its layout-switched and transliterated forms, without duplicates.
+func Variants(term string) []string {
+	term = strings.Join(strings.Fields(strings.ToLower(term)), " ")
+	if term == "" {
+		return nil
+	}
+
+	variants := []string{term}
+
+	add := func(v string) {
+		for _, existing := range variants {
+			if existing == v {
+				return
+			}
+		}
+		variants = append(variants, v)
+	}
+
+	add(SwitchLayout(term))
+
+	if hasLatin(term) && !hasCyrillic(term) {
+		add(Transliterate(term))
+	}
+
+	return variants
+}
+
+func hasLatin(s string) bool {
+	for _, r := range s {
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasCyrillic(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package keyboard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSwitchLayout(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"ghbdtn", "привет"},
+		{"rehnrf", "куртка"},
+		{"GHBDTN", "привет"},
+		{",jnbyrb", "ботинки"},
+		{"[jkjl", "холод"},
+		{"тшлу", "nike"},
+		{"руддщ", "hello"},
+		{"ёлка", "`krf"},
+		{"nike найк", "nike найк"},
+		{"42", "42"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := SwitchLayout(tt.in); got != tt.want {
+				t.Errorf("SwitchLayout(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"kurtka", "куртка"},
+		{"Kurtka", "куртка"},
+		{"zhurnal", "журнал"},
+		{"shchi", "щи"},
+		{"borsch", "борщ"},
+		{"yolka", "ёлка"},
+		{"tsvet", "цвет"},
+		{"xbox", "ксбокс"},
+		{"kurtka 2", "куртка 2"},
+		{"куртка", "куртка"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Transliterate(tt.in); got != tt.want {
+				t.Errorf("Transliterate(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"  Kurtka  ", []string{"kurtka", "лгкелф", "куртка"}},
+		{"rehnrf", []string{"rehnrf", "куртка", "рехнрф"}},
+		{"тшлу", []string{"тшлу", "nike"}},
+		{"nike найк", []string{"nike найк"}},
+		{"   ", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Variants(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Variants(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
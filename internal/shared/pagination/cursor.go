@@ -0,0 +1,131 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+const (
+	DefaultLimit = 16
+	MaxLimit     = 100
+	// MaxNumber bounds the offset of page number requests, deeper pages are reached with the cursor.
+	MaxNumber = 1000
+)
+
+const (
+	invalidCursor = "Некорректный курсор пагинации!"
+	invalidLimit  = "Лимит должен быть числом от 1 до 100!"
+	invalidNumber = "Номер страницы должен быть числом от 1 до 1000!"
+)
+
+// Cursor points at the last row of a page: the value of the active sort key and the row id as a tie breaker.
+// Sort names the ordering the cursor was issued for, so it can't be replayed against another one.
+type Cursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	Id   string `json:"i"`
+}
+
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Page is a parsed cursor request, Cursor is nil for the first page.
+// Sort is the ordering to continue with: the cursor's one or the default.
+// Offset serves clients that still ask for a page number, it is zero whenever a cursor is given.
+type Page struct {
+	Cursor *Cursor
+	Limit  int
+	Sort   string
+	Offset int
+}
+
+type ParamError struct {
+	Key     string
+	Message string
+}
+
+func (e *ParamError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+// Parse reads the cursor and limit query parameters for a list ordered by sort.
+// A list that can switch between several orderings passes them all, the first one is the default.
+func Parse(rawCursor string, rawLimit string, sort string, alternatives ...string) (*Page, *ParamError) {
+	page := &Page{Limit: DefaultLimit, Sort: sort}
+
+	if rawLimit != "" {
+		limit, err := strconv.Atoi(rawLimit)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return nil, &ParamError{Key: "limit", Message: invalidLimit}
+		}
+		page.Limit = limit
+	}
+
+	if rawCursor == "" {
+		return page, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(rawCursor)
+	if err != nil {
+		return nil, &ParamError{Key: "cursor", Message: invalidCursor}
+	}
+
+	cursor := Cursor{}
+	err = json.Unmarshal(raw, &cursor)
+	if err != nil || cursor.Key == "" || cursor.Id == "" {
+		return nil, &ParamError{Key: "cursor", Message: invalidCursor}
+	}
+
+	if cursor.Sort != sort && !slices.Contains(alternatives, cursor.Sort) {
+		return nil, &ParamError{Key: "cursor", Message: invalidCursor}
+	}
+
+	page.Cursor = &cursor
+	page.Sort = cursor.Sort
+
+	return page, nil
+}
+
+// Number applies a page number, which predates the cursor and is still accepted. A cursor takes precedence over it.
+func (p *Page) Number(rawNumber string) *ParamError {
+	if rawNumber == "" || p.Cursor != nil {
+		return nil
+	}
+
+	number, err := strconv.Atoi(rawNumber)
+	if err != nil || number < 1 || number > MaxNumber {
+		return &ParamError{Key: "page", Message: invalidNumber}
+	}
+	p.Offset = (number - 1) * p.Limit
+
+	return nil
+}
+
+// After returns a condition that keeps rows following the cursor for (key, id) ordered in one direction,
+// key and id are placeholders that the caller binds to Cursor.Key and Cursor.Id.
+func After(keyColumn string, idColumn string, desc bool, key string, id string) string {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	return fmt.Sprintf("(%s, %s) %s (%s, %s)", keyColumn, idColumn, op, key, id)
+}
+
+// Trim cuts a result fetched with limit + 1 rows down to the page and returns the cursor of the next page,
+// or nil when there is none.
+func Trim[T any](items []T, limit int, sort string, cursor func(T) (key string, id string)) ([]T, *string) {
+	if len(items) <= limit {
+		return items, nil
+	}
+
+	items = items[:limit]
+	key, id := cursor(items[limit-1])
+	next := Cursor{Sort: sort, Key: key, Id: id}.Encode()
+
+	return items, &next
+}
@@ -0,0 +1,216 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownOutcome  = errors.New("unknown payment outcome, expected pending, succeeded or canceled")
+	ErrPaymentNotFound = errors.New("платеж не найден")
+)
+
+// validOutcome reports whether a payment can be resolved to status, the statuses are the gateway's own.
+func validOutcome(status string) bool {
+	return status == StatusPending || status == StatusSucceeded || status == StatusCanceled
+}
+
+// FakeProvider is an in-process gateway for local runs and tests.
+// A new payment is pending until it is checked for the first time,
+// then it resolves to the configured outcome. Refunds may be partial.
+type FakeProvider struct {
+	mu       sync.Mutex
+	appLink  string
+	outcome  string
+	payments map[string]*OrderPayment
+	refunds  map[string]*RefundResponse
+	created  map[string]*Payment
+	keys     map[string]string
+}
+
+func NewFakeProvider(appLink string, outcome string) (*FakeProvider, error) {
+	if outcome == "" {
+		outcome = StatusSucceeded
+	}
+	if !validOutcome(outcome) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownOutcome, outcome)
+	}
+	return &FakeProvider{
+		appLink:  appLink,
+		outcome:  outcome,
+		payments: make(map[string]*OrderPayment),
+		refunds:  make(map[string]*RefundResponse),
+		created:  make(map[string]*Payment),
+		keys:     make(map[string]string),
+	}, nil
+}
+
+// SetOutcome changes the outcome for payments that are still pending.
+func (f *FakeProvider) SetOutcome(outcome string) error {
+	if !validOutcome(outcome) {
+		return ErrUnknownOutcome
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.outcome = outcome
+	return nil
+}
+
+// SetStatus forces the status of a single payment.
+func (f *FakeProvider) SetStatus(paymentId string, status string) error {
+	if !validOutcome(status) {
+		return ErrUnknownOutcome
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.payments[paymentId]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	f.setStatus(p, status)
+	return nil
+}
+
+func (f *FakeProvider) CreatePayment(orderId string, totalPrice float64, idempotenceKey string) (*Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.keys[idempotenceKey]; ok {
+		res := *f.created[id]
+		return &res, nil
+	}
+
+	now := time.Now()
+	amount := Amount{Value: formatAmount(totalPrice), Currency: "RUB"}
+	description := fmt.Sprintf("Оплата заказа №%s в магазине FamilyModa", orderId)
+
+	p := &OrderPayment{
+		ID:          uuid.New().String(),
+		Status:      StatusPending,
+		Amount:      amount,
+		CreatedAt:   now,
+		Description: description,
+		ExpiresAt:   now.Add(time.Hour),
+		Test:        true,
+	}
+	f.payments[p.ID] = p
+
+	created := &Payment{
+		ID:     p.ID,
+		Status: p.Status,
+		Amount: amount,
+		Confirmation: ConfirmationResponse{
+			Type:            "redirect",
+			ConfirmationURL: f.appLink + "/api/order/confirm-online-payment/" + orderId,
+		},
+		CreatedAt:   now,
+		Description: description,
+		Test:        true,
+	}
+	f.created[p.ID] = created
+	f.keys[idempotenceKey] = p.ID
+
+	res := *created
+	return &res, nil
+}
+
+func (f *FakeProvider) CheckPayment(paymentId string) (*OrderPayment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[paymentId]
+	if !ok {
+		return nil, fmt.Errorf("ошибка при получении платежа, http код: %d", 404)
+	}
+	if p.Status == StatusPending {
+		f.setStatus(p, f.outcome)
+	}
+	res := *p
+	return &res, nil
+}
+
+func (f *FakeProvider) CheckRefund(refundId string) (*RefundResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r, ok := f.refunds[refundId]
+	if !ok {
+		return nil, fmt.Errorf("ошибка при получении возврата, http код: %d", 404)
+	}
+	res := *r
+	return &res, nil
+}
+
+func (f *FakeProvider) RefundPayment(paymentId string, totalPrice float64, idempotenceKey string) (*RefundResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.keys[idempotenceKey]; ok {
+		res := *f.refunds[id]
+		return &res, nil
+	}
+
+	p, ok := f.payments[paymentId]
+	if !ok || p.Status != StatusSucceeded {
+		return nil, errors.New("ошибка при оформлении возврата")
+	}
+
+	r := &RefundResponse{
+		Id:        uuid.New().String(),
+		Amount:    Amount{Value: formatAmount(totalPrice), Currency: "RUB"},
+		CreatedAt: time.Now(),
+		PaymentId: paymentId,
+	}
+
+	refunded := 0.0
+	if p.RefundedAmount != nil {
+		refunded = parseAmount(p.RefundedAmount.Value)
+	}
+	left := parseAmount(p.Amount.Value) - refunded
+
+	if totalPrice <= 0 || math.Round(totalPrice*100) > math.Round(left*100) {
+		r.Status = StatusCanceled
+		r.RefundDetails = &RefundDetails{Party: "yoo_money", Reason: "insufficient_funds"}
+	} else {
+		r.Status = StatusSucceeded
+		p.RefundedAmount = &Amount{Value: formatAmount(refunded + totalPrice), Currency: "RUB"}
+		p.Refundable = math.Round((left-totalPrice)*100) > 0
+	}
+
+	f.refunds[r.Id] = r
+	f.keys[idempotenceKey] = r.Id
+	res := *r
+	return &res, nil
+}
+
+func (f *FakeProvider) CancelPayment(paymentId string, idempotenceKey string) (*OrderPayment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[paymentId]
+	if !ok {
+		return nil, fmt.Errorf("ошибка при отмене платежа, http код: %d", 404)
+	}
+	if p.Status == StatusCanceled && f.keys[idempotenceKey] == paymentId {
+		res := *p
+		return &res, nil
+	}
+	if p.Status != StatusPending {
+		return nil, fmt.Errorf("ошибка при отмене платежа, http код: %d", 400)
+	}
+	f.keys[idempotenceKey] = paymentId
+	f.setStatus(p, StatusCanceled)
+	res := *p
+	return &res, nil
+}
+
+func (f *FakeProvider) setStatus(p *OrderPayment, status string) {
+	p.Status = status
+	p.Paid = status == StatusSucceeded
+	p.Refundable = status == StatusSucceeded
+}
@@ -2,6 +2,18 @@ package payment
 
 import "time"
 
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentCanceled  = "payment.canceled"
+	EventRefundSucceeded  = "refund.succeeded"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusCanceled  = "canceled"
+)
+
 type Payment struct {
 	ID           string               `json:"id"`
 	Status       string               `json:"status"`
@@ -67,17 +79,18 @@ type PaymentMethodCardProduct struct {
 }
 
 type OrderPayment struct {
-	ID            string        `json:"id"`
-	Status        string        `json:"status"`
-	Paid          bool          `json:"paid"`
-	Amount        Amount        `json:"amount"`
-	CreatedAt     time.Time     `json:"created_at"`
-	Description   string        `json:"description"`
-	ExpiresAt     time.Time     `json:"expires_at"`
-	PaymentMethod PaymentMethod `json:"payment_method"`
-	Recipient     Recipient     `json:"recipient"`
-	Refundable    bool          `json:"refundable"`
-	Test          bool          `json:"test"`
+	ID             string        `json:"id"`
+	Status         string        `json:"status"`
+	Paid           bool          `json:"paid"`
+	Amount         Amount        `json:"amount"`
+	RefundedAmount *Amount       `json:"refunded_amount"`
+	CreatedAt      time.Time     `json:"created_at"`
+	Description    string        `json:"description"`
+	ExpiresAt      time.Time     `json:"expires_at"`
+	PaymentMethod  PaymentMethod `json:"payment_method"`
+	Recipient      Recipient     `json:"recipient"`
+	Refundable     bool          `json:"refundable"`
+	Test           bool          `json:"test"`
 }
 
 type RefundDto struct {
@@ -98,3 +111,15 @@ type RefundResponse struct {
 	PaymentId     string         `json:"payment_id"`
 	RefundDetails *RefundDetails `json:"cancellation_details"`
 }
+
+type Notification struct {
+	Type   string             `json:"type"`
+	Event  string             `json:"event"`
+	Object NotificationObject `json:"object"`
+}
+
+type NotificationObject struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	PaymentId string `json:"payment_id"`
+}
@@ -0,0 +1,52 @@
+package payment
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	YooKassa = "yookassa"
+	Fake     = "fake"
+)
+
+// PaymentProvider calls that change state take an idempotence key,
+// so a retried call returns the result of the first one instead of repeating it.
+type PaymentProvider interface {
+	CreatePayment(orderId string, totalPrice float64, idempotenceKey string) (*Payment, error)
+	CheckPayment(paymentId string) (*OrderPayment, error)
+	CheckRefund(refundId string) (*RefundResponse, error)
+	RefundPayment(paymentId string, totalPrice float64, idempotenceKey string) (*RefundResponse, error)
+	CancelPayment(paymentId string, idempotenceKey string) (*OrderPayment, error)
+}
+
+type ProviderConfig struct {
+	Provider    string
+	ShopId      string
+	SecretKey   string
+	AppLink     string
+	FakeOutcome string
+}
+
+func NewPaymentProvider(cfg ProviderConfig) (PaymentProvider, error) {
+	switch cfg.Provider {
+	case YooKassa, "":
+		return NewYooKassaProvider(cfg.ShopId, cfg.SecretKey, cfg.AppLink), nil
+	case Fake:
+		fake, err := NewFakeProvider(cfg.AppLink, cfg.FakeOutcome)
+		if err != nil {
+			return nil, err
+		}
+		return fake, nil
+	}
+	return nil, fmt.Errorf("unknown payment provider: %s", cfg.Provider)
+}
+
+func formatAmount(value float64) string {
+	return fmt.Sprintf("%.2f", value)
+}
+
+func parseAmount(value string) float64 {
+	v, _ := strconv.ParseFloat(value, 64)
+	return v
+}
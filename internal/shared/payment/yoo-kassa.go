@@ -11,11 +11,10 @@ import (
 	"log"
 	"net/http"
 
-	"github.com/google/uuid"
 	"github.com/maximfedotov74/diploma-backend/internal/shared/fall"
 )
 
-type PaymentService struct {
+type YooKassaProvider struct {
 	shopId     string
 	secretKey  string
 	appLink    string
@@ -25,11 +24,11 @@ type PaymentService struct {
 const paymentUrl = "https://api.yookassa.ru/v3/payments"
 const refundUrl = "https://api.yookassa.ru/v3/refunds"
 
-func NewPaymentService(shopId string, secretKey string, appLink string) *PaymentService {
-	return &PaymentService{shopId: shopId, secretKey: secretKey, appLink: appLink, httpClient: &http.Client{}}
+func NewYooKassaProvider(shopId string, secretKey string, appLink string) *YooKassaProvider {
+	return &YooKassaProvider{shopId: shopId, secretKey: secretKey, appLink: appLink, httpClient: &http.Client{}}
 }
 
-func (ps *PaymentService) CheckPayment(paymentId string) (*OrderPayment, error) {
+func (ps *YooKassaProvider) CheckPayment(paymentId string) (*OrderPayment, error) {
 
 	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%s", paymentUrl, paymentId), nil)
 	if err != nil {
@@ -63,10 +62,44 @@ func (ps *PaymentService) CheckPayment(paymentId string) (*OrderPayment, error)
 	return &p, nil
 }
 
-func (ps *PaymentService) RefundPayment(paymentId string, totalPrice float64) (*RefundResponse, error) {
+func (ps *YooKassaProvider) CheckRefund(refundId string) (*RefundResponse, error) {
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%s", refundUrl, refundId), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	authString := fmt.Sprintf("%s:%s", ps.shopId, ps.secretKey)
+	authBase64 := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(authString)))
+	req.Header.Set("Authorization", authBase64)
+
+	response, err := ps.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	bytes, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("ошибка при получении возврата, http код: %d", response.StatusCode)
+	}
+
+	var r RefundResponse
+	err = json.Unmarshal(bytes, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (ps *YooKassaProvider) RefundPayment(paymentId string, totalPrice float64, idempotenceKey string) (*RefundResponse, error) {
 
 	dto := RefundDto{
-		Amount:    Amount{Value: fmt.Sprintf("%.2f", totalPrice), Currency: "RUB"},
+		Amount:    Amount{Value: formatAmount(totalPrice), Currency: "RUB"},
 		PaymentId: paymentId,
 	}
 
@@ -79,7 +112,6 @@ func (ps *PaymentService) RefundPayment(paymentId string, totalPrice float64) (*
 	if err != nil {
 		return nil, err
 	}
-	idempotenceKey := uuid.New().String()
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Idempotence-Key", idempotenceKey)
 	authString := fmt.Sprintf("%s:%s", ps.shopId, ps.secretKey)
@@ -112,9 +144,9 @@ func (ps *PaymentService) RefundPayment(paymentId string, totalPrice float64) (*
 	return &p, nil
 }
 
-func (ps *PaymentService) CreatePayment(orderId string, totalPrice float64) (*Payment, error) {
+func (ps *YooKassaProvider) CreatePayment(orderId string, totalPrice float64, idempotenceKey string) (*Payment, error) {
 	dto := PaymentDto{
-		Amount:      Amount{Value: fmt.Sprintf("%.2f", totalPrice), Currency: "RUB"},
+		Amount:      Amount{Value: formatAmount(totalPrice), Currency: "RUB"},
 		Capture:     true,
 		Description: fmt.Sprintf("Оплата заказа №%s в магазине FamilyModa", orderId),
 		Confirmation: Confirmation{
@@ -130,7 +162,6 @@ func (ps *PaymentService) CreatePayment(orderId string, totalPrice float64) (*Pa
 	if err != nil {
 		return nil, err
 	}
-	idempotenceKey := uuid.New().String()
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Idempotence-Key", idempotenceKey)
 	authString := fmt.Sprintf("%s:%s", ps.shopId, ps.secretKey)
@@ -155,3 +186,33 @@ func (ps *PaymentService) CreatePayment(orderId string, totalPrice float64) (*Pa
 	}
 	return &p, nil
 }
+
+func (ps *YooKassaProvider) CancelPayment(paymentId string, idempotenceKey string) (*OrderPayment, error) {
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s/cancel", paymentUrl, paymentId), bytes.NewReader([]byte("{}")))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotence-Key", idempotenceKey)
+	authString := fmt.Sprintf("%s:%s", ps.shopId, ps.secretKey)
+	authBase64 := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(authString)))
+	req.Header.Set("Authorization", authBase64)
+	response, err := ps.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	bytes, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("ошибка при отмене платежа, http код: %d", response.StatusCode)
+	}
+	var p OrderPayment
+	err = json.Unmarshal(bytes, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
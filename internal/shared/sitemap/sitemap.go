@@ -0,0 +1,96 @@
+// Package sitemap writes sitemaps.org urlsets and the index that lists them.
+package sitemap
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// MaxUrls is the protocol limit of urls in one sitemap file.
+const MaxUrls = 50000
+
+const xmlns = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type Url struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type Sitemap struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type urlEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type urlSet struct {
+	XMLName xml.Name   `xml:"urlset"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	Urls    []urlEntry `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name   `xml:"sitemapindex"`
+	Xmlns    string     `xml:"xmlns,attr"`
+	Sitemaps []urlEntry `xml:"sitemap"`
+}
+
+// Split cuts urls into chunks of at most MaxUrls, one per sitemap file.
+func Split(urls []Url) [][]Url {
+	chunks := [][]Url{}
+	for len(urls) > MaxUrls {
+		chunks = append(chunks, urls[:MaxUrls])
+		urls = urls[MaxUrls:]
+	}
+	if len(urls) > 0 || len(chunks) == 0 {
+		chunks = append(chunks, urls)
+	}
+	return chunks
+}
+
+// LastMod is the latest modification among urls, the lastmod of the sitemap that holds them.
+func LastMod(urls []Url) time.Time {
+	var last time.Time
+	for _, u := range urls {
+		if u.LastMod.After(last) {
+			last = u.LastMod
+		}
+	}
+	return last
+}
+
+func WriteUrlSet(w io.Writer, urls []Url) error {
+	set := urlSet{Xmlns: xmlns, Urls: make([]urlEntry, 0, len(urls))}
+	for _, u := range urls {
+		set.Urls = append(set.Urls, urlEntry{Loc: u.Loc, LastMod: formatTime(u.LastMod)})
+	}
+	return write(w, set)
+}
+
+func WriteIndex(w io.Writer, sitemaps []Sitemap) error {
+	index := sitemapIndex{Xmlns: xmlns, Sitemaps: make([]urlEntry, 0, len(sitemaps))}
+	for _, s := range sitemaps {
+		index.Sitemaps = append(index.Sitemaps, urlEntry{Loc: s.Loc, LastMod: formatTime(s.LastMod)})
+	}
+	return write(w, index)
+}
+
+func write(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
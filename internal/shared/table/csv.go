@@ -0,0 +1,51 @@
+package table
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+)
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// readCSV accepts both comma and semicolon separated files, Excel with a russian locale saves the latter.
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+
+	comma := ','
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		comma = ';'
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+
+	var rows [][]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == MaxRows || len(record) > MaxColumns {
+			return nil, ErrTooLarge
+		}
+		rows = append(rows, record)
+	}
+}
+
+// writeCSV starts the file with a BOM so Excel opens it as UTF-8.
+func writeCSV(w io.Writer, rows [][]string) error {
+	if _, err := w.Write(utf8BOM); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
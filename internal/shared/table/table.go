@@ -0,0 +1,88 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	CSV  = "csv"
+	XLSX = "xlsx"
+)
+
+// Limits of what Read accepts. MaxColumns is the widest sheet Excel makes, XFD, MaxCells bounds the rows
+// once they are padded to the same width.
+const (
+	MaxRows    = 100_000
+	MaxColumns = 16_384
+	MaxCells   = 2_000_000
+)
+
+var ErrTooLarge = errors.New("table: too many rows or columns")
+
+// FormatFromName picks the table format by file extension, it returns an empty string for unknown ones.
+func FormatFromName(name string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), ".")); ext {
+	case CSV, XLSX:
+		return ext
+	}
+	return ""
+}
+
+func ContentType(format string) string {
+	if format == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Read returns the rows of the first sheet, every row is padded to the width of the widest one.
+func Read(data []byte, format string) ([][]string, error) {
+	var rows [][]string
+	var err error
+
+	switch format {
+	case CSV:
+		rows, err = readCSV(data)
+	case XLSX:
+		rows, err = readXLSX(data)
+	default:
+		return nil, fmt.Errorf("unknown table format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) > MaxRows {
+		return nil, ErrTooLarge
+	}
+
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	if width > MaxColumns || width*len(rows) > MaxCells {
+		return nil, ErrTooLarge
+	}
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		rows[i] = row
+	}
+
+	return rows, nil
+}
+
+func Write(w io.Writer, format string, rows [][]string) error {
+	switch format {
+	case CSV:
+		return writeCSV(w, rows)
+	case XLSX:
+		return writeXLSX(w, rows)
+	}
+	return fmt.Errorf("unknown table format: %s", format)
+}
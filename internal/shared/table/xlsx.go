@@ -0,0 +1,307 @@
+package table
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Only the parts of SpreadsheetML needed for plain tables are handled: the first sheet,
+// shared and inline strings, numbers and booleans. Formulas are read by their cached values.
+
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.Text)
+	}
+	return b.String()
+}
+
+type xlsxCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelId string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Items []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+var (
+	errNoSheet = errors.New("xlsx: workbook has no sheets")
+	errRef     = errors.New("xlsx: invalid cell reference")
+)
+
+// maxPartSize bounds an unpacked part of the archive, a few kilobytes of zip can unpack to gigabytes of XML.
+const maxPartSize = 64 << 20
+
+func readXLSX(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeZipXML(f, &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, errNoSheet
+	}
+	var sheet xlsxWorksheet
+	if err := decodeZipXML(f, &sheet); err != nil {
+		return nil, err
+	}
+
+	if len(sheet.Rows) > MaxRows {
+		return nil, ErrTooLarge
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, r := range sheet.Rows {
+		var row []string
+		for i, c := range r.Cells {
+			col := i
+			if c.Ref != "" {
+				idx, err := columnIndex(c.Ref)
+				if err != nil {
+					return nil, err
+				}
+				col = idx
+			}
+			if col >= MaxColumns {
+				return nil, ErrTooLarge
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+			row[col] = cellValue(c, shared)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	wf, ok := files["xl/workbook.xml"]
+	if !ok {
+		return fallback, nil
+	}
+	var wb xlsxWorkbook
+	if err := decodeZipXML(wf, &wb); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", errNoSheet
+	}
+
+	rf, ok := files["xl/_rels/workbook.xml.rels"]
+	if !ok {
+		return fallback, nil
+	}
+	var rels xlsxRelationships
+	if err := decodeZipXML(rf, &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Items {
+		if rel.Id != wb.Sheets[0].RelId {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return fallback, nil
+}
+
+func cellValue(c xlsxCell, shared xlsxSharedStrings) string {
+	switch c.Type {
+	case "s":
+		idx, err := strconv.Atoi(c.Value)
+		if err != nil || idx < 0 || idx >= len(shared.Items) {
+			return ""
+		}
+		return shared.Items[idx].String()
+	case "inlineStr":
+		return c.Inline.String()
+	case "b":
+		if c.Value == "1" {
+			return "true"
+		}
+		return "false"
+	}
+	return c.Value
+}
+
+// columnIndex turns the letters of a cell reference like "AB12" into a zero based column index.
+// References past XFD, the last column Excel has, are refused.
+func columnIndex(ref string) (int, error) {
+	idx := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		if n == 3 {
+			return 0, errRef
+		}
+		idx = idx*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 {
+		return 0, errRef
+	}
+	if idx > MaxColumns {
+		return 0, ErrTooLarge
+	}
+	return idx - 1, nil
+}
+
+func columnName(idx int) string {
+	name := ""
+	for idx++; idx > 0; idx = (idx - 1) / 26 {
+		name = string(rune('A'+(idx-1)%26)) + name
+	}
+	return name
+}
+
+func decodeZipXML(f *zip.File, v any) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if f.UncompressedSize64 > maxPartSize {
+		return ErrTooLarge
+	}
+	// the declared size is only a hint, the reader enforces it
+	return xml.NewDecoder(io.LimitReader(rc, maxPartSize)).Decode(v)
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+	xlsxWorkbookXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+)
+
+// writeXLSX stores every cell as an inline string, so values like articles with leading zeros survive a round trip.
+func writeXLSX(w io.Writer, rows [][]string) error {
+	zw := zip.NewWriter(w)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbookXML},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, p := range parts {
+		fw, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, p.content); err != nil {
+			return err
+		}
+	}
+
+	fw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&b, `<row r="%d">`, i+1)
+		for j, value := range row {
+			fmt.Fprintf(&b, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, columnName(j), i+1)
+			if err := xml.EscapeText(&b, []byte(value)); err != nil {
+				return err
+			}
+			b.WriteString(`</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+
+	if _, err := fw.Write(b.Bytes()); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
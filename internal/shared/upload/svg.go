@@ -0,0 +1,75 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// activeElements can run script or embed other documents.
+var activeElements = map[string]bool{
+	"script": true, "foreignobject": true, "iframe": true, "embed": true, "object": true, "handler": true,
+	"listener": true,
+}
+
+func isSvg(data []byte) bool {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if el, ok := tok.(xml.StartElement); ok {
+			return strings.EqualFold(el.Name.Local, "svg")
+		}
+	}
+}
+
+// safeSvg walks the whole document: no DTD, no script elements, no event handlers
+// and no links anywhere but to fragments of the same document.
+func safeSvg(data []byte) bool {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = true
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+
+		switch t := tok.(type) {
+		case xml.Directive:
+			return false
+		case xml.ProcInst:
+			if t.Target != "xml" {
+				return false
+			}
+		case xml.StartElement:
+			if activeElements[strings.ToLower(t.Name.Local)] {
+				return false
+			}
+			for _, attr := range t.Attr {
+				name := strings.ToLower(attr.Name.Local)
+				value := strings.ToLower(strings.Join(strings.Fields(attr.Value), ""))
+				if strings.HasPrefix(name, "on") {
+					return false
+				}
+				if name == "href" && !strings.HasPrefix(value, "#") {
+					return false
+				}
+				if strings.Contains(value, "javascript:") || strings.Contains(value, "url(http") {
+					return false
+				}
+			}
+		case xml.CharData:
+			if bytes.Contains(bytes.ToLower(t), []byte("@import")) {
+				return false
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package upload decides what may be uploaded for a purpose: the type is sniffed from the content,
+// never taken from the client, and files that are something else besides a picture are refused.
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/maximfedotov74/diploma-backend/internal/shared/imageproc"
+)
+
+const (
+	Jpeg = "image/jpeg"
+	Png  = "image/png"
+	Gif  = "image/gif"
+	Webp = "image/webp"
+	Svg  = "image/svg+xml"
+)
+
+const (
+	PurposeImage    = "image"
+	PurposeAvatar   = "avatar"
+	PurposeBrand    = "brand"
+	PurposeCategory = "category"
+	PurposeAction   = "action"
+	PurposeProduct  = "product"
+)
+
+const mb = 1024 * 1024
+
+type Policy struct {
+	MaxSize   int64
+	Types     []string
+	AdminOnly bool
+}
+
+// Policies are keyed by purpose, an upload without one gets PurposeImage.
+var Policies = map[string]Policy{
+	PurposeImage:    {MaxSize: 5 * mb, Types: []string{Jpeg, Png, Webp}},
+	PurposeAvatar:   {MaxSize: 2 * mb, Types: []string{Jpeg, Png, Webp}},
+	PurposeBrand:    {MaxSize: 2 * mb, Types: []string{Jpeg, Png, Webp, Svg}, AdminOnly: true},
+	PurposeCategory: {MaxSize: 2 * mb, Types: []string{Jpeg, Png, Webp, Svg}, AdminOnly: true},
+	PurposeAction:   {MaxSize: 5 * mb, Types: []string{Jpeg, Png, Webp, Gif}, AdminOnly: true},
+	PurposeProduct:  {MaxSize: 15 * mb, Types: []string{Jpeg, Png, Webp}, AdminOnly: true},
+}
+
+var Extensions = map[string]string{
+	Jpeg: ".jpg",
+	Png:  ".png",
+	Gif:  ".gif",
+	Webp: ".webp",
+	Svg:  ".svg",
+}
+
+var (
+	ErrTooLarge = errors.New("file is too large")
+	ErrType     = errors.New("file type is not allowed")
+	ErrUnsafe   = errors.New("file contains active content")
+	ErrPixels   = errors.New("image has too many pixels")
+)
+
+// markers are never part of a picture, finding one means the file is also a page, a script or an archive.
+var markers = [][]byte{
+	[]byte("<script"), []byte("<html"), []byte("<body"), []byte("<iframe"), []byte("<?php"), []byte("javascript:"),
+}
+
+// Check returns the sniffed content type of data if the policy allows it. Types that reencoded reports
+// are decoded and written anew on storing, which drops anything appended to them, so trailing data is
+// only refused for the others. Dimensions are read from the header, a small file may still declare
+// a picture too big to decode.
+func Check(p Policy, data []byte, reencoded func(contentType string) bool) (string, error) {
+	if int64(len(data)) > p.MaxSize {
+		return "", ErrTooLarge
+	}
+
+	contentType := Sniff(data)
+	if !slices.Contains(p.Types, contentType) {
+		return "", ErrType
+	}
+
+	if contentType == Svg {
+		if !safeSvg(data) {
+			return "", ErrUnsafe
+		}
+		return contentType, nil
+	}
+
+	lower := bytes.ToLower(data)
+	for _, m := range markers {
+		if bytes.Contains(lower, m) {
+			return "", ErrUnsafe
+		}
+	}
+
+	width, height, ok := dimensions(contentType, data)
+	if !ok || (!reencoded(contentType) && trailing(contentType, data)) {
+		return "", ErrUnsafe
+	}
+	if !imageproc.Fits(width, height) {
+		return "", ErrPixels
+	}
+
+	return contentType, nil
+}
+
+// Sniff detects the content type by the leading bytes, SVG is told apart from other XML by its root element.
+func Sniff(data []byte) string {
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+
+	if contentType == "text/xml" || contentType == "text/plain" {
+		if isSvg(data) {
+			return Svg
+		}
+	}
+
+	return contentType
+}
+
+// dimensions reads the picture size from its header, ok is false when the header is not a valid one.
+func dimensions(contentType string, data []byte) (width int, height int, ok bool) {
+	switch contentType {
+	case Jpeg, Png, Gif:
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return 0, 0, false
+		}
+		return cfg.Width, cfg.Height, true
+	case Webp:
+		return webpDimensions(data)
+	}
+	return 0, 0, false
+}
+
+// webpDimensions reads the first chunk, which is VP8X for extended files and the bitstream for simple ones.
+func webpDimensions(data []byte) (int, int, bool) {
+	if len(data) < 30 || string(data[8:12]) != "WEBP" {
+		return 0, 0, false
+	}
+
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8X":
+		// canvas width and height less one, 24 bits each after the flags
+		width := int(chunk[4]) | int(chunk[5])<<8 | int(chunk[6])<<16
+		height := int(chunk[7]) | int(chunk[8])<<8 | int(chunk[9])<<16
+		return width + 1, height + 1, true
+	case "VP8 ":
+		// frame tag, start code, then 14 bits of width and height each
+		if chunk[3] != 0x9d || chunk[4] != 0x01 || chunk[5] != 0x2a {
+			return 0, 0, false
+		}
+		width := int(binary.LittleEndian.Uint16(chunk[6:8]) & 0x3fff)
+		height := int(binary.LittleEndian.Uint16(chunk[8:10]) & 0x3fff)
+		return width, height, true
+	case "VP8L":
+		// signature, then width and height less one in 14 bits each
+		if chunk[0] != 0x2f {
+			return 0, 0, false
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:5])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, true
+	}
+	return 0, 0, false
+}
+
+// trailing tells whether something follows the end of the picture, which is where polyglots keep the second file.
+func trailing(contentType string, data []byte) bool {
+	switch contentType {
+	case Jpeg:
+		return !bytes.HasSuffix(bytes.TrimRight(data, "\x00"), []byte{0xFF, 0xD9})
+	case Png:
+		return !bytes.HasSuffix(data, []byte{'I', 'E', 'N', 'D', 0xAE, 0x42, 0x60, 0x82})
+	case Gif:
+		return !bytes.HasSuffix(data, []byte{0x3B})
+	case Webp:
+		// RIFF size counts everything after the first 8 bytes, the chunk is padded to an even length
+		return int(binary.LittleEndian.Uint32(data[4:8]))+8 < len(data)-1
+	}
+	return true
+}
@@ -0,0 +1,298 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func reencoded(string) bool { return true }
+
+func kept(string) bool { return false }
+
+func sample() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	for x := 0; x < 4; x++ {
+		img.Set(x, 1, color.RGBA{R: 200, A: 255})
+	}
+	return img
+}
+
+func encodeJpeg(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sample(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePng(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sample()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGif(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, sample(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func pngChunk(kind string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// pngHeader is a png that declares width x height and holds no pixels, which is enough for a bomb.
+func pngHeader(width, height uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32(nil, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = append(data, pngChunk("IHDR", ihdr)...)
+	return append(data, pngChunk("IEND", nil)...)
+}
+
+// withPngText puts a tEXt chunk in front of IEND, so the file still ends like a png.
+func withPngText(data []byte, text string) []byte {
+	end := len(data) - 12
+	out := append([]byte{}, data[:end]...)
+	out = append(out, pngChunk("tEXt", []byte("Comment\x00"+text))...)
+	return append(out, data[end:]...)
+}
+
+// jpegSized rewrites the frame header of an encoded jpeg to declare width x height.
+func jpegSized(t *testing.T, width, height uint16) []byte {
+	data := encodeJpeg(t)
+	i := bytes.Index(data, []byte{0xFF, 0xC0})
+	if i < 0 {
+		t.Fatal("no SOF0 marker")
+	}
+	binary.BigEndian.PutUint16(data[i+5:], height)
+	binary.BigEndian.PutUint16(data[i+7:], width)
+	return data
+}
+
+// webp wraps the first chunk in a RIFF container whose size matches it.
+func webp(chunk string, payload []byte) []byte {
+	body := append([]byte("WEBP"+chunk), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+	body = append(body, payload...)
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(data, body...)
+}
+
+func vp8x(width, height int) []byte {
+	payload := make([]byte, 10)
+	w, h := width-1, height-1
+	payload[4], payload[5], payload[6] = byte(w), byte(w>>8), byte(w>>16)
+	payload[7], payload[8], payload[9] = byte(h), byte(h>>8), byte(h>>16)
+	return webp("VP8X", payload)
+}
+
+func vp8(width, height uint16, startCode []byte) []byte {
+	payload := append([]byte{0, 0, 0}, startCode...)
+	payload = binary.LittleEndian.AppendUint16(payload, width)
+	payload = binary.LittleEndian.AppendUint16(payload, height)
+	return webp("VP8 ", payload)
+}
+
+func vp8l(width, height int, signature byte) []byte {
+	bits := uint32(width-1) | uint32(height-1)<<14
+	payload := binary.LittleEndian.AppendUint32([]byte{signature}, bits)
+	return webp("VP8L", append(payload, make([]byte, 5)...))
+}
+
+func TestCheck(t *testing.T) {
+	images := Policies[PurposeImage]
+	brand := Policies[PurposeBrand]
+	action := Policies[PurposeAction]
+
+	jpg := encodeJpeg(t)
+	pic := encodePng(t)
+	anim := encodeGif(t)
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		data      []byte
+		reencoded func(string) bool
+		want      string
+		err       error
+	}{
+		{name: "jpeg", policy: images, data: jpg, reencoded: kept, want: Jpeg},
+		{name: "png", policy: images, data: pic, reencoded: kept, want: Png},
+		{name: "gif", policy: action, data: anim, reencoded: kept, want: Gif},
+		{name: "gif not allowed", policy: images, data: anim, reencoded: kept, err: ErrType},
+		{name: "too large", policy: Policy{MaxSize: 10, Types: images.Types}, data: jpg, reencoded: kept, err: ErrTooLarge},
+		{name: "html", policy: images, data: []byte("<!DOCTYPE html><html><body></body></html>"), reencoded: kept, err: ErrType},
+		{name: "svg not allowed", policy: images, data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`),
+			reencoded: kept, err: ErrType},
+
+		// polyglots
+		{name: "jpeg with trailing html", policy: images,
+			data: append(append([]byte{}, jpg...), "<html><script>alert(1)</script></html>"...), reencoded: reencoded, err: ErrUnsafe},
+		{name: "jpeg with trailing data kept", policy: images, data: append(append([]byte{}, jpg...), "PK\x03\x04"...),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "jpeg with trailing data reencoded", policy: images, data: append(append([]byte{}, jpg...), "PK\x03\x04"...),
+			reencoded: reencoded, want: Jpeg},
+		{name: "jpeg padded with zeros", policy: images, data: append(append([]byte{}, jpg...), 0, 0, 0), reencoded: kept, want: Jpeg},
+		{name: "php inside png", policy: images, data: withPngText(pic, "<?php system($_GET['c']); ?>"), reencoded: reencoded,
+			err: ErrUnsafe},
+		{name: "php in upper case inside png", policy: images, data: withPngText(pic, "<?PHP echo 1; ?>"), reencoded: reencoded,
+			err: ErrUnsafe},
+		{name: "javascript url inside gif", policy: action, data: append(append([]byte{}, anim[:len(anim)-1]...),
+			"javascript:alert(1);"...), reencoded: reencoded, err: ErrUnsafe},
+		{name: "png with trailing data kept", policy: images, data: append(append([]byte{}, pic...), 0), reencoded: kept,
+			err: ErrUnsafe},
+		{name: "truncated png", policy: images, data: pic[:20], reencoded: reencoded, err: ErrUnsafe},
+
+		// webp
+		{name: "webp extended", policy: images, data: vp8x(640, 480), reencoded: kept, want: Webp},
+		{name: "webp lossy", policy: images, data: vp8(640, 480, []byte{0x9d, 0x01, 0x2a}), reencoded: kept, want: Webp},
+		{name: "webp lossless", policy: images, data: vp8l(640, 480, 0x2f), reencoded: kept, want: Webp},
+		{name: "webp truncated", policy: images, data: vp8x(640, 480)[:24], reencoded: reencoded, err: ErrUnsafe},
+		{name: "webp bad start code", policy: images, data: vp8(640, 480, []byte{0x9d, 0x01, 0x2b}), reencoded: reencoded,
+			err: ErrUnsafe},
+		{name: "webp bad signature", policy: images, data: vp8l(640, 480, 0x2e), reencoded: reencoded, err: ErrUnsafe},
+		{name: "webp unknown chunk", policy: images, data: webp("VP8Q", make([]byte, 10)), reencoded: reencoded, err: ErrUnsafe},
+		{name: "webp not webp", policy: images, data: append([]byte("RIFF\x16\x00\x00\x00WAVEfmt "), make([]byte, 20)...),
+			reencoded: reencoded, err: ErrType},
+		{name: "webp with trailing data kept", policy: images, data: append(vp8x(640, 480), "<svg/>xx"...), reencoded: kept,
+			err: ErrUnsafe},
+
+		// pixel bombs
+		{name: "png bomb", policy: images, data: pngHeader(50_000, 50_000), reencoded: reencoded, err: ErrPixels},
+		{name: "png at the limit", policy: images, data: pngHeader(8000, 5000), reencoded: reencoded, want: Png},
+		{name: "png over the limit", policy: images, data: pngHeader(8000, 5001), reencoded: reencoded, err: ErrPixels},
+		{name: "jpeg bomb", policy: images, data: jpegSized(t, 65_000, 65_000), reencoded: reencoded, err: ErrPixels},
+		{name: "webp extended bomb", policy: images, data: vp8x(1<<24, 1<<24), reencoded: reencoded, err: ErrPixels},
+		{name: "webp lossy bomb", policy: images, data: vp8(16_383, 16_383, []byte{0x9d, 0x01, 0x2a}), reencoded: reencoded,
+			err: ErrPixels},
+		{name: "webp lossless bomb", policy: images, data: vp8l(16_384, 16_384, 0x2f), reencoded: reencoded, err: ErrPixels},
+
+		// svg
+		{name: "svg", policy: brand, data: []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg">` +
+			`<defs><path id="p" d="M0 0"/></defs><use href="#p" style="fill:red"/></svg>`), reencoded: kept, want: Svg},
+		{name: "svg script", policy: brand, data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg script in upper case", policy: brand, data: []byte(`<svg><SCRIPT>alert(1)</SCRIPT></svg>`),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg foreign object", policy: brand,
+			data:      []byte(`<svg><foreignObject><body xmlns="http://www.w3.org/1999/xhtml"/></foreignObject></svg>`),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg onload", policy: brand, data: []byte(`<svg onload="alert(1)"/>`), reencoded: kept, err: ErrUnsafe},
+		{name: "svg handler on a child", policy: brand, data: []byte(`<svg><rect ONMOUSEOVER="alert(1)"/></svg>`),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg external href", policy: brand, data: []byte(`<svg><a href="https://evil.example"><rect/></a></svg>`),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg external xlink href", policy: brand,
+			data:      []byte(`<svg xmlns:xlink="http://www.w3.org/1999/xlink"><image xlink:href="http://evil.example/x.png"/></svg>`),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg javascript href split by whitespace", policy: brand, data: []byte("<svg><a href=\"java\n script:alert(1)\"/></svg>"),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg url in style", policy: brand, data: []byte(`<svg><rect style="fill: url( http://evil.example/#a)"/></svg>`),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg doctype with entities", policy: brand,
+			data:      []byte(`<?xml version="1.0"?><!DOCTYPE svg [<!ENTITY x SYSTEM "file:///etc/passwd">]><svg>&x;</svg>`),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg entity expansion", policy: brand,
+			data:      []byte(`<!DOCTYPE svg [<!ENTITY a "aaaa"><!ENTITY b "&a;&a;&a;&a;">]><svg><text>&b;</text></svg>`),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg import", policy: brand, data: []byte(`<svg><style>@import url(//evil.example/x.css);</style></svg>`),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg import in upper case", policy: brand, data: []byte(`<svg><style>@IMPORT "x.css";</style></svg>`),
+			reencoded: kept, err: ErrUnsafe},
+		{name: "svg stylesheet instruction", policy: brand,
+			data: []byte(`<?xml-stylesheet href="http://evil.example/x.css"?><svg/>`), reencoded: kept, err: ErrUnsafe},
+		{name: "svg malformed", policy: brand, data: []byte(`<svg><rect></svg>`), reencoded: kept, err: ErrUnsafe},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Check(tt.policy, tt.data, tt.reencoded)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %q, %v, want %v", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), Svg},
+		{"svg with prolog", []byte(`<?xml version="1.0"?><svg/>`), Svg},
+		{"svg with a generator comment", []byte(`<?xml version="1.0"?><!-- Generator: Adobe Illustrator --><svg/>`), Svg},
+		{"comment without prolog", []byte(`<!-- logo --><svg/>`), "text/html"},
+		{"other xml", []byte(`<?xml version="1.0"?><note/>`), "text/xml"},
+		{"html", []byte(`<html><svg/></html>`), "text/html"},
+		{"text", []byte("svg"), "text/plain"},
+		{"webp", vp8x(1, 1), Webp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sniff(tt.data); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebpDimensions(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height int
+		ok            bool
+	}{
+		{"extended", vp8x(640, 480), 640, 480, true},
+		{"extended largest", vp8x(1<<24, 1<<24), 1 << 24, 1 << 24, true},
+		{"lossy", vp8(640, 480, []byte{0x9d, 0x01, 0x2a}), 640, 480, true},
+		{"lossy scale bits are ignored", vp8(0xc000|640, 0x4000|480, []byte{0x9d, 0x01, 0x2a}), 640, 480, true},
+		{"lossless", vp8l(640, 480, 0x2f), 640, 480, true},
+		{"lossless largest", vp8l(16_384, 16_384, 0x2f), 16_384, 16_384, true},
+		{"empty", nil, 0, 0, false},
+		{"header only", []byte("RIFF\x00\x00\x00\x00WEBPVP8X"), 0, 0, false},
+		{"one byte short", vp8x(640, 480)[:29], 0, 0, false},
+		{"not webp", append([]byte("RIFF\x00\x00\x00\x00WAVE"), make([]byte, 30)...), 0, 0, false},
+		{"animation chunk first", webp("ANIM", make([]byte, 10)), 0, 0, false},
+		{"lossy bad start code", vp8(640, 480, []byte{0, 0, 0}), 0, 0, false},
+		{"lossless bad signature", vp8l(640, 480, 0), 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height, ok := webpDimensions(tt.data)
+			if ok != tt.ok || width != tt.width || height != tt.height {
+				t.Errorf("got %d x %d, %v, want %d x %d, %v", width, height, ok, tt.width, tt.height, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS promo_code_redemption CASCADE;
+DROP TABLE IF EXISTS promo_code_category CASCADE;
+DROP TABLE IF EXISTS promo_code_brand CASCADE;
+DROP TABLE IF EXISTS promo_code CASCADE;
+DROP TYPE IF EXISTS promo_discount_type_enum;
@@ -0,0 +1,43 @@
+DROP TYPE IF EXISTS promo_discount_type_enum;
+CREATE TYPE promo_discount_type_enum AS enum ('percent', 'fixed');
+
+CREATE TABLE IF NOT EXISTS promo_code (
+  promo_code_id SERIAL PRIMARY KEY,
+  created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  code VARCHAR(50) NOT NULL UNIQUE,
+  discount_type promo_discount_type_enum NOT NULL,
+  discount_value int NOT NULL CHECK (discount_value > 0),
+  min_basket_price int NOT NULL DEFAULT 0,
+  start_date timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  end_date timestamp(3) NOT NULL,
+  usage_limit int,
+  usage_limit_per_user int,
+  used_count int NOT NULL DEFAULT 0,
+  is_activated boolean NOT NULL DEFAULT true
+);
+
+CREATE TABLE IF NOT EXISTS promo_code_brand (
+  promo_code_brand_id SERIAL PRIMARY KEY,
+  promo_code_id INT REFERENCES promo_code (promo_code_id) ON DELETE CASCADE NOT NULL,
+  brand_id INT REFERENCES brand (brand_id) ON DELETE CASCADE NOT NULL,
+  UNIQUE (promo_code_id, brand_id)
+);
+
+CREATE TABLE IF NOT EXISTS promo_code_category (
+  promo_code_category_id SERIAL PRIMARY KEY,
+  promo_code_id INT REFERENCES promo_code (promo_code_id) ON DELETE CASCADE NOT NULL,
+  category_id INT REFERENCES category (category_id) ON DELETE CASCADE NOT NULL,
+  UNIQUE (promo_code_id, category_id)
+);
+
+CREATE TABLE IF NOT EXISTS promo_code_redemption (
+  promo_code_redemption_id SERIAL PRIMARY KEY,
+  created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  promo_code_id INT REFERENCES promo_code (promo_code_id) ON DELETE CASCADE NOT NULL,
+  order_id UUID UNIQUE REFERENCES public.order (order_id) ON DELETE CASCADE NOT NULL,
+  user_id INT REFERENCES public.user (user_id) ON DELETE CASCADE NOT NULL,
+  discount int NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS promo_code_redemption_user_idx ON promo_code_redemption (promo_code_id, user_id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS payment_event (
+  payment_event_id SERIAL PRIMARY KEY,
+  created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  event_type VARCHAR(50) NOT NULL,
+  object_id VARCHAR(255) NOT NULL,
+  payment_id UUID NOT NULL,
+  order_id UUID REFERENCES public.order (order_id) ON DELETE CASCADE NOT NULL,
+  UNIQUE (event_type, object_id)
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS order_refund CASCADE;
+DROP TABLE IF EXISTS order_return_model CASCADE;
+DROP TABLE IF EXISTS order_return CASCADE;
+DROP TYPE IF EXISTS order_return_status_enum;
@@ -0,0 +1,36 @@
+DROP TYPE IF EXISTS order_return_status_enum;
+CREATE TYPE order_return_status_enum AS enum ('pending', 'approved', 'rejected');
+
+CREATE TABLE IF NOT EXISTS order_return (
+  order_return_id SERIAL PRIMARY KEY,
+  created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  order_id UUID REFERENCES public.order (order_id) ON DELETE CASCADE NOT NULL,
+  user_id INT REFERENCES public.user (user_id) ON DELETE CASCADE NOT NULL,
+  return_status order_return_status_enum NOT NULL DEFAULT 'pending',
+  reason TEXT NOT NULL,
+  admin_comment TEXT,
+  amount float8 NOT NULL CHECK (amount >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS order_return_model (
+  order_return_model_id SERIAL PRIMARY KEY,
+  order_return_id INT REFERENCES order_return (order_return_id) ON DELETE CASCADE NOT NULL,
+  order_model_id INT REFERENCES order_model (order_model_id) ON DELETE CASCADE NOT NULL,
+  quantity int NOT NULL CHECK (quantity > 0),
+  amount float8 NOT NULL CHECK (amount >= 0),
+  UNIQUE (order_return_id, order_model_id)
+);
+
+CREATE TABLE IF NOT EXISTS order_refund (
+  order_refund_id SERIAL PRIMARY KEY,
+  created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  order_id UUID REFERENCES public.order (order_id) ON DELETE CASCADE NOT NULL,
+  order_return_id INT REFERENCES order_return (order_return_id) ON DELETE SET NULL,
+  payment_refund_id VARCHAR(255) UNIQUE,
+  amount float8 NOT NULL,
+  refund_status VARCHAR(50) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_return_order_id_idx ON order_return (order_id);
+CREATE INDEX IF NOT EXISTS order_refund_order_id_idx ON order_refund (order_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS order_status_history CASCADE;
+DROP TYPE IF EXISTS order_status_actor_enum;
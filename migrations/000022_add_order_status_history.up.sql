@@ -0,0 +1,15 @@
+DROP TYPE IF EXISTS order_status_actor_enum;
+CREATE TYPE order_status_actor_enum AS enum ('user', 'admin', 'payment', 'system');
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+  order_status_history_id SERIAL PRIMARY KEY,
+  created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  order_id UUID REFERENCES public.order (order_id) ON DELETE CASCADE NOT NULL,
+  from_status order_status_enum,
+  to_status order_status_enum NOT NULL,
+  actor_type order_status_actor_enum NOT NULL,
+  actor_id INT REFERENCES public.user (user_id) ON DELETE SET NULL,
+  reason TEXT
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_id_idx ON order_status_history (order_id);
@@ -0,0 +1,3 @@
+ALTER TABLE public.order DROP COLUMN IF EXISTS payment_url;
+DROP TABLE IF EXISTS outbox CASCADE;
+DROP TYPE IF EXISTS outbox_status_enum;
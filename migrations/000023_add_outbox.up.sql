@@ -0,0 +1,19 @@
+DROP TYPE IF EXISTS outbox_status_enum;
+CREATE TYPE outbox_status_enum AS enum ('pending', 'done', 'dead');
+
+CREATE TABLE IF NOT EXISTS outbox (
+  outbox_id BIGSERIAL PRIMARY KEY,
+  created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  topic VARCHAR(100) NOT NULL,
+  payload JSONB NOT NULL,
+  outbox_status outbox_status_enum NOT NULL DEFAULT 'pending',
+  attempts int NOT NULL DEFAULT 0,
+  next_attempt_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  locked_until timestamp(3),
+  last_error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE outbox_status = 'pending';
+
+ALTER TABLE public.order ADD COLUMN IF NOT EXISTS payment_url TEXT;
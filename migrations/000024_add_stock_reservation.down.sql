@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS stock_reservation CASCADE;
+DROP TYPE IF EXISTS stock_reservation_status_enum;
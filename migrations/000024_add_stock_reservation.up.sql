@@ -0,0 +1,16 @@
+DROP TYPE IF EXISTS stock_reservation_status_enum;
+CREATE TYPE stock_reservation_status_enum AS enum ('active', 'sold', 'released');
+
+CREATE TABLE IF NOT EXISTS stock_reservation (
+  stock_reservation_id SERIAL PRIMARY KEY,
+  created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  order_id UUID REFERENCES public.order (order_id) ON DELETE CASCADE NOT NULL,
+  model_size_id INT REFERENCES model_sizes (model_size_id) ON DELETE CASCADE NOT NULL,
+  quantity int NOT NULL CHECK (quantity > 0),
+  reservation_status stock_reservation_status_enum NOT NULL DEFAULT 'active',
+  expires_at timestamp(3) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS stock_reservation_order_id_idx ON stock_reservation (order_id);
+CREATE INDEX IF NOT EXISTS stock_reservation_active_idx ON stock_reservation (expires_at) WHERE reservation_status = 'active';
@@ -0,0 +1 @@
+ALTER TABLE model_sizes DROP CONSTRAINT IF EXISTS model_sizes_in_stock_check;
@@ -0,0 +1,2 @@
+UPDATE model_sizes SET in_stock = 0 WHERE in_stock < 0;
+ALTER TABLE model_sizes ADD CONSTRAINT model_sizes_in_stock_check CHECK (in_stock >= 0);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS user_created_at_user_id_idx;
+DROP INDEX IF EXISTS feedback_created_at_feedback_id_idx;
+DROP INDEX IF EXISTS order_created_at_order_id_idx;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS order_created_at_order_id_idx ON public.order (created_at, order_id);
+CREATE INDEX IF NOT EXISTS feedback_created_at_feedback_id_idx ON feedback (created_at, feedback_id);
+CREATE INDEX IF NOT EXISTS user_created_at_user_id_idx ON public.user (created_at, user_id);
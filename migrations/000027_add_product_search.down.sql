@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS product_search_category_trigger ON category;
+DROP TRIGGER IF EXISTS product_search_brand_trigger ON brand;
+DROP TRIGGER IF EXISTS product_search_product_trigger ON product;
+DROP FUNCTION IF EXISTS product_search_on_category();
+DROP FUNCTION IF EXISTS product_search_on_brand();
+DROP FUNCTION IF EXISTS product_search_on_product();
+DROP FUNCTION IF EXISTS refresh_product_search(INT);
+DROP TABLE IF EXISTS product_search;
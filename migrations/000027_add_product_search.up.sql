@@ -0,0 +1,65 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE IF NOT EXISTS product_search (
+  product_id INT PRIMARY KEY REFERENCES product (product_id) ON DELETE CASCADE,
+  document tsvector NOT NULL,
+  search_text TEXT NOT NULL,
+  headline_text TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS product_search_document_idx ON product_search USING GIN (document);
+CREATE INDEX IF NOT EXISTS product_search_text_trgm_idx ON product_search USING GIN (search_text gin_trgm_ops);
+
+CREATE OR REPLACE FUNCTION refresh_product_search(target_product_id INT) RETURNS void AS $$
+BEGIN
+  INSERT INTO product_search (product_id, document, search_text, headline_text)
+  SELECT p.product_id,
+    setweight(to_tsvector('russian', coalesce(p.title, '')), 'A') ||
+    setweight(to_tsvector('russian', coalesce(b.title, '')), 'A') ||
+    setweight(to_tsvector('russian', coalesce(c.title, '')), 'B') ||
+    setweight(to_tsvector('russian', coalesce(p.description, '')), 'C'),
+    lower(concat_ws(' ', p.title, b.title, c.title)),
+    concat_ws(' ', p.title, p.description)
+  FROM product p
+  INNER JOIN brand b ON p.brand_id = b.brand_id
+  INNER JOIN category c ON p.category_id = c.category_id
+  WHERE p.product_id = target_product_id
+  ON CONFLICT (product_id) DO UPDATE SET document = EXCLUDED.document,
+    search_text = EXCLUDED.search_text, headline_text = EXCLUDED.headline_text;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION product_search_on_product() RETURNS trigger AS $$
+BEGIN
+  PERFORM refresh_product_search(NEW.product_id);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION product_search_on_brand() RETURNS trigger AS $$
+BEGIN
+  PERFORM refresh_product_search(p.product_id) FROM product p WHERE p.brand_id = NEW.brand_id;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION product_search_on_category() RETURNS trigger AS $$
+BEGIN
+  PERFORM refresh_product_search(p.product_id) FROM product p WHERE p.category_id = NEW.category_id;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_search_product_trigger
+AFTER INSERT OR UPDATE OF title, description, brand_id, category_id ON product
+FOR EACH ROW EXECUTE FUNCTION product_search_on_product();
+
+CREATE TRIGGER product_search_brand_trigger
+AFTER UPDATE OF title ON brand
+FOR EACH ROW EXECUTE FUNCTION product_search_on_brand();
+
+CREATE TRIGGER product_search_category_trigger
+AFTER UPDATE OF title ON category
+FOR EACH ROW EXECUTE FUNCTION product_search_on_category();
+
+SELECT refresh_product_search(product_id) FROM product;
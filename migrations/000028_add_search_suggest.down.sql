@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS product_title_trgm_idx;
+DROP INDEX IF EXISTS category_title_trgm_idx;
+DROP INDEX IF EXISTS brand_title_trgm_idx;
+DROP TABLE IF EXISTS search_query;
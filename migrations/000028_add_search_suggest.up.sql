@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS search_query (
+  query VARCHAR(100) PRIMARY KEY,
+  hits INT NOT NULL DEFAULT 1,
+  results INT NOT NULL DEFAULT 0,
+  created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_searched_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS search_query_prefix_idx ON search_query (query text_pattern_ops);
+CREATE INDEX IF NOT EXISTS brand_title_trgm_idx ON brand USING GIN (lower(title) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS category_title_trgm_idx ON category USING GIN (lower(title) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS product_title_trgm_idx ON product USING GIN (lower(title) gin_trgm_ops);
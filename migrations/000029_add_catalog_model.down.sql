@@ -0,0 +1,21 @@
+DROP TRIGGER IF EXISTS catalog_model_order_model_trigger ON order_model;
+DROP TRIGGER IF EXISTS catalog_model_feedback_trigger ON feedback;
+DROP TRIGGER IF EXISTS catalog_model_option_trigger ON product_model_option;
+DROP TRIGGER IF EXISTS catalog_model_img_trigger ON product_model_img;
+DROP TRIGGER IF EXISTS catalog_model_model_sizes_trigger ON model_sizes;
+DROP TRIGGER IF EXISTS catalog_model_size_trigger ON sizes;
+DROP TRIGGER IF EXISTS catalog_model_category_trigger ON category;
+DROP TRIGGER IF EXISTS catalog_model_brand_trigger ON brand;
+DROP TRIGGER IF EXISTS catalog_model_product_trigger ON product;
+DROP TRIGGER IF EXISTS catalog_model_model_trigger ON product_model;
+
+DROP FUNCTION IF EXISTS catalog_model_on_order_model();
+DROP FUNCTION IF EXISTS catalog_model_on_size();
+DROP FUNCTION IF EXISTS catalog_model_on_category();
+DROP FUNCTION IF EXISTS catalog_model_on_brand();
+DROP FUNCTION IF EXISTS catalog_model_on_product();
+DROP FUNCTION IF EXISTS catalog_model_on_model();
+DROP FUNCTION IF EXISTS catalog_model_on_model_child();
+DROP FUNCTION IF EXISTS refresh_catalog_model(INT);
+
+DROP TABLE IF EXISTS catalog_model;
@@ -0,0 +1,198 @@
+CREATE TABLE IF NOT EXISTS catalog_model (
+  product_model_id INT PRIMARY KEY REFERENCES product_model (product_model_id) ON DELETE CASCADE,
+  product_id INT NOT NULL,
+  title TEXT NOT NULL,
+  slug TEXT NOT NULL,
+  article VARCHAR(12) NOT NULL,
+  price INT NOT NULL,
+  discount INT2,
+  main_image_path TEXT NOT NULL,
+  created_at timestamp(3) NOT NULL,
+  brand_id INT NOT NULL,
+  brand_title TEXT NOT NULL,
+  brand_slug TEXT NOT NULL,
+  category_id INT NOT NULL,
+  category_title TEXT NOT NULL,
+  category_short_title TEXT NOT NULL,
+  category_slug TEXT NOT NULL,
+  images jsonb NOT NULL DEFAULT '[]',
+  sizes jsonb NOT NULL DEFAULT '[]',
+  size_ids INT[] NOT NULL DEFAULT '{}',
+  size_values TEXT[] NOT NULL DEFAULT '{}',
+  in_stock INT NOT NULL DEFAULT 0,
+  option_value_ids INT[] NOT NULL DEFAULT '{}',
+  rating NUMERIC(3, 2),
+  feedback_count INT NOT NULL DEFAULT 0,
+  order_count INT NOT NULL DEFAULT 0,
+  listed boolean NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS catalog_model_category_popular_idx ON catalog_model (category_id, order_count DESC, product_model_id DESC) WHERE listed;
+CREATE INDEX IF NOT EXISTS catalog_model_category_price_idx ON catalog_model (category_id, price, product_model_id) WHERE listed;
+CREATE INDEX IF NOT EXISTS catalog_model_category_created_idx ON catalog_model (category_id, created_at DESC, product_model_id DESC) WHERE listed;
+CREATE INDEX IF NOT EXISTS catalog_model_brand_idx ON catalog_model (brand_id) WHERE listed;
+CREATE INDEX IF NOT EXISTS catalog_model_option_values_idx ON catalog_model USING GIN (option_value_ids);
+CREATE INDEX IF NOT EXISTS catalog_model_size_values_idx ON catalog_model USING GIN (size_values);
+
+-- refresh_catalog_model rebuilds the projection row of one model from the source tables.
+-- A model is listed once it has at least one image and one size, as the catalog joins required before.
+CREATE OR REPLACE FUNCTION refresh_catalog_model(target_model_id INT) RETURNS void AS $$
+BEGIN
+  INSERT INTO catalog_model (product_model_id, product_id, title, slug, article, price, discount, main_image_path,
+    created_at, brand_id, brand_title, brand_slug, category_id, category_title, category_short_title, category_slug,
+    images, sizes, size_ids, size_values, in_stock, option_value_ids, rating, feedback_count, order_count, listed)
+  SELECT pm.product_model_id, p.product_id, p.title, pm.slug, pm.article, pm.price, pm.discount, pm.main_image_path,
+    pm.created_at, b.brand_id, b.title, b.slug, c.category_id, c.title, c.short_title, c.slug,
+    coalesce(img.images, '[]'), coalesce(sz.sizes, '[]'), coalesce(sz.size_ids, '{}'), coalesce(sz.size_values, '{}'),
+    coalesce(sz.in_stock, 0), coalesce(opt.option_value_ids, '{}'), fb.rating, coalesce(fb.feedback_count, 0),
+    coalesce(ord.order_count, 0), img.images IS NOT NULL AND sz.sizes IS NOT NULL
+  FROM product_model pm
+  INNER JOIN product p ON pm.product_id = p.product_id
+  INNER JOIN brand b ON p.brand_id = b.brand_id
+  INNER JOIN category c ON p.category_id = c.category_id
+  LEFT JOIN LATERAL (
+    SELECT jsonb_agg(jsonb_build_object('id', pimg.product_img_id, 'img_path', pimg.img_path)
+      ORDER BY pimg.product_img_id) AS images
+    FROM product_model_img pimg WHERE pimg.product_model_id = pm.product_model_id
+  ) img ON true
+  LEFT JOIN LATERAL (
+    SELECT jsonb_agg(jsonb_build_object('size_id', s.size_id, 'model_id', ms.product_model_id,
+      'size_model_id', ms.model_size_id, 'literal', ms.literal_size, 'size_value', s.size_value,
+      'in_stock', ms.in_stock) ORDER BY ms.model_size_id) AS sizes,
+      array_agg(DISTINCT s.size_id) AS size_ids, array_agg(DISTINCT s.size_value::text) AS size_values,
+      sum(ms.in_stock)::int AS in_stock
+    FROM model_sizes ms INNER JOIN sizes s ON ms.size_id = s.size_id
+    WHERE ms.product_model_id = pm.product_model_id
+  ) sz ON true
+  LEFT JOIN LATERAL (
+    SELECT array_agg(DISTINCT pmo.option_value_id) AS option_value_ids
+    FROM product_model_option pmo WHERE pmo.product_model_id = pm.product_model_id
+  ) opt ON true
+  LEFT JOIN LATERAL (
+    SELECT round(avg(f.rate), 2) AS rating, count(*)::int AS feedback_count
+    FROM feedback f WHERE f.product_model_id = pm.product_model_id AND f.is_hidden = false
+  ) fb ON true
+  LEFT JOIN LATERAL (
+    SELECT count(om.order_model_id)::int AS order_count
+    FROM order_model om INNER JOIN model_sizes oms ON om.model_size_id = oms.model_size_id
+    WHERE oms.product_model_id = pm.product_model_id
+  ) ord ON true
+  WHERE pm.product_model_id = target_model_id
+  ON CONFLICT (product_model_id) DO UPDATE SET product_id = EXCLUDED.product_id, title = EXCLUDED.title,
+    slug = EXCLUDED.slug, article = EXCLUDED.article, price = EXCLUDED.price, discount = EXCLUDED.discount,
+    main_image_path = EXCLUDED.main_image_path, created_at = EXCLUDED.created_at, brand_id = EXCLUDED.brand_id,
+    brand_title = EXCLUDED.brand_title, brand_slug = EXCLUDED.brand_slug, category_id = EXCLUDED.category_id,
+    category_title = EXCLUDED.category_title, category_short_title = EXCLUDED.category_short_title,
+    category_slug = EXCLUDED.category_slug, images = EXCLUDED.images, sizes = EXCLUDED.sizes,
+    size_ids = EXCLUDED.size_ids, size_values = EXCLUDED.size_values, in_stock = EXCLUDED.in_stock,
+    option_value_ids = EXCLUDED.option_value_ids, rating = EXCLUDED.rating, feedback_count = EXCLUDED.feedback_count,
+    order_count = EXCLUDED.order_count, listed = EXCLUDED.listed;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Child tables carry product_model_id, a delete refreshes the old model, an update may move the row to another one.
+CREATE OR REPLACE FUNCTION catalog_model_on_model_child() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    PERFORM refresh_catalog_model(OLD.product_model_id);
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') AND (TG_OP = 'INSERT' OR NEW.product_model_id <> OLD.product_model_id) THEN
+    PERFORM refresh_catalog_model(NEW.product_model_id);
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION catalog_model_on_model() RETURNS trigger AS $$
+BEGIN
+  PERFORM refresh_catalog_model(NEW.product_model_id);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION catalog_model_on_product() RETURNS trigger AS $$
+BEGIN
+  PERFORM refresh_catalog_model(pm.product_model_id) FROM product_model pm WHERE pm.product_id = NEW.product_id;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION catalog_model_on_brand() RETURNS trigger AS $$
+BEGIN
+  PERFORM refresh_catalog_model(pm.product_model_id) FROM product_model pm
+  INNER JOIN product p ON pm.product_id = p.product_id WHERE p.brand_id = NEW.brand_id;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION catalog_model_on_category() RETURNS trigger AS $$
+BEGIN
+  PERFORM refresh_catalog_model(pm.product_model_id) FROM product_model pm
+  INNER JOIN product p ON pm.product_id = p.product_id WHERE p.category_id = NEW.category_id;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION catalog_model_on_size() RETURNS trigger AS $$
+BEGIN
+  PERFORM refresh_catalog_model(m.product_model_id)
+  FROM (SELECT DISTINCT ms.product_model_id FROM model_sizes ms WHERE ms.size_id = NEW.size_id) m;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION catalog_model_on_order_model() RETURNS trigger AS $$
+DECLARE
+  target_model_size_id INT;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    target_model_size_id := OLD.model_size_id;
+  ELSE
+    target_model_size_id := NEW.model_size_id;
+  END IF;
+  PERFORM refresh_catalog_model(ms.product_model_id) FROM model_sizes ms WHERE ms.model_size_id = target_model_size_id;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER catalog_model_model_trigger
+AFTER INSERT OR UPDATE ON product_model
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_model();
+
+CREATE TRIGGER catalog_model_product_trigger
+AFTER UPDATE OF title, brand_id, category_id ON product
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_product();
+
+CREATE TRIGGER catalog_model_brand_trigger
+AFTER UPDATE OF title, slug ON brand
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_brand();
+
+CREATE TRIGGER catalog_model_category_trigger
+AFTER UPDATE OF title, short_title, slug ON category
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_category();
+
+CREATE TRIGGER catalog_model_size_trigger
+AFTER UPDATE OF size_value ON sizes
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_size();
+
+CREATE TRIGGER catalog_model_model_sizes_trigger
+AFTER INSERT OR UPDATE OR DELETE ON model_sizes
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_model_child();
+
+CREATE TRIGGER catalog_model_img_trigger
+AFTER INSERT OR UPDATE OR DELETE ON product_model_img
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_model_child();
+
+CREATE TRIGGER catalog_model_option_trigger
+AFTER INSERT OR UPDATE OR DELETE ON product_model_option
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_model_child();
+
+CREATE TRIGGER catalog_model_feedback_trigger
+AFTER INSERT OR UPDATE OF rate, is_hidden OR DELETE ON feedback
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_model_child();
+
+CREATE TRIGGER catalog_model_order_model_trigger
+AFTER INSERT OR DELETE ON order_model
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_order_model();
+
+SELECT refresh_catalog_model(product_model_id) FROM product_model;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS order_not_exported_idx;
+ALTER TABLE public.order DROP COLUMN IF EXISTS exported_at;
@@ -0,0 +1,6 @@
+ALTER TABLE public.order ADD COLUMN IF NOT EXISTS exported_at timestamp(3);
+
+-- orders placed before the exchange existed are already known to the back office
+UPDATE public.order SET exported_at = CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS order_not_exported_idx ON public.order (created_at) WHERE exported_at IS NULL;
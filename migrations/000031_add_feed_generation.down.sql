@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS feed_generation;
+DROP TYPE IF EXISTS feed_status_enum;
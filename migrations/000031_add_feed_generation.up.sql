@@ -0,0 +1,14 @@
+DROP TYPE IF EXISTS feed_status_enum;
+CREATE TYPE feed_status_enum AS enum ('running', 'succeeded', 'failed');
+
+-- feed_generation keeps the latest run of every feed
+CREATE TABLE IF NOT EXISTS feed_generation (
+  feed VARCHAR(50) PRIMARY KEY,
+  feed_status feed_status_enum NOT NULL,
+  started_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  finished_at timestamp(3),
+  offers INT NOT NULL DEFAULT 0,
+  path TEXT,
+  error TEXT,
+  warnings jsonb NOT NULL DEFAULT '[]'
+);
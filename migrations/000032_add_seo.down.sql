@@ -0,0 +1,24 @@
+DROP TRIGGER IF EXISTS product_model_updated_at_trigger ON product_model;
+DROP TRIGGER IF EXISTS product_updated_at_trigger ON product;
+DROP TRIGGER IF EXISTS brand_updated_at_trigger ON brand;
+DROP TRIGGER IF EXISTS category_updated_at_trigger ON category;
+DROP FUNCTION IF EXISTS set_updated_at();
+
+ALTER TABLE product_model DROP COLUMN IF EXISTS canonical;
+ALTER TABLE product_model DROP COLUMN IF EXISTS meta_description;
+ALTER TABLE product_model DROP COLUMN IF EXISTS meta_title;
+
+ALTER TABLE product DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE product DROP COLUMN IF EXISTS canonical;
+ALTER TABLE product DROP COLUMN IF EXISTS meta_description;
+ALTER TABLE product DROP COLUMN IF EXISTS meta_title;
+
+ALTER TABLE brand DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE brand DROP COLUMN IF EXISTS canonical;
+ALTER TABLE brand DROP COLUMN IF EXISTS meta_description;
+ALTER TABLE brand DROP COLUMN IF EXISTS meta_title;
+
+ALTER TABLE category DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE category DROP COLUMN IF EXISTS canonical;
+ALTER TABLE category DROP COLUMN IF EXISTS meta_description;
+ALTER TABLE category DROP COLUMN IF EXISTS meta_title;
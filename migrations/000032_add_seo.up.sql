@@ -0,0 +1,42 @@
+ALTER TABLE category ADD COLUMN IF NOT EXISTS meta_title VARCHAR(255);
+ALTER TABLE category ADD COLUMN IF NOT EXISTS meta_description TEXT;
+ALTER TABLE category ADD COLUMN IF NOT EXISTS canonical TEXT;
+ALTER TABLE category ADD COLUMN IF NOT EXISTS updated_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE brand ADD COLUMN IF NOT EXISTS meta_title VARCHAR(255);
+ALTER TABLE brand ADD COLUMN IF NOT EXISTS meta_description TEXT;
+ALTER TABLE brand ADD COLUMN IF NOT EXISTS canonical TEXT;
+ALTER TABLE brand ADD COLUMN IF NOT EXISTS updated_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE product ADD COLUMN IF NOT EXISTS meta_title VARCHAR(255);
+ALTER TABLE product ADD COLUMN IF NOT EXISTS meta_description TEXT;
+ALTER TABLE product ADD COLUMN IF NOT EXISTS canonical TEXT;
+ALTER TABLE product ADD COLUMN IF NOT EXISTS updated_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE product_model ADD COLUMN IF NOT EXISTS meta_title VARCHAR(255);
+ALTER TABLE product_model ADD COLUMN IF NOT EXISTS meta_description TEXT;
+ALTER TABLE product_model ADD COLUMN IF NOT EXISTS canonical TEXT;
+
+-- updates don't set updated_at themselves, sitemap lastmod relies on it
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at = CURRENT_TIMESTAMP;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS category_updated_at_trigger ON category;
+CREATE TRIGGER category_updated_at_trigger BEFORE UPDATE ON category
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS brand_updated_at_trigger ON brand;
+CREATE TRIGGER brand_updated_at_trigger BEFORE UPDATE ON brand
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS product_updated_at_trigger ON product;
+CREATE TRIGGER product_updated_at_trigger BEFORE UPDATE ON product
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS product_model_updated_at_trigger ON product_model;
+CREATE TRIGGER product_model_updated_at_trigger BEFORE UPDATE ON product_model
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,14 @@
+DROP TRIGGER IF EXISTS product_model_slug_delete_trigger ON product_model;
+DROP TRIGGER IF EXISTS product_model_slug_update_trigger ON product_model;
+DROP TRIGGER IF EXISTS product_model_slug_insert_trigger ON product_model;
+DROP TRIGGER IF EXISTS category_slug_delete_trigger ON category;
+DROP TRIGGER IF EXISTS category_slug_update_trigger ON category;
+DROP TRIGGER IF EXISTS category_slug_insert_trigger ON category;
+DROP TRIGGER IF EXISTS brand_slug_delete_trigger ON brand;
+DROP TRIGGER IF EXISTS brand_slug_update_trigger ON brand;
+DROP TRIGGER IF EXISTS brand_slug_insert_trigger ON brand;
+
+DROP FUNCTION IF EXISTS slug_history_on_delete();
+DROP FUNCTION IF EXISTS slug_history_on_write();
+
+DROP TABLE IF EXISTS slug_history;
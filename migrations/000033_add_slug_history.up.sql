@@ -0,0 +1,63 @@
+CREATE TABLE IF NOT EXISTS slug_history (
+  slug_history_id SERIAL PRIMARY KEY,
+  entity VARCHAR(20) NOT NULL,
+  entity_id INT NOT NULL,
+  slug VARCHAR(255) NOT NULL,
+  retired_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (entity, slug)
+);
+
+CREATE INDEX IF NOT EXISTS slug_history_entity_idx ON slug_history (entity, entity_id);
+
+-- TG_ARGV is the entity name and its id column. A slug that is in use again stops redirecting,
+-- a retired one points at the entity that had it last.
+CREATE OR REPLACE FUNCTION slug_history_on_write() RETURNS trigger AS $$
+BEGIN
+  DELETE FROM slug_history WHERE entity = TG_ARGV[0] AND slug = NEW.slug;
+
+  IF TG_OP = 'UPDATE' THEN
+    INSERT INTO slug_history (entity, entity_id, slug)
+    VALUES (TG_ARGV[0], (to_jsonb(NEW) ->> TG_ARGV[1])::int, OLD.slug)
+    ON CONFLICT (entity, slug) DO UPDATE SET entity_id = EXCLUDED.entity_id, retired_at = CURRENT_TIMESTAMP;
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION slug_history_on_delete() RETURNS trigger AS $$
+BEGIN
+  DELETE FROM slug_history WHERE entity = TG_ARGV[0] AND entity_id = (to_jsonb(OLD) ->> TG_ARGV[1])::int;
+  RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS brand_slug_insert_trigger ON brand;
+CREATE TRIGGER brand_slug_insert_trigger AFTER INSERT ON brand
+  FOR EACH ROW EXECUTE FUNCTION slug_history_on_write('brand', 'brand_id');
+DROP TRIGGER IF EXISTS brand_slug_update_trigger ON brand;
+CREATE TRIGGER brand_slug_update_trigger AFTER UPDATE OF slug ON brand
+  FOR EACH ROW WHEN (OLD.slug IS DISTINCT FROM NEW.slug) EXECUTE FUNCTION slug_history_on_write('brand', 'brand_id');
+DROP TRIGGER IF EXISTS brand_slug_delete_trigger ON brand;
+CREATE TRIGGER brand_slug_delete_trigger AFTER DELETE ON brand
+  FOR EACH ROW EXECUTE FUNCTION slug_history_on_delete('brand', 'brand_id');
+
+DROP TRIGGER IF EXISTS category_slug_insert_trigger ON category;
+CREATE TRIGGER category_slug_insert_trigger AFTER INSERT ON category
+  FOR EACH ROW EXECUTE FUNCTION slug_history_on_write('category', 'category_id');
+DROP TRIGGER IF EXISTS category_slug_update_trigger ON category;
+CREATE TRIGGER category_slug_update_trigger AFTER UPDATE OF slug ON category
+  FOR EACH ROW WHEN (OLD.slug IS DISTINCT FROM NEW.slug) EXECUTE FUNCTION slug_history_on_write('category', 'category_id');
+DROP TRIGGER IF EXISTS category_slug_delete_trigger ON category;
+CREATE TRIGGER category_slug_delete_trigger AFTER DELETE ON category
+  FOR EACH ROW EXECUTE FUNCTION slug_history_on_delete('category', 'category_id');
+
+DROP TRIGGER IF EXISTS product_model_slug_insert_trigger ON product_model;
+CREATE TRIGGER product_model_slug_insert_trigger AFTER INSERT ON product_model
+  FOR EACH ROW EXECUTE FUNCTION slug_history_on_write('model', 'product_model_id');
+DROP TRIGGER IF EXISTS product_model_slug_update_trigger ON product_model;
+CREATE TRIGGER product_model_slug_update_trigger AFTER UPDATE OF slug ON product_model
+  FOR EACH ROW WHEN (OLD.slug IS DISTINCT FROM NEW.slug) EXECUTE FUNCTION slug_history_on_write('model', 'product_model_id');
+DROP TRIGGER IF EXISTS product_model_slug_delete_trigger ON product_model;
+CREATE TRIGGER product_model_slug_delete_trigger AFTER DELETE ON product_model
+  FOR EACH ROW EXECUTE FUNCTION slug_history_on_delete('model', 'product_model_id');
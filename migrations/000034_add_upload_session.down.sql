@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS upload_session;
+DROP TYPE IF EXISTS upload_session_status_enum;
@@ -0,0 +1,18 @@
+DROP TYPE IF EXISTS upload_session_status_enum;
+CREATE TYPE upload_session_status_enum AS enum ('pending', 'confirmed', 'rejected');
+
+-- upload_session is a presigned upload straight to the storage, the file is only attached on confirm
+CREATE TABLE IF NOT EXISTS upload_session (
+  upload_session_id UUID PRIMARY KEY,
+  created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at timestamp(3) NOT NULL,
+  confirmed_at timestamp(3),
+  user_id INT REFERENCES public.user (user_id) ON DELETE CASCADE NOT NULL,
+  purpose VARCHAR(20) NOT NULL,
+  target_id VARCHAR(50) NOT NULL,
+  object_key TEXT NOT NULL,
+  upload_status upload_session_status_enum NOT NULL DEFAULT 'pending',
+  path TEXT
+);
+
+CREATE INDEX IF NOT EXISTS upload_session_open_idx ON upload_session (expires_at) WHERE upload_status <> 'confirmed';
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS model_sizes_stock_movement_trigger ON model_sizes;
+DROP FUNCTION IF EXISTS stock_movement_on_write();
+DROP TABLE IF EXISTS stock_movement;
+DROP FUNCTION IF EXISTS stock_movement_append_only();
+DROP TYPE IF EXISTS stock_movement_type_enum;
@@ -0,0 +1,68 @@
+DROP TYPE IF EXISTS stock_movement_type_enum;
+CREATE TYPE stock_movement_type_enum AS enum ('sale', 'cancellation', 'return', 'adjustment', 'import', 'stocktake');
+
+-- stock_movement is the ledger of model_sizes.in_stock: quantity is the signed change, balance the stock after it.
+-- References are kept without foreign keys, so the history outlives the orders and users it names.
+CREATE TABLE IF NOT EXISTS stock_movement (
+  stock_movement_id BIGSERIAL PRIMARY KEY,
+  created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  model_size_id INT REFERENCES model_sizes (model_size_id) ON DELETE CASCADE NOT NULL,
+  movement_type stock_movement_type_enum NOT NULL,
+  quantity INT NOT NULL,
+  balance INT NOT NULL,
+  order_id UUID,
+  user_id INT,
+  import_id UUID,
+  comment TEXT
+);
+
+CREATE INDEX IF NOT EXISTS stock_movement_model_size_idx ON stock_movement (model_size_id, created_at DESC, stock_movement_id DESC);
+CREATE INDEX IF NOT EXISTS stock_movement_order_idx ON stock_movement (order_id) WHERE order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS stock_movement_import_idx ON stock_movement (import_id) WHERE import_id IS NOT NULL;
+
+-- The application names the cause of the changes in a transaction with set_config('stock.source', <json>, true),
+-- a change without one is recorded as an adjustment without reference.
+CREATE OR REPLACE FUNCTION stock_movement_on_write() RETURNS trigger AS $$
+DECLARE
+  source jsonb := nullif(current_setting('stock.source', true), '')::jsonb;
+  previous INT := 0;
+BEGIN
+  IF TG_OP = 'UPDATE' THEN
+    previous := OLD.in_stock;
+  END IF;
+
+  IF NEW.in_stock = previous THEN
+    RETURN NULL;
+  END IF;
+
+  INSERT INTO stock_movement (model_size_id, movement_type, quantity, balance, order_id, user_id, import_id, comment)
+  VALUES (NEW.model_size_id, coalesce(source ->> 'type', 'adjustment')::stock_movement_type_enum, NEW.in_stock - previous,
+    NEW.in_stock, (source ->> 'order_id')::uuid, (source ->> 'user_id')::int, (source ->> 'import_id')::uuid,
+    source ->> 'comment');
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS model_sizes_stock_movement_trigger ON model_sizes;
+CREATE TRIGGER model_sizes_stock_movement_trigger AFTER INSERT OR UPDATE OF in_stock ON model_sizes
+  FOR EACH ROW EXECUTE FUNCTION stock_movement_on_write();
+
+-- Entries are never changed. Deleting a model size removes its history through the cascade, which runs
+-- as a nested trigger, any other delete is refused.
+CREATE OR REPLACE FUNCTION stock_movement_append_only() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'DELETE' AND pg_trigger_depth() > 1 THEN
+    RETURN OLD;
+  END IF;
+  RAISE EXCEPTION 'stock_movement is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS stock_movement_append_only_trigger ON stock_movement;
+CREATE TRIGGER stock_movement_append_only_trigger BEFORE UPDATE OR DELETE ON stock_movement
+  FOR EACH ROW EXECUTE FUNCTION stock_movement_append_only();
+
+-- the stock before the ledger is its opening balance
+INSERT INTO stock_movement (model_size_id, movement_type, quantity, balance, comment)
+SELECT model_size_id, 'stocktake', in_stock, in_stock, 'opening balance' FROM model_sizes WHERE in_stock <> 0;
@@ -0,0 +1,22 @@
+CREATE OR REPLACE FUNCTION refresh_product_search(target_product_id INT) RETURNS void AS $$
+BEGIN
+  INSERT INTO product_search (product_id, document, search_text, headline_text)
+  SELECT p.product_id,
+    setweight(to_tsvector('russian', coalesce(p.title, '')), 'A') ||
+    setweight(to_tsvector('russian', coalesce(b.title, '')), 'A') ||
+    setweight(to_tsvector('russian', coalesce(c.title, '')), 'B') ||
+    setweight(to_tsvector('russian', coalesce(p.description, '')), 'C'),
+    lower(concat_ws(' ', p.title, b.title, c.title)),
+    concat_ws(' ', p.title, p.description)
+  FROM product p
+  INNER JOIN brand b ON p.brand_id = b.brand_id
+  INNER JOIN category c ON p.category_id = c.category_id
+  WHERE p.product_id = target_product_id
+  ON CONFLICT (product_id) DO UPDATE SET document = EXCLUDED.document,
+    search_text = EXCLUDED.search_text, headline_text = EXCLUDED.headline_text;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT refresh_product_search(product_id) FROM product;
+
+DROP FUNCTION IF EXISTS html_escape(TEXT);
@@ -0,0 +1,27 @@
+-- ts_headline only adds the <mark> tags, the text around them is served as html and has to be escaped
+-- before it is indexed. The parser reads entities as single tokens, so highlighting is not affected.
+CREATE OR REPLACE FUNCTION html_escape(value TEXT) RETURNS TEXT AS $$
+  SELECT replace(replace(replace(replace(replace(value,
+    '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;');
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+CREATE OR REPLACE FUNCTION refresh_product_search(target_product_id INT) RETURNS void AS $$
+BEGIN
+  INSERT INTO product_search (product_id, document, search_text, headline_text)
+  SELECT p.product_id,
+    setweight(to_tsvector('russian', coalesce(p.title, '')), 'A') ||
+    setweight(to_tsvector('russian', coalesce(b.title, '')), 'A') ||
+    setweight(to_tsvector('russian', coalesce(c.title, '')), 'B') ||
+    setweight(to_tsvector('russian', coalesce(p.description, '')), 'C'),
+    lower(concat_ws(' ', p.title, b.title, c.title)),
+    html_escape(concat_ws(' ', p.title, p.description))
+  FROM product p
+  INNER JOIN brand b ON p.brand_id = b.brand_id
+  INNER JOIN category c ON p.category_id = c.category_id
+  WHERE p.product_id = target_product_id
+  ON CONFLICT (product_id) DO UPDATE SET document = EXCLUDED.document,
+    search_text = EXCLUDED.search_text, headline_text = EXCLUDED.headline_text;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT refresh_product_search(product_id) FROM product;
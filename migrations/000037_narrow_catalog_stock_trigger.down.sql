@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS catalog_model_stock_trigger ON model_sizes;
+DROP FUNCTION IF EXISTS catalog_model_on_stock();
+
+DROP TRIGGER IF EXISTS catalog_model_model_sizes_trigger ON model_sizes;
+CREATE TRIGGER catalog_model_model_sizes_trigger
+AFTER INSERT OR UPDATE OR DELETE ON model_sizes
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_model_child();
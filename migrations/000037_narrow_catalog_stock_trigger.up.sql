@@ -0,0 +1,30 @@
+-- A stock change touched every column of the projection row, now only the columns the row keeps for that size
+-- are rewritten: the size entry in sizes and the total in_stock. Other changes still rebuild the whole row.
+CREATE OR REPLACE FUNCTION catalog_model_on_stock() RETURNS trigger AS $$
+BEGIN
+  -- the total is summed from the entries rather than shifted by the change, so a rebuild of the row
+  -- by the other trigger in the same statement does not count the change twice
+  UPDATE catalog_model cm SET (sizes, in_stock) = (
+    SELECT jsonb_agg(e.size ORDER BY e.position), sum((e.size ->> 'in_stock')::int)::int
+    FROM (
+      SELECT s.position, CASE WHEN (s.size ->> 'size_model_id')::int = NEW.model_size_id
+        THEN jsonb_set(s.size, '{in_stock}', to_jsonb(NEW.in_stock)) ELSE s.size END AS size
+      FROM jsonb_array_elements(cm.sizes) WITH ORDINALITY AS s(size, position)
+    ) e
+  )
+  WHERE cm.product_model_id = NEW.product_model_id AND cm.sizes <> '[]';
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS catalog_model_model_sizes_trigger ON model_sizes;
+CREATE TRIGGER catalog_model_model_sizes_trigger
+AFTER INSERT OR UPDATE OF product_model_id, size_id, literal_size OR DELETE ON model_sizes
+FOR EACH ROW EXECUTE FUNCTION catalog_model_on_model_child();
+
+-- a row moved to another model is rebuilt by the trigger above
+DROP TRIGGER IF EXISTS catalog_model_stock_trigger ON model_sizes;
+CREATE TRIGGER catalog_model_stock_trigger
+AFTER UPDATE OF in_stock ON model_sizes
+FOR EACH ROW WHEN (OLD.in_stock IS DISTINCT FROM NEW.in_stock AND OLD.product_model_id = NEW.product_model_id)
+EXECUTE FUNCTION catalog_model_on_stock();
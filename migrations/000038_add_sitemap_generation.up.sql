@@ -0,0 +1,10 @@
+-- sitemap_generation keeps the last sitemap that was written, so its files are served right after a restart
+-- and an unchanged catalog is not written again. paths maps file names to their storage paths.
+CREATE TABLE IF NOT EXISTS sitemap_generation (
+  sitemap_generation_id INT PRIMARY KEY DEFAULT 1 CHECK (sitemap_generation_id = 1),
+  version TEXT NOT NULL,
+  urls INT NOT NULL,
+  files TEXT[] NOT NULL,
+  paths jsonb NOT NULL,
+  generated_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);